@@ -0,0 +1,126 @@
+// Command authadmin manages client applications of the auth service.
+//
+//	authadmin [-addr host:port] [-token admin-token] create <name>
+//	authadmin list
+//	authadmin rotate <app id>
+//	authadmin disable <app id>
+//
+// The admin token is read from AUTH_ADMIN_TOKEN, when -token is not set.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	authv1 "github.com/SmoothWay/gophkeeper/api/gen"
+	"github.com/SmoothWay/gophkeeper/pkg/tlsconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:44044", "address of the auth service")
+	token := flag.String("token", os.Getenv("AUTH_ADMIN_TOKEN"), "admin token of the auth service")
+	caFile := flag.String("ca", "./keys/ca-cert.pem", "CA certificate verifying the auth service, system roots if empty")
+	certFile := flag.String("cert", "", "client certificate, when the auth service requires mutual TLS")
+	keyFile := flag.String("key", "", "key of the client certificate")
+	flag.Parse()
+
+	tlsConfig, err := tlsconfig.Client(*caFile, *certFile, *keyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "authadmin:", err)
+		os.Exit(1)
+	}
+	if err := run(*addr, credentials.NewTLS(tlsConfig), *token, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "authadmin:", err)
+		os.Exit(1)
+	}
+}
+
+func run(addr string, creds credentials.TransportCredentials, token string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("command is required: create, list, rotate or disable")
+	}
+	if token == "" {
+		return errors.New("admin token is required")
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := authv1.NewAppAdminClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+
+	switch args[0] {
+	case "create":
+		if len(args) != 2 {
+			return errors.New("usage: create <name>")
+		}
+		res, err := client.CreateApp(ctx, &authv1.CreateAppRequest{Name: args[1]})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("app_id: %d\napp_secret: %s\n", res.AppId, res.Secret)
+	case "list":
+		res, err := client.ListApps(ctx, &authv1.ListAppsRequest{})
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tSTATUS")
+		for _, app := range res.Apps {
+			state := "enabled"
+			if app.Disabled {
+				state = "disabled"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", app.Id, app.Name, state)
+		}
+		return w.Flush()
+	case "rotate":
+		id, err := appID(args)
+		if err != nil {
+			return err
+		}
+		res, err := client.RotateAppSecret(ctx, &authv1.RotateAppSecretRequest{AppId: id})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("app_secret: %s\n", res.Secret)
+	case "disable":
+		id, err := appID(args)
+		if err != nil {
+			return err
+		}
+		if _, err := client.DisableApp(ctx, &authv1.DisableAppRequest{AppId: id}); err != nil {
+			return err
+		}
+		fmt.Printf("app %d disabled\n", id)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return nil
+}
+
+// appID parses id of the application from the second argument of the command.
+func appID(args []string) (int32, error) {
+	if len(args) != 2 {
+		return 0, fmt.Errorf("usage: %s <app id>", args[0])
+	}
+	id, err := strconv.ParseInt(args[1], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid app id %q", args[1])
+	}
+	return int32(id), nil
+}
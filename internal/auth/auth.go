@@ -1,16 +1,32 @@
 package auth
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/SmoothWay/gophkeeper/internal/auth/config"
 	grpcApp "github.com/SmoothWay/gophkeeper/internal/auth/grpc"
+	"github.com/SmoothWay/gophkeeper/internal/auth/mail"
 	"github.com/SmoothWay/gophkeeper/internal/auth/service"
 	"github.com/SmoothWay/gophkeeper/internal/auth/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/jwt"
+	"github.com/SmoothWay/gophkeeper/pkg/password"
 )
 
+// fakeKeyLen is length of the secret of fake SRP salts.
+const fakeKeyLen = 32
+
 type App struct {
-	grpcApp *grpcApp.App
+	grpcApp    *grpcApp.App
+	dispatcher *mail.Dispatcher
 }
 
 func New(log *slog.Logger, cfg *config.Config) (*App, error) {
@@ -29,22 +45,159 @@ func New(log *slog.Logger, cfg *config.Config) (*App, error) {
 		return nil, err
 	}
 
-	authService := service.New(log, userStorage, appStorage, cfg.TokenTTL)
+	tokenStorage, err := storage.NewToken(cfg.DatabaseURL, cfg.ConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	factorStorage, err := storage.NewSecondFactor(cfg.DatabaseURL, cfg.ConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	throttleStorage, err := storage.NewThrottle(cfg.DatabaseURL, cfg.ConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	emailStorage, err := storage.NewEmail(cfg.DatabaseURL, cfg.ConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionStorage, err := storage.NewSession(cfg.DatabaseURL, cfg.ConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	auditStorage, err := storage.NewAudit(cfg.DatabaseURL, cfg.ConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	sender, err := newSender(cfg.Mail)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := newSigner(log, cfg.SigningKeys)
+	if err != nil {
+		return nil, err
+	}
 
-	grpcApp, err := grpcApp.New(log, authService, cfg)
+	throttling := service.Throttling{
+		AccountAttempts: cfg.LoginThrottle.AccountAttempts,
+		AddressAttempts: cfg.LoginThrottle.AddressAttempts,
+		Lockout:         cfg.LoginThrottle.Lockout,
+		MaxLockout:      cfg.LoginThrottle.MaxLockout,
+	}
+	policy := password.Policy{
+		MinLength:  cfg.Password.MinLength,
+		MinClasses: cfg.Password.MinClasses,
+		MinEntropy: cfg.Password.MinEntropy,
+	}
+	var breached service.BreachedRanges
+	if cfg.Password.BreachedDir != "" {
+		dir, err := password.NewRangeDir(cfg.Password.BreachedDir)
+		if err != nil {
+			return nil, err
+		}
+		policy.Breached, breached = dir, dir
+	}
+	fakeKey, err := newFakeKey(log, cfg.FakeSaltKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	authService := service.New(log, userStorage, appStorage, tokenStorage, factorStorage, throttleStorage, emailStorage,
+		sessionStorage, auditStorage, signer, cfg.TokenTTL, cfg.RefreshTTL, throttling, policy, breached, fakeKey)
+
+	grpcApp, err := grpcApp.New(log, authService, authService, authService, cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	return &App{
-		grpcApp: grpcApp,
+		grpcApp:    grpcApp,
+		dispatcher: mail.NewDispatcher(log, emailStorage, sender, cfg.Mail.Interval),
 	}, nil
 }
 
+// newSender returns sender of mails configured by cfg.
+func newSender(cfg config.MailConfig) (mail.Sender, error) {
+	switch cfg.Sender {
+	case "smtp":
+		return mail.NewSMTP(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.From), nil
+	case "file":
+		return mail.NewFile(cfg.OutboxDir, cfg.From)
+	default:
+		return nil, fmt.Errorf("unknown mail sender %q", cfg.Sender)
+	}
+}
+
+// newSigner reads signing keys from the file, the file with the new active key is created on the first start.
+func newSigner(log *slog.Logger, cfg config.SigningKeys) (*jwt.Signer, error) {
+	data, err := os.ReadFile(cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := jwt.GenerateKey()
+		if err != nil {
+			return nil, fmt.Errorf("generate signing key: %w", err)
+		}
+		data = []byte(jwt.FormatKeys(map[string]ed25519.PrivateKey{cfg.Active: key}))
+
+		if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o700); err != nil {
+			return nil, fmt.Errorf("create signing keys directory: %w", err)
+		}
+		if err := os.WriteFile(cfg.Path, data, 0o600); err != nil {
+			return nil, fmt.Errorf("write signing keys: %w", err)
+		}
+		log.Info("signing key generated", slog.String("kid", cfg.Active), slog.String("path", cfg.Path))
+	} else if err != nil {
+		return nil, fmt.Errorf("read signing keys: %w", err)
+	}
+
+	keys, err := jwt.ParseKeys(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return jwt.NewSigner(cfg.Active, keys)
+}
+
+// newFakeKey reads secret of fake SRP salts from the file, the file with the new secret is created on the first start.
+func newFakeKey(log *slog.Logger, path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, fakeKeyLen)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generate fake salt key: %w", err)
+		}
+		data = []byte(hex.EncodeToString(key))
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, fmt.Errorf("create fake salt key directory: %w", err)
+		}
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, fmt.Errorf("write fake salt key: %w", err)
+		}
+		log.Info("fake salt key generated", slog.String("path", path))
+	} else if err != nil {
+		return nil, fmt.Errorf("read fake salt key: %w", err)
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) < fakeKeyLen {
+		return nil, fmt.Errorf("fake salt key %s is invalid, it has to be at least %d hex encoded bytes", path, fakeKeyLen)
+	}
+	return key, nil
+}
+
 func (app *App) MustRun() {
+	go app.dispatcher.Run(context.Background())
+
 	app.grpcApp.MustRun()
 }
 
 func (app *App) Stop() {
+	app.dispatcher.Stop()
 	app.grpcApp.Stop()
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"flag"
+	"log/slog"
 	"os"
 	"time"
 
@@ -10,11 +11,68 @@ import (
 
 type Config struct {
 	DatabaseURL    string        `yaml:"database_url" env-required:"true"`
-	TokenTTL       time.Duration `yaml:"token_ttl" env-default:"1h"`
+	TokenTTL       time.Duration `yaml:"token_ttl" env-default:"15m"`
+	RefreshTTL     time.Duration `yaml:"refresh_token_ttl" env-default:"720h"`
 	ConnectTimeout time.Duration `yaml:"connect_timeout" env-default:"2s"`
 	CertFile       string        `yaml:"cert_file" env-required:"true"`
 	KeyFile        string        `yaml:"key_file" env-required:"true"`
-	GRPC           GRPCConfig    `yaml:"grpc"`
+	// ClientCAFile enables mutual TLS, clients have to present certificate signed by this CA.
+	ClientCAFile string      `yaml:"client_ca_file"`
+	SigningKeys  SigningKeys `yaml:"signing_keys"`
+	// FakeSaltKeyFile keeps secret, which derives SRP salts of unknown emails, so the salts do not change
+	// after restarts and do not tell whether the account exists. The file is generated on the first start.
+	FakeSaltKeyFile string `yaml:"fake_salt_key_file" env-default:"./keys/fake_salt.key"`
+	// AdminToken authorizes calls of AppAdmin service, the service is disabled without it.
+	AdminToken string `yaml:"admin_token" env:"AUTH_ADMIN_TOKEN"`
+	// ServiceToken authorizes calls of Internal service by the keeper server, it is required,
+	// otherwise ended sessions and deleted accounts do not reach the keeper server.
+	ServiceToken  string         `yaml:"service_token" env:"AUTH_SERVICE_TOKEN" env-required:"true"`
+	LoginThrottle LoginThrottle  `yaml:"login_throttle"`
+	Password      PasswordPolicy `yaml:"password_policy"`
+	Mail          MailConfig     `yaml:"mail"`
+	GRPC          GRPCConfig     `yaml:"grpc"`
+}
+
+// SigningKeys configures Ed25519 keys, which sign access tokens.
+// To rotate keys add new key to the file and make it active,
+// remove retired key after tokens signed with it expire.
+type SigningKeys struct {
+	Path   string `yaml:"path" env-default:"./keys/jwt.keys"`
+	Active string `yaml:"active" env-default:"k1"`
+}
+
+// LoginThrottle configures lockout after failed logins, see service.Throttling.
+type LoginThrottle struct {
+	AccountAttempts int           `yaml:"account_attempts" env-default:"5"`
+	AddressAttempts int           `yaml:"address_attempts" env-default:"20"`
+	Lockout         time.Duration `yaml:"lockout" env-default:"30s"`
+	MaxLockout      time.Duration `yaml:"max_lockout" env-default:"1h"`
+}
+
+// PasswordPolicy configures requirements to new passwords, see password.Policy.
+// BreachedDir contains breached password hash ranges in HIBP range file format, empty value disables the check.
+type PasswordPolicy struct {
+	MinLength   int     `yaml:"min_length" env-default:"12"`
+	MinClasses  int     `yaml:"min_classes" env-default:"2"`
+	MinEntropy  float64 `yaml:"min_entropy" env-default:"40"`
+	BreachedDir string  `yaml:"breached_dir" env-default:""`
+}
+
+// MailConfig configures delivery of mails from the outbox.
+// Sender "smtp" sends mails through SMTP server, "file" writes them into OutboxDir for local testing.
+type MailConfig struct {
+	Sender    string        `yaml:"sender" env-default:"file"`
+	From      string        `yaml:"from" env-default:"gophkeeper@localhost"`
+	OutboxDir string        `yaml:"outbox_dir" env-default:"./outbox"`
+	Interval  time.Duration `yaml:"interval" env-default:"5s"`
+	SMTP      SMTPConfig    `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" env-default:"localhost"`
+	Port     int    `yaml:"port" env-default:"587"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password" env:"AUTH_SMTP_PASSWORD"`
 }
 
 type GRPCConfig struct {
@@ -22,6 +80,24 @@ type GRPCConfig struct {
 	Timeout time.Duration `yaml:"timeout"`
 }
 
+// LogValue hides tokens, the SMTP password and the database URL with credentials, when the configuration is logged.
+func (c Config) LogValue() slog.Value {
+	// plain does not implement slog.LogValuer, its fields are logged as is
+	type plain Config
+	c.DatabaseURL = redact(c.DatabaseURL)
+	c.AdminToken = redact(c.AdminToken)
+	c.ServiceToken = redact(c.ServiceToken)
+	c.Mail.SMTP.Password = redact(c.Mail.SMTP.Password)
+	return slog.AnyValue(plain(c))
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "REDACTED"
+}
+
 func MustLoad() *Config {
 	path := configPath()
 	if path == "" {
@@ -0,0 +1,113 @@
+package grpcapp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	authv1 "github.com/SmoothWay/gophkeeper/api/gen"
+	"github.com/SmoothWay/gophkeeper/internal/auth/service"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type AppAdmin interface {
+	CreateApp(ctx context.Context, name string) (models.App, string, error)
+	Apps(ctx context.Context) ([]models.App, error)
+	RotateAppSecret(ctx context.Context, appID int) (string, error)
+	DisableApp(ctx context.Context, appID int) error
+}
+
+// AdminServer serves AppAdmin service to the holder of the admin token.
+type AdminServer struct {
+	authv1.UnimplementedAppAdminServer
+	admin AppAdmin
+	token []byte
+}
+
+func RegisterAdmin(gRPC *grpc.Server, admin AppAdmin, token string) {
+	authv1.RegisterAppAdminServer(gRPC, &AdminServer{admin: admin, token: []byte(token)})
+}
+
+func (s *AdminServer) CreateApp(ctx context.Context, in *authv1.CreateAppRequest) (*authv1.CreateAppResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	app, secret, err := s.admin.CreateApp(ctx, in.GetName())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidData):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, service.ErrAppExists):
+			return nil, status.Error(codes.AlreadyExists, "application already exists")
+		default:
+			return nil, status.Error(codes.Internal, "failed to create application")
+		}
+	}
+	return &authv1.CreateAppResponse{AppId: int32(app.ID), Secret: secret}, nil
+}
+
+func (s *AdminServer) ListApps(ctx context.Context, _ *authv1.ListAppsRequest) (*authv1.ListAppsResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	apps, err := s.admin.Apps(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to list applications")
+	}
+	resp := &authv1.ListAppsResponse{Apps: make([]*authv1.App, 0, len(apps))}
+	for _, app := range apps {
+		resp.Apps = append(resp.Apps, &authv1.App{Id: int32(app.ID), Name: app.Name, Disabled: app.Disabled})
+	}
+	return resp, nil
+}
+
+func (s *AdminServer) RotateAppSecret(ctx context.Context, in *authv1.RotateAppSecretRequest) (*authv1.RotateAppSecretResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	secret, err := s.admin.RotateAppSecret(ctx, int(in.GetAppId()))
+	if err != nil {
+		if errors.Is(err, service.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, "application not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to rotate application secret")
+	}
+	return &authv1.RotateAppSecretResponse{Secret: secret}, nil
+}
+
+func (s *AdminServer) DisableApp(ctx context.Context, in *authv1.DisableAppRequest) (*authv1.DisableAppResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.admin.DisableApp(ctx, int(in.GetAppId())); err != nil {
+		if errors.Is(err, service.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, "application not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to disable application")
+	}
+	return &authv1.DisableAppResponse{}, nil
+}
+
+func (s *AdminServer) authorize(ctx context.Context) error {
+	if !bearer(ctx, s.token) {
+		return status.Error(codes.Unauthenticated, "invalid admin token")
+	}
+	return nil
+}
+
+// bearer checks "authorization: Bearer <token>" metadata of the call.
+func bearer(ctx context.Context, token []byte) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		got, ok := strings.CutPrefix(v, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(got), token) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -2,18 +2,63 @@ package grpcapp
 
 import (
 	"context"
+	"crypto/ed25519"
 	"errors"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"time"
 
 	authv1 "github.com/SmoothWay/gophkeeper/api/gen"
 	"github.com/SmoothWay/gophkeeper/internal/auth/service"
+	"github.com/SmoothWay/gophkeeper/pkg/jwt"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/SmoothWay/gophkeeper/pkg/password"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
+// RetryAfterHeader is metadata key with seconds to wait, when login is locked after failed attempts.
+const RetryAfterHeader = "retry-after"
+
 type Auth interface {
-	Login(ctx context.Context, email string, password string, appID int) (token string, err error)
-	Register(ctx context.Context, email string, password string) (userID int64, err error)
+	Login(ctx context.Context, email string, password string, appID int, appSecret string,
+		device models.Device, verifier models.PasswordVerifier) (models.Login, error)
+	BeginLogin(ctx context.Context, email string, appID int, appSecret string,
+		clientAddr string) (models.LoginChallenge, error)
+	FinishLogin(ctx context.Context, proof models.PasswordProof, device models.Device) (models.Login, error)
+	VerifyLogin(ctx context.Context, ticket string, code string, device models.Device) (models.Login, error)
+	EnrollTOTP(ctx context.Context, accessToken string, proof models.PasswordProof,
+		clientAddr string) (secret []byte, uri string, err error)
+	ConfirmTOTP(ctx context.Context, accessToken string, proof models.PasswordProof, code string,
+		clientAddr string) ([]string, error)
+	Refresh(ctx context.Context, refreshToken string, appID int, clientAddr string) (models.Tokens, error)
+	Logout(ctx context.Context, refreshToken string) error
+	PublicKeys() map[string]ed25519.PublicKey
+	Register(ctx context.Context, email string, password string, verifier models.PasswordVerifier,
+		clientAddr string) (userID int64, err error)
+	PasswordPolicy(hashPrefix string) (password.Policy, password.Range, error)
+	SetRecovery(ctx context.Context, accessToken string, proof models.PasswordProof, code string, verifier []byte,
+		clientAddr string) error
+	Recover(ctx context.Context, email string, proof []byte, password string, vaultKey []byte,
+		verifier models.PasswordVerifier, clientAddr string) error
+	ChangePassword(ctx context.Context, accessToken string, proof models.PasswordProof, code string, newPassword string,
+		vaultKey []byte, verifier models.PasswordVerifier, appID int, appSecret string, clientAddr string) error
+	VerifyEmail(ctx context.Context, token string) error
+	RequestPasswordReset(ctx context.Context, email string, clientAddr string) error
+	ResetPassword(ctx context.Context, token string, password string, verifier models.PasswordVerifier,
+		clientAddr string) error
+	Sessions(ctx context.Context, accessToken string) ([]models.Session, string, error)
+	RevokeSession(ctx context.Context, accessToken string, sessionID string) error
+	AuditEvents(ctx context.Context, accessToken string, filter models.AuditFilter,
+		pageToken string) ([]models.AuditEvent, string, error)
+	DeleteAccount(ctx context.Context, accessToken string, proof models.PasswordProof, code string,
+		clientAddr string) error
 	Close()
 }
 
@@ -27,9 +72,13 @@ func Register(gRPC *grpc.Server, auth Auth) {
 }
 
 func (s *Server) Register(ctx context.Context, in *authv1.RegisterRequest) (*authv1.RegisterResponse, error) {
-	uid, err := s.auth.Register(ctx, in.GetEmail(), in.GetPassword())
+	uid, err := s.auth.Register(ctx, in.GetEmail(), in.GetPassword(), passwordVerifier(in.GetVerifier()),
+		clientAddr(ctx))
 	if err != nil {
+		var weak *password.Error
 		switch {
+		case errors.As(err, &weak):
+			return nil, weakPasswordStatus(weak)
 		case errors.Is(err, service.ErrInvalidData):
 			return nil, status.Error(codes.InvalidArgument, err.Error())
 		case errors.Is(err, service.ErrUserExists):
@@ -41,15 +90,445 @@ func (s *Server) Register(ctx context.Context, in *authv1.RegisterRequest) (*aut
 	return &authv1.RegisterResponse{UserId: uid}, nil
 }
 
+func (s *Server) GetPasswordPolicy(_ context.Context, in *authv1.GetPasswordPolicyRequest) (*authv1.GetPasswordPolicyResponse, error) {
+	policy, breached, err := s.auth.PasswordPolicy(in.GetHashPrefix())
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidData) {
+			return nil, status.Error(codes.InvalidArgument, "invalid hash prefix")
+		}
+		return nil, status.Error(codes.Internal, "failed to get password policy")
+	}
+
+	res := &authv1.GetPasswordPolicyResponse{
+		MinLength:  int32(policy.MinLength),
+		MinClasses: int32(policy.MinClasses),
+		MinEntropy: policy.MinEntropy,
+		Breached:   make([]*authv1.BreachedPassword, 0, len(breached.Counts)),
+	}
+	for suffix, count := range breached.Counts {
+		res.Breached = append(res.Breached, &authv1.BreachedPassword{HashSuffix: suffix, Count: int32(count)})
+	}
+	sort.Slice(res.Breached, func(i, j int) bool { return res.Breached[i].HashSuffix < res.Breached[j].HashSuffix })
+
+	return res, nil
+}
+
 func (s *Server) Login(ctx context.Context, in *authv1.LoginRequest) (*authv1.LoginResponse, error) {
-	token, err := s.auth.Login(ctx, in.GetEmail(), in.GetPassword(), int(in.GetAppId()))
+	login, err := s.auth.Login(ctx, in.GetEmail(), in.GetPassword(), int(in.GetAppId()), in.GetAppSecret(),
+		models.Device{Name: in.GetDevice(), Address: clientAddr(ctx)}, passwordVerifier(in.GetVerifier()))
 	if err != nil {
-		if errors.Is(err, service.ErrInvalidCredentials) {
+		var locked *service.LockedError
+		switch {
+		case errors.As(err, &locked):
+			return nil, lockedStatus(ctx, locked)
+		case errors.Is(err, service.ErrInvalidApp):
+			return nil, status.Error(codes.PermissionDenied, "invalid application")
+		case errors.Is(err, service.ErrInvalidCredentials):
 			return nil, status.Error(codes.InvalidArgument, "invalid")
+		case errors.Is(err, service.ErrInvalidData):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, service.ErrEmailNotVerified):
+			return nil, status.Error(codes.FailedPrecondition, "email is not verified")
+		default:
+			return nil, status.Error(codes.Internal, "failed to login")
 		}
-		return nil, status.Error(codes.Internal, "failed to login")
+	}
+	return loginResponse(login), nil
+}
+
+func (s *Server) BeginLogin(ctx context.Context, in *authv1.BeginLoginRequest) (*authv1.BeginLoginResponse, error) {
+	challenge, err := s.auth.BeginLogin(ctx, in.GetEmail(), int(in.GetAppId()), in.GetAppSecret(), clientAddr(ctx))
+	if err != nil {
+		var locked *service.LockedError
+		switch {
+		case errors.As(err, &locked):
+			return nil, lockedStatus(ctx, locked)
+		case errors.Is(err, service.ErrInvalidApp):
+			return nil, status.Error(codes.PermissionDenied, "invalid application")
+		case errors.Is(err, service.ErrInvalidData):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, "failed to login")
+		}
+	}
+	return &authv1.BeginLoginResponse{
+		Handshake:    challenge.Handshake,
+		Salt:         challenge.Salt,
+		ServerPublic: challenge.ServerPublic,
+	}, nil
+}
+
+func (s *Server) FinishLogin(ctx context.Context, in *authv1.FinishLoginRequest) (*authv1.LoginResponse, error) {
+	proof := models.PasswordProof{
+		Handshake:    in.GetHandshake(),
+		ClientPublic: in.GetClientPublic(),
+		ClientProof:  in.GetClientProof(),
+	}
+	login, err := s.auth.FinishLogin(ctx, proof, models.Device{Name: in.GetDevice(), Address: clientAddr(ctx)})
+	if err != nil {
+		var locked *service.LockedError
+		switch {
+		case errors.As(err, &locked):
+			return nil, lockedStatus(ctx, locked)
+		case errors.Is(err, service.ErrInvalidApp):
+			return nil, status.Error(codes.PermissionDenied, "invalid application")
+		case errors.Is(err, service.ErrInvalidCredentials), errors.Is(err, service.ErrInvalidData):
+			return nil, status.Error(codes.InvalidArgument, "invalid")
+		case errors.Is(err, service.ErrEmailNotVerified):
+			return nil, status.Error(codes.FailedPrecondition, "email is not verified")
+		default:
+			return nil, status.Error(codes.Internal, "failed to login")
+		}
+	}
+	return loginResponse(login), nil
+}
+
+// lockedStatus sets header with seconds to wait before the next login attempt and returns status of locked login.
+func lockedStatus(ctx context.Context, locked *service.LockedError) error {
+	retryAfter := strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds())))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RetryAfterHeader, retryAfter))
+	return status.Error(codes.ResourceExhausted, "too many failed login attempts")
+}
+
+// weakPasswordStatus returns status of the rejected password,
+// every unsatisfied requirement of the password policy is a field violation of the "password" field.
+func weakPasswordStatus(weak *password.Error) error {
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(weak.Violations))
+	for _, v := range weak.Violations {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: "password", Description: v})
+	}
+	st, err := status.New(codes.InvalidArgument, weak.Error()).
+		WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, weak.Error())
+	}
+	return st.Err()
+}
+
+// passwordVerifier returns verifier from the request, it is empty, if the client did not send it.
+func passwordVerifier(in *authv1.PasswordVerifier) models.PasswordVerifier {
+	return models.PasswordVerifier{Salt: in.GetSalt(), Verifier: in.GetVerifier()}
+}
+
+// passwordProof returns proof of the password from the request, it is empty, if the client did not send it.
+func passwordProof(in *authv1.PasswordProof) models.PasswordProof {
+	return models.PasswordProof{
+		Handshake:    in.GetHandshake(),
+		ClientPublic: in.GetClientPublic(),
+		ClientProof:  in.GetClientProof(),
+	}
+}
+
+// clientAddr returns IP address of the client.
+func clientAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func (s *Server) VerifyLogin(ctx context.Context, in *authv1.VerifyLoginRequest) (*authv1.LoginResponse, error) {
+	login, err := s.auth.VerifyLogin(ctx, in.GetLoginTicket(), in.GetCode(),
+		models.Device{Name: in.GetDevice(), Address: clientAddr(ctx)})
+	if err != nil {
+		var locked *service.LockedError
+		switch {
+		case errors.As(err, &locked):
+			return nil, lockedStatus(ctx, locked)
+		case errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.Unauthenticated, "invalid code")
+		case errors.Is(err, service.ErrInvalidApp):
+			return nil, status.Error(codes.PermissionDenied, "invalid application")
+		default:
+			return nil, status.Error(codes.Internal, "failed to login")
+		}
+	}
+	return loginResponse(login), nil
+}
+
+func loginResponse(login models.Login) *authv1.LoginResponse {
+	if login.Ticket != "" {
+		return &authv1.LoginResponse{TotpRequired: true, LoginTicket: login.Ticket, ServerProof: login.ServerProof}
 	}
 	return &authv1.LoginResponse{
-		Token: token,
+		Token:        login.Tokens.AccessToken,
+		VaultKey:     login.VaultKey,
+		RefreshToken: login.Tokens.RefreshToken,
+		ExpiresAt:    login.Tokens.ExpiresAt.Unix(),
+		ServerProof:  login.ServerProof,
+	}
+}
+
+func (s *Server) EnrollTOTP(ctx context.Context, in *authv1.EnrollTOTPRequest) (*authv1.EnrollTOTPResponse, error) {
+	secret, uri, err := s.auth.EnrollTOTP(ctx, in.GetToken(), passwordProof(in.GetProof()), clientAddr(ctx))
+	if err != nil {
+		var locked *service.LockedError
+		switch {
+		case errors.As(err, &locked):
+			return nil, lockedStatus(ctx, locked)
+		case errors.Is(err, service.ErrInvalidData), errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "invalid")
+		case errors.Is(err, service.ErrTOTPEnabled):
+			return nil, status.Error(codes.FailedPrecondition, "two-factor authentication already enabled")
+		default:
+			return nil, status.Error(codes.Internal, "failed to enroll two-factor authentication")
+		}
+	}
+	return &authv1.EnrollTOTPResponse{Secret: secret, Uri: uri}, nil
+}
+
+func (s *Server) ConfirmTOTP(ctx context.Context, in *authv1.ConfirmTOTPRequest) (*authv1.ConfirmTOTPResponse, error) {
+	backupCodes, err := s.auth.ConfirmTOTP(ctx, in.GetToken(), passwordProof(in.GetProof()), in.GetCode(),
+		clientAddr(ctx))
+	if err != nil {
+		var locked *service.LockedError
+		switch {
+		case errors.As(err, &locked):
+			return nil, lockedStatus(ctx, locked)
+		case errors.Is(err, service.ErrInvalidData), errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "invalid")
+		case errors.Is(err, service.ErrTOTPEnabled):
+			return nil, status.Error(codes.FailedPrecondition, "two-factor authentication already enabled")
+		default:
+			return nil, status.Error(codes.Internal, "failed to enable two-factor authentication")
+		}
+	}
+	return &authv1.ConfirmTOTPResponse{BackupCodes: backupCodes}, nil
+}
+
+func (s *Server) Refresh(ctx context.Context, in *authv1.RefreshRequest) (*authv1.RefreshResponse, error) {
+	tokens, err := s.auth.Refresh(ctx, in.GetRefreshToken(), int(in.GetAppId()), clientAddr(ctx))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+		case errors.Is(err, service.ErrInvalidApp):
+			return nil, status.Error(codes.PermissionDenied, "invalid application")
+		default:
+			return nil, status.Error(codes.Internal, "failed to refresh token")
+		}
+	}
+	return &authv1.RefreshResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt.Unix(),
 	}, nil
 }
+
+func (s *Server) Logout(ctx context.Context, in *authv1.LogoutRequest) (*authv1.LogoutResponse, error) {
+	err := s.auth.Logout(ctx, in.GetRefreshToken())
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+		}
+		return nil, status.Error(codes.Internal, "failed to logout")
+	}
+	return &authv1.LogoutResponse{}, nil
+}
+
+func (s *Server) SetRecovery(ctx context.Context, in *authv1.SetRecoveryRequest) (*authv1.SetRecoveryResponse, error) {
+	err := s.auth.SetRecovery(ctx, in.GetToken(), passwordProof(in.GetProof()), in.GetCode(), in.GetVerifier(),
+		clientAddr(ctx))
+	if err != nil {
+		var locked *service.LockedError
+		switch {
+		case errors.As(err, &locked):
+			return nil, lockedStatus(ctx, locked)
+		case errors.Is(err, service.ErrInvalidData), errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "invalid")
+		default:
+			return nil, status.Error(codes.Internal, "failed to save recovery kit")
+		}
+	}
+	return &authv1.SetRecoveryResponse{}, nil
+}
+
+func (s *Server) Recover(ctx context.Context, in *authv1.RecoverRequest) (*authv1.RecoverResponse, error) {
+	err := s.auth.Recover(ctx, in.GetEmail(), in.GetProof(), in.GetPassword(), in.GetVaultKey(),
+		passwordVerifier(in.GetVerifier()), clientAddr(ctx))
+	if err != nil {
+		var weak *password.Error
+		switch {
+		case errors.As(err, &weak):
+			return nil, weakPasswordStatus(weak)
+		case errors.Is(err, service.ErrInvalidData), errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "invalid")
+		default:
+			return nil, status.Error(codes.Internal, "failed to recover account")
+		}
+	}
+	return &authv1.RecoverResponse{}, nil
+}
+
+func (s *Server) ChangePassword(ctx context.Context, in *authv1.ChangePasswordRequest) (*authv1.ChangePasswordResponse, error) {
+	err := s.auth.ChangePassword(ctx, in.GetToken(), passwordProof(in.GetProof()), in.GetCode(), in.GetNewPassword(),
+		in.GetVaultKey(), passwordVerifier(in.GetVerifier()), int(in.GetAppId()), in.GetAppSecret(), clientAddr(ctx))
+	if err != nil {
+		var weak *password.Error
+		var locked *service.LockedError
+		switch {
+		case errors.As(err, &weak):
+			return nil, weakPasswordStatus(weak)
+		case errors.As(err, &locked):
+			return nil, lockedStatus(ctx, locked)
+		case errors.Is(err, service.ErrInvalidData), errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "invalid")
+		case errors.Is(err, service.ErrInvalidApp):
+			return nil, status.Error(codes.PermissionDenied, "invalid application")
+		default:
+			return nil, status.Error(codes.Internal, "failed to change password")
+		}
+	}
+	return &authv1.ChangePasswordResponse{}, nil
+}
+
+func (s *Server) VerifyEmail(ctx context.Context, in *authv1.VerifyEmailRequest) (*authv1.VerifyEmailResponse, error) {
+	if err := s.auth.VerifyEmail(ctx, in.GetToken()); err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			return nil, status.Error(codes.InvalidArgument, "invalid or expired token")
+		}
+		return nil, status.Error(codes.Internal, "failed to verify email")
+	}
+	return &authv1.VerifyEmailResponse{}, nil
+}
+
+func (s *Server) RequestPasswordReset(ctx context.Context, in *authv1.RequestPasswordResetRequest) (*authv1.RequestPasswordResetResponse, error) {
+	if err := s.auth.RequestPasswordReset(ctx, in.GetEmail(), clientAddr(ctx)); err != nil {
+		var locked *service.LockedError
+		switch {
+		case errors.As(err, &locked):
+			return nil, lockedStatus(ctx, locked)
+		case errors.Is(err, service.ErrInvalidData):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, "failed to request password reset")
+		}
+	}
+	return &authv1.RequestPasswordResetResponse{}, nil
+}
+
+func (s *Server) ResetPassword(ctx context.Context, in *authv1.ResetPasswordRequest) (*authv1.ResetPasswordResponse, error) {
+	err := s.auth.ResetPassword(ctx, in.GetToken(), in.GetPassword(), passwordVerifier(in.GetVerifier()),
+		clientAddr(ctx))
+	if err != nil {
+		var weak *password.Error
+		switch {
+		case errors.As(err, &weak):
+			return nil, weakPasswordStatus(weak)
+		case errors.Is(err, service.ErrInvalidData):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "invalid or expired token")
+		default:
+			return nil, status.Error(codes.Internal, "failed to reset password")
+		}
+	}
+	return &authv1.ResetPasswordResponse{}, nil
+}
+
+func (s *Server) ListSessions(ctx context.Context, in *authv1.ListSessionsRequest) (*authv1.ListSessionsResponse, error) {
+	sessions, current, err := s.auth.Sessions(ctx, in.GetToken())
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return nil, status.Error(codes.Internal, "failed to list sessions")
+	}
+	resp := &authv1.ListSessionsResponse{Sessions: make([]*authv1.Session, 0, len(sessions))}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, &authv1.Session{
+			Id:         session.ID,
+			Device:     session.Device,
+			Address:    session.Address,
+			CreatedAt:  session.CreatedAt.Unix(),
+			LastSeenAt: session.LastSeenAt.Unix(),
+			Current:    session.ID == current,
+		})
+	}
+	return resp, nil
+}
+
+func (s *Server) RevokeSession(ctx context.Context, in *authv1.RevokeSessionRequest) (*authv1.RevokeSessionResponse, error) {
+	if err := s.auth.RevokeSession(ctx, in.GetToken(), in.GetSessionId()); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		case errors.Is(err, service.ErrSessionNotFound):
+			return nil, status.Error(codes.NotFound, "session not found")
+		default:
+			return nil, status.Error(codes.Internal, "failed to revoke session")
+		}
+	}
+	return &authv1.RevokeSessionResponse{}, nil
+}
+
+func (s *Server) ListAuditEvents(ctx context.Context, in *authv1.ListAuditEventsRequest) (*authv1.ListAuditEventsResponse, error) {
+	filter := models.AuditFilter{
+		Types:   in.GetTypes(),
+		Outcome: in.GetOutcome(),
+		Limit:   int(in.GetPageSize()),
+	}
+	if in.GetSince() > 0 {
+		filter.Since = time.Unix(in.GetSince(), 0)
+	}
+	if in.GetUntil() > 0 {
+		filter.Until = time.Unix(in.GetUntil(), 0)
+	}
+
+	events, next, err := s.auth.AuditEvents(ctx, in.GetToken(), filter, in.GetPageToken())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		case errors.Is(err, service.ErrInvalidData):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, "failed to list audit events")
+		}
+	}
+	resp := &authv1.ListAuditEventsResponse{Events: make([]*authv1.AuditEvent, 0, len(events)), NextPageToken: next}
+	for _, event := range events {
+		resp.Events = append(resp.Events, &authv1.AuditEvent{
+			Id:        event.ID,
+			Type:      event.Type,
+			AppId:     int32(event.AppID),
+			Address:   event.Address,
+			Outcome:   event.Outcome,
+			CreatedAt: event.CreatedAt.Unix(),
+		})
+	}
+	return resp, nil
+}
+
+func (s *Server) DeleteAccount(ctx context.Context, in *authv1.DeleteAccountRequest) (*authv1.DeleteAccountResponse, error) {
+	err := s.auth.DeleteAccount(ctx, in.GetToken(), passwordProof(in.GetProof()), in.GetCode(), clientAddr(ctx))
+	if err != nil {
+		var locked *service.LockedError
+		switch {
+		case errors.As(err, &locked):
+			return nil, lockedStatus(ctx, locked)
+		case errors.Is(err, service.ErrInvalidCredentials):
+			return nil, status.Error(codes.InvalidArgument, "invalid")
+		default:
+			return nil, status.Error(codes.Internal, "failed to delete account")
+		}
+	}
+	return &authv1.DeleteAccountResponse{}, nil
+}
+
+func (s *Server) GetPublicKeys(_ context.Context, _ *authv1.GetPublicKeysRequest) (*authv1.GetPublicKeysResponse, error) {
+	keys := s.auth.PublicKeys()
+
+	res := &authv1.GetPublicKeysResponse{Keys: make([]*authv1.PublicKey, 0, len(keys))}
+	for kid, key := range keys {
+		res.Keys = append(res.Keys, &authv1.PublicKey{Kid: kid, Alg: jwt.Alg, Key: key})
+	}
+	sort.Slice(res.Keys, func(i, j int) bool { return res.Keys[i].Kid < res.Keys[j].Kid })
+
+	return res, nil
+}
@@ -0,0 +1,77 @@
+package grpcapp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	authv1 "github.com/SmoothWay/gophkeeper/api/gen"
+	"github.com/SmoothWay/gophkeeper/internal/auth/service"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/SmoothWay/gophkeeper/pkg/password"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// weakAuth rejects registration with the password policy error.
+type weakAuth struct {
+	fakeAuth
+}
+
+func (weakAuth) Register(context.Context, string, string, models.PasswordVerifier, string) (int64, error) {
+	weak := &password.Error{Violations: []string{"at least 12 characters", "found in breached passwords"}}
+	return 0, fmt.Errorf("register: %w, %w", weak, service.ErrInvalidData)
+}
+
+func TestRegisterWeakPassword(t *testing.T) {
+	s := &Server{auth: weakAuth{}}
+
+	_, err := s.Register(context.Background(), &authv1.RegisterRequest{Email: "name@example.com", Password: "short"})
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+	details, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+	var descriptions []string
+	for _, v := range details.GetFieldViolations() {
+		assert.Equal(t, "password", v.GetField())
+		descriptions = append(descriptions, v.GetDescription())
+	}
+	assert.Equal(t, []string{"at least 12 characters", "found in breached passwords"}, descriptions)
+}
+
+// policyAuth returns the password policy and breached passwords of the range "ABCDE".
+type policyAuth struct {
+	fakeAuth
+}
+
+func (policyAuth) PasswordPolicy(hashPrefix string) (password.Policy, password.Range, error) {
+	if hashPrefix != "ABCDE" {
+		return password.Policy{}, password.Range{}, fmt.Errorf("policy: %w, %w", password.ErrInvalidPrefix,
+			service.ErrInvalidData)
+	}
+	policy := password.Policy{MinLength: 12, MinClasses: 2, MinEntropy: 40}
+	breached := password.Range{Prefix: hashPrefix, Counts: map[string]int{"FFF": 1, "000": 42}}
+	return policy, breached, nil
+}
+
+func TestGetPasswordPolicy(t *testing.T) {
+	s := &Server{auth: policyAuth{}}
+
+	res, err := s.GetPasswordPolicy(context.Background(), &authv1.GetPasswordPolicyRequest{HashPrefix: "ABCDE"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(12), res.GetMinLength())
+	assert.Equal(t, int32(2), res.GetMinClasses())
+	assert.Equal(t, 40.0, res.GetMinEntropy())
+	require.Len(t, res.GetBreached(), 2)
+	assert.Equal(t, "000", res.GetBreached()[0].GetHashSuffix())
+	assert.Equal(t, int32(42), res.GetBreached()[0].GetCount())
+	assert.Equal(t, "FFF", res.GetBreached()[1].GetHashSuffix())
+
+	_, err = s.GetPasswordPolicy(context.Background(), &authv1.GetPasswordPolicyRequest{HashPrefix: "password"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,67 @@
+package grpcapp
+
+import (
+	"context"
+	"time"
+
+	authv1 "github.com/SmoothWay/gophkeeper/api/gen"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Internal is the part of the auth service used by the keeper server.
+type Internal interface {
+	RevokedSessions(ctx context.Context, after int64, since time.Time) ([]string, int64, error)
+	DeletedAccounts(ctx context.Context, after int64, since time.Time) ([]models.DeletedAccount, int64, error)
+}
+
+// InternalServer serves Internal service to the holder of the service token.
+type InternalServer struct {
+	authv1.UnimplementedInternalServer
+	internal Internal
+	token    []byte
+}
+
+func RegisterInternal(gRPC *grpc.Server, internal Internal, token string) {
+	authv1.RegisterInternalServer(gRPC, &InternalServer{internal: internal, token: []byte(token)})
+}
+
+func (s *InternalServer) RevokedSessions(ctx context.Context, in *authv1.RevokedSessionsRequest) (*authv1.RevokedSessionsResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	ids, until, err := s.internal.RevokedSessions(ctx, in.GetAfter(), time.UnixMilli(in.GetSince()))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to get revoked sessions")
+	}
+	return &authv1.RevokedSessionsResponse{SessionIds: ids, Until: until}, nil
+}
+
+func (s *InternalServer) DeletedAccounts(ctx context.Context, in *authv1.DeletedAccountsRequest) (*authv1.DeletedAccountsResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	accounts, until, err := s.internal.DeletedAccounts(ctx, in.GetAfter(), time.UnixMilli(in.GetSince()))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to get deleted accounts")
+	}
+	resp := &authv1.DeletedAccountsResponse{Accounts: make([]*authv1.DeletedAccount, 0, len(accounts)),
+		Until: until}
+	for _, account := range accounts {
+		resp.Accounts = append(resp.Accounts, &authv1.DeletedAccount{
+			UserId:    account.UserID,
+			DeletedAt: account.DeletedAt.UnixMilli(),
+			Seq:       account.Seq,
+		})
+	}
+	return resp, nil
+}
+
+func (s *InternalServer) authorize(ctx context.Context) error {
+	if !bearer(ctx, s.token) {
+		return status.Error(codes.Unauthenticated, "invalid service token")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package grpcapp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	authv1 "github.com/SmoothWay/gophkeeper/api/gen"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeInternal returns one ended session and one deleted account.
+type fakeInternal struct{}
+
+func (fakeInternal) RevokedSessions(context.Context, int64, time.Time) ([]string, int64, error) {
+	return []string{"session"}, 1000, nil
+}
+
+func (fakeInternal) DeletedAccounts(context.Context, int64, time.Time) ([]models.DeletedAccount, int64, error) {
+	return []models.DeletedAccount{{UserID: 10, DeletedAt: time.UnixMilli(500), Seq: 900}}, 1000, nil
+}
+
+func TestInternalToken(t *testing.T) {
+	s := &InternalServer{internal: fakeInternal{}, token: []byte("service-token")}
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+
+	for name, ctx := range map[string]context.Context{
+		"no token":    context.Background(),
+		"wrong token": withToken("admin-token"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.RevokedSessions(ctx, &authv1.RevokedSessionsRequest{})
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+			_, err = s.DeletedAccounts(ctx, &authv1.DeletedAccountsRequest{})
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		})
+	}
+
+	res, err := s.RevokedSessions(withToken("service-token"), &authv1.RevokedSessionsRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"session"}, res.GetSessionIds())
+	deleted, err := s.DeletedAccounts(withToken("service-token"), &authv1.DeletedAccountsRequest{})
+	require.NoError(t, err)
+	require.Len(t, deleted.GetAccounts(), 1)
+	assert.Equal(t, int64(10), deleted.GetAccounts()[0].GetUserId())
+	assert.Equal(t, int64(900), deleted.GetAccounts()[0].GetSeq())
+	assert.Equal(t, int64(1000), deleted.GetUntil())
+}
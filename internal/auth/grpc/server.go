@@ -7,9 +7,10 @@ import (
 
 	"github.com/SmoothWay/gophkeeper/internal/auth/config"
 	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/tlsconfig"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/credentials"
 )
 
 type App struct {
@@ -19,13 +20,32 @@ type App struct {
 	port       int
 }
 
-func New(log *slog.Logger, authService Auth, cfg *config.Config) (*App, error) {
+// New returns gRPC server of the auth service, it serves TLS with the configured certificate.
+// Clients have to present certificate signed by the client CA, when it is configured.
+// AppAdmin service is served only when admin token is configured,
+// Internal service is served only when service token is configured.
+func New(log *slog.Logger, authService Auth, admin AppAdmin, internal Internal, cfg *config.Config) (*App, error) {
+	const op = "auth.grpcapp.New"
+
+	tlsConfig, err := tlsconfig.Server(cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 	gRPCServer := grpc.NewServer(
 		grpc.UnaryInterceptor(logging.UnaryServerInterceptor(&rpcLogger{log: log})),
-		grpc.Creds(insecure.NewCredentials()),
+		grpc.Creds(credentials.NewTLS(tlsConfig)),
 	)
+	if cfg.ClientCAFile == "" {
+		log.Warn("client CA is not configured, client certificates are not required")
+	}
 
 	Register(gRPCServer, authService)
+	if cfg.AdminToken != "" {
+		RegisterAdmin(gRPCServer, admin, cfg.AdminToken)
+	} else {
+		log.Warn("admin token is not configured, application management is disabled")
+	}
+	RegisterInternal(gRPCServer, internal, cfg.ServiceToken)
 
 	return &App{
 		log:        log,
@@ -0,0 +1,173 @@
+package grpcapp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	authv1 "github.com/SmoothWay/gophkeeper/api/gen"
+	"github.com/SmoothWay/gophkeeper/internal/auth/config"
+	"github.com/SmoothWay/gophkeeper/pkg/tlsconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// fakeAuth serves only public keys, other methods are not called by the tests.
+type fakeAuth struct {
+	Auth
+}
+
+func (fakeAuth) PublicKeys() map[string]ed25519.PublicKey {
+	return map[string]ed25519.PublicKey{"k1": make(ed25519.PublicKey, ed25519.PublicKeySize)}
+}
+
+func (fakeAuth) Close() {}
+
+// testCA issues certificates for the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newCA(t *testing.T, dir string, name string) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	file := filepath.Join(dir, name+".pem")
+	writePEM(t, file, "CERTIFICATE", der)
+	return &testCA{cert: cert, key: key, file: file}
+}
+
+// issue writes certificate signed by the CA and its key, it returns paths of the files.
+func (ca *testCA) issue(t *testing.T, dir string, name string, usage x509.ExtKeyUsage) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(dir, name+"-cert.pem"), filepath.Join(dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, file string, blockType string, der []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+}
+
+// serve starts the auth server with cfg, it returns address of the server.
+func serve(t *testing.T, cfg *config.Config) string {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	app, err := New(log, fakeAuth{}, nil, nil, cfg)
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = app.gRPCServer.Serve(listener) }()
+	t.Cleanup(app.gRPCServer.Stop)
+
+	return listener.Addr().String()
+}
+
+// publicKeys calls the server at addr with client TLS configuration built from the files.
+func publicKeys(t *testing.T, addr string, caFile, certFile, keyFile string) error {
+	t.Helper()
+
+	tlsConfig, err := tlsconfig.Client(caFile, certFile, keyFile)
+	require.NoError(t, err)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = authv1.NewAuthClient(conn).GetPublicKeys(ctx, &authv1.GetPublicKeysRequest{})
+	return err
+}
+
+func TestServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t, dir, "ca")
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	otherCA := newCA(t, dir, "other-ca")
+
+	addr := serve(t, &config.Config{CertFile: certFile, KeyFile: keyFile})
+
+	assert.NoError(t, publicKeys(t, addr, ca.file, "", ""))
+	assert.Error(t, publicKeys(t, addr, otherCA.file, "", ""), "server is verified against the CA")
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t, dir, "ca")
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	otherCA := newCA(t, dir, "other-ca")
+	otherCert, otherKey := otherCA.issue(t, dir, "other-client", x509.ExtKeyUsageClientAuth)
+
+	addr := serve(t, &config.Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: ca.file})
+
+	assert.NoError(t, publicKeys(t, addr, ca.file, clientCert, clientKey))
+	assert.Error(t, publicKeys(t, addr, ca.file, "", ""), "client certificate is required")
+	assert.Error(t, publicKeys(t, addr, ca.file, otherCert, otherKey), "client certificate is verified")
+}
+
+func TestNewInvalidCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t, dir, "ca")
+	certFile, keyFile := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := New(log, fakeAuth{}, nil, nil, &config.Config{CertFile: filepath.Join(dir, "missing.pem"), KeyFile: keyFile})
+	assert.Error(t, err)
+
+	_, err = New(log, fakeAuth{}, nil, nil, &config.Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile})
+	assert.ErrorIs(t, err, tlsconfig.ErrNoCertificates)
+}
@@ -0,0 +1,104 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+const (
+	batchSize = 20
+	// maxAttempts limits delivery attempts of the mail, mail to invalid address is not retried forever.
+	maxAttempts = 5
+)
+
+// Outbox keeps mails waiting for delivery.
+type Outbox interface {
+	PendingMail(ctx context.Context, limit int, maxAttempts int) ([]models.Mail, error)
+	MarkSent(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, reason string) error
+}
+
+// Dispatcher sends mails from the outbox.
+type Dispatcher struct {
+	log      *slog.Logger
+	outbox   Outbox
+	sender   Sender
+	interval time.Duration
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewDispatcher(log *slog.Logger, outbox Outbox, sender Sender, interval time.Duration) *Dispatcher {
+	return &Dispatcher{
+		log:      log,
+		outbox:   outbox,
+		sender:   sender,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// Run sends pending mails every interval until ctx is done or Stop is called.
+func (d *Dispatcher) Run(ctx context.Context) {
+	const op = "auth.mail.Run"
+	log := d.log.With(slog.String("op", op))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-d.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Dispatch(ctx); err != nil && ctx.Err() == nil {
+			log.Error("failed to dispatch mails", logger.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops Run, mails left in the outbox are sent after restart.
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() { close(d.done) })
+}
+
+// Dispatch sends batch of pending mails. Failed mails stay in the outbox until they run out of attempts.
+func (d *Dispatcher) Dispatch(ctx context.Context) error {
+	const op = "auth.mail.Dispatch"
+
+	mails, err := d.outbox.PendingMail(ctx, batchSize, maxAttempts)
+	if err != nil {
+		return err
+	}
+
+	for _, mail := range mails {
+		if err := d.sender.Send(ctx, mail); err != nil {
+			d.log.Warn("failed to send mail", slog.String("op", op), slog.Int64("mail_id", mail.ID), logger.Err(err))
+			if err := d.outbox.MarkFailed(ctx, mail.ID, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.outbox.MarkSent(ctx, mail.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
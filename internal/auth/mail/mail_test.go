@@ -0,0 +1,91 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// memOutbox is in-memory Outbox.
+type memOutbox struct {
+	mails    []models.Mail
+	sent     map[int64]bool
+	attempts map[int64]int
+}
+
+func (m *memOutbox) PendingMail(_ context.Context, limit int, maxAttempts int) ([]models.Mail, error) {
+	var pending []models.Mail
+	for _, mail := range m.mails {
+		if !m.sent[mail.ID] && m.attempts[mail.ID] < maxAttempts && len(pending) < limit {
+			pending = append(pending, mail)
+		}
+	}
+	return pending, nil
+}
+
+func (m *memOutbox) MarkSent(_ context.Context, id int64) error {
+	m.sent[id] = true
+	m.attempts[id]++
+	return nil
+}
+
+func (m *memOutbox) MarkFailed(_ context.Context, id int64, _ string) error {
+	m.attempts[id]++
+	return nil
+}
+
+// failingSender fails to send mails to the recipient.
+type failingSender struct {
+	Sender
+	recipient string
+}
+
+func (f failingSender) Send(ctx context.Context, mail models.Mail) error {
+	if mail.To == f.recipient {
+		return errors.New("mailbox unavailable")
+	}
+	return f.Sender.Send(ctx, mail)
+}
+
+func TestDispatch(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	file, err := NewFile(dir, "gophkeeper@localhost")
+	require.NoError(t, err)
+
+	outbox := &memOutbox{
+		mails: []models.Mail{
+			{ID: 1, To: "name@example.com", Subject: "Confirm your email", Body: "code"},
+			{ID: 2, To: "invalid@example.com", Subject: "Confirm your email", Body: "code"},
+		},
+		sent:     make(map[int64]bool),
+		attempts: make(map[int64]int),
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dispatcher := NewDispatcher(log, outbox, failingSender{Sender: file, recipient: "invalid@example.com"}, time.Second)
+
+	require.NoError(t, dispatcher.Dispatch(ctx))
+	data, err := os.ReadFile(filepath.Join(dir, "1.eml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "To: name@example.com\r\n")
+	assert.Contains(t, string(data), "\r\n\r\ncode")
+	assert.True(t, outbox.sent[1])
+	assert.NoFileExists(t, filepath.Join(dir, "2.eml"))
+
+	// failed mail is retried until it runs out of attempts
+	for i := 0; i < maxAttempts+2; i++ {
+		require.NoError(t, dispatcher.Dispatch(ctx))
+	}
+	assert.Equal(t, 1, outbox.attempts[1], "sent mail is not sent again")
+	assert.Equal(t, maxAttempts, outbox.attempts[2])
+}
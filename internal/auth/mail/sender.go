@@ -0,0 +1,80 @@
+// Package mail delivers mails of the auth service from the outbox.
+//
+// Mails are put into the outbox in the same transaction as the tokens they carry,
+// Dispatcher sends them with Sender and retries failed deliveries.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// Sender delivers mail.
+type Sender interface {
+	Send(ctx context.Context, mail models.Mail) error
+}
+
+// SMTP sends mails through SMTP server.
+type SMTP struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP returns SMTP sender, the server is authenticated with PLAIN auth when username is set.
+func NewSMTP(host string, port int, username string, password string, from string) *SMTP {
+	s := &SMTP{
+		addr: host + ":" + strconv.Itoa(port),
+		from: from,
+	}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+func (s *SMTP) Send(_ context.Context, mail models.Mail) error {
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{mail.To}, format(s.from, mail))
+}
+
+// File writes mails into the directory instead of sending them, it is used for local testing.
+type File struct {
+	dir  string
+	from string
+}
+
+func NewFile(dir string, from string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create outbox directory: %w", err)
+	}
+	return &File{dir: dir, from: from}, nil
+}
+
+// Send writes mail into file named by the mail id, so retried mail overwrites the same file.
+func (f *File) Send(_ context.Context, mail models.Mail) error {
+	path := filepath.Join(f.dir, fmt.Sprintf("%d.eml", mail.ID))
+	return os.WriteFile(path, format(f.from, mail), 0o600)
+}
+
+// format returns mail in the message format of RFC 5322.
+func format(from string, mail models.Mail) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", mail.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(mail.Body)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
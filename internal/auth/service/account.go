@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/internal/auth/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// DeleteAccount deletes account of the user, who owns the access token, after the user proves the password
+// and enters the second factor code, when two-factor authentication is enabled.
+// Sessions, tokens and second factors of the user are deleted. The keeper server deletes vault data of the user
+// and closes connections of the user, when it polls DeletedAccounts, so the deletion is finished,
+// though the keeper server is down at the moment.
+// Failed attempts are counted as failed logins, it returns LockedError, while login is locked.
+// It returns ErrInvalidCredentials, if the token is invalid, or the proof or the code do not match.
+func (a *Auth) DeleteAccount(ctx context.Context, accessToken string, proof models.PasswordProof, code string,
+	clientAddr string) error {
+	const op = "auth.DeleteAccount"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("client_addr", clientAddr),
+	)
+
+	user, err := a.reauthenticate(ctx, accessToken, proof, code, clientAddr, models.AuditAccountDeleted)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log = log.With(slog.Int64("user_id", user.ID))
+
+	// time of the deletion is sent to the keeper server in milliseconds
+	if err := a.userProvider.DeleteUser(ctx, user.ID, a.now().Truncate(time.Millisecond)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("account deleted")
+	a.audit(ctx, models.AuditEvent{Type: models.AuditAccountDeleted, UserID: user.ID, Email: user.Email,
+		Address: clientAddr, Outcome: models.OutcomeSuccess})
+	return nil
+}
+
+// reauthenticate returns the user, who owns the access token, after the user proves the password again
+// and enters the second factor code, when two-factor authentication is enabled.
+// The proof is computed for the handshake, which the client starts with BeginLogin for email of the user.
+// Failed attempts are counted as failed logins and recorded in the audit log as event,
+// it returns LockedError, while login is locked.
+// It returns ErrInvalidCredentials, if the token or the handshake is invalid, or the proof or the code do not match.
+func (a *Auth) reauthenticate(ctx context.Context, accessToken string, proof models.PasswordProof, code string,
+	clientAddr string, event string) (models.User, error) {
+	user, err := a.sessionUser(ctx, accessToken)
+	if err != nil {
+		return models.User{}, err
+	}
+	if err := a.checkReauthentication(ctx, user, proof, code, clientAddr, event); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// sessionUser returns the user, who owns the access token of the active session.
+// It returns ErrInvalidCredentials, if the token is invalid or the session has ended.
+func (a *Auth) sessionUser(ctx context.Context, accessToken string) (models.User, error) {
+	claims, _, err := a.currentSessions(ctx, accessToken)
+	if err != nil {
+		return models.User{}, err
+	}
+	user, err := a.userProvider.UserByID(ctx, claims.User.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.User{}, ErrInvalidCredentials
+		}
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// checkReauthentication checks proof of the password of the user and the second factor code, see reauthenticate.
+func (a *Auth) checkReauthentication(ctx context.Context, user models.User, proof models.PasswordProof, code string,
+	clientAddr string, event string) error {
+	keys := a.throttleKeys(user.Email, clientAddr)
+	if err := a.checkLocked(ctx, keys); err != nil {
+		return err
+	}
+	h, err := a.takeHandshake(ctx, proof.Handshake)
+	if err != nil {
+		return err
+	}
+	if h.Email != user.Email {
+		return ErrInvalidCredentials
+	}
+
+	_, err = checkProof(h, user, proof)
+	if err == nil && user.TOTPEnabled {
+		err = a.checkCode(ctx, user, code)
+	}
+	if err != nil {
+		if !errors.Is(err, ErrInvalidCredentials) {
+			return err
+		}
+		a.log.Warn("failed reauthentication attempt", slog.String("event", event), slog.Int64("user_id", user.ID))
+		a.audit(ctx, models.AuditEvent{Type: event, UserID: user.ID, Email: user.Email,
+			Address: clientAddr, Outcome: models.OutcomeFailure})
+		if err := a.loginFailed(ctx, keys); err != nil {
+			return err
+		}
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// DeletedAccounts returns accounts deleted after the position after in order of deletion and the position to poll
+// the next accounts after. When after is zero, accounts deleted after since are returned.
+func (a *Auth) DeletedAccounts(ctx context.Context, after int64, since time.Time) ([]models.DeletedAccount, int64,
+	error) {
+	const op = "auth.DeletedAccounts"
+
+	accounts, until, err := a.userProvider.DeletedUsers(ctx, after, since)
+	if err != nil {
+		return nil, after, fmt.Errorf("%s: %w", op, err)
+	}
+	return accounts, until, nil
+}
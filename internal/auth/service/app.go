@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/SmoothWay/gophkeeper/internal/auth/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+var (
+	ErrInvalidApp  = errors.New("application is unknown or disabled")
+	ErrAppExists   = errors.New("application already exists")
+	ErrAppNotFound = errors.New("application not found")
+)
+
+// CreateApp registers new client application and returns it with its secret.
+// The secret is kept only hashed, it can not be shown again.
+func (a *Auth) CreateApp(ctx context.Context, name string) (models.App, string, error) {
+	const op = "auth.CreateApp"
+
+	if name == "" {
+		return models.App{}, "", fmt.Errorf("%s, %w", "application name is required", ErrInvalidData)
+	}
+
+	secret, err := randomString(32)
+	if err != nil {
+		return models.App{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	app := models.App{Name: name, SecretHash: hashToken(secret)}
+	app.ID, err = a.appProvider.SaveApp(ctx, name, app.SecretHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppExists) {
+			return models.App{}, "", fmt.Errorf("%s: %w", op, ErrAppExists)
+		}
+		return models.App{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.log.Info("application created", slog.String("op", op), slog.Int("app_id", app.ID), slog.String("name", name))
+	return app, secret, nil
+}
+
+// Apps returns all registered applications.
+func (a *Auth) Apps(ctx context.Context) ([]models.App, error) {
+	const op = "auth.Apps"
+
+	apps, err := a.appProvider.Apps(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return apps, nil
+}
+
+// RotateAppSecret replaces secret of the application and returns the new one, the old secret stops working.
+func (a *Auth) RotateAppSecret(ctx context.Context, appID int) (string, error) {
+	const op = "auth.RotateAppSecret"
+
+	secret, err := randomString(32)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.appProvider.SetAppSecret(ctx, appID, hashToken(secret)); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.log.Info("application secret rotated", slog.String("op", op), slog.Int("app_id", appID))
+	return secret, nil
+}
+
+// DisableApp disables the application, users can not login with it and its refresh tokens are revoked.
+// Access tokens issued earlier stay valid until they expire.
+func (a *Auth) DisableApp(ctx context.Context, appID int) error {
+	const op = "auth.DisableApp"
+
+	if err := a.appProvider.DisableApp(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.log.Info("application disabled", slog.String("op", op), slog.Int("app_id", appID))
+	return nil
+}
+
+// authenticateApp returns enabled application, if the secret matches.
+func (a *Auth) authenticateApp(ctx context.Context, appID int, secret string) (models.App, error) {
+	app, err := a.enabledApp(ctx, appID)
+	if err != nil {
+		return models.App{}, err
+	}
+	if subtle.ConstantTimeCompare(hashToken(secret), app.SecretHash) != 1 {
+		return models.App{}, ErrInvalidApp
+	}
+	return app, nil
+}
+
+// enabledApp returns application, which is not disabled.
+func (a *Auth) enabledApp(ctx context.Context, appID int) (models.App, error) {
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.App{}, ErrInvalidApp
+		}
+		return models.App{}, err
+	}
+	if app.Disabled {
+		return models.App{}, ErrInvalidApp
+	}
+	return app, nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/SmoothWay/gophkeeper/internal/auth/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+const (
+	// defaultAuditPage is number of audit events returned, when page size is not set.
+	defaultAuditPage = 50
+	// maxAuditPage limits number of audit events returned at once.
+	maxAuditPage = 200
+)
+
+// AuditEvents returns events of the audit log of the user, who owns the access token, newest first,
+// and token of the next page, it is empty on the last page.
+// Page of the list is continued with pageToken returned with the previous page.
+// It returns ErrInvalidCredentials, if the token is invalid or its session has ended,
+// and ErrInvalidData, if pageToken is invalid.
+func (a *Auth) AuditEvents(ctx context.Context, accessToken string, filter models.AuditFilter,
+	pageToken string) ([]models.AuditEvent, string, error) {
+	const op = "auth.AuditEvents"
+
+	claims, _, err := a.currentSessions(ctx, accessToken)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	filter.UserID = claims.User.ID
+	if pageToken != "" {
+		filter.BeforeID, err = strconv.ParseInt(pageToken, 10, 64)
+		if err != nil || filter.BeforeID <= 0 {
+			return nil, "", fmt.Errorf("%s, %w", "page token is invalid", ErrInvalidData)
+		}
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditPage
+	}
+	filter.Limit = min(filter.Limit, maxAuditPage)
+
+	// one more event tells whether the next page exists
+	limit := filter.Limit
+	filter.Limit++
+	events, err := a.auditProvider.AuditEvents(ctx, filter)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	if len(events) <= limit {
+		return events, "", nil
+	}
+	events = events[:limit]
+	return events, strconv.FormatInt(events[limit-1].ID, 10), nil
+}
+
+// audit appends the event to the audit log. Event of the email without user is linked to the account with the email,
+// so the user sees failed logins to the account.
+// Failure to write the event is logged, it does not fail the audited operation.
+func (a *Auth) audit(ctx context.Context, event models.AuditEvent) {
+	const op = "auth.audit"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("type", event.Type),
+	)
+
+	if event.UserID == 0 && event.Email != "" {
+		user, err := a.userProvider.User(ctx, event.Email)
+		switch {
+		case err == nil:
+			event.UserID = user.ID
+		case !errors.Is(err, storage.ErrUserNotFound):
+			log.Error("failed to find user of audit event", logger.Err(err))
+		}
+	}
+	event.CreatedAt = a.now()
+
+	if err := a.auditProvider.SaveAuditEvent(ctx, event); err != nil {
+		log.Error("failed to save audit event", logger.Err(err))
+	}
+}
+
+// auditLogin appends login attempt of email refused with err to the audit log.
+func (a *Auth) auditLogin(ctx context.Context, email string, appID int, clientAddr string, err error) {
+	a.audit(ctx, models.AuditEvent{Type: models.AuditLogin, Email: email, AppID: appID, Address: clientAddr,
+		Outcome: auditOutcome(err)})
+}
+
+// auditOutcome returns outcome of the operation, which returned err.
+func auditOutcome(err error) string {
+	var locked *LockedError
+	switch {
+	case err == nil:
+		return models.OutcomeSuccess
+	case errors.As(err, &locked):
+		return models.OutcomeLocked
+	default:
+		return models.OutcomeFailure
+	}
+}
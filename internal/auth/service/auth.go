@@ -2,6 +2,9 @@ package service
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -11,6 +14,7 @@ import (
 	"github.com/SmoothWay/gophkeeper/pkg/jwt"
 	"github.com/SmoothWay/gophkeeper/pkg/logger"
 	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/SmoothWay/gophkeeper/pkg/password"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -18,123 +22,532 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserExists         = errors.New("user already exists")
 	ErrInvalidData        = errors.New("invalid request")
+	ErrTOTPEnabled        = errors.New("two-factor authentication already enabled")
 )
 
 //go:generate mockgen -source=auth.go -destination=../storage/mocks/mock.go
 type UserProvider interface {
-	SaveUser(ctx context.Context, email string, passHash []byte) (int64, error)
+	SaveUser(ctx context.Context, email string, verifier models.PasswordVerifier) (int64, error)
 	User(ctx context.Context, email string) (models.User, error)
+	UserByID(ctx context.Context, id int64) (models.User, error)
+	SetRecovery(ctx context.Context, userID int64, verifier []byte) error
+	UpdatePassword(ctx context.Context, userID int64, verifier models.PasswordVerifier, vaultKey []byte,
+		verifyEmail bool, keepSession string) error
+	SetVerifier(ctx context.Context, userID int64, verifier models.PasswordVerifier) error
+	SetEmailVerified(ctx context.Context, userID int64) error
+	SetTOTP(ctx context.Context, userID int64, secret []byte, enabled bool) error
+	UseTOTPCounter(ctx context.Context, userID int64, counter int64) error
+	DeleteUser(ctx context.Context, userID int64, at time.Time) error
+	DeletedUsers(ctx context.Context, after int64, since time.Time) ([]models.DeletedAccount, int64, error)
 	Close()
 }
 
 type AppProvider interface {
 	App(ctx context.Context, id int) (models.App, error)
+	Apps(ctx context.Context) ([]models.App, error)
+	SaveApp(ctx context.Context, name string, secretHash []byte) (int, error)
+	SetAppSecret(ctx context.Context, id int, secretHash []byte) error
+	DisableApp(ctx context.Context, id int) error
 	Close()
 }
 
+type TokenProvider interface {
+	SaveToken(ctx context.Context, token models.RefreshToken) error
+	Token(ctx context.Context, hash []byte) (models.RefreshToken, error)
+	RotateToken(ctx context.Context, usedID int64, token models.RefreshToken) error
+	RevokeFamily(ctx context.Context, family string) error
+	Close()
+}
+
+// SecondFactorProvider keeps backup codes and state of unfinished logins:
+// SRP handshakes and tickets of logins waiting for the second factor.
+type SecondFactorProvider interface {
+	SaveBackupCodes(ctx context.Context, userID int64, hashes [][]byte) error
+	UseBackupCode(ctx context.Context, userID int64, hash []byte) error
+	SaveTicket(ctx context.Context, ticket models.LoginTicket) error
+	Ticket(ctx context.Context, hash []byte) (models.LoginTicket, error)
+	AddTicketAttempt(ctx context.Context, hash []byte) error
+	DeleteTicket(ctx context.Context, hash []byte) error
+	SaveHandshake(ctx context.Context, handshake models.Handshake) error
+	TakeHandshake(ctx context.Context, hash []byte) (models.Handshake, error)
+	Close()
+}
+
+// ThrottleProvider counts failed logins by account and client address.
+type ThrottleProvider interface {
+	LoginFailures(ctx context.Context, key string) (models.LoginFailures, error)
+	AddLoginFailure(ctx context.Context, key string, at time.Time, resetBefore time.Time) (int, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	ResetLoginFailures(ctx context.Context, key string) error
+	Close()
+}
+
+// EmailProvider keeps tokens, which are sent to the email of the user.
+// Mail with the token is put into the outbox together with the token.
+type EmailProvider interface {
+	SaveEmailToken(ctx context.Context, token models.EmailToken, mail models.Mail) error
+	UseEmailToken(ctx context.Context, hash []byte, purpose string) (models.EmailToken, error)
+	Close()
+}
+
+// SessionProvider keeps sessions of logins, session is active while its refresh tokens are not revoked.
+type SessionProvider interface {
+	SaveSession(ctx context.Context, session models.Session) error
+	Sessions(ctx context.Context, userID int64) ([]models.Session, error)
+	TouchSession(ctx context.Context, id string, address string, at time.Time) error
+	RevokedSessions(ctx context.Context, after int64, since time.Time) ([]string, int64, error)
+	Close()
+}
+
+// AuditProvider keeps append-only audit log of auth events.
+type AuditProvider interface {
+	SaveAuditEvent(ctx context.Context, event models.AuditEvent) error
+	AuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+	Close()
+}
+
+// BreachedRanges returns breached passwords by prefix of SHA-1 hash of the password, see password.RangeDir.
+type BreachedRanges interface {
+	Range(prefix string) (password.Range, error)
+}
+
+// Signer signs access tokens.
+type Signer interface {
+	NewToken(user models.User, app models.App, sessionID string, now time.Time, duration time.Duration) (string, error)
+	PublicKeys() map[string]ed25519.PublicKey
+}
+
 // Auth implements Auth interface (grpcapp module).
 type Auth struct {
-	log          *slog.Logger
-	userProvider UserProvider
-	appProvider  AppProvider
-	tokenTTL     time.Duration
+	log              *slog.Logger
+	userProvider     UserProvider
+	appProvider      AppProvider
+	tokenProvider    TokenProvider
+	factorProvider   SecondFactorProvider
+	throttleProvider ThrottleProvider
+	emailProvider    EmailProvider
+	sessionProvider  SessionProvider
+	auditProvider    AuditProvider
+	signer           Signer
+	tokenTTL         time.Duration
+	refreshTTL       time.Duration
+	throttling       Throttling
+	// passwords checks new passwords, it is published to clients, see PasswordPolicy
+	passwords password.Policy
+	breached  BreachedRanges
+	// fakeKey derives SRP salts of unknown emails, see BeginLogin
+	fakeKey []byte
+	now     func() time.Time
 }
 
-func New(log *slog.Logger, userProvider UserProvider, appProvider AppProvider, tokenTTL time.Duration) *Auth {
+func New(log *slog.Logger, userProvider UserProvider, appProvider AppProvider, tokenProvider TokenProvider,
+	factorProvider SecondFactorProvider, throttleProvider ThrottleProvider, emailProvider EmailProvider,
+	sessionProvider SessionProvider, auditProvider AuditProvider, signer Signer,
+	tokenTTL time.Duration, refreshTTL time.Duration, throttling Throttling, passwords password.Policy,
+	breached BreachedRanges, fakeKey []byte) *Auth {
 	return &Auth{
-		log:          log,
-		userProvider: userProvider,
-		appProvider:  appProvider,
-		tokenTTL:     tokenTTL,
+		log:              log,
+		userProvider:     userProvider,
+		appProvider:      appProvider,
+		tokenProvider:    tokenProvider,
+		factorProvider:   factorProvider,
+		throttleProvider: throttleProvider,
+		emailProvider:    emailProvider,
+		sessionProvider:  sessionProvider,
+		auditProvider:    auditProvider,
+		signer:           signer,
+		tokenTTL:         tokenTTL,
+		refreshTTL:       refreshTTL,
+		throttling:       throttling,
+		passwords:        passwords,
+		breached:         breached,
+		fakeKey:          fakeKey,
+		now:              time.Now,
 	}
 }
 
-// Register method hashes password and saves user data into database.
+// Register method saves user data into database, verifier of the password is computed by the client for SRP login.
+// The password is checked against the password policy and the verifier, only the verifier is saved.
+// Verification token is sent to the email, user can not login until the email is verified.
+// Registration is recorded in the audit log with clientAddr.
 // It returns ErrUserExists, if user with email already registered.
-func (a *Auth) Register(ctx context.Context, email string, password string) (userID int64, err error) {
+func (a *Auth) Register(ctx context.Context, email string, password string, verifier models.PasswordVerifier,
+	clientAddr string) (userID int64, err error) {
 	const op = "auth.Register"
 	log := a.log.With(
 		slog.String("op", op),
 		slog.String("email", email),
 	)
 
-	if err := validate(email, password); err != nil {
-		return 0, err
+	if email == "" {
+		return 0, fmt.Errorf("%s, %w", "email is required", ErrInvalidData)
 	}
-
-	log.Debug("registering user")
-
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
+	if err := a.checkPassword(email, password, verifier); err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	id, err := a.userProvider.SaveUser(ctx, email, passHash)
+	log.Debug("registering user")
+
+	id, err := a.userProvider.SaveUser(ctx, email, verifier)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
+			a.audit(ctx, models.AuditEvent{Type: models.AuditRegister, Email: email, Address: clientAddr,
+				Outcome: models.OutcomeFailure})
 			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
 		}
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
+	a.audit(ctx, models.AuditEvent{Type: models.AuditRegister, UserID: id, Email: email, Address: clientAddr,
+		Outcome: models.OutcomeSuccess})
+
+	if err := a.sendEmailToken(ctx, models.User{ID: id, Email: email}, models.EmailVerify); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
 
 	log.Debug("user registered")
 	return id, nil
 }
 
-// Login method checks credentials and returns JWT token.
-// It returns ErrInvalidCredentials, if user with credentials does not registered.
-func (a *Auth) Login(ctx context.Context, email string, password string, appID int) (string, error) {
+// dummyHash is compared with passwords of accounts, which can not log in with the password,
+// so they take as long as accounts with the password hash.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// Login method checks the password of the account, which has not logged in since SRP was introduced,
+// and replaces hash of the password with verifier, so the next logins use BeginLogin and the password is not sent.
+// Other accounts log in only with SRP, their logins fail here as wrong passwords do.
+// The method is removed together with password hashes, when all accounts have verifiers.
+// If the user has enabled two-factor authentication, only login ticket is returned,
+// login is completed with VerifyLogin. Login starts new session of the device.
+// Failed logins are counted by account and address of the device, it returns LockedError, while login is locked.
+// It returns ErrInvalidCredentials, if the password does not match, ErrEmailNotVerified, if the user has not
+// verified the email, and ErrInvalidApp, if the application is unknown, disabled or appSecret does not match.
+func (a *Auth) Login(ctx context.Context, email string, password string, appID int, appSecret string,
+	device models.Device, verifier models.PasswordVerifier) (models.Login, error) {
 	const op = "auth.Login"
 	log := a.log.With(
 		slog.String("op", op),
 		slog.String("username", email),
+		slog.String("client_addr", device.Address),
 	)
 
-	if err := validate(email, password); err != nil {
-		return "", err
+	if email == "" {
+		return models.Login{}, fmt.Errorf("%s, %w", "email is required", ErrInvalidData)
+	}
+	if password == "" {
+		return models.Login{}, fmt.Errorf("%s, %w", "password is required", ErrInvalidData)
+	}
+	if err := validateVerifier(verifier); err != nil {
+		return models.Login{}, err
+	}
+	app, err := a.authenticateApp(ctx, appID, appSecret)
+	if err != nil {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	keys := a.throttleKeys(email, device.Address)
+	if err := a.checkLocked(ctx, keys); err != nil {
+		a.auditLogin(ctx, email, app.ID, device.Address, err)
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
 	}
-
-	log.Debug("attempting to login user")
 
 	user, err := a.userProvider.User(ctx, email)
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(user.PassHash) == 0 || len(user.SRPVerifier) > 0 {
+		// nothing to guess here, SRP login of the account has already counted the attempt
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		a.auditLogin(ctx, email, app.ID, device.Address, ErrInvalidCredentials)
+		return models.Login{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
+		log.Warn("failed login attempt")
+		a.auditLogin(ctx, email, app.ID, device.Address, ErrInvalidCredentials)
+		if err := a.loginFailed(ctx, keys); err != nil {
+			return models.Login{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return models.Login{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if err := matchVerifier(email, password, verifier); err != nil {
+		return models.Login{}, err
+	}
+	if err := a.userProvider.SetVerifier(ctx, user.ID, verifier); err != nil {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log.Info("password hash replaced with verifier")
+
+	login, err := a.completeLogin(ctx, log, user, app, device, nil)
 	if err != nil {
-		if errors.Is(err, storage.ErrUserNotFound) {
-			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return login, nil
+}
+
+// completeLogin finishes login of the user, whose password is accepted.
+// Ticket is returned, if the user has to enter the second factor code.
+// sealKey of SRP login encrypts the vault key, it is saved with the ticket for VerifyLogin.
+func (a *Auth) completeLogin(ctx context.Context, log *slog.Logger, user models.User, app models.App,
+	device models.Device, sealKey []byte) (models.Login, error) {
+	if !user.EmailVerified {
+		// verification token may be lost or expired, the new one is sent
+		if err := a.sendEmailToken(ctx, user, models.EmailVerify); err != nil {
+			return models.Login{}, err
 		}
-		return "", fmt.Errorf("%s: %w", op, err)
+		return models.Login{}, ErrEmailNotVerified
 	}
 
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
-		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	if user.TOTPEnabled {
+		ticket, err := a.newTicket(ctx, user, app, sealKey)
+		if err != nil {
+			return models.Login{}, err
+		}
+		log.Info("password accepted, waiting for the second factor")
+		return models.Login{Ticket: ticket}, nil
 	}
 
-	app, err := a.appProvider.App(ctx, appID)
+	// failures of the account are counted until the second factor is accepted
+	if err := a.loginSucceeded(ctx, user.Email); err != nil {
+		return models.Login{}, err
+	}
+	login, err := a.login(ctx, user, app, device)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		log.Error("failed to issue tokens", logger.Err(err))
+		return models.Login{}, err
+	}
+	if login.VaultKey, err = sealVaultKey(sealKey, login.VaultKey); err != nil {
+		return models.Login{}, err
 	}
 	log.Info("user logged in successfully")
+	a.audit(ctx, models.AuditEvent{Type: models.AuditLogin, UserID: user.ID, Email: user.Email, AppID: app.ID,
+		Address: device.Address, Outcome: models.OutcomeSuccess})
+
+	return login, nil
+}
+
+// login starts session of the authenticated user on the device and issues its tokens.
+func (a *Auth) login(ctx context.Context, user models.User, app models.App, device models.Device) (models.Login, error) {
+	// every login starts new family of refresh tokens, the family is id of the session
+	family, err := randomString(16)
+	if err != nil {
+		return models.Login{}, err
+	}
+	tokens, refresh, err := a.issueTokens(user, app, family)
+	if err != nil {
+		return models.Login{}, err
+	}
+	if err := a.tokenProvider.SaveToken(ctx, refresh); err != nil {
+		return models.Login{}, err
+	}
+	if device.Name == "" {
+		device.Name = unknownDevice
+	}
+	now := a.now()
+	err = a.sessionProvider.SaveSession(ctx, models.Session{
+		ID:         family,
+		UserID:     user.ID,
+		AppID:      app.ID,
+		Device:     device.Name,
+		Address:    device.Address,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	})
+	if err != nil {
+		return models.Login{}, err
+	}
 
-	token, err := jwt.NewToken(user, app, a.tokenTTL)
+	return models.Login{Tokens: tokens, VaultKey: user.VaultKey}, nil
+}
+
+// SetRecovery saves verifier of the recovery kit created by the user, who owns the access token.
+// The user proves the password again and enters the second factor code, when two-factor authentication is enabled.
+// Previous recovery kit of the user stops working.
+// Failed attempts are counted as failed logins, it returns LockedError, while login is locked.
+// It returns ErrInvalidCredentials, if the token is invalid, or the proof or the code do not match.
+func (a *Auth) SetRecovery(ctx context.Context, accessToken string, proof models.PasswordProof, code string,
+	verifier []byte, clientAddr string) error {
+	const op = "auth.SetRecovery"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("client_addr", clientAddr),
+	)
+
+	if len(verifier) != sha256.Size {
+		return fmt.Errorf("%s, %w", "recovery verifier is invalid", ErrInvalidData)
+	}
+
+	user, err := a.reauthenticate(ctx, accessToken, proof, code, clientAddr, models.AuditRecoveryKit)
 	if err != nil {
-		log.Error("failed to generate token", logger.Err(err))
-		return "", fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
+	log = log.With(slog.Int64("user_id", user.ID))
 
-	return token, nil
+	if err := a.userProvider.SetRecovery(ctx, user.ID, verifier); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("recovery kit created")
+	a.audit(ctx, models.AuditEvent{Type: models.AuditRecoveryKit, UserID: user.ID, Email: user.Email,
+		Address: clientAddr, Outcome: models.OutcomeSuccess})
+	return nil
 }
 
-func validate(email string, password string) error {
-	switch {
-	case email == "":
+// Recover sets new password of the user, who proved with the recovery kit that they have master key of the vault.
+// vaultKey is the master key wrapped with the new password, verifier is SRP verifier of the new password,
+// the password is checked against the password policy and the verifier.
+// All sessions of the user are revoked. Recovery is recorded in the audit log with clientAddr.
+// It returns ErrInvalidCredentials, if the user has no recovery kit or proof does not match.
+func (a *Auth) Recover(ctx context.Context, email string, proof []byte, password string, vaultKey []byte,
+	verifier models.PasswordVerifier, clientAddr string) error {
+	const op = "auth.Recover"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("username", email),
+	)
+
+	if email == "" {
 		return fmt.Errorf("%s, %w", "email is required", ErrInvalidData)
-	case password == "":
+	}
+	if password == "" {
+		return fmt.Errorf("%s, %w", "password is required", ErrInvalidData)
+	}
+	if err := validateVerifier(verifier); err != nil {
+		return err
+	}
+	if len(vaultKey) == 0 {
+		return fmt.Errorf("%s, %w", "vault key is required", ErrInvalidData)
+	}
+
+	user, err := a.userProvider.User(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	sum := sha256.Sum256(proof)
+	if len(user.RecoveryVerifier) == 0 || subtle.ConstantTimeCompare(sum[:], user.RecoveryVerifier) != 1 {
+		log.Warn("invalid recovery proof")
+		a.audit(ctx, models.AuditEvent{Type: models.AuditRecovery, UserID: user.ID, Email: email, Address: clientAddr,
+			Outcome: models.OutcomeFailure})
+		return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+	if err := a.checkPassword(email, password, verifier); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.userProvider.UpdatePassword(ctx, user.ID, verifier, vaultKey, false, ""); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user recovered account")
+	a.audit(ctx, models.AuditEvent{Type: models.AuditRecovery, UserID: user.ID, Email: email, Address: clientAddr,
+		Outcome: models.OutcomeSuccess})
+	return nil
+}
+
+// ChangePassword sets new password of the user, who owns the access token. The user proves the old password again
+// and enters the second factor code, when two-factor authentication is enabled, the client application is checked
+// with appSecret as on login.
+// vaultKey is master key of the vault wrapped with the new password, it is replaced together with verifier,
+// which is SRP verifier of the new password, the new password is checked against the password policy and the verifier.
+// Other sessions of the user are revoked, the current session stays, so the client keeps its connection.
+// Failed attempts are counted as failed logins, it returns LockedError, while login is locked.
+// It returns ErrInvalidCredentials, if the token is invalid, or the proof or the code do not match.
+func (a *Auth) ChangePassword(ctx context.Context, accessToken string, proof models.PasswordProof, code string,
+	newPassword string, vaultKey []byte, verifier models.PasswordVerifier, appID int, appSecret string,
+	clientAddr string) error {
+	const op = "auth.ChangePassword"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("client_addr", clientAddr),
+	)
+
+	if newPassword == "" {
 		return fmt.Errorf("%s, %w", "password is required", ErrInvalidData)
-	default:
-		return nil
 	}
+	if err := validateVerifier(verifier); err != nil {
+		return err
+	}
+	if len(vaultKey) == 0 {
+		return fmt.Errorf("%s, %w", "vault key is required", ErrInvalidData)
+	}
+
+	app, err := a.authenticateApp(ctx, appID, appSecret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := a.reauthenticate(ctx, accessToken, proof, code, clientAddr, models.AuditPasswordChange)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	log = log.With(slog.Int64("user_id", user.ID))
+	// the token is checked by reauthenticate, its session is kept
+	claims, err := jwt.ParseClaimsAt(accessToken, a.publicKey, a.now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if err := a.checkPassword(user.Email, newPassword, verifier); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.userProvider.UpdatePassword(ctx, user.ID, verifier, vaultKey, false, claims.SessionID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password changed, other sessions revoked")
+	a.audit(ctx, models.AuditEvent{Type: models.AuditPasswordChange, UserID: user.ID, Email: user.Email,
+		AppID: app.ID, Address: clientAddr, Outcome: models.OutcomeSuccess})
+	return nil
+}
+
+// PasswordPolicy returns requirements to new passwords and breached passwords of the range of SHA-1 hash prefix.
+// Clients check new passwords before they compute verifiers, the prefix does not reveal the password.
+// Range is empty, when the check of breached passwords is disabled.
+// It returns ErrInvalidData, if the prefix is invalid.
+func (a *Auth) PasswordPolicy(hashPrefix string) (password.Policy, password.Range, error) {
+	const op = "auth.PasswordPolicy"
+
+	if a.breached == nil {
+		return a.passwords, password.Range{}, nil
+	}
+	breached, err := a.breached.Range(hashPrefix)
+	if err != nil {
+		if errors.Is(err, password.ErrInvalidPrefix) {
+			return password.Policy{}, password.Range{}, fmt.Errorf("%s: %w, %w", op, err, ErrInvalidData)
+		}
+		return password.Policy{}, password.Range{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return a.passwords, breached, nil
+}
+
+// checkPassword checks the new password against the password policy, inputs of the policy is email of the user,
+// and checks that verifier is SRP verifier of the password, so the client can not save verifier of another password.
+// Error of the weak password wraps ErrInvalidData and *password.Error with requirements, which are not satisfied.
+func (a *Auth) checkPassword(email string, newPassword string, verifier models.PasswordVerifier) error {
+	if newPassword == "" {
+		return fmt.Errorf("%s, %w", "password is required", ErrInvalidData)
+	}
+	if err := validateVerifier(verifier); err != nil {
+		return err
+	}
+	err := a.passwords.Check(newPassword, email)
+	if errors.Is(err, password.ErrWeak) {
+		return fmt.Errorf("%w, %w", err, ErrInvalidData)
+	}
+	if err != nil {
+		return err
+	}
+	return matchVerifier(email, newPassword, verifier)
 }
 
 func (a *Auth) Close() {
 	a.userProvider.Close()
 	a.appProvider.Close()
+	a.tokenProvider.Close()
+	a.factorProvider.Close()
+	a.throttleProvider.Close()
+	a.emailProvider.Close()
+	a.sessionProvider.Close()
+	a.auditProvider.Close()
 }
@@ -0,0 +1,1592 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/internal/auth/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/encrypt"
+	"github.com/SmoothWay/gophkeeper/pkg/jwt"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/SmoothWay/gophkeeper/pkg/password"
+	"github.com/SmoothWay/gophkeeper/pkg/srp"
+	"github.com/SmoothWay/gophkeeper/pkg/totp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// memUsers is in-memory UserProvider.
+type memUsers struct {
+	users   map[string]models.User
+	tokens  *memTokens
+	deleted []models.DeletedAccount
+}
+
+func (m *memUsers) SaveUser(_ context.Context, email string, verifier models.PasswordVerifier) (int64, error) {
+	if _, ok := m.users[email]; ok {
+		return 0, storage.ErrUserExists
+	}
+	user := models.User{ID: int64(len(m.users) + len(m.deleted) + 1), Email: email,
+		SRPSalt: verifier.Salt, SRPVerifier: verifier.Verifier}
+	m.users[email] = user
+	return user.ID, nil
+}
+
+func (m *memUsers) User(_ context.Context, email string) (models.User, error) {
+	user, ok := m.users[email]
+	if !ok {
+		return models.User{}, storage.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (m *memUsers) UserByID(_ context.Context, id int64) (models.User, error) {
+	for _, user := range m.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return models.User{}, storage.ErrUserNotFound
+}
+
+func (m *memUsers) update(userID int64, fn func(user *models.User)) error {
+	for email, user := range m.users {
+		if user.ID == userID {
+			fn(&user)
+			m.users[email] = user
+			return nil
+		}
+	}
+	return storage.ErrUserNotFound
+}
+
+func (m *memUsers) SetRecovery(_ context.Context, userID int64, verifier []byte) error {
+	return m.update(userID, func(user *models.User) { user.RecoveryVerifier = verifier })
+}
+
+func (m *memUsers) UpdatePassword(_ context.Context, userID int64, verifier models.PasswordVerifier, vaultKey []byte,
+	verifyEmail bool, keepSession string) error {
+	for i := range m.tokens.tokens {
+		if m.tokens.tokens[i].UserID == userID && m.tokens.tokens[i].Family != keepSession {
+			m.tokens.tokens[i].Revoked = true
+		}
+	}
+	return m.update(userID, func(user *models.User) {
+		user.VaultKey = vaultKey
+		user.PassHash = nil
+		user.SRPSalt = verifier.Salt
+		user.SRPVerifier = verifier.Verifier
+		user.EmailVerified = user.EmailVerified || verifyEmail
+	})
+}
+
+func (m *memUsers) SetVerifier(_ context.Context, userID int64, verifier models.PasswordVerifier) error {
+	return m.update(userID, func(user *models.User) {
+		user.PassHash = nil
+		user.SRPSalt = verifier.Salt
+		user.SRPVerifier = verifier.Verifier
+	})
+}
+
+func (m *memUsers) SetEmailVerified(_ context.Context, userID int64) error {
+	return m.update(userID, func(user *models.User) { user.EmailVerified = true })
+}
+
+func (m *memUsers) SetTOTP(_ context.Context, userID int64, secret []byte, enabled bool) error {
+	return m.update(userID, func(user *models.User) {
+		user.TOTPSecret = secret
+		user.TOTPEnabled = enabled
+		user.TOTPCounter = 0
+	})
+}
+
+func (m *memUsers) UseTOTPCounter(_ context.Context, userID int64, counter int64) error {
+	used := false
+	err := m.update(userID, func(user *models.User) {
+		if used = user.TOTPCounter >= counter; !used {
+			user.TOTPCounter = counter
+		}
+	})
+	if err == nil && used {
+		return storage.ErrCodeUsed
+	}
+	return err
+}
+
+func (m *memUsers) DeleteUser(_ context.Context, userID int64, at time.Time) error {
+	for email, user := range m.users {
+		if user.ID == userID {
+			delete(m.users, email)
+			m.tokens.tokens = slices.DeleteFunc(m.tokens.tokens, func(token models.RefreshToken) bool {
+				return token.UserID == userID
+			})
+			m.deleted = append(m.deleted, models.DeletedAccount{UserID: userID, DeletedAt: at,
+				Seq: int64(len(m.deleted) + 1)})
+			return nil
+		}
+	}
+	return storage.ErrUserNotFound
+}
+
+func (m *memUsers) DeletedUsers(_ context.Context, after int64, since time.Time) ([]models.DeletedAccount, int64,
+	error) {
+	var accounts []models.DeletedAccount
+	for _, account := range m.deleted {
+		if after > 0 && account.Seq > after || after == 0 && account.DeletedAt.After(since) {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, max(after, int64(len(m.deleted))), nil
+}
+
+func (m *memUsers) Close() {}
+
+// memApps is in-memory AppProvider.
+type memApps struct {
+	apps   []models.App
+	tokens *memTokens
+}
+
+func (m *memApps) App(_ context.Context, id int) (models.App, error) {
+	if id < 1 || id > len(m.apps) {
+		return models.App{}, storage.ErrAppNotFound
+	}
+	return m.apps[id-1], nil
+}
+
+func (m *memApps) Apps(_ context.Context) ([]models.App, error) {
+	return m.apps, nil
+}
+
+func (m *memApps) SaveApp(_ context.Context, name string, secretHash []byte) (int, error) {
+	for _, app := range m.apps {
+		if app.Name == name {
+			return 0, storage.ErrAppExists
+		}
+	}
+	m.apps = append(m.apps, models.App{ID: len(m.apps) + 1, Name: name, SecretHash: secretHash})
+	return len(m.apps), nil
+}
+
+func (m *memApps) SetAppSecret(_ context.Context, id int, secretHash []byte) error {
+	if id < 1 || id > len(m.apps) {
+		return storage.ErrAppNotFound
+	}
+	m.apps[id-1].SecretHash = secretHash
+	return nil
+}
+
+func (m *memApps) DisableApp(_ context.Context, id int) error {
+	if id < 1 || id > len(m.apps) {
+		return storage.ErrAppNotFound
+	}
+	m.apps[id-1].Disabled = true
+	for i := range m.tokens.tokens {
+		if m.tokens.tokens[i].AppID == id {
+			m.tokens.tokens[i].Revoked = true
+		}
+	}
+	return nil
+}
+
+func (m *memApps) Close() {}
+
+// memTokens is in-memory TokenProvider.
+type memTokens struct {
+	tokens []models.RefreshToken
+}
+
+func (m *memTokens) SaveToken(_ context.Context, token models.RefreshToken) error {
+	token.ID = int64(len(m.tokens) + 1)
+	m.tokens = append(m.tokens, token)
+	return nil
+}
+
+func (m *memTokens) Token(_ context.Context, hash []byte) (models.RefreshToken, error) {
+	for _, token := range m.tokens {
+		if bytes.Equal(token.Hash, hash) {
+			return token, nil
+		}
+	}
+	return models.RefreshToken{}, storage.ErrTokenNotFound
+}
+
+func (m *memTokens) RotateToken(ctx context.Context, usedID int64, token models.RefreshToken) error {
+	used := &m.tokens[usedID-1]
+	if used.Used || used.Revoked {
+		return storage.ErrTokenUsed
+	}
+	used.Used = true
+	return m.SaveToken(ctx, token)
+}
+
+func (m *memTokens) RevokeFamily(_ context.Context, family string) error {
+	for i := range m.tokens {
+		if m.tokens[i].Family == family {
+			m.tokens[i].Revoked = true
+		}
+	}
+	return nil
+}
+
+func (m *memTokens) Close() {}
+
+// memFactors is in-memory SecondFactorProvider.
+type memFactors struct {
+	codes      map[int64]map[string]bool
+	tickets    map[string]models.LoginTicket
+	handshakes map[string]models.Handshake
+}
+
+func (m *memFactors) SaveBackupCodes(_ context.Context, userID int64, hashes [][]byte) error {
+	m.codes[userID] = make(map[string]bool)
+	for _, hash := range hashes {
+		m.codes[userID][string(hash)] = false
+	}
+	return nil
+}
+
+func (m *memFactors) UseBackupCode(_ context.Context, userID int64, hash []byte) error {
+	used, ok := m.codes[userID][string(hash)]
+	if !ok || used {
+		return storage.ErrCodeNotFound
+	}
+	m.codes[userID][string(hash)] = true
+	return nil
+}
+
+func (m *memFactors) SaveTicket(_ context.Context, ticket models.LoginTicket) error {
+	m.tickets[string(ticket.Hash)] = ticket
+	return nil
+}
+
+func (m *memFactors) Ticket(_ context.Context, hash []byte) (models.LoginTicket, error) {
+	ticket, ok := m.tickets[string(hash)]
+	if !ok {
+		return models.LoginTicket{}, storage.ErrTicketNotFound
+	}
+	return ticket, nil
+}
+
+func (m *memFactors) AddTicketAttempt(_ context.Context, hash []byte) error {
+	ticket := m.tickets[string(hash)]
+	ticket.Attempts++
+	m.tickets[string(hash)] = ticket
+	return nil
+}
+
+func (m *memFactors) DeleteTicket(_ context.Context, hash []byte) error {
+	delete(m.tickets, string(hash))
+	return nil
+}
+
+func (m *memFactors) SaveHandshake(_ context.Context, handshake models.Handshake) error {
+	m.handshakes[string(handshake.Hash)] = handshake
+	return nil
+}
+
+func (m *memFactors) TakeHandshake(_ context.Context, hash []byte) (models.Handshake, error) {
+	handshake, ok := m.handshakes[string(hash)]
+	if !ok {
+		return models.Handshake{}, storage.ErrHandshakeNotFound
+	}
+	delete(m.handshakes, string(hash))
+	return handshake, nil
+}
+
+func (m *memFactors) Close() {}
+
+// memThrottle is in-memory ThrottleProvider.
+type memThrottle struct {
+	failures map[string]models.LoginFailures
+	updated  map[string]time.Time
+}
+
+func (m *memThrottle) LoginFailures(_ context.Context, key string) (models.LoginFailures, error) {
+	failures, ok := m.failures[key]
+	if !ok {
+		return models.LoginFailures{Key: key}, nil
+	}
+	return failures, nil
+}
+
+func (m *memThrottle) AddLoginFailure(_ context.Context, key string, at time.Time, resetBefore time.Time) (int, error) {
+	failures := m.failures[key]
+	if m.updated[key].Before(resetBefore) {
+		failures = models.LoginFailures{Key: key}
+	}
+	failures.Failures++
+	m.failures[key], m.updated[key] = failures, at
+	return failures.Failures, nil
+}
+
+func (m *memThrottle) LockLogin(_ context.Context, key string, until time.Time) error {
+	failures := m.failures[key]
+	failures.LockedUntil = until
+	m.failures[key] = failures
+	return nil
+}
+
+func (m *memThrottle) ResetLoginFailures(_ context.Context, key string) error {
+	delete(m.failures, key)
+	delete(m.updated, key)
+	return nil
+}
+
+func (m *memThrottle) Close() {}
+
+// memSessions is in-memory SessionProvider, session is active while its family has unused token.
+type memSessions struct {
+	sessions []models.Session
+	tokens   *memTokens
+}
+
+func (m *memSessions) SaveSession(_ context.Context, session models.Session) error {
+	m.sessions = append(m.sessions, session)
+	return nil
+}
+
+func (m *memSessions) Sessions(_ context.Context, userID int64) ([]models.Session, error) {
+	var sessions []models.Session
+	for _, session := range m.sessions {
+		if session.UserID != userID {
+			continue
+		}
+		for _, token := range m.tokens.tokens {
+			if token.Family == session.ID && !token.Used && !token.Revoked {
+				sessions = append(sessions, session)
+				break
+			}
+		}
+	}
+	return sessions, nil
+}
+
+func (m *memSessions) TouchSession(_ context.Context, id string, address string, at time.Time) error {
+	for i := range m.sessions {
+		if m.sessions[i].ID == id {
+			m.sessions[i].Address, m.sessions[i].LastSeenAt = address, at
+		}
+	}
+	return nil
+}
+
+func (m *memSessions) RevokedSessions(_ context.Context, after int64, _ time.Time) ([]string, int64, error) {
+	revoked := make(map[string]bool)
+	var ids []string
+	for _, token := range m.tokens.tokens {
+		if token.Revoked && !revoked[token.Family] {
+			revoked[token.Family] = true
+			ids = append(ids, token.Family)
+		}
+	}
+	return ids, after, nil
+}
+
+func (m *memSessions) Close() {}
+
+// memAudit is in-memory AuditProvider.
+type memAudit struct {
+	events []models.AuditEvent
+}
+
+func (m *memAudit) SaveAuditEvent(_ context.Context, event models.AuditEvent) error {
+	event.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *memAudit) AuditEvents(_ context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	for i := len(m.events) - 1; i >= 0 && len(events) < filter.Limit; i-- {
+		e := m.events[i]
+		if e.UserID != filter.UserID || (len(filter.Types) > 0 && !slices.Contains(filter.Types, e.Type)) ||
+			(filter.Outcome != "" && e.Outcome != filter.Outcome) || (filter.BeforeID > 0 && e.ID >= filter.BeforeID) ||
+			(!filter.Since.IsZero() && e.CreatedAt.Before(filter.Since)) ||
+			(!filter.Until.IsZero() && !e.CreatedAt.Before(filter.Until)) {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func (m *memAudit) Close() {}
+
+// memEmails is in-memory EmailProvider, it keeps mails of the outbox.
+type memEmails struct {
+	tokens map[string]models.EmailToken
+	mails  []models.Mail
+}
+
+func (m *memEmails) SaveEmailToken(_ context.Context, token models.EmailToken, mail models.Mail) error {
+	for hash, t := range m.tokens {
+		if t.UserID == token.UserID && t.Purpose == token.Purpose {
+			delete(m.tokens, hash)
+		}
+	}
+	m.tokens[string(token.Hash)] = token
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+func (m *memEmails) UseEmailToken(_ context.Context, hash []byte, purpose string) (models.EmailToken, error) {
+	token, ok := m.tokens[string(hash)]
+	if !ok || token.Purpose != purpose {
+		return models.EmailToken{}, storage.ErrEmailTokenNotFound
+	}
+	delete(m.tokens, string(hash))
+	return token, nil
+}
+
+func (m *memEmails) Close() {}
+
+var mailCode = regexp.MustCompile(`code: (\S+)`)
+
+// lastCode returns code from the last mail sent to the email.
+func (m *memEmails) lastCode(t *testing.T, email string) string {
+	t.Helper()
+
+	for i := len(m.mails) - 1; i >= 0; i-- {
+		if m.mails[i].To == email {
+			match := mailCode.FindStringSubmatch(m.mails[i].Body)
+			require.Len(t, match, 2)
+			return match[1]
+		}
+	}
+	require.Fail(t, "no mail to "+email)
+	return ""
+}
+
+// register registers user with verified email.
+func register(t *testing.T, auth *Auth, email string, password string) {
+	t.Helper()
+
+	ctx := context.Background()
+	_, err := auth.Register(ctx, email, password, newVerifier(t, email, password), "")
+	require.NoError(t, err)
+	require.NoError(t, auth.VerifyEmail(ctx, auth.emailProvider.(*memEmails).lastCode(t, email)))
+}
+
+// testAppSecret is secret of the application with id 1.
+const testAppSecret = "test-secret"
+
+// testFakeKey derives salts of unknown emails.
+const testFakeKey = "test-fake-salt-key-of-32-bytes.."
+
+func newTestAuth(t *testing.T) *Auth {
+	t.Helper()
+
+	key, err := jwt.GenerateKey()
+	require.NoError(t, err)
+	signer, err := jwt.NewSigner("k1", map[string]ed25519.PrivateKey{"k1": key})
+	require.NoError(t, err)
+
+	tokens := &memTokens{}
+	users := &memUsers{users: make(map[string]models.User), tokens: tokens}
+	factors := &memFactors{codes: make(map[int64]map[string]bool), tickets: make(map[string]models.LoginTicket),
+		handshakes: make(map[string]models.Handshake)}
+	throttle := &memThrottle{failures: make(map[string]models.LoginFailures), updated: make(map[string]time.Time)}
+	throttling := Throttling{AccountAttempts: 3, AddressAttempts: 5, Lockout: time.Minute, MaxLockout: 10 * time.Minute}
+	emails := &memEmails{tokens: make(map[string]models.EmailToken)}
+	apps := &memApps{
+		apps:   []models.App{{ID: 1, Name: "gophkeeper", SecretHash: hashToken(testAppSecret)}},
+		tokens: tokens,
+	}
+	sessions := &memSessions{tokens: tokens}
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), users, apps, tokens, factors, throttle, emails, sessions,
+		&memAudit{}, signer, time.Hour, 24*time.Hour, throttling, password.Policy{}, nil, []byte(testFakeKey))
+}
+
+func TestRecover(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+
+	register(t, auth, "name@example.com", "forgotten")
+	session, _, err := clientLogin(t, auth, "name@example.com", "forgotten", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	token := session.Tokens.AccessToken
+
+	proof := []byte("proof restored from the recovery kit")
+	verifier := sha256.Sum256(proof)
+	newPassword := newVerifier(t, "name@example.com", "new password")
+
+	err = auth.Recover(ctx, "name@example.com", proof, "new password", []byte("wrapped"), newPassword, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "user without recovery kit")
+
+	err = auth.SetRecovery(ctx, "not a token", passwordProof(t, auth, "name@example.com", "forgotten"), "",
+		verifier[:], "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	err = auth.SetRecovery(ctx, token, passwordProof(t, auth, "name@example.com", "wrong"), "", verifier[:], "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	err = auth.SetRecovery(ctx, token, passwordProof(t, auth, "name@example.com", "forgotten"), "", []byte("short"), "")
+	assert.ErrorIs(t, err, ErrInvalidData)
+	require.NoError(t, auth.SetRecovery(ctx, token, passwordProof(t, auth, "name@example.com", "forgotten"), "",
+		verifier[:], ""))
+
+	err = auth.Recover(ctx, "name@example.com", []byte("wrong proof"), "new password", []byte("wrapped"), newPassword, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	err = auth.Recover(ctx, "missing@example.com", proof, "new password", []byte("wrapped"), newPassword, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	err = auth.Recover(ctx, "name@example.com", proof, "new password", nil, newPassword, "")
+	assert.ErrorIs(t, err, ErrInvalidData)
+	err = auth.Recover(ctx, "name@example.com", proof, "new password", []byte("wrapped"), models.PasswordVerifier{}, "")
+	assert.ErrorIs(t, err, ErrInvalidData, "verifier of the new password is required")
+
+	require.NoError(t, auth.Recover(ctx, "name@example.com", proof, "new password", []byte("wrapped"), newPassword, ""))
+
+	_, _, err = clientLogin(t, auth, "name@example.com", "forgotten", 1, testAppSecret, models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	login, session2, err := clientLogin(t, auth, "name@example.com", "new password", 1, testAppSecret,
+		models.Device{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, login.Tokens.AccessToken)
+	vaultKey, err := encrypt.Decrypt(session2.SealKey(), login.VaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("wrapped"), vaultKey)
+}
+
+func TestSetRecoveryTOTP(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+	users := auth.userProvider.(*memUsers)
+
+	register(t, auth, "name@example.com", "password")
+	login, _, err := clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	token := login.Tokens.AccessToken
+	secret, _, err := auth.EnrollTOTP(ctx, token, passwordProof(t, auth, "name@example.com", "password"), "")
+	require.NoError(t, err)
+	_, err = auth.ConfirmTOTP(ctx, token, passwordProof(t, auth, "name@example.com", "password"),
+		totp.Code(secret, now), "")
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+
+	verifier := make([]byte, sha256.Size)
+	err = auth.SetRecovery(ctx, token, passwordProof(t, auth, "name@example.com", "password"), "", verifier,
+		"10.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "second factor is required")
+	require.NoError(t, auth.SetRecovery(ctx, token, passwordProof(t, auth, "name@example.com", "password"),
+		totp.Code(secret, now), verifier, "10.0.0.1"))
+	user, err := users.User(ctx, "name@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, verifier, user.RecoveryVerifier)
+
+	// proof for the handshake of another account does not prove the password of the user
+	register(t, auth, "other@example.com", "password")
+	err = auth.SetRecovery(ctx, token, passwordProof(t, auth, "other@example.com", "password"),
+		totp.Code(secret, now), verifier, "10.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// failed attempts lock login of the account
+	proof := passwordProof(t, auth, "name@example.com", "password")
+	for i := 0; i < 2; i++ {
+		err = auth.SetRecovery(ctx, token, passwordProof(t, auth, "name@example.com", "wrong"),
+			totp.Code(secret, now), verifier, "10.0.0.1")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	var locked *LockedError
+	assert.ErrorAs(t, auth.SetRecovery(ctx, token, proof, totp.Code(secret, now), verifier, "10.0.0.1"), &locked)
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	assert.ErrorAs(t, err, &locked)
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+
+	register(t, auth, "name@example.com", "password")
+
+	res, _, err := clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	login := res.Tokens
+	assert.NotEmpty(t, login.RefreshToken)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), login.ExpiresAt, time.Minute)
+
+	user, err := jwt.Parse(login.AccessToken, func(kid string) (ed25519.PublicKey, error) {
+		return auth.PublicKeys()[kid], nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "name@example.com", user.Email)
+
+	_, err = auth.Refresh(ctx, "unknown", 1, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = auth.Refresh(ctx, login.RefreshToken, 2, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "token of another app")
+
+	refreshed, err := auth.Refresh(ctx, login.RefreshToken, 1, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+	assert.NotEmpty(t, refreshed.AccessToken)
+
+	// the first token was stolen and is used again, all tokens of the login are revoked
+	_, err = auth.Refresh(ctx, login.RefreshToken, 1, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = auth.Refresh(ctx, refreshed.RefreshToken, 1, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// other logins are not affected
+	res, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	other, err := auth.Refresh(ctx, res.Tokens.RefreshToken, 1, "")
+	require.NoError(t, err)
+
+	require.NoError(t, auth.Logout(ctx, other.RefreshToken))
+	_, err = auth.Refresh(ctx, other.RefreshToken, 1, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.ErrorIs(t, auth.Logout(ctx, "unknown"), ErrInvalidCredentials)
+}
+
+func TestRefreshExpired(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	auth.refreshTTL = -time.Second
+
+	register(t, auth, "name@example.com", "password")
+	login, _, err := clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+
+	_, err = auth.Refresh(ctx, login.Tokens.RefreshToken, 1, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestTOTP(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+	// wrong codes of the test do not lock the account, see TestTOTPThrottling
+	auth.throttling.AccountAttempts = 20
+
+	register(t, auth, "name@example.com", "password")
+	login, _, err := clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	token := login.Tokens.AccessToken
+
+	_, err = auth.ConfirmTOTP(ctx, token, passwordProof(t, auth, "name@example.com", "password"), "123456", "")
+	assert.ErrorIs(t, err, ErrInvalidData, "not enrolled")
+	_, _, err = auth.EnrollTOTP(ctx, "not a token", passwordProof(t, auth, "name@example.com", "password"), "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, _, err = auth.EnrollTOTP(ctx, token, passwordProof(t, auth, "name@example.com", "wrong"), "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	secret, uri, err := auth.EnrollTOTP(ctx, token, passwordProof(t, auth, "name@example.com", "password"), "")
+	require.NoError(t, err)
+	assert.Contains(t, uri, "secret="+totp.Encode(secret))
+
+	// second factor is not required until it is confirmed
+	login, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	assert.Empty(t, login.Ticket)
+
+	_, err = auth.ConfirmTOTP(ctx, token, passwordProof(t, auth, "name@example.com", "wrong"), totp.Code(secret, now),
+		"")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = auth.ConfirmTOTP(ctx, token, passwordProof(t, auth, "name@example.com", "password"),
+		totp.Code([]byte("other secret"), now), "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	backupCodes, err := auth.ConfirmTOTP(ctx, token, passwordProof(t, auth, "name@example.com", "password"),
+		totp.Code(secret, now), "")
+	require.NoError(t, err)
+	assert.Len(t, backupCodes, backupCodesCount)
+
+	_, _, err = auth.EnrollTOTP(ctx, token, passwordProof(t, auth, "name@example.com", "password"), "")
+	assert.ErrorIs(t, err, ErrTOTPEnabled)
+
+	login, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	require.NotEmpty(t, login.Ticket)
+	assert.Empty(t, login.Tokens.AccessToken)
+
+	_, err = auth.VerifyLogin(ctx, login.Ticket, totp.Code(secret, now), models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "confirmation code can not be reused")
+
+	now = now.Add(totp.Period)
+	verified, err := auth.VerifyLogin(ctx, login.Ticket, totp.Code(secret, now), models.Device{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, verified.Tokens.AccessToken)
+	_, err = auth.VerifyLogin(ctx, login.Ticket, totp.Code(secret, now), models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "ticket works once")
+
+	// backup code works once, separators and case are ignored
+	login, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	_, err = auth.VerifyLogin(ctx, login.Ticket, strings.ToUpper(backupCodes[0]), models.Device{})
+	require.NoError(t, err)
+	login, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	_, err = auth.VerifyLogin(ctx, login.Ticket, backupCodes[0], models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// the ticket stops working after too many wrong codes
+	for i := 1; i < maxTicketAttempts; i++ {
+		_, err = auth.VerifyLogin(ctx, login.Ticket, "000000", models.Device{})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, err = auth.VerifyLogin(ctx, login.Ticket, backupCodes[1], models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	login, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	now = now.Add(ticketTTL + time.Second)
+	_, err = auth.VerifyLogin(ctx, login.Ticket, totp.Code(secret, now), models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "expired ticket")
+}
+
+func TestBackupCode(t *testing.T) {
+	seen := make(map[rune]bool)
+	for i := 0; i < 100; i++ {
+		code, err := backupCode()
+		require.NoError(t, err)
+		require.Regexp(t, "^[a-z2-9]{5}-[a-z2-9]{5}$", code)
+		for _, c := range strings.ReplaceAll(code, "-", "") {
+			require.Contains(t, backupCodeAlphabet, string(c))
+			seen[c] = true
+		}
+	}
+	assert.Len(t, seen, len(backupCodeAlphabet), "every character of the alphabet is used")
+}
+
+func TestTOTPThrottling(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+	device := models.Device{Address: "10.0.0.1"}
+
+	register(t, auth, "name@example.com", "password")
+	login, _, err := clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, device)
+	require.NoError(t, err)
+	token := login.Tokens.AccessToken
+	_, _, err = auth.EnrollTOTP(ctx, token, passwordProof(t, auth, "name@example.com", "wrong"), device.Address)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	secret, _, err := auth.EnrollTOTP(ctx, token, passwordProof(t, auth, "name@example.com", "password"),
+		device.Address)
+	require.NoError(t, err)
+	_, err = auth.ConfirmTOTP(ctx, token, passwordProof(t, auth, "name@example.com", "password"),
+		totp.Code(secret, now), device.Address)
+	require.NoError(t, err)
+
+	// wrong codes with new tickets are counted, the accepted password does not reset them
+	for i := 0; i < 2; i++ {
+		login, _, err := clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, device)
+		require.NoError(t, err)
+		_, err = auth.VerifyLogin(ctx, login.Ticket, "000000", device)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	var locked *LockedError
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, device)
+	require.ErrorAs(t, err, &locked)
+
+	// the right code after the lockout resets failures of the account
+	now = now.Add(locked.RetryAfter + totp.Period)
+	login, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, device)
+	require.NoError(t, err)
+	_, err = auth.VerifyLogin(ctx, login.Ticket, totp.Code(secret, now), device)
+	require.NoError(t, err)
+	failures, err := auth.throttleProvider.LoginFailures(ctx, "account:name@example.com")
+	require.NoError(t, err)
+	assert.Zero(t, failures.Failures)
+}
+
+func TestLoginThrottling(t *testing.T) {
+	auth := newTestAuth(t)
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+
+	for _, email := range []string{"name@example.com", "other@example.com"} {
+		register(t, auth, email, "password")
+	}
+
+	// account is locked after free attempts, lockout doubles with every failure
+	for i := 0; i < 3; i++ {
+		_, _, err := clientLogin(t, auth, "name@example.com", "wrong", 1, testAppSecret,
+			models.Device{Address: "10.0.0.1"})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	var locked *LockedError
+	_, _, err := clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret,
+		models.Device{Address: "10.0.0.2"})
+	require.ErrorAs(t, err, &locked, "correct password is refused while account is locked")
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+	assert.Equal(t, time.Minute, locked.RetryAfter)
+
+	now = now.Add(time.Minute)
+	_, _, err = clientLogin(t, auth, "name@example.com", "wrong", 1, testAppSecret, models.Device{Address: "10.0.0.1"})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret,
+		models.Device{Address: "10.0.0.2"})
+	require.ErrorAs(t, err, &locked)
+	assert.Equal(t, 2*time.Minute, locked.RetryAfter)
+
+	// lockout is limited, successful login resets failures of the account
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 5; i++ {
+		_, _, err = clientLogin(t, auth, "name@example.com", "wrong", 1, testAppSecret,
+			models.Device{Address: "10.0.0.3"})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		now = now.Add(10 * time.Minute)
+	}
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret,
+		models.Device{Address: "10.0.0.2"})
+	require.NoError(t, err)
+	_, _, err = clientLogin(t, auth, "name@example.com", "wrong", 1, testAppSecret, models.Device{Address: "10.0.0.2"})
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "free attempts are restored")
+
+	// address is locked after failures on many accounts
+	for i := 0; i < 5; i++ {
+		_, _, err = clientLogin(t, auth, fmt.Sprintf("guess%d@example.com", i), "wrong", 1, testAppSecret,
+			models.Device{Address: "10.0.0.4"})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, _, err = clientLogin(t, auth, "other@example.com", "password", 1, testAppSecret,
+		models.Device{Address: "10.0.0.4"})
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+	_, _, err = clientLogin(t, auth, "other@example.com", "password", 1, testAppSecret,
+		models.Device{Address: "10.0.0.5"})
+	require.NoError(t, err)
+
+	// failures are forgotten after the window
+	now = now.Add(failureWindow + time.Hour)
+	for i := 0; i < 2; i++ {
+		_, _, err = clientLogin(t, auth, "other@example.com", "wrong", 1, testAppSecret, models.Device{})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, _, err = clientLogin(t, auth, "other@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+
+	register(t, auth, "name@example.com", "old password")
+	other, _, err := clientLogin(t, auth, "name@example.com", "old password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	login, _, err := clientLogin(t, auth, "name@example.com", "old password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	token := login.Tokens.AccessToken
+
+	proof := func(password string) models.PasswordProof {
+		return passwordProof(t, auth, "name@example.com", password)
+	}
+	newPassword := newVerifier(t, "name@example.com", "new password")
+	change := func(token string, proof models.PasswordProof, code string, vaultKey []byte,
+		verifier models.PasswordVerifier, appSecret string) error {
+		return auth.ChangePassword(ctx, token, proof, code, "new password", vaultKey, verifier, 1, appSecret, "10.0.0.1")
+	}
+	assert.ErrorIs(t, change("not a token", proof("old password"), "", []byte("wrapped"), newPassword, testAppSecret),
+		ErrInvalidCredentials)
+	assert.ErrorIs(t, change(token, proof("old password"), "", []byte("wrapped"), newPassword, "wrong secret"),
+		ErrInvalidApp)
+	assert.ErrorIs(t, change(token, proof("wrong"), "", []byte("wrapped"), newPassword, testAppSecret),
+		ErrInvalidCredentials)
+	assert.ErrorIs(t, change(token, proof("old password"), "", []byte("wrapped"), models.PasswordVerifier{},
+		testAppSecret), ErrInvalidData)
+	assert.ErrorIs(t, change(token, proof("old password"), "", nil, newPassword, testAppSecret), ErrInvalidData)
+
+	// the second factor is required, when it is enabled
+	secret, _, err := auth.EnrollTOTP(ctx, token, proof("old password"), "")
+	require.NoError(t, err)
+	_, err = auth.ConfirmTOTP(ctx, token, proof("old password"), totp.Code(secret, now), "")
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	last := proof("old password")
+	assert.ErrorIs(t, change(token, proof("old password"), "", []byte("wrapped"), newPassword, testAppSecret),
+		ErrInvalidCredentials)
+	assert.ErrorIs(t, change(token, proof("wrong"), totp.Code(secret, now), []byte("wrapped"), newPassword,
+		testAppSecret), ErrInvalidCredentials)
+	var locked *LockedError
+	require.ErrorAs(t, change(token, last, totp.Code(secret, now), []byte("wrapped"), newPassword, testAppSecret),
+		&locked, "failed attempts lock the account")
+
+	now = now.Add(locked.RetryAfter + totp.Period)
+	require.NoError(t, change(token, proof("old password"), totp.Code(secret, now), []byte("wrapped"), newPassword,
+		testAppSecret))
+
+	// other sessions are revoked, the current session stays, the user logs in with the new password
+	_, err = auth.Refresh(ctx, other.Tokens.RefreshToken, 1, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = auth.Refresh(ctx, login.Tokens.RefreshToken, 1, "")
+	assert.NoError(t, err)
+	relogin, _, err := clientLogin(t, auth, "name@example.com", "new password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, relogin.Ticket)
+	_, _, err = clientLogin(t, auth, "name@example.com", "old password", 1, testAppSecret, models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestEmailVerification(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	emails := auth.emailProvider.(*memEmails)
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+
+	_, err := auth.Register(ctx, "name@example.com", "password", newVerifier(t, "name@example.com", "password"), "")
+	require.NoError(t, err)
+	require.Len(t, emails.mails, 1)
+	code := emails.lastCode(t, "name@example.com")
+
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+	assert.Len(t, emails.mails, 2, "verification mail is sent again")
+	assert.ErrorIs(t, auth.VerifyEmail(ctx, code), ErrInvalidCredentials, "previous code stops working")
+
+	code = emails.lastCode(t, "name@example.com")
+	now = now.Add(verifyTokenTTL + time.Second)
+	assert.ErrorIs(t, auth.VerifyEmail(ctx, code), ErrInvalidCredentials, "expired code")
+
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+	code = emails.lastCode(t, "name@example.com")
+	require.NoError(t, auth.VerifyEmail(ctx, code))
+	assert.ErrorIs(t, auth.VerifyEmail(ctx, code), ErrInvalidCredentials, "code works once")
+
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+}
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	emails := auth.emailProvider.(*memEmails)
+
+	register(t, auth, "name@example.com", "forgotten")
+	session, _, err := clientLogin(t, auth, "name@example.com", "forgotten", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	require.NoError(t, auth.SetRecovery(ctx, session.Tokens.AccessToken,
+		passwordProof(t, auth, "name@example.com", "forgotten"), "", make([]byte, sha256.Size), ""))
+
+	sent := len(emails.mails)
+	require.NoError(t, auth.RequestPasswordReset(ctx, "missing@example.com", ""), "unknown email is not revealed")
+	assert.Len(t, emails.mails, sent)
+
+	require.NoError(t, auth.RequestPasswordReset(ctx, "name@example.com", ""))
+	code := emails.lastCode(t, "name@example.com")
+	assert.ErrorIs(t, auth.VerifyEmail(ctx, code), ErrInvalidCredentials, "reset code does not verify email")
+	assert.ErrorIs(t, auth.ResetPassword(ctx, code, "new password", models.PasswordVerifier{}, ""), ErrInvalidData)
+	require.NoError(t, auth.ResetPassword(ctx, code, "new password",
+		newVerifier(t, "name@example.com", "new password"), ""))
+	assert.ErrorIs(t, auth.ResetPassword(ctx, code, "other password",
+		newVerifier(t, "name@example.com", "other password"), ""), ErrInvalidCredentials)
+
+	_, err = auth.Refresh(ctx, session.Tokens.RefreshToken, 1, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "sessions are revoked")
+
+	login, _, err := clientLogin(t, auth, "name@example.com", "new password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	assert.Empty(t, login.VaultKey, "vault key wrapped with the old password is removed")
+	user, err := auth.userProvider.User(ctx, "name@example.com")
+	require.NoError(t, err)
+	assert.NotEmpty(t, user.RecoveryVerifier, "recovery kit restores the old vault")
+}
+
+func TestRequestPasswordResetThrottling(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+	emails := auth.emailProvider.(*memEmails)
+	register(t, auth, "name@example.com", "password")
+	sent := len(emails.mails)
+
+	// every request uses an attempt of the email
+	for i := 0; i < 3; i++ {
+		require.NoError(t, auth.RequestPasswordReset(ctx, "name@example.com", "10.0.0.1"))
+	}
+	var locked *LockedError
+	err := auth.RequestPasswordReset(ctx, "name@example.com", "10.0.0.2")
+	require.ErrorAs(t, err, &locked)
+	assert.Equal(t, time.Minute, locked.RetryAfter)
+	assert.Len(t, emails.mails, sent+3, "no mail is sent while requests are locked")
+
+	// requests do not lock login
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret,
+		models.Device{Address: "10.0.0.1"})
+	require.NoError(t, err)
+
+	// unknown emails use attempts of the address too
+	for i := 0; i < 2; i++ {
+		require.NoError(t, auth.RequestPasswordReset(ctx, fmt.Sprintf("guess%d@example.com", i), "10.0.0.1"))
+	}
+	err = auth.RequestPasswordReset(ctx, "other@example.com", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, auth.RequestPasswordReset(ctx, "name@example.com", "10.0.0.3"))
+	assert.Len(t, emails.mails, sent+4)
+}
+
+// memBreached is in-memory BreachedRanges and password.BreachChecker,
+// it maps breached passwords to number of breaches.
+type memBreached map[string]int
+
+func (m memBreached) Count(password string) (int, error) {
+	return m[password], nil
+}
+
+func (m memBreached) Range(prefix string) (password.Range, error) {
+	if len(prefix) != password.PrefixLen {
+		return password.Range{}, password.ErrInvalidPrefix
+	}
+	r := password.Range{Prefix: prefix, Counts: make(map[string]int)}
+	for breached, count := range m {
+		sum := sha1.Sum([]byte(breached))
+		if hash := strings.ToUpper(hex.EncodeToString(sum[:])); hash[:password.PrefixLen] == prefix {
+			r.Counts[hash[password.PrefixLen:]] = count
+		}
+	}
+	return r, nil
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	auth := newTestAuth(t)
+	auth.passwords = password.Policy{MinLength: 12, MinClasses: 2, MinEntropy: 40}
+
+	policy, breached, err := auth.PasswordPolicy(password.HashPrefix("Correct-Horse-Battery-9"))
+	require.NoError(t, err)
+	assert.Equal(t, auth.passwords, policy)
+	assert.Empty(t, breached.Counts, "check of breached passwords is disabled")
+
+	auth.breached = memBreached{"Correct-Horse-Battery-9": 42}
+	_, _, err = auth.PasswordPolicy("XYZ")
+	assert.ErrorIs(t, err, ErrInvalidData)
+
+	// the client checks the new password with the range of its hash prefix
+	policy, breached, err = auth.PasswordPolicy(password.HashPrefix("Correct-Horse-Battery-9"))
+	require.NoError(t, err)
+	assert.Len(t, breached.Counts, 1)
+	policy.Breached = breached
+	var weak *password.Error
+	require.ErrorAs(t, policy.Check("Correct-Horse-Battery-9", "name@example.com"), &weak)
+	assert.Contains(t, weak.Error(), "data breaches")
+
+	policy, breached, err = auth.PasswordPolicy(password.HashPrefix("k9#Lm2$pQ7!z-vault"))
+	require.NoError(t, err)
+	policy.Breached = breached
+	assert.NoError(t, policy.Check("k9#Lm2$pQ7!z-vault", "name@example.com"))
+}
+
+func TestCheckPassword(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	auth.passwords = password.Policy{
+		MinLength:  12,
+		MinClasses: 2,
+		MinEntropy: 40,
+		Breached:   memBreached{"Correct-Horse-Battery-9": 42},
+	}
+
+	// the server checks new passwords again, clients may skip their check
+	registerWith := func(pw string, verifier models.PasswordVerifier) error {
+		_, err := auth.Register(ctx, "name@example.com", pw, verifier, "")
+		return err
+	}
+	err := registerWith("short1", newVerifier(t, "name@example.com", "short1"))
+	assert.ErrorIs(t, err, ErrInvalidData)
+	var weak *password.Error
+	require.ErrorAs(t, err, &weak)
+	assert.NotEmpty(t, weak.Violations)
+	err = registerWith("Correct-Horse-Battery-9", newVerifier(t, "name@example.com", "Correct-Horse-Battery-9"))
+	assert.ErrorIs(t, err, ErrInvalidData, "breached password")
+	err = registerWith("name@example.com1", newVerifier(t, "name@example.com", "name@example.com1"))
+	assert.ErrorIs(t, err, ErrInvalidData, "password guessed from the email")
+	err = registerWith("k9#Lm2$pQ7!z-vault", newVerifier(t, "name@example.com", "short1"))
+	assert.ErrorIs(t, err, ErrInvalidData, "verifier of another password")
+	assert.False(t, errors.As(err, &weak))
+
+	register(t, auth, "name@example.com", "k9#Lm2$pQ7!z-vault")
+	login, _, err := srpLogin(t, auth, "name@example.com", "k9#Lm2$pQ7!z-vault")
+	require.NoError(t, err)
+	err = auth.ChangePassword(ctx, login.Tokens.AccessToken, passwordProof(t, auth, "name@example.com",
+		"k9#Lm2$pQ7!z-vault"), "", "passwordpassword", []byte("wrapped"),
+		newVerifier(t, "name@example.com", "passwordpassword"), 1, testAppSecret, "")
+	assert.ErrorIs(t, err, ErrInvalidData)
+	require.ErrorAs(t, err, &weak)
+
+	require.NoError(t, auth.RequestPasswordReset(ctx, "name@example.com", ""))
+	code := auth.emailProvider.(*memEmails).lastCode(t, "name@example.com")
+	err = auth.ResetPassword(ctx, code, "passwordpassword", newVerifier(t, "name@example.com", "passwordpassword"), "")
+	assert.ErrorIs(t, err, ErrInvalidData)
+	require.ErrorAs(t, err, &weak)
+}
+
+func TestApps(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+
+	register(t, auth, "name@example.com", "password")
+
+	_, _, err := auth.CreateApp(ctx, "")
+	assert.ErrorIs(t, err, ErrInvalidData)
+	_, _, err = auth.CreateApp(ctx, "gophkeeper")
+	assert.ErrorIs(t, err, ErrAppExists)
+
+	app, secret, err := auth.CreateApp(ctx, "mobile")
+	require.NoError(t, err)
+	assert.NotEqual(t, 1, app.ID, "applications have separate ids")
+	assert.NotEqual(t, []byte(secret), app.SecretHash, "secret is stored hashed")
+
+	apps, err := auth.Apps(ctx)
+	require.NoError(t, err)
+	assert.Len(t, apps, 2)
+
+	for range 5 {
+		_, _, err = clientLogin(t, auth, "name@example.com", "password", app.ID, "wrong", models.Device{})
+		assert.ErrorIs(t, err, ErrInvalidApp)
+	}
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", 42, secret, models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidApp)
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", app.ID, secret, models.Device{})
+	require.NoError(t, err, "invalid application does not lock the account")
+
+	rotated, err := auth.RotateAppSecret(ctx, app.ID)
+	require.NoError(t, err)
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", app.ID, secret, models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidApp, "old secret stops working")
+	login, _, err := clientLogin(t, auth, "name@example.com", "password", app.ID, rotated, models.Device{})
+	require.NoError(t, err)
+	_, err = auth.RotateAppSecret(ctx, 42)
+	assert.ErrorIs(t, err, ErrAppNotFound)
+
+	other, _, err := clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+
+	require.NoError(t, auth.DisableApp(ctx, app.ID))
+	assert.ErrorIs(t, auth.DisableApp(ctx, 42), ErrAppNotFound)
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", app.ID, rotated, models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidApp)
+	_, err = auth.Refresh(ctx, login.Tokens.RefreshToken, app.ID, "")
+	assert.Error(t, err, "sessions of the disabled application are revoked")
+	_, err = auth.Refresh(ctx, other.Tokens.RefreshToken, 1, "")
+	assert.NoError(t, err, "other applications keep working")
+}
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+
+	register(t, auth, "name@example.com", "password")
+	register(t, auth, "other@example.com", "password")
+
+	laptop, _, err := clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret,
+		models.Device{Name: "laptop", Address: "10.0.0.1"})
+	require.NoError(t, err)
+	phone, _, err := clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret,
+		models.Device{Address: "10.0.0.2"})
+	require.NoError(t, err)
+	other, _, err := clientLogin(t, auth, "other@example.com", "password", 1, testAppSecret,
+		models.Device{Name: "desktop"})
+	require.NoError(t, err)
+
+	_, _, err = auth.Sessions(ctx, "not a token")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	sessions, current, err := auth.Sessions(ctx, laptop.Tokens.AccessToken)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2, "sessions of other users are not listed")
+	assert.Equal(t, "laptop", sessions[0].Device)
+	assert.Equal(t, sessions[0].ID, current)
+	assert.Equal(t, unknownDevice, sessions[1].Device)
+
+	phone.Tokens, err = auth.Refresh(ctx, phone.Tokens.RefreshToken, 1, "10.0.0.3")
+	require.NoError(t, err)
+	sessions, current, err = auth.Sessions(ctx, phone.Tokens.AccessToken)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2, "refresh keeps the session")
+	assert.Equal(t, sessions[1].ID, current)
+	assert.Equal(t, "10.0.0.3", sessions[1].Address)
+
+	otherSessions, _, err := auth.Sessions(ctx, other.Tokens.AccessToken)
+	require.NoError(t, err)
+	assert.ErrorIs(t, auth.RevokeSession(ctx, laptop.Tokens.AccessToken, otherSessions[0].ID), ErrSessionNotFound,
+		"session of another user")
+
+	require.NoError(t, auth.RevokeSession(ctx, laptop.Tokens.AccessToken, current))
+	_, err = auth.Refresh(ctx, phone.Tokens.RefreshToken, 1, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, _, err = auth.Sessions(ctx, phone.Tokens.AccessToken)
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "access token of the revoked session")
+
+	revoked, _, err := auth.RevokedSessions(ctx, 0, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{current}, revoked)
+
+	sessions, _, err = auth.Sessions(ctx, laptop.Tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
+
+func TestAuditEvents(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+
+	register(t, auth, "name@example.com", "password")
+	register(t, auth, "other@example.com", "password")
+	login, _, err := clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret,
+		models.Device{Address: "10.0.0.1"})
+	require.NoError(t, err)
+	token := login.Tokens.AccessToken
+
+	now = now.Add(time.Minute)
+	for range 3 {
+		_, _, err = clientLogin(t, auth, "name@example.com", "wrong", 1, testAppSecret,
+			models.Device{Address: "10.0.0.9"})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret,
+		models.Device{Address: "10.0.0.9"})
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+	_, _, err = clientLogin(t, auth, "missing@example.com", "password", 1, testAppSecret,
+		models.Device{Address: "10.0.0.9"})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, _, err = auth.AuditEvents(ctx, "not a token", models.AuditFilter{}, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	events, next, err := auth.AuditEvents(ctx, token, models.AuditFilter{}, "")
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, events, 6, "events of other accounts are not listed")
+	assert.Equal(t, models.AuditEvent{ID: events[0].ID, Type: models.AuditLogin, UserID: events[0].UserID,
+		Email: "name@example.com", AppID: 1, Address: "10.0.0.9", Outcome: models.OutcomeLocked, CreatedAt: now}, events[0])
+	for _, event := range events[1:4] {
+		assert.Equal(t, models.OutcomeFailure, event.Outcome, "failed login is linked to the account")
+	}
+	assert.Equal(t, models.OutcomeSuccess, events[4].Outcome)
+	assert.Equal(t, "10.0.0.1", events[4].Address)
+	assert.Equal(t, models.AuditRegister, events[5].Type)
+
+	failed, _, err := auth.AuditEvents(ctx, token, models.AuditFilter{Types: []string{models.AuditLogin},
+		Outcome: models.OutcomeFailure}, "")
+	require.NoError(t, err)
+	assert.Equal(t, events[1:4], failed)
+	recent, _, err := auth.AuditEvents(ctx, token, models.AuditFilter{Since: now}, "")
+	require.NoError(t, err)
+	assert.Equal(t, events[:4], recent)
+
+	page, next, err := auth.AuditEvents(ctx, token, models.AuditFilter{Limit: 4}, "")
+	require.NoError(t, err)
+	assert.Equal(t, events[:4], page)
+	require.NotEmpty(t, next)
+	page, next, err = auth.AuditEvents(ctx, token, models.AuditFilter{Limit: 4}, next)
+	require.NoError(t, err)
+	assert.Equal(t, events[4:], page)
+	assert.Empty(t, next)
+
+	_, _, err = auth.AuditEvents(ctx, token, models.AuditFilter{}, "not a page")
+	assert.ErrorIs(t, err, ErrInvalidData)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+
+	register(t, auth, "name@example.com", "password")
+	register(t, auth, "other@example.com", "password")
+	login, _, err := clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	require.NoError(t, err)
+	token := login.Tokens.AccessToken
+	proof := func(password string) models.PasswordProof {
+		return passwordProof(t, auth, "name@example.com", password)
+	}
+	secret, _, err := auth.EnrollTOTP(ctx, token, proof("password"), "")
+	require.NoError(t, err)
+	_, err = auth.ConfirmTOTP(ctx, token, proof("password"), totp.Code(secret, now), "")
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+
+	assert.ErrorIs(t, auth.DeleteAccount(ctx, "not a token", proof("password"), "", ""), ErrInvalidCredentials)
+	assert.ErrorIs(t, auth.DeleteAccount(ctx, token, proof("wrong"), totp.Code(secret, now), ""),
+		ErrInvalidCredentials)
+	assert.ErrorIs(t, auth.DeleteAccount(ctx, token, proof("password"), "", ""), ErrInvalidCredentials,
+		"second factor is required")
+	assert.ErrorIs(t, auth.DeleteAccount(ctx, token, passwordProof(t, auth, "other@example.com", "password"),
+		totp.Code(secret, now), ""), ErrInvalidCredentials, "proof for the handshake of another account")
+
+	require.NoError(t, auth.DeleteAccount(ctx, token, proof("password"), totp.Code(secret, now), ""))
+	_, _, err = clientLogin(t, auth, "name@example.com", "password", 1, testAppSecret, models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = auth.Refresh(ctx, login.Tokens.RefreshToken, 1, "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	assert.ErrorIs(t, auth.DeleteAccount(ctx, token, models.PasswordProof{}, "", ""), ErrInvalidCredentials)
+
+	_, _, err = clientLogin(t, auth, "other@example.com", "password", 1, testAppSecret, models.Device{})
+	assert.NoError(t, err, "other accounts are kept")
+
+	deleted, until, err := auth.DeletedAccounts(ctx, 0, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []models.DeletedAccount{{UserID: 1, DeletedAt: now, Seq: 1}}, deleted)
+	assert.Equal(t, int64(1), until)
+	deleted, _, err = auth.DeletedAccounts(ctx, until, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+}
+
+// newVerifier returns SRP verifier of the password as the client computes it.
+func newVerifier(t *testing.T, email string, password string) models.PasswordVerifier {
+	t.Helper()
+
+	salt, err := srp.NewSalt()
+	require.NoError(t, err)
+	return models.PasswordVerifier{Salt: salt, Verifier: srp.Verifier(email, salt, passwordSecret(password, salt))}
+}
+
+// passwordSecrets caches secrets derived from passwords, the derivation is slow by design.
+var passwordSecrets = make(map[string][]byte)
+
+// passwordSecret returns srp.Secret of the password and the salt.
+func passwordSecret(password string, salt []byte) []byte {
+	key := password + "\x00" + string(salt)
+	if _, ok := passwordSecrets[key]; !ok {
+		passwordSecrets[key] = srp.Secret(password, salt)
+	}
+	return passwordSecrets[key]
+}
+
+// srpLogin runs SRP login as the client does and returns the result and the client session.
+func srpLogin(t *testing.T, auth *Auth, email string, password string) (models.Login, srp.Session, error) {
+	t.Helper()
+
+	return clientLogin(t, auth, email, password, 1, testAppSecret, models.Device{Address: "10.0.0.1"})
+}
+
+// clientLogin runs SRP login with the application and the device as the client does.
+func clientLogin(t *testing.T, auth *Auth, email string, password string, appID int, appSecret string,
+	device models.Device) (models.Login, srp.Session, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	challenge, err := auth.BeginLogin(ctx, email, appID, appSecret, device.Address)
+	if err != nil {
+		return models.Login{}, srp.Session{}, err
+	}
+	proof, session := clientProof(t, email, password, challenge)
+
+	login, err := auth.FinishLogin(ctx, proof, device)
+	return login, session, err
+}
+
+// clientProof computes proof of the password for the challenge as the client does.
+func clientProof(t *testing.T, email string, password string,
+	challenge models.LoginChallenge) (models.PasswordProof, srp.Session) {
+	t.Helper()
+
+	private, public, err := srp.NewClient()
+	require.NoError(t, err)
+	session, err := srp.ClientSession(email, challenge.Salt, passwordSecret(password, challenge.Salt), private,
+		challenge.ServerPublic)
+	require.NoError(t, err)
+	return models.PasswordProof{Handshake: challenge.Handshake, ClientPublic: public,
+		ClientProof: session.ClientProof}, session
+}
+
+// passwordProof returns proof of the password for a new handshake, requests requiring the password again carry it.
+func passwordProof(t *testing.T, auth *Auth, email string, password string) models.PasswordProof {
+	t.Helper()
+
+	challenge, err := auth.BeginLogin(context.Background(), email, 1, testAppSecret, "")
+	require.NoError(t, err)
+	proof, _ := clientProof(t, email, password, challenge)
+	return proof
+}
+
+func TestSRPLogin(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+	users := auth.userProvider.(*memUsers)
+
+	_, err := auth.Register(ctx, "name@example.com", "password",
+		models.PasswordVerifier{Salt: []byte("short"), Verifier: []byte{1}}, "")
+	assert.ErrorIs(t, err, ErrInvalidData)
+	_, err = auth.Register(ctx, "name@example.com", "password", models.PasswordVerifier{}, "")
+	assert.ErrorIs(t, err, ErrInvalidData, "verifier is required")
+	_, err = auth.Register(ctx, "name@example.com", "password", newVerifier(t, "name@example.com", "password"), "")
+	require.NoError(t, err)
+	require.NoError(t, auth.VerifyEmail(ctx, auth.emailProvider.(*memEmails).lastCode(t, "name@example.com")))
+	user, err := users.User(ctx, "name@example.com")
+	require.NoError(t, err)
+	require.NoError(t, users.update(user.ID, func(user *models.User) { user.VaultKey = []byte("wrapped") }))
+
+	login, session, err := srpLogin(t, auth, "name@example.com", "password")
+	require.NoError(t, err)
+	assert.True(t, session.VerifyServer(login.ServerProof))
+	_, _, err = auth.Sessions(ctx, login.Tokens.AccessToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, []byte("wrapped"), login.VaultKey, "vault key is encrypted for the client of the session")
+	vaultKey, err := encrypt.Decrypt(session.SealKey(), login.VaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("wrapped"), vaultKey)
+
+	_, _, err = srpLogin(t, auth, "name@example.com", "wrong")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	failures, err := auth.throttleProvider.LoginFailures(ctx, "account:name@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures.Failures, "wrong proof is a failed login")
+
+	// handshake is finished once, replayed messages of the client are refused
+	challenge, err := auth.BeginLogin(ctx, "name@example.com", 1, testAppSecret, "")
+	require.NoError(t, err)
+	proof, _ := clientProof(t, "name@example.com", "password", challenge)
+	_, err = auth.FinishLogin(ctx, proof, models.Device{})
+	require.NoError(t, err)
+	_, err = auth.FinishLogin(ctx, proof, models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	proof = passwordProof(t, auth, "name@example.com", "password")
+	proof.ClientPublic = nil
+	_, err = auth.FinishLogin(ctx, proof, models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidData)
+
+	proof = passwordProof(t, auth, "name@example.com", "password")
+	now = now.Add(handshakeTTL + time.Second)
+	_, err = auth.FinishLogin(ctx, proof, models.Device{})
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "handshake expired")
+
+	_, err = auth.BeginLogin(ctx, "name@example.com", 1, "wrong secret", "")
+	assert.ErrorIs(t, err, ErrInvalidApp)
+}
+
+func TestSRPLoginUnknownEmail(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+
+	first, err := auth.BeginLogin(ctx, "missing@example.com", 1, testAppSecret, "")
+	require.NoError(t, err)
+	second, err := auth.BeginLogin(ctx, "missing@example.com", 1, testAppSecret, "")
+	require.NoError(t, err)
+	assert.Equal(t, first.Salt, second.Salt, "unknown email has stable salt")
+	assert.Len(t, first.Salt, srp.SaltLen)
+	other, err := auth.BeginLogin(ctx, "other@example.com", 1, testAppSecret, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Salt, other.Salt)
+
+	_, _, err = srpLogin(t, auth, "missing@example.com", "password")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestSRPLoginWithoutVerifier(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	users := auth.userProvider.(*memUsers)
+
+	// account saved before passwords were replaced with verifiers
+	passHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	_, err = users.SaveUser(ctx, "name@example.com", models.PasswordVerifier{})
+	require.NoError(t, err)
+	require.NoError(t, users.update(1, func(user *models.User) {
+		user.PassHash = passHash
+		user.EmailVerified = true
+		user.VaultKey = []byte("wrapped")
+	}))
+
+	first, err := auth.BeginLogin(ctx, "name@example.com", 1, testAppSecret, "")
+	require.NoError(t, err)
+	second, err := auth.BeginLogin(ctx, "name@example.com", 1, testAppSecret, "")
+	require.NoError(t, err)
+	assert.Equal(t, first.Salt, second.Salt, "account without verifier looks like unknown email")
+	assert.Equal(t, auth.fakeVerifier("name@example.com").Salt, first.Salt)
+
+	_, _, err = srpLogin(t, auth, "name@example.com", "password")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// fake salts are derived from the persistent key, they do not change after restarts
+	restarted := newTestAuth(t)
+	_, err = restarted.userProvider.(*memUsers).SaveUser(ctx, "name@example.com", models.PasswordVerifier{})
+	require.NoError(t, err)
+	third, err := restarted.BeginLogin(ctx, "name@example.com", 1, testAppSecret, "")
+	require.NoError(t, err)
+	assert.Equal(t, first.Salt, third.Salt)
+
+	// the client logs in with the password once, hash of the password is replaced with the verifier
+	passwordLogin := func(password string, verifier models.PasswordVerifier) (models.Login, error) {
+		return auth.Login(ctx, "name@example.com", password, 1, testAppSecret, models.Device{}, verifier)
+	}
+	verifier := newVerifier(t, "name@example.com", "password")
+	_, err = passwordLogin("wrong", newVerifier(t, "name@example.com", "wrong"))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = passwordLogin("password", models.PasswordVerifier{})
+	assert.ErrorIs(t, err, ErrInvalidData, "verifier is required")
+	_, err = passwordLogin("password", newVerifier(t, "name@example.com", "wrong"))
+	assert.ErrorIs(t, err, ErrInvalidData, "verifier of another password")
+	_, err = auth.Login(ctx, "missing@example.com", "password", 1, testAppSecret, models.Device{}, verifier)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	login, err := passwordLogin("password", verifier)
+	require.NoError(t, err)
+	assert.NotEmpty(t, login.Tokens.AccessToken)
+	assert.Equal(t, []byte("wrapped"), login.VaultKey, "vault key is kept")
+	user, err := users.User(ctx, "name@example.com")
+	require.NoError(t, err)
+	assert.Empty(t, user.PassHash)
+	assert.Equal(t, verifier.Verifier, user.SRPVerifier)
+
+	_, err = passwordLogin("password", verifier)
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "account with verifier logs in only with SRP")
+	login, session, err := srpLogin(t, auth, "name@example.com", "password")
+	require.NoError(t, err)
+	vaultKey, err := encrypt.Decrypt(session.SealKey(), login.VaultKey)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("wrapped"), vaultKey)
+}
+
+func TestSRPLoginTOTP(t *testing.T) {
+	ctx := context.Background()
+	auth := newTestAuth(t)
+	now := time.Unix(1_700_000_000, 0)
+	auth.now = func() time.Time { return now }
+	users := auth.userProvider.(*memUsers)
+
+	register(t, auth, "name@example.com", "password")
+	login, _, err := srpLogin(t, auth, "name@example.com", "password")
+	require.NoError(t, err)
+	secret, _, err := auth.EnrollTOTP(ctx, login.Tokens.AccessToken, passwordProof(t, auth, "name@example.com",
+		"password"), "")
+	require.NoError(t, err)
+	_, err = auth.ConfirmTOTP(ctx, login.Tokens.AccessToken, passwordProof(t, auth, "name@example.com", "password"),
+		totp.Code(secret, now), "")
+	require.NoError(t, err)
+	user, err := users.User(ctx, "name@example.com")
+	require.NoError(t, err)
+	require.NoError(t, users.update(user.ID, func(user *models.User) { user.VaultKey = []byte("wrapped") }))
+
+	login, session, err := srpLogin(t, auth, "name@example.com", "password")
+	require.NoError(t, err)
+	require.NotEmpty(t, login.Ticket)
+	assert.True(t, session.VerifyServer(login.ServerProof))
+
+	now = now.Add(totp.Period)
+	verified, err := auth.VerifyLogin(ctx, login.Ticket, totp.Code(secret, now), models.Device{})
+	require.NoError(t, err)
+	vaultKey, err := encrypt.Decrypt(session.SealKey(), verified.VaultKey)
+	require.NoError(t, err, "vault key is encrypted with the key of SRP session after the second factor")
+	assert.Equal(t, []byte("wrapped"), vaultKey)
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/internal/auth/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+const (
+	verifyTokenTTL = 24 * time.Hour
+	resetTokenTTL  = time.Hour
+)
+
+var ErrEmailNotVerified = errors.New("email is not verified")
+
+// VerifyEmail marks email of the user as verified with the token sent on registration.
+// It returns ErrInvalidCredentials, if the token is unknown, used or expired.
+func (a *Auth) VerifyEmail(ctx context.Context, token string) error {
+	const op = "auth.VerifyEmail"
+
+	t, err := a.useEmailToken(ctx, token, models.EmailVerify)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.userProvider.SetEmailVerified(ctx, t.UserID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.log.Info("email verified", slog.String("op", op), slog.Int64("user_id", t.UserID))
+	return nil
+}
+
+// RequestPasswordReset sends password reset token to the email of the user.
+// Unknown emails are ignored, so the response does not tell, whether the email is registered.
+// Every request uses an attempt of the email and of clientAddr, unknown emails too,
+// it returns LockedError, while requests are locked.
+func (a *Auth) RequestPasswordReset(ctx context.Context, email string, clientAddr string) error {
+	const op = "auth.RequestPasswordReset"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("username", email),
+	)
+
+	if email == "" {
+		return fmt.Errorf("%s, %w", "email is required", ErrInvalidData)
+	}
+
+	keys := a.resetThrottleKeys(email, clientAddr)
+	if err := a.checkLocked(ctx, keys); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.loginFailed(ctx, keys); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.User(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("password reset of unknown user requested")
+			return nil
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.sendEmailToken(ctx, user, models.PasswordReset); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password reset requested")
+	return nil
+}
+
+// ResetPassword sets new password of the user with the token sent by RequestPasswordReset.
+// Master key of the vault can not be unwrapped without the old password, so it is removed:
+// the user starts new vault unless they restore the old one with the recovery kit.
+// verifier is SRP verifier of the new password, the password is checked against the password policy and the verifier.
+// The token is used up also when the password is rejected.
+// All sessions of the user are revoked, email of the user is verified.
+// Reset is recorded in the audit log with clientAddr.
+// It returns ErrInvalidCredentials, if the token is unknown, used or expired.
+func (a *Auth) ResetPassword(ctx context.Context, token string, password string, verifier models.PasswordVerifier,
+	clientAddr string) error {
+	const op = "auth.ResetPassword"
+
+	if password == "" {
+		return fmt.Errorf("%s, %w", "password is required", ErrInvalidData)
+	}
+	if err := validateVerifier(verifier); err != nil {
+		return err
+	}
+
+	t, err := a.useEmailToken(ctx, token, models.PasswordReset)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := a.userProvider.UserByID(ctx, t.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.checkPassword(user.Email, password, verifier); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.userProvider.UpdatePassword(ctx, t.UserID, verifier, nil, true, ""); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.log.Info("password reset", slog.String("op", op), slog.Int64("user_id", t.UserID))
+	a.audit(ctx, models.AuditEvent{Type: models.AuditPasswordReset, UserID: t.UserID, Address: clientAddr,
+		Outcome: models.OutcomeSuccess})
+	return nil
+}
+
+// sendEmailToken puts mail with new token of the purpose into the outbox.
+func (a *Auth) sendEmailToken(ctx context.Context, user models.User, purpose string) error {
+	token, err := randomString(32)
+	if err != nil {
+		return err
+	}
+
+	ttl, mail := verifyTokenTTL, models.Mail{
+		To:      user.Email,
+		Subject: "Confirm your email for GophKeeper",
+		Body: fmt.Sprintf("Your verification code: %s\n\nEnter it in the GophKeeper client, the code expires in %s.",
+			token, verifyTokenTTL),
+	}
+	if purpose == models.PasswordReset {
+		ttl, mail.Subject = resetTokenTTL, "Reset your GophKeeper password"
+		mail.Body = fmt.Sprintf("Your password reset code: %s\n\nEnter it in the GophKeeper client, the code expires in %s.\n"+
+			"If you did not request password reset, ignore this mail.", token, resetTokenTTL)
+	}
+
+	return a.emailProvider.SaveEmailToken(ctx, models.EmailToken{
+		Hash:      hashToken(token),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: a.now().Add(ttl),
+	}, mail)
+}
+
+// useEmailToken returns token of the purpose, the token can not be used again.
+func (a *Auth) useEmailToken(ctx context.Context, token string, purpose string) (models.EmailToken, error) {
+	t, err := a.emailProvider.UseEmailToken(ctx, hashToken(token), purpose)
+	if err != nil {
+		if errors.Is(err, storage.ErrEmailTokenNotFound) {
+			return models.EmailToken{}, ErrInvalidCredentials
+		}
+		return models.EmailToken{}, err
+	}
+	if a.now().After(t.ExpiresAt) {
+		return models.EmailToken{}, ErrInvalidCredentials
+	}
+	return t, nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/jwt"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// unknownDevice is name of the device, which did not send its name on login.
+const unknownDevice = "unknown device"
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Sessions returns active sessions of the user, who owns the access token, and id of the session of the token.
+// It returns ErrInvalidCredentials, if the token is invalid or its session has ended.
+func (a *Auth) Sessions(ctx context.Context, accessToken string) ([]models.Session, string, error) {
+	const op = "auth.Sessions"
+
+	claims, sessions, err := a.currentSessions(ctx, accessToken)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	return sessions, claims.SessionID, nil
+}
+
+// RevokeSession ends the session of the user, who owns the access token, the session can be the current one.
+// Refresh tokens of the session are revoked, the keeper server closes connections of the session.
+// It returns ErrSessionNotFound, if the user has no such active session.
+func (a *Auth) RevokeSession(ctx context.Context, accessToken string, sessionID string) error {
+	const op = "auth.RevokeSession"
+
+	claims, sessions, err := a.currentSessions(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !hasSession(sessions, sessionID) {
+		return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+	}
+	if err := a.tokenProvider.RevokeFamily(ctx, sessionID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.log.Info("session revoked", slog.String("op", op), slog.Int64("user_id", claims.User.ID),
+		slog.Bool("current", sessionID == claims.SessionID))
+	return nil
+}
+
+// RevokedSessions returns ids of sessions ended after the position after and the position to poll the next sessions
+// after. When after is zero, sessions ended after since are returned.
+func (a *Auth) RevokedSessions(ctx context.Context, after int64, since time.Time) ([]string, int64, error) {
+	const op = "auth.RevokedSessions"
+
+	ids, until, err := a.sessionProvider.RevokedSessions(ctx, after, since)
+	if err != nil {
+		return nil, after, fmt.Errorf("%s: %w", op, err)
+	}
+	return ids, until, nil
+}
+
+// currentSessions returns claims of the access token and active sessions of its user.
+// Access token of the ended session is refused, though it has not expired.
+func (a *Auth) currentSessions(ctx context.Context, accessToken string) (jwt.Claims, []models.Session, error) {
+	claims, err := jwt.ParseClaimsAt(accessToken, a.publicKey, a.now())
+	if err != nil {
+		return jwt.Claims{}, nil, ErrInvalidCredentials
+	}
+	sessions, err := a.sessionProvider.Sessions(ctx, claims.User.ID)
+	if err != nil {
+		return jwt.Claims{}, nil, err
+	}
+	if !hasSession(sessions, claims.SessionID) {
+		return jwt.Claims{}, nil, ErrInvalidCredentials
+	}
+	return claims, sessions, nil
+}
+
+// publicKey returns public key of the signing key kid.
+func (a *Auth) publicKey(kid string) (ed25519.PublicKey, error) {
+	key, ok := a.signer.PublicKeys()[kid]
+	if !ok {
+		return nil, jwt.ErrUnknownKey
+	}
+	return key, nil
+}
+
+func hasSession(sessions []models.Session, id string) bool {
+	for _, session := range sessions {
+		if id != "" && session.ID == id {
+			return true
+		}
+	}
+	return false
+}
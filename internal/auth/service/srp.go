@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/internal/auth/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/encrypt"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/SmoothWay/gophkeeper/pkg/srp"
+)
+
+const (
+	// handshakeTTL is time to finish SRP login after it is started.
+	handshakeTTL = time.Minute
+	// maxSaltLen limits salt of the verifier sent by the client.
+	maxSaltLen = 64
+	// sealKeyID marks the vault key encrypted with the key of SRP session.
+	sealKeyID = "srp"
+)
+
+// BeginLogin starts SRP login of the user: it returns salt of the verifier and ephemeral public key of the server.
+// The password does not leave the client, login is finished with FinishLogin and proof of the password.
+// Requests, which require the password again, carry the proof for the handshake instead of the password.
+// Unknown email and account without verifier get stable fake salt, so the response does not tell whether
+// the account exists, their proofs fail as wrong passwords do.
+// It returns LockedError, while login is locked,
+// and ErrInvalidApp, if the application is unknown, disabled or appSecret does not match.
+func (a *Auth) BeginLogin(ctx context.Context, email string, appID int, appSecret string,
+	clientAddr string) (models.LoginChallenge, error) {
+	const op = "auth.BeginLogin"
+
+	if email == "" {
+		return models.LoginChallenge{}, fmt.Errorf("%s, %w", "email is required", ErrInvalidData)
+	}
+
+	app, err := a.authenticateApp(ctx, appID, appSecret)
+	if err != nil {
+		return models.LoginChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.checkLocked(ctx, a.throttleKeys(email, clientAddr)); err != nil {
+		a.auditLogin(ctx, email, app.ID, clientAddr, err)
+		return models.LoginChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.User(ctx, email)
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		return models.LoginChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+	verifier := models.PasswordVerifier{Salt: user.SRPSalt, Verifier: user.SRPVerifier}
+	if len(user.SRPVerifier) == 0 {
+		verifier = a.fakeVerifier(email)
+	}
+
+	private, public, err := srp.NewServer(verifier.Verifier)
+	if err != nil {
+		return models.LoginChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+	handshake, err := randomString(32)
+	if err != nil {
+		return models.LoginChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+	err = a.factorProvider.SaveHandshake(ctx, models.Handshake{
+		Hash:       hashToken(handshake),
+		Email:      email,
+		AppID:      app.ID,
+		PrivateKey: private,
+		ExpiresAt:  a.now().Add(handshakeTTL),
+	})
+	if err != nil {
+		return models.LoginChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return models.LoginChallenge{Handshake: handshake, Salt: verifier.Salt, ServerPublic: public}, nil
+}
+
+// FinishLogin checks proof of the password computed by the client for the handshake started with BeginLogin.
+// It returns access and refresh tokens and master key of the vault wrapped with the password and encrypted
+// with the key of SRP session, and proof of the server, the client checks it to make sure the server knows
+// the verifier. If the user has enabled two-factor authentication, only login ticket is returned,
+// login is completed with VerifyLogin. Login starts new session of the device.
+// Every handshake can be finished once. Failed proofs are counted as failed logins.
+// It returns ErrInvalidCredentials, if the handshake is unknown or expired or the proof does not match,
+// ErrInvalidData, if public key of the client is invalid, ErrEmailNotVerified, if the user has not verified
+// the email, and LockedError, while login is locked.
+func (a *Auth) FinishLogin(ctx context.Context, proof models.PasswordProof, device models.Device) (models.Login, error) {
+	const op = "auth.FinishLogin"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("client_addr", device.Address),
+	)
+
+	h, err := a.takeHandshake(ctx, proof.Handshake)
+	if err != nil {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log = log.With(slog.String("username", h.Email))
+
+	keys := a.throttleKeys(h.Email, device.Address)
+	if err := a.checkLocked(ctx, keys); err != nil {
+		a.auditLogin(ctx, h.Email, h.AppID, device.Address, err)
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// unknown user has no verifier, the proof is checked anyway and fails as the wrong password does
+	user, err := a.userProvider.User(ctx, h.Email)
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	session, err := checkProof(h, user, proof)
+	if errors.Is(err, ErrInvalidCredentials) {
+		log.Warn("failed login attempt")
+		a.auditLogin(ctx, h.Email, h.AppID, device.Address, ErrInvalidCredentials)
+		if err := a.loginFailed(ctx, keys); err != nil {
+			return models.Login{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return models.Login{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+	if err != nil {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	app, err := a.enabledApp(ctx, h.AppID)
+	if err != nil {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	login, err := a.completeLogin(ctx, log, user, app, device, session.SealKey())
+	if err != nil {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	login.ServerProof = session.ServerProof
+
+	return login, nil
+}
+
+// takeHandshake returns the handshake started with BeginLogin, every handshake can be taken once.
+// It returns ErrInvalidCredentials, if the handshake is unknown or expired.
+func (a *Auth) takeHandshake(ctx context.Context, handshake string) (models.Handshake, error) {
+	h, err := a.factorProvider.TakeHandshake(ctx, hashToken(handshake))
+	if err != nil {
+		if errors.Is(err, storage.ErrHandshakeNotFound) {
+			return models.Handshake{}, ErrInvalidCredentials
+		}
+		return models.Handshake{}, err
+	}
+	if a.now().After(h.ExpiresAt) {
+		return models.Handshake{}, ErrInvalidCredentials
+	}
+	return h, nil
+}
+
+// checkProof checks proof of the password of the user for the handshake h.
+// Unknown user has no verifier, the proof is checked anyway and fails as the wrong password does.
+// It returns ErrInvalidCredentials, if the proof does not match, and ErrInvalidData, if public key of the client
+// is invalid.
+func checkProof(h models.Handshake, user models.User, proof models.PasswordProof) (srp.Session, error) {
+	session, err := srp.ServerSession(h.Email, user.SRPSalt, user.SRPVerifier, h.PrivateKey, proof.ClientPublic)
+	if err != nil {
+		return srp.Session{}, fmt.Errorf("%w: %w", ErrInvalidData, err)
+	}
+	if len(user.SRPVerifier) == 0 || !session.VerifyClient(proof.ClientProof) {
+		return srp.Session{}, ErrInvalidCredentials
+	}
+	return session, nil
+}
+
+// fakeVerifier returns verifier of the unknown email, it is the same for every login attempt.
+// It is derived from the persistent secret, so it does not change after restarts.
+func (a *Auth) fakeVerifier(email string) models.PasswordVerifier {
+	mac := hmac.New(sha256.New, a.fakeKey)
+	mac.Write([]byte(email))
+	sum := mac.Sum(nil)
+
+	salt := sum[:srp.SaltLen]
+	return models.PasswordVerifier{Salt: salt, Verifier: srp.Verifier(email, salt, sum[srp.SaltLen:])}
+}
+
+// sealVaultKey encrypts the vault key with the key of SRP session, so only the client of the session can read it.
+// Login with the password has no session key, the vault key is returned as is then.
+func sealVaultKey(sealKey []byte, vaultKey []byte) ([]byte, error) {
+	if len(sealKey) == 0 || len(vaultKey) == 0 {
+		return vaultKey, nil
+	}
+	return encrypt.Encrypt(sealKey, sealKeyID, vaultKey)
+}
+
+// validateVerifier checks verifier of the new password sent by the client.
+func validateVerifier(verifier models.PasswordVerifier) error {
+	if len(verifier.Salt) < srp.SaltLen || len(verifier.Salt) > maxSaltLen || !srp.ValidVerifier(verifier.Verifier) {
+		return fmt.Errorf("%s, %w", "password verifier is invalid", ErrInvalidData)
+	}
+	return nil
+}
+
+// matchVerifier checks that verifier sent by the client is SRP verifier of the password.
+func matchVerifier(email string, password string, verifier models.PasswordVerifier) error {
+	secret := srp.Secret(password, verifier.Salt)
+	if subtle.ConstantTimeCompare(srp.Verifier(email, verifier.Salt, secret), verifier.Verifier) != 1 {
+		return fmt.Errorf("%s, %w", "password verifier does not match the password", ErrInvalidData)
+	}
+	return nil
+}
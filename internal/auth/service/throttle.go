@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// failureWindow is time, after which failed logins are forgotten.
+const failureWindow = 24 * time.Hour
+
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// LockedError is returned, when login of the account or from the client address is locked after failed attempts.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter)
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// Throttling limits failed logins. After free attempts login is locked for Lockout,
+// lockout doubles with every next failure up to MaxLockout.
+// Address limit is higher than account limit, many users can share the address.
+type Throttling struct {
+	AccountAttempts int
+	AddressAttempts int
+	Lockout         time.Duration
+	MaxLockout      time.Duration
+}
+
+// throttleKey is counter of failed logins with free attempts.
+type throttleKey struct {
+	key      string
+	attempts int
+}
+
+func (a *Auth) throttleKeys(email string, clientAddr string) []throttleKey {
+	keys := []throttleKey{{key: "account:" + strings.ToLower(email), attempts: a.throttling.AccountAttempts}}
+	if clientAddr != "" {
+		keys = append(keys, throttleKey{key: "address:" + clientAddr, attempts: a.throttling.AddressAttempts})
+	}
+	return keys
+}
+
+// resetThrottleKeys returns counters of password reset requests, they are separate from failed logins.
+func (a *Auth) resetThrottleKeys(email string, clientAddr string) []throttleKey {
+	keys := a.throttleKeys(email, clientAddr)
+	for i := range keys {
+		keys[i].key = "reset:" + keys[i].key
+	}
+	return keys
+}
+
+// checkLocked returns LockedError, if login by any of the keys is locked.
+func (a *Auth) checkLocked(ctx context.Context, keys []throttleKey) error {
+	now := a.now()
+	for _, k := range keys {
+		failures, err := a.throttleProvider.LoginFailures(ctx, k.key)
+		if err != nil {
+			return err
+		}
+		if now.Before(failures.LockedUntil) {
+			return &LockedError{RetryAfter: failures.LockedUntil.Sub(now).Round(time.Second)}
+		}
+	}
+	return nil
+}
+
+// loginFailed counts failed login and locks keys, which have used free attempts.
+func (a *Auth) loginFailed(ctx context.Context, keys []throttleKey) error {
+	now := a.now()
+	for _, k := range keys {
+		failures, err := a.throttleProvider.AddLoginFailure(ctx, k.key, now, now.Add(-failureWindow))
+		if err != nil {
+			return err
+		}
+		if failures < k.attempts {
+			continue
+		}
+		if err := a.throttleProvider.LockLogin(ctx, k.key, now.Add(a.lockout(failures-k.attempts))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockout returns lockout after n failures over the free attempts.
+func (a *Auth) lockout(n int) time.Duration {
+	lockout := a.throttling.Lockout
+	for i := 0; i < n && lockout < a.throttling.MaxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, a.throttling.MaxLockout)
+}
+
+// loginSucceeded forgets failures of the account after the user passed all factors.
+// Failures of the address are forgotten only when they expire,
+// so login to own account does not help to guess passwords of other accounts.
+func (a *Auth) loginSucceeded(ctx context.Context, email string) error {
+	return a.throttleProvider.ResetLoginFailures(ctx, a.throttleKeys(email, "")[0].key)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/SmoothWay/gophkeeper/internal/auth/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// Refresh exchanges refresh token for new access and refresh tokens, the used refresh token stops working.
+// Refresh token used the second time is considered stolen, then all tokens of its family are revoked.
+// Address of the client and time of the refresh are saved as last seen of the session.
+// It returns ErrInvalidCredentials, if refresh token is unknown, expired, revoked or reused.
+func (a *Auth) Refresh(ctx context.Context, refreshToken string, appID int, clientAddr string) (models.Tokens, error) {
+	const op = "auth.Refresh"
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	used, err := a.tokenProvider.Token(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return models.Tokens{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return models.Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log = log.With(slog.Int64("user_id", used.UserID))
+
+	switch {
+	case used.Revoked, used.AppID != appID, a.now().After(used.ExpiresAt):
+		return models.Tokens{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	case used.Used:
+		return models.Tokens{}, fmt.Errorf("%s: %w", op, a.reused(ctx, log, used))
+	}
+
+	user, err := a.userProvider.UserByID(ctx, used.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.Tokens{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return models.Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	app, err := a.enabledApp(ctx, appID)
+	if err != nil {
+		return models.Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, refresh, err := a.issueTokens(user, app, used.Family)
+	if err != nil {
+		return models.Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.tokenProvider.RotateToken(ctx, used.ID, refresh); err != nil {
+		if errors.Is(err, storage.ErrTokenUsed) {
+			// concurrent request has used the token first
+			return models.Tokens{}, fmt.Errorf("%s: %w", op, a.reused(ctx, log, used))
+		}
+		return models.Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.sessionProvider.TouchSession(ctx, used.Family, clientAddr, a.now()); err != nil {
+		return models.Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Debug("tokens refreshed")
+	return tokens, nil
+}
+
+// Logout revokes refresh token and all tokens rotated from the same login, so the session ends.
+// Access tokens issued earlier stay valid until they expire.
+func (a *Auth) Logout(ctx context.Context, refreshToken string) error {
+	const op = "auth.Logout"
+
+	token, err := a.tokenProvider.Token(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.tokenProvider.RevokeFamily(ctx, token.Family); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.log.Info("user logged out", slog.String("op", op), slog.Int64("user_id", token.UserID))
+	return nil
+}
+
+// PublicKeys returns public keys, which verify access tokens, by key id.
+func (a *Auth) PublicKeys() map[string]ed25519.PublicKey {
+	return a.signer.PublicKeys()
+}
+
+// reused revokes family of the refresh token used twice.
+func (a *Auth) reused(ctx context.Context, log *slog.Logger, token models.RefreshToken) error {
+	log.Warn("refresh token reuse detected, revoking all tokens of the login")
+
+	if err := a.tokenProvider.RevokeFamily(ctx, token.Family); err != nil {
+		return err
+	}
+	return ErrInvalidCredentials
+}
+
+// issueTokens returns new access and refresh tokens of the user, refresh token is returned for saving.
+func (a *Auth) issueTokens(user models.User, app models.App, family string) (models.Tokens, models.RefreshToken, error) {
+	now := a.now()
+	access, err := a.signer.NewToken(user, app, family, now, a.tokenTTL)
+	if err != nil {
+		return models.Tokens{}, models.RefreshToken{}, err
+	}
+	refresh, err := randomString(32)
+	if err != nil {
+		return models.Tokens{}, models.RefreshToken{}, err
+	}
+
+	tokens := models.Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresAt:    now.Add(a.tokenTTL),
+	}
+	token := models.RefreshToken{
+		Hash:      hashToken(refresh),
+		Family:    family,
+		UserID:    user.ID,
+		AppID:     app.ID,
+		ExpiresAt: now.Add(a.refreshTTL),
+	}
+
+	return tokens, token, nil
+}
+
+// hashToken returns hash of the refresh token, tokens are random, so hash does not need salt.
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/internal/auth/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/SmoothWay/gophkeeper/pkg/totp"
+)
+
+const (
+	// Issuer is shown by authenticator apps next to the account.
+	Issuer = "GophKeeper"
+	// ticketTTL is time to enter the second factor code after the password is accepted.
+	ticketTTL = 5 * time.Minute
+	// maxTicketAttempts limits guessing of the code with one ticket.
+	maxTicketAttempts = 5
+	backupCodesCount  = 10
+	// backupCodeAlphabet has no similar looking characters.
+	backupCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// VerifyLogin completes login of the user with two-factor authentication.
+// code is the current code of the authenticator app or one of the backup codes, each backup code works once.
+// Session of the device is started, when the code is accepted.
+// It returns ErrInvalidCredentials, if the ticket is unknown, expired or used up, or the code is wrong.
+func (a *Auth) VerifyLogin(ctx context.Context, ticket string, code string, device models.Device) (models.Login, error) {
+	const op = "auth.VerifyLogin"
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	hash := hashToken(ticket)
+	t, err := a.factorProvider.Ticket(ctx, hash)
+	if err != nil {
+		if errors.Is(err, storage.ErrTicketNotFound) {
+			return models.Login{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	log = log.With(slog.Int64("user_id", t.UserID))
+
+	if a.now().After(t.ExpiresAt) || t.Attempts >= maxTicketAttempts {
+		if err := a.factorProvider.DeleteTicket(ctx, hash); err != nil {
+			return models.Login{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return models.Login{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, t.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.Login{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// wrong codes are counted as failed logins, so the account is locked after failures with many tickets
+	keys := a.throttleKeys(user.Email, device.Address)
+	if err := a.checkLocked(ctx, keys); err != nil {
+		a.auditLogin(ctx, user.Email, t.AppID, device.Address, err)
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.checkCode(ctx, user, code); err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			log.Warn("invalid second factor code")
+			a.audit(ctx, models.AuditEvent{Type: models.AuditSecondFactor, UserID: user.ID, Email: user.Email,
+				AppID: t.AppID, Address: device.Address, Outcome: models.OutcomeFailure})
+			if err := a.factorProvider.AddTicketAttempt(ctx, hash); err != nil {
+				return models.Login{}, fmt.Errorf("%s: %w", op, err)
+			}
+			if err := a.loginFailed(ctx, keys); err != nil {
+				return models.Login{}, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.factorProvider.DeleteTicket(ctx, hash); err != nil {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.loginSucceeded(ctx, user.Email); err != nil {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.enabledApp(ctx, t.AppID)
+	if err != nil {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	login, err := a.login(ctx, user, app, device)
+	if err != nil {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if login.VaultKey, err = sealVaultKey(t.SealKey, login.VaultKey); err != nil {
+		return models.Login{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user logged in with second factor")
+	a.audit(ctx, models.AuditEvent{Type: models.AuditSecondFactor, UserID: user.ID, Email: user.Email, AppID: app.ID,
+		Address: device.Address, Outcome: models.OutcomeSuccess})
+	a.audit(ctx, models.AuditEvent{Type: models.AuditLogin, UserID: user.ID, Email: user.Email, AppID: app.ID,
+		Address: device.Address, Outcome: models.OutcomeSuccess})
+	return login, nil
+}
+
+// EnrollTOTP generates new second factor secret of the user, who owns the access token, and returns it
+// with provisioning URI for authenticator apps, the user proves the password again.
+// Second factor is not required until it is confirmed with ConfirmTOTP.
+// It returns ErrTOTPEnabled, if the user has already enabled two-factor authentication.
+// Wrong proofs are counted as failed logins of the account and of clientAddr.
+func (a *Auth) EnrollTOTP(ctx context.Context, accessToken string, proof models.PasswordProof,
+	clientAddr string) (secret []byte, uri string, err error) {
+	const op = "auth.EnrollTOTP"
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	user, err := a.sessionUser(ctx, accessToken)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	log = log.With(slog.Int64("user_id", user.ID))
+	if user.TOTPEnabled {
+		return nil, "", fmt.Errorf("%s: %w", op, ErrTOTPEnabled)
+	}
+	err = a.checkReauthentication(ctx, user, proof, "", clientAddr, models.AuditSecondFactor)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.userProvider.SetTOTP(ctx, user.ID, secret, false); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("two-factor authentication enrolled")
+	return secret, totp.URI(Issuer, user.Email, secret), nil
+}
+
+// ConfirmTOTP enables two-factor authentication, when code shows that the authenticator app has the enrolled secret.
+// The user, who owns the access token, proves the password again.
+// It returns backup codes, which replace the app once each, they are shown to the user only once.
+// Wrong proofs and codes are counted as failed logins of the account and of clientAddr.
+func (a *Auth) ConfirmTOTP(ctx context.Context, accessToken string, proof models.PasswordProof, code string,
+	clientAddr string) ([]string, error) {
+	const op = "auth.ConfirmTOTP"
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	user, err := a.sessionUser(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	log = log.With(slog.Int64("user_id", user.ID))
+	switch {
+	case user.TOTPEnabled:
+		return nil, fmt.Errorf("%s: %w", op, ErrTOTPEnabled)
+	case len(user.TOTPSecret) == 0:
+		return nil, fmt.Errorf("%s, %w", "two-factor authentication is not enrolled", ErrInvalidData)
+	}
+	err = a.checkReauthentication(ctx, user, proof, "", clientAddr, models.AuditSecondFactor)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	counter, ok := totp.Validate(user.TOTPSecret, code, a.now())
+	if !ok {
+		log.Warn("invalid second factor code")
+		if err := a.loginFailed(ctx, a.throttleKeys(user.Email, clientAddr)); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	codes := make([]string, backupCodesCount)
+	hashes := make([][]byte, backupCodesCount)
+	for i := range codes {
+		if codes[i], err = backupCode(); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		hashes[i] = hashBackupCode(codes[i])
+	}
+	if err := a.factorProvider.SaveBackupCodes(ctx, user.ID, hashes); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.userProvider.SetTOTP(ctx, user.ID, user.TOTPSecret, true); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// the confirmation code can not be used for login
+	if err := a.userProvider.UseTOTPCounter(ctx, user.ID, counter); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("two-factor authentication enabled")
+	return codes, nil
+}
+
+// newTicket saves and returns ticket of the login waiting for the second factor.
+func (a *Auth) newTicket(ctx context.Context, user models.User, app models.App, sealKey []byte) (string, error) {
+	ticket, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	err = a.factorProvider.SaveTicket(ctx, models.LoginTicket{
+		Hash:      hashToken(ticket),
+		UserID:    user.ID,
+		AppID:     app.ID,
+		ExpiresAt: a.now().Add(ticketTTL),
+		SealKey:   sealKey,
+	})
+	if err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// checkCode checks code of the authenticator app or backup code of the user.
+// It returns ErrInvalidCredentials, if the code is wrong or was already used.
+func (a *Auth) checkCode(ctx context.Context, user models.User, code string) error {
+	code = normalizeCode(code)
+
+	if len(code) == totp.Digits {
+		counter, ok := totp.Validate(user.TOTPSecret, code, a.now())
+		if !ok {
+			return ErrInvalidCredentials
+		}
+		if err := a.userProvider.UseTOTPCounter(ctx, user.ID, counter); err != nil {
+			if errors.Is(err, storage.ErrCodeUsed) {
+				return ErrInvalidCredentials
+			}
+			return err
+		}
+		return nil
+	}
+
+	if err := a.factorProvider.UseBackupCode(ctx, user.ID, hashBackupCode(code)); err != nil {
+		if errors.Is(err, storage.ErrCodeNotFound) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+	return nil
+}
+
+// backupCode returns random code in the form "xxxxx-xxxxx".
+func backupCode() (string, error) {
+	// rand.Int picks every character with the same probability, byte modulo length of the alphabet would not
+	n := big.NewInt(int64(len(backupCodeAlphabet)))
+	b := make([]byte, 10)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		b[i] = backupCodeAlphabet[idx.Int64()]
+	}
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+// hashBackupCode returns hash of the backup code, codes are random, so hash does not need salt.
+func hashBackupCode(code string) []byte {
+	sum := sha256.Sum256([]byte(normalizeCode(code)))
+	return sum[:]
+}
+
+// normalizeCode removes separators, which users type or copy with the code.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
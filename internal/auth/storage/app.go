@@ -11,6 +11,10 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// appColumns is the row of models.App.
+const appColumns = "(id, name, secret_hash, disabled_at IS NOT NULL)"
+
+// App implements AppProvider interface.
 type App struct {
 	db      *pgxpool.Pool
 	timeout time.Duration
@@ -34,7 +38,7 @@ func (s *App) App(ctx context.Context, id int) (models.App, error) {
 	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	rows, err := s.db.Query(newCtx, "SELECT (id, name, secret) FROM apps WHERE id = $1", id)
+	rows, err := s.db.Query(newCtx, "SELECT "+appColumns+" FROM apps WHERE id = $1", id)
 	if err != nil {
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
@@ -48,6 +52,91 @@ func (s *App) App(ctx context.Context, id int) (models.App, error) {
 	return app, nil
 }
 
+// Apps returns all registered applications.
+func (s *App) Apps(ctx context.Context) ([]models.App, error) {
+	const op = "auth.storage.Apps"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx, "SELECT "+appColumns+" FROM apps ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	apps, err := pgx.CollectRows(rows, pgx.RowTo[models.App])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return apps, nil
+}
+
+// SaveApp registers new application and returns its id.
+// It returns ErrAppExists error, if application with the name is registered.
+func (s *App) SaveApp(ctx context.Context, name string, secretHash []byte) (int, error) {
+	const op = "auth.storage.SaveApp"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var id int
+	err := s.db.QueryRow(newCtx, "INSERT INTO apps (name, secret_hash) VALUES ($1, $2) RETURNING id", name, secretHash).Scan(&id)
+	if err != nil {
+		if isLoginExistError(err) {
+			return 0, fmt.Errorf("%s: %w", op, ErrAppExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+// SetAppSecret replaces secret of the application, the old secret stops working.
+func (s *App) SetAppSecret(ctx context.Context, id int, secretHash []byte) error {
+	const op = "auth.storage.SetAppSecret"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tag, err := s.db.Exec(newCtx, "UPDATE apps SET secret_hash = $1 WHERE id = $2", secretHash, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+	return nil
+}
+
+// DisableApp disables the application and revokes refresh tokens issued to it in one transaction.
+func (s *App) DisableApp(ctx context.Context, id int) error {
+	const op = "auth.storage.DisableApp"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.Begin(newCtx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(newCtx) }()
+
+	tag, err := tx.Exec(newCtx, "UPDATE apps SET disabled_at = coalesce(disabled_at, now()) WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+	_, err = tx.Exec(newCtx, "UPDATE refresh_tokens SET revoked_at = now() WHERE app_id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(newCtx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
 func (s *App) Close() {
 	s.db.Close()
 }
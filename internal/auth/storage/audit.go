@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Audit implements AuditProvider interface.
+type Audit struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewAudit(databaseURL string, timeout time.Duration) (*Audit, error) {
+	pool, err := newPool(databaseURL, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Audit{
+		db:      pool,
+		timeout: timeout,
+	}, nil
+}
+
+// SaveAuditEvent appends the event to the audit log.
+func (s *Audit) SaveAuditEvent(ctx context.Context, event models.AuditEvent) error {
+	const op = "auth.storage.SaveAuditEvent"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx, `INSERT INTO audit_events (type, user_id, email, app_id, address, outcome, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, event.Type, nullInt(event.UserID), event.Email, nullInt(int64(event.AppID)),
+		event.Address, event.Outcome, event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// AuditEvents returns events of the user selected by filter, newest first.
+func (s *Audit) AuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	const op = "auth.storage.AuditEvents"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	conds, args := []string{"user_id = $1"}, []any{filter.UserID}
+	where := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if len(filter.Types) > 0 {
+		where("type = ANY($%d)", filter.Types)
+	}
+	if filter.Outcome != "" {
+		where("outcome = $%d", filter.Outcome)
+	}
+	if !filter.Since.IsZero() {
+		where("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		where("created_at < $%d", filter.Until)
+	}
+	if filter.BeforeID > 0 {
+		where("id < $%d", filter.BeforeID)
+	}
+	args = append(args, filter.Limit)
+
+	rows, err := s.db.Query(newCtx, fmt.Sprintf(`SELECT (id, type, coalesce(user_id, 0), email, coalesce(app_id, 0),
+		address, outcome, created_at) FROM audit_events WHERE %s ORDER BY id DESC LIMIT $%d`,
+		strings.Join(conds, " AND "), len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	events, err := pgx.CollectRows(rows, pgx.RowTo[models.AuditEvent])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return events, nil
+}
+
+func (s *Audit) Close() {
+	s.db.Close()
+}
+
+// nullInt returns nil for zero id, so it is stored as NULL.
+func nullInt(id int64) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
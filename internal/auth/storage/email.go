@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Email implements EmailProvider interface and outbox of the mail dispatcher.
+type Email struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewEmail(databaseURL string, timeout time.Duration) (*Email, error) {
+	pool, err := newPool(databaseURL, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Email{
+		db:      pool,
+		timeout: timeout,
+	}, nil
+}
+
+// SaveEmailToken saves email token and puts mail with the token into the outbox in one transaction.
+// Previous tokens of the user with the same purpose stop working.
+func (s *Email) SaveEmailToken(ctx context.Context, token models.EmailToken, mail models.Mail) error {
+	const op = "auth.storage.SaveEmailToken"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.Begin(newCtx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(newCtx) }()
+
+	_, err = tx.Exec(newCtx, "DELETE FROM email_tokens WHERE user_id = $1 AND purpose = $2", token.UserID, token.Purpose)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = tx.Exec(newCtx, "INSERT INTO email_tokens (token_hash, user_id, purpose, expires_at) VALUES ($1, $2, $3, $4)",
+		token.Hash, token.UserID, token.Purpose, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = tx.Exec(newCtx, "INSERT INTO outbox (recipient, subject, body) VALUES ($1, $2, $3)", mail.To, mail.Subject, mail.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(newCtx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UseEmailToken deletes email token by hash and purpose and returns it, so the token works once.
+// It returns ErrEmailTokenNotFound error, if there is no such token.
+func (s *Email) UseEmailToken(ctx context.Context, hash []byte, purpose string) (models.EmailToken, error) {
+	const op = "auth.storage.UseEmailToken"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx, `DELETE FROM email_tokens WHERE token_hash = $1 AND purpose = $2
+		RETURNING (token_hash, user_id, purpose, expires_at)`, hash, purpose)
+	if err != nil {
+		return models.EmailToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	token, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[models.EmailToken])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.EmailToken{}, fmt.Errorf("%s: %w", op, ErrEmailTokenNotFound)
+		}
+		return models.EmailToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return token, nil
+}
+
+// PendingMail returns up to limit mails, which are not sent and have failed less than maxAttempts times.
+func (s *Email) PendingMail(ctx context.Context, limit int, maxAttempts int) ([]models.Mail, error) {
+	const op = "auth.storage.PendingMail"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx, `SELECT (id, recipient, subject, body) FROM outbox
+		WHERE sent_at IS NULL AND attempts < $1 ORDER BY id LIMIT $2`, maxAttempts, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	mails, err := pgx.CollectRows(rows, pgx.RowTo[models.Mail])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return mails, nil
+}
+
+// MarkSent marks mail as delivered to the mail server.
+func (s *Email) MarkSent(ctx context.Context, id int64) error {
+	const op = "auth.storage.MarkSent"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.db.Exec(newCtx, "UPDATE outbox SET sent_at = now(), attempts = attempts + 1 WHERE id = $1", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// MarkFailed counts failed delivery attempt of the mail.
+func (s *Email) MarkFailed(ctx context.Context, id int64, reason string) error {
+	const op = "auth.storage.MarkFailed"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx, "UPDATE outbox SET attempts = attempts + 1, last_error = $1 WHERE id = $2", reason, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Email) Close() {
+	s.db.Close()
+}
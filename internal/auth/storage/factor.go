@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SecondFactor implements SecondFactorProvider interface.
+type SecondFactor struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewSecondFactor(databaseURL string, timeout time.Duration) (*SecondFactor, error) {
+	pool, err := newPool(databaseURL, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &SecondFactor{
+		db:      pool,
+		timeout: timeout,
+	}, nil
+}
+
+// SaveBackupCodes replaces backup codes of the user with codes by hashes.
+func (s *SecondFactor) SaveBackupCodes(ctx context.Context, userID int64, hashes [][]byte) error {
+	const op = "auth.storage.SaveBackupCodes"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.Begin(newCtx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(newCtx) }()
+
+	if _, err := tx.Exec(newCtx, "DELETE FROM backup_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec(newCtx, "INSERT INTO backup_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(newCtx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UseBackupCode marks backup code of the user as used.
+// It returns ErrCodeNotFound error, if the user has no such unused code.
+func (s *SecondFactor) UseBackupCode(ctx context.Context, userID int64, hash []byte) error {
+	const op = "auth.storage.UseBackupCode"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tag, err := s.db.Exec(newCtx, `UPDATE backup_codes SET used_at = now()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, hash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrCodeNotFound)
+	}
+	return nil
+}
+
+// SaveTicket saves login ticket, expired tickets are removed.
+func (s *SecondFactor) SaveTicket(ctx context.Context, ticket models.LoginTicket) error {
+	const op = "auth.storage.SaveTicket"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.db.Exec(newCtx, "DELETE FROM login_tickets WHERE expires_at < now()"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err := s.db.Exec(newCtx, `INSERT INTO login_tickets (ticket_hash, user_id, app_id, expires_at, attempts, seal_key)
+		VALUES ($1, $2, $3, $4, $5, $6)`, ticket.Hash, ticket.UserID, ticket.AppID, ticket.ExpiresAt, ticket.Attempts,
+		nullBytes(ticket.SealKey))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Ticket returns login ticket by hash.
+// It returns ErrTicketNotFound error, if ticket with hash was not issued or was deleted.
+func (s *SecondFactor) Ticket(ctx context.Context, hash []byte) (models.LoginTicket, error) {
+	const op = "auth.storage.Ticket"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx, `SELECT (ticket_hash, user_id, app_id, expires_at, attempts,
+		coalesce(seal_key, ''::bytea)) FROM login_tickets WHERE ticket_hash = $1`, hash)
+	if err != nil {
+		return models.LoginTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+	ticket, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[models.LoginTicket])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.LoginTicket{}, fmt.Errorf("%s: %w", op, ErrTicketNotFound)
+		}
+		return models.LoginTicket{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return ticket, nil
+}
+
+// AddTicketAttempt counts failed attempt to complete login with the ticket.
+func (s *SecondFactor) AddTicketAttempt(ctx context.Context, hash []byte) error {
+	const op = "auth.storage.AddTicketAttempt"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx, "UPDATE login_tickets SET attempts = attempts + 1 WHERE ticket_hash = $1", hash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeleteTicket deletes login ticket, so it can not be used again.
+func (s *SecondFactor) DeleteTicket(ctx context.Context, hash []byte) error {
+	const op = "auth.storage.DeleteTicket"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.db.Exec(newCtx, "DELETE FROM login_tickets WHERE ticket_hash = $1", hash); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SaveHandshake saves started SRP login, expired handshakes are removed.
+func (s *SecondFactor) SaveHandshake(ctx context.Context, handshake models.Handshake) error {
+	const op = "auth.storage.SaveHandshake"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.db.Exec(newCtx, "DELETE FROM login_handshakes WHERE expires_at < now()"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	_, err := s.db.Exec(newCtx, `INSERT INTO login_handshakes (handshake_hash, email, app_id, private_key, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`, handshake.Hash, handshake.Email, handshake.AppID, handshake.PrivateKey,
+		handshake.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// TakeHandshake deletes SRP login by hash and returns it, so every handshake is finished once.
+// It returns ErrHandshakeNotFound error, if handshake with hash was not started or was taken.
+func (s *SecondFactor) TakeHandshake(ctx context.Context, hash []byte) (models.Handshake, error) {
+	const op = "auth.storage.TakeHandshake"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx, `DELETE FROM login_handshakes WHERE handshake_hash = $1
+		RETURNING (handshake_hash, email, app_id, private_key, expires_at)`, hash)
+	if err != nil {
+		return models.Handshake{}, fmt.Errorf("%s: %w", op, err)
+	}
+	handshake, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[models.Handshake])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Handshake{}, fmt.Errorf("%s: %w", op, ErrHandshakeNotFound)
+		}
+		return models.Handshake{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return handshake, nil
+}
+
+func (s *SecondFactor) Close() {
+	s.db.Close()
+}
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN IF NOT EXISTS vault_key BYTEA;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS recovery_verifier BYTEA;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS recovery_verifier;
+ALTER TABLE users DROP COLUMN IF EXISTS vault_key;
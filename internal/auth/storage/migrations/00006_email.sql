@@ -0,0 +1,33 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT false;
+-- accounts registered before verification was introduced are trusted
+UPDATE users SET email_verified = true;
+
+CREATE TABLE IF NOT EXISTS email_tokens
+(
+    token_hash BYTEA PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose    VARCHAR(16) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS email_tokens_user_idx ON email_tokens (user_id, purpose);
+
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id         BIGSERIAL PRIMARY KEY,
+    recipient  VARCHAR(255) NOT NULL,
+    subject    TEXT NOT NULL,
+    body       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at    TIMESTAMPTZ,
+    attempts   INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (id) WHERE sent_at IS NULL;
+
+-- +goose Down
+DROP TABLE outbox;
+DROP TABLE email_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified;
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS secret_hash BYTEA;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+-- application with the well-known secret seeded by the first migration is removed,
+-- applications are created with the admin API
+DELETE FROM apps WHERE secret = 'test-secret';
+-- secrets are kept only hashed
+UPDATE apps SET secret_hash = sha256(convert_to(secret, 'UTF8'));
+ALTER TABLE apps ALTER COLUMN secret_hash SET NOT NULL;
+ALTER TABLE apps DROP COLUMN secret;
+SELECT setval(pg_get_serial_sequence('apps', 'id'), coalesce(max(id), 0) + 1, false) FROM apps;
+
+-- +goose Down
+-- hashed secrets can not be restored, apps get new secrets equal to hex of the hash
+ALTER TABLE apps ADD COLUMN IF NOT EXISTS secret VARCHAR(255);
+UPDATE apps SET secret = encode(secret_hash, 'hex');
+ALTER TABLE apps ALTER COLUMN secret SET NOT NULL;
+ALTER TABLE apps ADD CONSTRAINT apps_secret_key UNIQUE (secret);
+ALTER TABLE apps DROP COLUMN created_at;
+ALTER TABLE apps DROP COLUMN disabled_at;
+ALTER TABLE apps DROP COLUMN secret_hash;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS sessions
+(
+    id           VARCHAR(64) PRIMARY KEY,
+    user_id      INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id       INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    device       VARCHAR(255) NOT NULL,
+    address      VARCHAR(64) NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+-- revoked sessions are polled by the keeper server to close their connections
+CREATE INDEX IF NOT EXISTS refresh_tokens_revoked_at_idx ON refresh_tokens (revoked_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS refresh_tokens_revoked_at_idx;
+DROP TABLE sessions;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN IF NOT EXISTS srp_salt BYTEA;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS srp_verifier BYTEA;
+ALTER TABLE login_tickets ADD COLUMN IF NOT EXISTS seal_key BYTEA;
+
+CREATE TABLE IF NOT EXISTS login_handshakes
+(
+    handshake_hash BYTEA PRIMARY KEY,
+    -- email is kept for unknown users too, so their logins fail the same way
+    email          VARCHAR(255) NOT NULL,
+    app_id         INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    private_key    BYTEA NOT NULL,
+    expires_at     TIMESTAMPTZ NOT NULL
+);
+
+-- +goose Down
+DROP TABLE login_handshakes;
+ALTER TABLE login_tickets DROP COLUMN IF EXISTS seal_key;
+ALTER TABLE users DROP COLUMN IF EXISTS srp_verifier;
+ALTER TABLE users DROP COLUMN IF EXISTS srp_salt;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- audit_events is append-only: the trigger refuses updates and deletes,
+-- events are not linked to users and apps to outlive them
+CREATE TABLE IF NOT EXISTS audit_events
+(
+    id         BIGSERIAL PRIMARY KEY,
+    type       VARCHAR(32) NOT NULL,
+    user_id    INTEGER,
+    email      VARCHAR(255) NOT NULL,
+    app_id     INTEGER,
+    address    VARCHAR(64) NOT NULL,
+    outcome    VARCHAR(16) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_user_id_idx ON audit_events (user_id, id);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- +goose Down
+DROP TABLE audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
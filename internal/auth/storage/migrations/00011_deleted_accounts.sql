@@ -0,0 +1,13 @@
+-- +goose Up
+-- deleted_accounts is polled by the keeper server to delete data of the users,
+-- records are kept, so the keeper server catches up after downtime
+CREATE TABLE IF NOT EXISTS deleted_accounts
+(
+    user_id    INTEGER PRIMARY KEY,
+    deleted_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS deleted_accounts_deleted_at_idx ON deleted_accounts (deleted_at);
+
+-- +goose Down
+DROP TABLE deleted_accounts;
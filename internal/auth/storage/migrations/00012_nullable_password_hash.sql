@@ -0,0 +1,8 @@
+-- +goose Up
+-- new passwords are kept only as SRP verifiers, hashes of old passwords are replaced on the first login,
+-- the column is dropped, when all accounts have verifiers
+ALTER TABLE users ALTER COLUMN password_hash DROP NOT NULL;
+
+-- +goose Down
+UPDATE users SET password_hash = '' WHERE password_hash IS NULL;
+ALTER TABLE users ALTER COLUMN password_hash SET NOT NULL;
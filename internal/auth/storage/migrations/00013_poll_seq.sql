@@ -0,0 +1,31 @@
+-- +goose Up
+-- seq is id of the transaction, which deleted the account or revoked the token, the keeper server polls records
+-- of finished transactions in seq order, so records committed late are not skipped as with time
+ALTER TABLE deleted_accounts ADD COLUMN seq xid8 NOT NULL DEFAULT pg_current_xact_id();
+CREATE INDEX IF NOT EXISTS deleted_accounts_seq_idx ON deleted_accounts (seq);
+
+ALTER TABLE refresh_tokens ADD COLUMN revoked_seq xid8;
+UPDATE refresh_tokens SET revoked_seq = pg_current_xact_id() WHERE revoked_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS refresh_tokens_revoked_seq_idx ON refresh_tokens (revoked_seq);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION refresh_tokens_revoked_seq() RETURNS trigger AS $$
+BEGIN
+    IF NEW.revoked_at IS NOT NULL AND OLD.revoked_at IS NULL THEN
+        NEW.revoked_seq := pg_current_xact_id();
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER refresh_tokens_revoked_seq BEFORE UPDATE ON refresh_tokens
+    FOR EACH ROW EXECUTE FUNCTION refresh_tokens_revoked_seq();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS refresh_tokens_revoked_seq ON refresh_tokens;
+DROP FUNCTION IF EXISTS refresh_tokens_revoked_seq();
+DROP INDEX IF EXISTS refresh_tokens_revoked_seq_idx;
+ALTER TABLE refresh_tokens DROP COLUMN revoked_seq;
+DROP INDEX IF EXISTS deleted_accounts_seq_idx;
+ALTER TABLE deleted_accounts DROP COLUMN seq;
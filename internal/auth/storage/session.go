@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Session implements SessionProvider interface.
+type Session struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewSession(databaseURL string, timeout time.Duration) (*Session, error) {
+	pool, err := newPool(databaseURL, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		db:      pool,
+		timeout: timeout,
+	}, nil
+}
+
+// SaveSession saves session started by login.
+func (s *Session) SaveSession(ctx context.Context, session models.Session) error {
+	const op = "auth.storage.SaveSession"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx, `INSERT INTO sessions (id, user_id, app_id, device, address, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, session.ID, session.UserID, session.AppID, session.Device, session.Address,
+		session.CreatedAt, session.LastSeenAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Sessions returns active sessions of the user, recently seen first.
+// Session is active, while it has refresh token, which is not used, revoked or expired.
+func (s *Session) Sessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	const op = "auth.storage.Sessions"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx, `SELECT (s.id, s.user_id, s.app_id, s.device, s.address, s.created_at, s.last_seen_at)
+		FROM sessions s WHERE s.user_id = $1 AND EXISTS (SELECT 1 FROM refresh_tokens t WHERE t.family = s.id
+			AND t.used_at IS NULL AND t.revoked_at IS NULL AND t.expires_at > now())
+		ORDER BY s.last_seen_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	sessions, err := pgx.CollectRows(rows, pgx.RowTo[models.Session])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return sessions, nil
+}
+
+// TouchSession saves time and address of the last refresh of the session.
+func (s *Session) TouchSession(ctx context.Context, id string, address string, at time.Time) error {
+	const op = "auth.storage.TouchSession"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.db.Exec(newCtx, "UPDATE sessions SET address = $1, last_seen_at = $2 WHERE id = $3", address, at, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RevokedSessions returns ids of sessions, which refresh tokens were revoked by finished transactions,
+// and the position to poll the next sessions after. Sessions revoked after the position after are returned,
+// when after is zero, sessions revoked after since.
+func (s *Session) RevokedSessions(ctx context.Context, after int64, since time.Time) ([]string, int64, error) {
+	const op = "auth.storage.RevokedSessions"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(newCtx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, after, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(newCtx) }()
+
+	horizon, err := pollHorizon(newCtx, tx)
+	if err != nil {
+		return nil, after, fmt.Errorf("%s: %w", op, err)
+	}
+	rows, err := tx.Query(newCtx, `SELECT DISTINCT family FROM refresh_tokens
+		WHERE revoked_seq < $1::bigint::text::xid8
+			AND CASE WHEN $2::bigint > 0 THEN revoked_seq > $2::bigint::text::xid8 ELSE revoked_at > $3 END`, horizon, after, since)
+	if err != nil {
+		return nil, after, fmt.Errorf("%s: %w", op, err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, after, fmt.Errorf("%s: %w", op, err)
+	}
+	return ids, max(after, horizon-1), nil
+}
+
+func (s *Session) Close() {
+	s.db.Close()
+}
@@ -10,9 +10,17 @@ import (
 )
 
 var (
-	ErrUserExists   = errors.New("user already exists")
-	ErrUserNotFound = errors.New("user not found")
-	ErrAppNotFound  = errors.New("app not found")
+	ErrUserExists         = errors.New("user already exists")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrAppNotFound        = errors.New("app not found")
+	ErrAppExists          = errors.New("app already exists")
+	ErrTokenNotFound      = errors.New("refresh token not found")
+	ErrTokenUsed          = errors.New("refresh token already used")
+	ErrCodeUsed           = errors.New("code already used")
+	ErrCodeNotFound       = errors.New("code not found")
+	ErrTicketNotFound     = errors.New("login ticket not found")
+	ErrHandshakeNotFound  = errors.New("login handshake not found")
+	ErrEmailTokenNotFound = errors.New("email token not found")
 )
 
 func Migrate(databaseURL string, timeout time.Duration) error {
@@ -21,7 +29,7 @@ func Migrate(databaseURL string, timeout time.Duration) error {
 		return err
 	}
 
-	if err = migrate(pool, 1); err != nil {
+	if err = migrate(pool, 13); err != nil {
 		return fmt.Errorf("postgres migration error: %w", err)
 	}
 
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Throttle implements ThrottleProvider interface.
+type Throttle struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewThrottle(databaseURL string, timeout time.Duration) (*Throttle, error) {
+	pool, err := newPool(databaseURL, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Throttle{
+		db:      pool,
+		timeout: timeout,
+	}, nil
+}
+
+// LoginFailures returns failed logins by key, key without failures has zero counter.
+func (s *Throttle) LoginFailures(ctx context.Context, key string) (models.LoginFailures, error) {
+	const op = "auth.storage.LoginFailures"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx, `SELECT (key, failures, coalesce(locked_until, 'epoch'::timestamptz))
+		FROM login_failures WHERE key = $1`, key)
+	if err != nil {
+		return models.LoginFailures{}, fmt.Errorf("%s: %w", op, err)
+	}
+	failures, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[models.LoginFailures])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.LoginFailures{Key: key}, nil
+		}
+		return models.LoginFailures{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return failures, nil
+}
+
+// AddLoginFailure counts failed login at time at and returns number of failures.
+// Failures before resetBefore are forgotten, counting starts again.
+func (s *Throttle) AddLoginFailure(ctx context.Context, key string, at time.Time, resetBefore time.Time) (int, error) {
+	const op = "auth.storage.AddLoginFailure"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var failures int
+	err := s.db.QueryRow(newCtx, `INSERT INTO login_failures (key, failures, updated_at) VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_failures.updated_at < $3 THEN 1 ELSE login_failures.failures + 1 END,
+			updated_at = $2
+		RETURNING failures`, key, at, resetBefore).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return failures, nil
+}
+
+// LockLogin refuses logins by key until time until.
+func (s *Throttle) LockLogin(ctx context.Context, key string, until time.Time) error {
+	const op = "auth.storage.LockLogin"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.db.Exec(newCtx, "UPDATE login_failures SET locked_until = $1 WHERE key = $2", until, key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ResetLoginFailures forgets failed logins by key after successful login.
+func (s *Throttle) ResetLoginFailures(ctx context.Context, key string) error {
+	const op = "auth.storage.ResetLoginFailures"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.db.Exec(newCtx, "DELETE FROM login_failures WHERE key = $1", key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Throttle) Close() {
+	s.db.Close()
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Token implements TokenProvider interface.
+type Token struct {
+	db      *pgxpool.Pool
+	timeout time.Duration
+}
+
+func NewToken(databaseURL string, timeout time.Duration) (*Token, error) {
+	pool, err := newPool(databaseURL, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Token{
+		db:      pool,
+		timeout: timeout,
+	}, nil
+}
+
+// SaveToken saves refresh token issued on login.
+func (s *Token) SaveToken(ctx context.Context, token models.RefreshToken) error {
+	const op = "auth.storage.SaveToken"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := saveToken(newCtx, s.db, token); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Token returns refresh token by hash.
+// It returns ErrTokenNotFound error, if token with hash was not issued.
+func (s *Token) Token(ctx context.Context, hash []byte) (models.RefreshToken, error) {
+	const op = "auth.storage.Token"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx, `SELECT (id, token_hash, family, user_id, app_id, expires_at,
+		used_at IS NOT NULL, revoked_at IS NOT NULL) FROM refresh_tokens WHERE token_hash = $1`, hash)
+	if err != nil {
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	token, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[models.RefreshToken])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.RefreshToken{}, fmt.Errorf("%s: %w", op, ErrTokenNotFound)
+		}
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return token, nil
+}
+
+// RotateToken marks refresh token with usedID as used and saves token issued instead of it.
+// It returns ErrTokenUsed error, if the token was already used or revoked,
+// so concurrent requests with the same token can not both succeed.
+func (s *Token) RotateToken(ctx context.Context, usedID int64, token models.RefreshToken) error {
+	const op = "auth.storage.RotateToken"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.Begin(newCtx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(newCtx) }()
+
+	tag, err := tx.Exec(newCtx, `UPDATE refresh_tokens SET used_at = now()
+		WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`, usedID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrTokenUsed)
+	}
+
+	if err := saveToken(newCtx, tx, token); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(newCtx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RevokeFamily revokes all refresh tokens of the family, the user has to login again.
+func (s *Token) RevokeFamily(ctx context.Context, family string) error {
+	const op = "auth.storage.RevokeFamily"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx, "UPDATE refresh_tokens SET revoked_at = now() WHERE family = $1 AND revoked_at IS NULL", family)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Token) Close() {
+	s.db.Close()
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func saveToken(ctx context.Context, db execer, token models.RefreshToken) error {
+	_, err := db.Exec(ctx, `INSERT INTO refresh_tokens (token_hash, family, user_id, app_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`, token.Hash, token.Family, token.UserID, token.AppID, token.ExpiresAt)
+	return err
+}
@@ -12,6 +12,11 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// userColumns is the row of models.User.
+const userColumns = `(id, login, coalesce(password_hash, ''), coalesce(vault_key, ''::bytea), coalesce(recovery_verifier, ''::bytea),
+	coalesce(totp_secret, ''::bytea), totp_enabled, totp_counter, email_verified, coalesce(srp_salt, ''::bytea),
+	coalesce(srp_verifier, ''::bytea))`
+
 // User implements UserProvider interface.
 type User struct {
 	db      *pgxpool.Pool
@@ -29,9 +34,9 @@ func NewUser(databaseURL string, timeout time.Duration) (*User, error) {
 	}, nil
 }
 
-// SaveUser saved new user into database.
+// SaveUser saved new user into database with SRP verifier of the password.
 // It returns ErrUserExists error, if user alredy inserted into database.
-func (s *User) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+func (s *User) SaveUser(ctx context.Context, email string, verifier models.PasswordVerifier) (int64, error) {
 	const op = "auth.storage.SaveUser"
 	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
@@ -39,7 +44,8 @@ func (s *User) SaveUser(ctx context.Context, email string, passHash []byte) (int
 	var id int64
 	var err error
 
-	row := s.db.QueryRow(newCtx, "INSERT INTO users (login, password_hash) values ($1, $2) RETURNING id", email, passHash)
+	row := s.db.QueryRow(newCtx, `INSERT INTO users (login, srp_salt, srp_verifier)
+		values ($1, $2, $3) RETURNING id`, email, verifier.Salt, verifier.Verifier)
 	err = row.Scan(&id)
 	if err != nil {
 		if isLoginExistError(err) {
@@ -58,7 +64,29 @@ func (s *User) User(ctx context.Context, email string) (models.User, error) {
 	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	rows, err := s.db.Query(newCtx, "select (id, login, password_hash) from users where login = $1", email)
+	rows, err := s.db.Query(newCtx, "select "+userColumns+" from users where login = $1", email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[models.User])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return user, nil
+}
+
+// UserByID returns user credentials by id.
+// It returns ErrUserNotFound error, if user with id does not exist.
+func (s *User) UserByID(ctx context.Context, id int64) (models.User, error) {
+	const op = "auth.storage.UserByID"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx, "select "+userColumns+" from users where id = $1", id)
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
@@ -72,10 +100,226 @@ func (s *User) User(ctx context.Context, email string) (models.User, error) {
 	return user, nil
 }
 
+// SetRecovery saves verifier of the recovery kit of the user.
+func (s *User) SetRecovery(ctx context.Context, userID int64, verifier []byte) error {
+	const op = "auth.storage.SetRecovery"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tag, err := s.db.Exec(newCtx, "UPDATE users SET recovery_verifier = $1 WHERE id = $2", verifier, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+	return nil
+}
+
+// UpdatePassword replaces verifier of the password of the user and master key of the vault wrapped with the password,
+// hash of the old password is removed.
+// Refresh tokens of the user are revoked in the same transaction except tokens of the session keepSession,
+// sessions opened with the old password end. Empty keepSession revokes all sessions.
+// Email of the user is marked as verified in the same transaction, if verifyEmail is set.
+func (s *User) UpdatePassword(ctx context.Context, userID int64, verifier models.PasswordVerifier, vaultKey []byte,
+	verifyEmail bool, keepSession string) error {
+	const op = "auth.storage.UpdatePassword"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.Begin(newCtx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(newCtx) }()
+
+	tag, err := tx.Exec(newCtx, `UPDATE users SET vault_key = $1, srp_salt = $2, srp_verifier = $3, password_hash = NULL,
+		email_verified = email_verified OR $4 WHERE id = $5`,
+		vaultKey, verifier.Salt, verifier.Verifier, verifyEmail, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+	_, err = tx.Exec(newCtx, `UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL
+		AND family <> $2`, userID, keepSession)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(newCtx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeleteUser deletes the user together with sessions, tokens and second factors of the user
+// and records the deletion for the keeper server, see DeletedUsers.
+func (s *User) DeleteUser(ctx context.Context, userID int64, at time.Time) error {
+	const op = "auth.storage.DeleteUser"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.Begin(newCtx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(newCtx) }()
+
+	tag, err := tx.Exec(newCtx, "DELETE FROM users WHERE id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+	_, err = tx.Exec(newCtx, "INSERT INTO deleted_accounts (user_id, deleted_at) VALUES ($1, $2)", userID, at)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(newCtx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeletedUsers returns users deleted by finished transactions in order of deletion and the position to poll
+// the next users after. Users after the position after are returned, when after is zero, users deleted after since.
+func (s *User) DeletedUsers(ctx context.Context, after int64, since time.Time) ([]models.DeletedAccount, int64, error) {
+	const op = "auth.storage.DeletedUsers"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(newCtx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, after, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(newCtx) }()
+
+	horizon, err := pollHorizon(newCtx, tx)
+	if err != nil {
+		return nil, after, fmt.Errorf("%s: %w", op, err)
+	}
+	rows, err := tx.Query(newCtx, `SELECT user_id, deleted_at, seq::text::bigint FROM deleted_accounts
+		WHERE seq < $1::bigint::text::xid8
+			AND CASE WHEN $2::bigint > 0 THEN seq > $2::bigint::text::xid8 ELSE deleted_at > $3 END
+		ORDER BY seq, user_id`, horizon, after, since)
+	if err != nil {
+		return nil, after, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var accounts []models.DeletedAccount
+	for rows.Next() {
+		var account models.DeletedAccount
+		if err := rows.Scan(&account.UserID, &account.DeletedAt, &account.Seq); err != nil {
+			return nil, after, fmt.Errorf("%s: %w", op, err)
+		}
+		accounts = append(accounts, account)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, after, fmt.Errorf("%s: %w", op, err)
+	}
+	return accounts, max(after, horizon-1), nil
+}
+
+// pollHorizon returns id of the oldest transaction running at the snapshot of tx,
+// all transactions before it are finished, so their records are not committed later.
+func pollHorizon(ctx context.Context, tx pgx.Tx) (int64, error) {
+	var horizon int64
+	err := tx.QueryRow(ctx, "SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint").Scan(&horizon)
+	return horizon, err
+}
+
+// SetVerifier replaces hash of the password of the user, who has logged in with the password, with SRP verifier.
+func (s *User) SetVerifier(ctx context.Context, userID int64, verifier models.PasswordVerifier) error {
+	const op = "auth.storage.SetVerifier"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tag, err := s.db.Exec(newCtx, "UPDATE users SET srp_salt = $1, srp_verifier = $2, password_hash = NULL WHERE id = $3",
+		verifier.Salt, verifier.Verifier, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+	return nil
+}
+
+// SetEmailVerified marks email of the user as verified.
+func (s *User) SetEmailVerified(ctx context.Context, userID int64) error {
+	const op = "auth.storage.SetEmailVerified"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tag, err := s.db.Exec(newCtx, "UPDATE users SET email_verified = true WHERE id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+	return nil
+}
+
+// SetTOTP saves second factor secret of the user, the second factor is required on login when it is enabled.
+func (s *User) SetTOTP(ctx context.Context, userID int64, secret []byte, enabled bool) error {
+	const op = "auth.storage.SetTOTP"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tag, err := s.db.Exec(newCtx, "UPDATE users SET totp_secret = $1, totp_enabled = $2, totp_counter = 0 WHERE id = $3",
+		secret, enabled, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+	return nil
+}
+
+// UseTOTPCounter saves time period of the accepted code.
+// It returns ErrCodeUsed error, if the code of the same or later period was accepted already.
+func (s *User) UseTOTPCounter(ctx context.Context, userID int64, counter int64) error {
+	const op = "auth.storage.UseTOTPCounter"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tag, err := s.db.Exec(newCtx, "UPDATE users SET totp_counter = $1 WHERE id = $2 AND totp_counter < $1", counter, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, ErrCodeUsed)
+	}
+	return nil
+}
+
 func (s *User) Close() {
 	s.db.Close()
 }
 
+// nullBytes returns nil for empty value, so it is saved as NULL.
+func nullBytes(b []byte) any {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
+
 func isLoginExistError(err error) bool {
 	pgxErr, ok := err.(*pgconn.PgError)
 	if ok && pgxErr.Code == "23505" {
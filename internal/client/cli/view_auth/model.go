@@ -6,7 +6,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-var choices = []string{"Login", "Register"}
+var choices = []string{"Login", "Register", "Reset password", "Recover account"}
 
 type Model struct {
 	cursor int
@@ -6,7 +6,10 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-var choices = []string{"Get all secrets", "Add credentials", "Add text data", "Add binary data", "Add card data"}
+var choices = []string{"Get all secrets", "Add credentials", "Add text data", "Add binary data", "Add card data", "Export binary data", "Share secret", "Revoke share",
+	"Create team vault", "Set team member", "Copy secret to team vault",
+	"Create recovery kit", "Change password", "Enable two-factor authentication", "Devices", "Account activity",
+	"Delete account"}
 
 type Model struct {
 	cursor int
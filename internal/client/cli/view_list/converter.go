@@ -1,13 +1,15 @@
 package viewlist
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/SmoothWay/gophkeeper/pkg/models"
 )
 
-func Convert(creds []models.Credentials, texts []models.Text, bins []models.Binary, cards []models.Card) []string {
-	viewList := make([]string, 0, len(creds)+len(texts)+len(bins)+len(cards))
+func Convert(creds []models.Credentials, texts []models.Text, bins []models.Binary, cards []models.Card,
+	shared []models.SharedSecret, teams []models.TeamVault, team []models.TeamSecret) []string {
+	viewList := make([]string, 0, len(creds)+len(texts)+len(bins)+len(cards)+len(shared))
 
 	if len(creds) > 0 {
 		viewList = append(viewList, "Credentials:")
@@ -34,8 +36,52 @@ func Convert(creds []models.Credentials, texts []models.Text, bins []models.Bina
 			viewList = append(viewList, fmt.Sprintf(`tag=%s; number=%s; exp=%s; cvv=%d; comment=%s`, c.Tag, c.Number, c.Exp, c.Cvv, c.Comment))
 		}
 	}
+	if len(shared) > 0 {
+		viewList = append(viewList, "Shared with me:")
+		for _, s := range shared {
+			viewList = append(viewList, fmt.Sprintf(`from=%s; %s`, s.From, convertShared(s.Item)))
+		}
+	}
+	for _, v := range teams {
+		items := make([]string, 0)
+		for _, t := range team {
+			if t.VaultID == v.ID {
+				items = append(items, convertShared(t.Item))
+			}
+		}
+		if len(items) > 0 {
+			viewList = append(viewList, fmt.Sprintf("Team vault %s (%s):", v.Name, v.Role))
+			viewList = append(viewList, items...)
+		}
+	}
 	if len(viewList) == 0 {
 		viewList = append(viewList, "secrets list is empty")
 	}
 	return viewList
 }
+
+func convertShared(item []byte) string {
+	var header struct{ Type models.ItemType }
+	_ = json.Unmarshal(item, &header)
+
+	switch header.Type {
+	case models.CredItem:
+		var c models.Credentials
+		_ = json.Unmarshal(item, &c)
+		return fmt.Sprintf("tag=%s; login=%s; password=%s; comment=%s.", c.Tag, c.Login, c.Password, c.Comment)
+	case models.TextItem:
+		var t models.Text
+		_ = json.Unmarshal(item, &t)
+		return fmt.Sprintf(`tag=%s; key=%s; value=%s; comment=%s.`, t.Tag, t.Key, t.Value, t.Comment)
+	case models.BinItem:
+		var b models.Binary
+		_ = json.Unmarshal(item, &b)
+		return fmt.Sprintf(`tag=%s; key=%s; comment=%s.`, b.Tag, b.Key, b.Comment)
+	case models.CardItem:
+		var c models.Card
+		_ = json.Unmarshal(item, &c)
+		return fmt.Sprintf(`tag=%s; number=%s; exp=%s; cvv=%d; comment=%s`, c.Tag, c.Number, c.Exp, c.Cvv, c.Comment)
+	}
+
+	return "unknown item"
+}
@@ -2,10 +2,12 @@ package viewlogin
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/SmoothWay/gophkeeper/internal/client/grpcclient"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
 	"github.com/charmbracelet/bubbles/cursor"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -23,9 +25,10 @@ var (
 )
 
 type Model struct {
-	State      string
-	result     string
-	Token      string
+	State  string
+	result string
+	// Login has login ticket instead of tokens, when the account has two-factor authentication
+	Login      models.Login
 	grpcClient *grpcclient.GRPCClient
 	focusIndex int
 	Inputs     []textinput.Model
@@ -89,12 +92,18 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			s := msg.String()
 
 			if s == "enter" && m.focusIndex == len(m.Inputs) {
-				token, err := m.grpcClient.Login(context.Background(), m.Inputs[0].Value(), m.Inputs[1].Value())
+				login, err := m.grpcClient.Login(context.Background(), m.Inputs[0].Value(), m.Inputs[1].Value())
 				if err != nil {
 					if err.Error() == "invalid login or password" {
 						m.State = "again"
 						m.result = "invalid login or password, try again"
 
+					} else if errors.Is(err, grpcclient.ErrTooManyAttempts) {
+						m.State = "again"
+						m.result = err.Error()
+					} else if errors.Is(err, grpcclient.ErrEmailNotVerified) {
+						m.State = "unverified"
+						m.result = err.Error()
 					} else {
 						m.State = "error"
 						m.result = err.Error()
@@ -104,7 +113,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				m.State = "completed"
 				m.result = "success"
-				m.Token = token
+				m.Login = login
 				m.focusIndex = -1
 				return m, nil
 			}
@@ -0,0 +1,154 @@
+package viewshare
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	focusedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	blurredStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	cursorStyle  = focusedStyle
+	noStyle      = lipgloss.NewStyle()
+
+	focusedButton = focusedStyle.Render("[ Submit ]")
+	blurredButton = fmt.Sprintf("[ %s ]", blurredStyle.Render("Submit"))
+)
+
+// Model asks which item to share or revoke and email of the recipient.
+// The same form is used to manage team vaults with other placeholders.
+type Model struct {
+	title      string
+	focusIndex int
+	Inputs     []textinput.Model
+	cursorMode cursor.Mode
+	State      string
+}
+
+func InitialModel(title string) Model {
+	return NewModel(title, "Type (cred, text, bin, card)", "Login, key or card number", "Email of the user")
+}
+
+// NewModel returns form with input for each placeholder.
+func NewModel(title string, placeholders ...string) Model {
+	m := Model{
+		title:  title,
+		Inputs: make([]textinput.Model, len(placeholders)),
+	}
+	var t textinput.Model
+	for i := range m.Inputs {
+		t = textinput.New()
+		t.Cursor.Style = cursorStyle
+		t.CharLimit = 64
+		t.Placeholder = placeholders[i]
+
+		if i == 0 {
+			t.Focus()
+			t.PromptStyle = focusedStyle
+			t.TextStyle = focusedStyle
+		}
+
+		m.Inputs[i] = t
+	}
+
+	return m
+}
+
+func (m Model) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			m.State = "quit"
+			return m, tea.Quit
+
+		case "ctrl+r":
+			m.cursorMode++
+			if m.cursorMode > cursor.CursorHide {
+				m.cursorMode = cursor.CursorBlink
+			}
+			cmds := make([]tea.Cmd, len(m.Inputs))
+			for i := range m.Inputs {
+				cmds[i] = m.Inputs[i].Cursor.SetMode(m.cursorMode)
+			}
+			return m, tea.Batch(cmds...)
+
+		case "tab", "shift+tab", "enter", "up", "down":
+			s := msg.String()
+
+			if s == "enter" && m.focusIndex == len(m.Inputs) {
+				return m, tea.Quit
+			}
+
+			if s == "up" || s == "shift+tab" {
+				m.focusIndex--
+			} else {
+				m.focusIndex++
+			}
+
+			if m.focusIndex > len(m.Inputs) {
+				m.focusIndex = 0
+			} else if m.focusIndex < 0 {
+				m.focusIndex = len(m.Inputs)
+			}
+
+			cmds := make([]tea.Cmd, len(m.Inputs))
+			for i := 0; i <= len(m.Inputs)-1; i++ {
+				if i == m.focusIndex {
+					cmds[i] = m.Inputs[i].Focus()
+					m.Inputs[i].PromptStyle = focusedStyle
+					m.Inputs[i].TextStyle = focusedStyle
+					continue
+				}
+				m.Inputs[i].Blur()
+				m.Inputs[i].PromptStyle = noStyle
+				m.Inputs[i].TextStyle = noStyle
+			}
+
+			return m, tea.Batch(cmds...)
+		}
+	}
+
+	cmd := m.updateInputs(msg)
+
+	return m, cmd
+}
+
+func (m *Model) updateInputs(msg tea.Msg) tea.Cmd {
+	cmds := make([]tea.Cmd, len(m.Inputs))
+
+	for i := range m.Inputs {
+		m.Inputs[i], cmds[i] = m.Inputs[i].Update(msg)
+	}
+
+	return tea.Batch(cmds...)
+}
+
+func (m Model) View() string {
+	var b strings.Builder
+	b.WriteString(m.title + ":\n\n")
+
+	for i := range m.Inputs {
+		b.WriteString(m.Inputs[i].View())
+		if i < len(m.Inputs)-1 {
+			b.WriteRune('\n')
+		}
+	}
+
+	button := &blurredButton
+	if m.focusIndex == len(m.Inputs) {
+		button = &focusedButton
+	}
+	fmt.Fprintf(&b, "\n\n%s\n\n", *button)
+
+	return b.String()
+}
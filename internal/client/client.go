@@ -2,14 +2,18 @@ package client
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 
 	viewaddbinary "github.com/SmoothWay/gophkeeper/internal/client/cli/view_add_binary"
@@ -20,38 +24,71 @@ import (
 	viewlist "github.com/SmoothWay/gophkeeper/internal/client/cli/view_list"
 	viewlogin "github.com/SmoothWay/gophkeeper/internal/client/cli/view_login"
 	viewregister "github.com/SmoothWay/gophkeeper/internal/client/cli/view_register"
+	viewshare "github.com/SmoothWay/gophkeeper/internal/client/cli/view_share"
 	"github.com/SmoothWay/gophkeeper/internal/client/config"
 	"github.com/SmoothWay/gophkeeper/internal/client/grpcclient"
 	"github.com/SmoothWay/gophkeeper/internal/client/service"
 	"github.com/SmoothWay/gophkeeper/internal/client/storage"
+	"github.com/SmoothWay/gophkeeper/internal/client/vault"
 	"github.com/SmoothWay/gophkeeper/internal/client/ws"
 	"github.com/SmoothWay/gophkeeper/pkg/logger"
 	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/SmoothWay/gophkeeper/pkg/tlsconfig"
+	"github.com/SmoothWay/gophkeeper/pkg/totp"
 )
 
 var (
 	ErrViewModel      = errors.New("viewing UI model error")
 	ErrRetrieveModel  = errors.New("failed retrieve model")
 	ErrUserStoppedApp = errors.New("user stopped execution")
+	ErrInvalidCode    = errors.New("second factor code is not accepted")
 )
 
+// activityPage is number of account activity events shown at once.
+const activityPage = 20
+
 type AppClient struct {
 	ch           chan models.Message
 	grpcClient   *grpcclient.GRPCClient
+	session      *grpcclient.Session
 	keeper       *service.Keeper
+	vault        *vault.Vault
 	log          *slog.Logger
 	queryTimeout time.Duration
 	storagePath  string
+	blobDir      string
 	grpcAddress  string
+	caCertFile   string
+	certFile     string
+	keyFile      string
+	appID        int
+	appSecret    string
+	deviceName   string
+	email        string
 	WSURL        string
+	serverPin    string
+	// accountDeleted is set, when the user deleted the account, local data is deleted on stop
+	accountDeleted bool
 }
 
 func NewAppClient(log *slog.Logger, cfg *config.ClientConfig) *AppClient {
+	deviceName := cfg.DeviceName
+	if deviceName == "" {
+		deviceName, _ = os.Hostname()
+	}
 	return &AppClient{
 		log:          log,
 		storagePath:  cfg.StoragePath,
+		blobDir:      cfg.BlobDir,
 		grpcAddress:  cfg.GRPCAddress,
+		caCertFile:   cfg.CaCertFile,
+		certFile:     cfg.CertFile,
+		keyFile:      cfg.KeyFile,
+		appID:        cfg.AppID,
+		appSecret:    cfg.AppSecret,
+		deviceName:   deviceName,
 		WSURL:        cfg.WSURL,
+		serverPin:    cfg.ServerPin,
 		queryTimeout: cfg.QueryTime,
 	}
 }
@@ -75,36 +112,88 @@ func (app *AppClient) Run(ctx context.Context, stop chan os.Signal) {
 		return
 	}
 
-	dbCred, err := storage.NewCredentials(app.storagePath, app.queryTimeout)
+	// local database is encrypted with the vault key, it is usable only after login
+	app.vault = vault.New()
+
+	dbCred, err := storage.NewCredentials(app.storagePath, app.queryTimeout, app.vault)
 	if err != nil {
 		log.Error("failed to init credentials storage")
 		stop <- syscall.SIGTERM
 		return
 	}
 
-	dbText, err := storage.NewText(app.storagePath, app.queryTimeout)
+	dbText, err := storage.NewText(app.storagePath, app.queryTimeout, app.vault)
 	if err != nil {
 		log.Error("failed to init text storage")
 		stop <- syscall.SIGTERM
 		return
 	}
 
-	dbBin, err := storage.NewBinary(app.storagePath, app.queryTimeout)
+	dbBin, err := storage.NewBinary(app.storagePath, app.queryTimeout, app.vault)
 	if err != nil {
 		log.Error("failed to init binary storage")
 		stop <- syscall.SIGTERM
 		return
 	}
 
-	dbCard, err := storage.NewCard(app.storagePath, app.queryTimeout)
+	dbCard, err := storage.NewCard(app.storagePath, app.queryTimeout, app.vault)
 	if err != nil {
 		log.Error("failed to init card storage")
 		stop <- syscall.SIGTERM
 		return
 	}
-	app.keeper = service.NewKeeper(log, app.ch, dbCred, dbText, dbBin, dbCard)
 
-	app.grpcClient, err = grpcclient.NewGRPCClient(app.grpcAddress)
+	dbShared, err := storage.NewShared(app.storagePath, app.queryTimeout, app.vault)
+	if err != nil {
+		log.Error("failed to init shared storage")
+		stop <- syscall.SIGTERM
+		return
+	}
+
+	dbTeam, err := storage.NewTeam(app.storagePath, app.queryTimeout, app.vault)
+	if err != nil {
+		log.Error("failed to init team storage")
+		stop <- syscall.SIGTERM
+		return
+	}
+
+	dbUploads, err := storage.NewUploads(app.storagePath, app.queryTimeout, app.vault)
+	if err != nil {
+		log.Error("failed to init upload storage")
+		stop <- syscall.SIGTERM
+		return
+	}
+
+	dbPins, err := storage.NewPins(app.storagePath, app.queryTimeout)
+	if err != nil {
+		log.Error("failed to init server pins storage")
+		stop <- syscall.SIGTERM
+		return
+	}
+
+	dbLegacy, err := storage.NewLegacy(app.storagePath, app.queryTimeout)
+	if err != nil {
+		log.Error("failed to init legacy storage")
+		stop <- syscall.SIGTERM
+		return
+	}
+
+	blobCache, err := storage.NewBlobCache(app.blobDir)
+	if err != nil {
+		log.Error("failed to init blob cache", logger.Err(err))
+		stop <- syscall.SIGTERM
+		return
+	}
+	app.keeper = service.NewKeeper(log, app.ch, app.vault, dbCred, dbText, dbBin, dbCard, dbShared, dbTeam,
+		dbUploads, blobCache)
+
+	tlsConfig, err := tlsconfig.Client(app.caCertFile, app.certFile, app.keyFile)
+	if err != nil {
+		log.Error("failed to load TLS certificates", logger.Err(err))
+		stop <- syscall.SIGTERM
+		return
+	}
+	app.grpcClient, err = grpcclient.NewGRPCClient(app.grpcAddress, tlsConfig, app.appID, app.appSecret, app.deviceName)
 	if err != nil {
 		log.Error(
 			"failed connect to GRPC auth server",
@@ -124,22 +213,43 @@ func (app *AppClient) Run(ctx context.Context, stop chan os.Signal) {
 		return
 	}
 
+	if modelAuth.Choice == "Recover account" {
+		if err := app.recovery(ctx); err != nil {
+			log.Error("account recovery failed", logger.Err(err))
+			stop <- syscall.SIGTERM
+			return
+		}
+	}
+
+	if modelAuth.Choice == "Reset password" {
+		if err := app.resetPassword(ctx); err != nil {
+			log.Error("password reset failed", logger.Err(err))
+			stop <- syscall.SIGTERM
+			return
+		}
+	}
+
 	if modelAuth.Choice == "Register" {
 		if err := app.registration(ctx); err != nil {
 			log.Error("registration failed", logger.Err(err))
 			stop <- syscall.SIGTERM
 			return
 		}
+		// the code can be entered on the next login, when the mail is late
+		if err := app.verifyEmail(ctx); err != nil && !errors.Is(err, ErrUserStoppedApp) {
+			log.Warn("email verification failed", logger.Err(err))
+		}
 	}
 
-	token, err := app.login(ctx)
-	if err != nil || token == "" {
+	tokens, err := app.login(ctx)
+	if err != nil || tokens.AccessToken == "" {
 		log.Error("login user error", logger.Err(err))
 		stop <- syscall.SIGTERM
 		return
 	}
+	app.session = grpcclient.NewSession(app.grpcClient, tokens)
 
-	wsClient := ws.NewWSClient(log, app.ch, app.keeper, app.WSURL)
+	wsClient := ws.NewWSClient(log, app.ch, app.keeper, app.WSURL, tlsConfig, app.serverPin, dbPins)
 
 	interrupt := make(chan struct{})
 	go func(interrupt chan struct{}) {
@@ -147,7 +257,26 @@ func (app *AppClient) Run(ctx context.Context, stop chan os.Signal) {
 		stop <- syscall.SIGTERM
 	}(interrupt)
 
-	wsClient.Run(ctx, interrupt, token)
+	if err := wsClient.Run(ctx, interrupt, app.session); err != nil {
+		log.Error("failed connect to the server", slog.String("url", app.WSURL), logger.Err(err))
+		app.showConnectError(err)
+		stop <- syscall.SIGTERM
+		return
+	}
+	go func() {
+		if err := app.keeper.RegisterKey(ctx); err != nil {
+			log.Error("failed register public key", logger.Err(err))
+		}
+		if err := app.keeper.ResumeUploads(ctx); err != nil {
+			log.Error("failed resume uploads", logger.Err(err))
+		}
+		if err := app.keeper.ImportLegacy(ctx, dbLegacy); err != nil {
+			log.Error("failed import plaintext items", logger.Err(err))
+		}
+		if err := dbLegacy.Close(); err != nil {
+			log.Error("failed close legacy storage", logger.Err(err))
+		}
+	}()
 
 	for {
 		select {
@@ -205,21 +334,122 @@ func (app *AppClient) Run(ctx context.Context, stop chan os.Signal) {
 					return
 				}
 
+			case "Export binary data":
+				ok := app.commandAdd(ctx, app.commandExportBinary, "export binary data")
+				if !ok {
+					stop <- syscall.SIGTERM
+					return
+				}
+
 			case "Add card data":
 				ok := app.commandAdd(ctx, app.commandAddCard, "card")
 				if !ok {
 					stop <- syscall.SIGTERM
 					return
 				}
+
+			case "Share secret":
+				ok := app.commandAdd(ctx, app.commandShare, "share")
+				if !ok {
+					stop <- syscall.SIGTERM
+					return
+				}
+
+			case "Revoke share":
+				ok := app.commandAdd(ctx, app.commandRevoke, "revoke")
+				if !ok {
+					stop <- syscall.SIGTERM
+					return
+				}
+
+			case "Create team vault":
+				ok := app.commandAdd(ctx, app.commandCreateTeamVault, "team vault")
+				if !ok {
+					stop <- syscall.SIGTERM
+					return
+				}
+
+			case "Set team member":
+				ok := app.commandAdd(ctx, app.commandSetTeamMember, "team member")
+				if !ok {
+					stop <- syscall.SIGTERM
+					return
+				}
+
+			case "Copy secret to team vault":
+				ok := app.commandAdd(ctx, app.commandTeamItem, "team item")
+				if !ok {
+					stop <- syscall.SIGTERM
+					return
+				}
+
+			case "Create recovery kit":
+				ok := app.commandAdd(ctx, app.commandRecoveryKit, "recovery kit")
+				if !ok {
+					stop <- syscall.SIGTERM
+					return
+				}
+
+			case "Change password":
+				ok := app.commandAdd(ctx, app.commandChangePassword, "password")
+				if !ok {
+					stop <- syscall.SIGTERM
+					return
+				}
+
+			case "Enable two-factor authentication":
+				ok := app.commandAdd(ctx, app.commandEnableTOTP, "two-factor authentication")
+				if !ok {
+					stop <- syscall.SIGTERM
+					return
+				}
+
+			case "Devices":
+				ok := app.commandAdd(ctx, app.commandDevices, "devices")
+				if !ok {
+					stop <- syscall.SIGTERM
+					return
+				}
+
+			case "Account activity":
+				ok := app.commandAdd(ctx, app.commandActivity, "account activity")
+				if !ok {
+					stop <- syscall.SIGTERM
+					return
+				}
+
+			case "Delete account":
+				ok := app.commandAdd(ctx, app.commandDeleteAccount, "account deletion")
+				if !ok || app.accountDeleted {
+					stop <- syscall.SIGTERM
+					return
+				}
 			}
 		}
 	}
 }
 
 func (app *AppClient) Stop() {
+	if app.session != nil && !app.accountDeleted {
+		ctx, cancel := context.WithTimeout(context.Background(), app.queryTimeout)
+		if err := app.session.Logout(ctx); err != nil {
+			app.log.Error("failed logout", logger.Err(err))
+		}
+		cancel()
+	}
+	app.vault.Lock()
 	app.keeper.Stop()
 	close(app.ch)
 	app.grpcClient.Stop()
+
+	if app.accountDeleted {
+		if err := os.Remove(app.storagePath); err != nil {
+			app.log.Error("failed delete local database", logger.Err(err))
+		}
+		if err := os.RemoveAll(app.blobDir); err != nil {
+			app.log.Error("failed delete local blobs", logger.Err(err))
+		}
+	}
 }
 
 func (app *AppClient) registration(ctx context.Context) error {
@@ -259,41 +489,122 @@ loop:
 	return nil
 }
 
-func (app *AppClient) login(ctx context.Context) (string, error) {
+func (app *AppClient) login(ctx context.Context) (models.Tokens, error) {
 	for {
 		select {
 		case <-ctx.Done():
-			return "", nil
+			return models.Tokens{}, nil
 		default:
 			p := tea.NewProgram(viewlogin.InitialModel(app.grpcClient))
 			m, err := p.Run()
 			if err != nil {
-				return "", ErrViewModel
+				return models.Tokens{}, ErrViewModel
 			}
 
 			modelLogin, ok := m.(viewlogin.Model)
 			if !ok {
-				return "", ErrRetrieveModel
+				return models.Tokens{}, ErrRetrieveModel
 			}
 
 			if modelLogin.State == "" {
 				// user stopped execution in UI (q, ctrl+C, esc)
-				return "", ErrUserStoppedApp
+				return models.Tokens{}, ErrUserStoppedApp
 			}
 
 			if modelLogin.State == "again" {
 				continue
 			}
 
+			if modelLogin.State == "unverified" {
+				if err := app.verifyEmail(ctx); err != nil {
+					app.log.Warn("email verification failed", logger.Err(err))
+				}
+				continue
+			}
+
 			if modelLogin.State == "error" {
-				return "", errors.New("failed login user")
+				return models.Tokens{}, errors.New("failed login user")
 			}
 
-			return modelLogin.Token, nil
+			login := modelLogin.Login
+			if login.Ticket != "" {
+				login, err = app.verifyLogin(ctx, login.Ticket)
+				if errors.Is(err, ErrInvalidCode) {
+					continue
+				}
+				if err != nil {
+					return models.Tokens{}, err
+				}
+			}
+
+			// the same master password unlocks the vault on every device of the user
+			app.email = modelLogin.Inputs[0].Value()
+			err = app.vault.UnlockWrapped(app.email, modelLogin.Inputs[1].Value(), login.VaultKey)
+			if err != nil {
+				return models.Tokens{}, fmt.Errorf("failed unlock vault: %w", err)
+			}
+
+			return login.Tokens, nil
 		}
 	}
 }
 
+// verifyEmail asks the code from the verification mail and confirms email of the user.
+func (app *AppClient) verifyEmail(ctx context.Context) error {
+	values, err := app.formInputs(viewshare.NewModel("verify email\n\nenter the code sent to your email", "Code"))
+	if err != nil {
+		return err
+	}
+	return app.grpcClient.VerifyEmail(ctx, strings.TrimSpace(values[0]))
+}
+
+// resetPassword sets new password with the code sent to the email of the user.
+// The vault can not be unlocked without the old password, so the user starts new vault,
+// items of the old vault are restored only with the recovery kit.
+func (app *AppClient) resetPassword(ctx context.Context) error {
+	values, err := app.formInputs(viewshare.NewModel("reset password\n\n"+
+		"the vault can not be opened without the old password, new empty vault is created.\n"+
+		"use \"Recover account\" instead, if you have the recovery kit", "Email"))
+	if err != nil {
+		return err
+	}
+	email := values[0]
+	if err := app.grpcClient.RequestPasswordReset(ctx, email); err != nil {
+		return err
+	}
+
+	model := viewshare.NewModel("reset password\n\nenter the code sent to your email", "Code", "New password", "Repeat new password")
+	for i := 1; i < len(model.Inputs); i++ {
+		model.Inputs[i].EchoMode = textinput.EchoPassword
+		model.Inputs[i].EchoCharacter = '•'
+	}
+	values, err = app.formInputs(model)
+	if err != nil {
+		return err
+	}
+	if values[1] == "" || values[1] != values[2] {
+		return errors.New("new passwords do not match")
+	}
+
+	return app.grpcClient.ResetPassword(ctx, email, strings.TrimSpace(values[0]), values[1])
+}
+
+// verifyLogin asks the code of the authenticator app or backup code and completes login with the ticket.
+// It returns ErrInvalidCode, if the user has to login again.
+func (app *AppClient) verifyLogin(ctx context.Context, ticket string) (models.Login, error) {
+	values, err := app.formInputs(viewshare.NewModel("two-factor authentication", "Code of the authenticator app or backup code"))
+	if err != nil {
+		return models.Login{}, err
+	}
+
+	login, err := app.grpcClient.VerifyLogin(ctx, ticket, values[0])
+	if err != nil {
+		app.log.Warn("second factor is not accepted", logger.Err(err))
+		return models.Login{}, ErrInvalidCode
+	}
+	return login, nil
+}
+
 func (app *AppClient) commandGetAllSecrets(ctx context.Context) error {
 	const op = "client.Run.GetAllSecrets"
 	log := app.log.With(
@@ -317,8 +628,16 @@ func (app *AppClient) commandGetAllSecrets(ctx context.Context) error {
 	if err != nil {
 		log.Error("query all cards error", logger.Err(err))
 	}
+	shared, err := app.keeper.AllShared(ctx)
+	if err != nil {
+		log.Error("query all shared items error", logger.Err(err))
+	}
+	team, err := app.keeper.AllTeam(ctx)
+	if err != nil {
+		log.Error("query all team items error", logger.Err(err))
+	}
 	// view result
-	p := tea.NewProgram(viewlist.Model{Msg: viewlist.Convert(creds, texts, bins, cards)})
+	p := tea.NewProgram(viewlist.Model{Msg: viewlist.Convert(creds, texts, bins, cards, shared, app.keeper.TeamVaults(), team)})
 	_, err = p.Run()
 	if err != nil {
 		return ErrViewModel
@@ -401,19 +720,28 @@ func (app *AppClient) commandAddBinary(ctx context.Context) error {
 	}
 
 	path := modelAddBinary.Inputs[1].Value()
-	fileName, data, err := app.keeper.ExtractDataFromFile(path)
-	if err != nil {
-		return fmt.Errorf("failed extract data from file %w", err)
-	}
-
 	bin := models.Binary{
 		Type:    models.BinItem,
 		Tag:     modelAddBinary.Inputs[0].Value(),
-		Key:     fileName,
-		Value:   data,
+		Key:     filepath.Base(path),
 		Comment: modelAddBinary.Inputs[2].Value(),
 		Created: time.Now().Unix(),
 	}
+
+	fileName, data, err := app.keeper.ExtractDataFromFile(path)
+	if errors.Is(err, service.ErrFileTooBig) {
+		// large files are uploaded in chunks
+		if err := app.keeper.SendSaveBlob(ctx, bin, path); err != nil {
+			return fmt.Errorf("saving binary data error %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed extract data from file %w", err)
+	}
+
+	bin.Key = fileName
+	bin.Value = data
 	err = app.keeper.SendSaveBinary(ctx, bin)
 	if err != nil {
 		return fmt.Errorf("saving binary data error %w", err)
@@ -422,6 +750,19 @@ func (app *AppClient) commandAddBinary(ctx context.Context) error {
 	return nil
 }
 
+func (app *AppClient) commandExportBinary(ctx context.Context) error {
+	values, err := app.formInputs(viewshare.NewModel("export binary data", "Key of the binary data", "Path of the file"))
+	if err != nil {
+		return err
+	}
+
+	if err := app.keeper.ExportBinary(ctx, values[0], values[1]); err != nil {
+		return fmt.Errorf("exporting binary data error %w", err)
+	}
+
+	return nil
+}
+
 func (app *AppClient) commandAddCard(ctx context.Context) error {
 	p := tea.NewProgram(viewaddcard.InitialModel())
 	m, err := p.Run()
@@ -458,3 +799,427 @@ func (app *AppClient) commandAddCard(ctx context.Context) error {
 
 	return nil
 }
+
+func (app *AppClient) commandShare(ctx context.Context) error {
+	kind, key, email, err := app.shareInputs("share secret")
+	if err != nil {
+		return err
+	}
+
+	if err := app.keeper.SendShare(ctx, kind, key, email); err != nil {
+		return fmt.Errorf("sharing secret error %w", err)
+	}
+
+	return nil
+}
+
+func (app *AppClient) commandRevoke(ctx context.Context) error {
+	kind, key, email, err := app.shareInputs("revoke share")
+	if err != nil {
+		return err
+	}
+
+	if err := app.keeper.SendRevoke(ctx, kind, key, email); err != nil {
+		return fmt.Errorf("revoking share error %w", err)
+	}
+
+	return nil
+}
+
+func (app *AppClient) shareInputs(title string) (models.ItemType, string, string, error) {
+	values, err := app.formInputs(viewshare.InitialModel(title))
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return models.ItemType(values[0]), values[1], values[2], nil
+}
+
+func (app *AppClient) commandCreateTeamVault(ctx context.Context) error {
+	values, err := app.formInputs(viewshare.NewModel("create team vault", "Name of the vault"))
+	if err != nil {
+		return err
+	}
+
+	if err := app.keeper.CreateTeamVault(ctx, values[0]); err != nil {
+		return fmt.Errorf("creating team vault error %w", err)
+	}
+
+	return nil
+}
+
+func (app *AppClient) commandSetTeamMember(ctx context.Context) error {
+	values, err := app.formInputs(viewshare.NewModel("set team member",
+		"Name of the vault", "Email of the user", "Role (editor, viewer or empty to remove)"))
+	if err != nil {
+		return err
+	}
+
+	if err := app.keeper.SetTeamMember(ctx, values[0], values[1], values[2]); err != nil {
+		return fmt.Errorf("setting team member error %w", err)
+	}
+
+	return nil
+}
+
+func (app *AppClient) commandTeamItem(ctx context.Context) error {
+	values, err := app.formInputs(viewshare.NewModel("copy secret to team vault",
+		"Name of the vault", "Type (cred, text, bin, card)", "Login, key or card number"))
+	if err != nil {
+		return err
+	}
+
+	if err := app.keeper.SendTeamItem(ctx, values[0], models.ItemType(values[1]), values[2]); err != nil {
+		return fmt.Errorf("copying secret to team vault error %w", err)
+	}
+
+	return nil
+}
+
+// commandRecoveryKit splits master key of the vault into recovery codes, shows them and exports them into file.
+func (app *AppClient) commandRecoveryKit(ctx context.Context) error {
+	model := viewshare.NewModel("create recovery kit",
+		"Password", "Number of codes", "Codes required to recover", "File to export codes (optional)",
+		"Code of the authenticator app or backup code (empty, if two-factor authentication is off)")
+	model.Inputs[0].EchoMode = textinput.EchoPassword
+	model.Inputs[0].EchoCharacter = '•'
+	values, err := app.formInputs(model)
+	if err != nil {
+		return err
+	}
+
+	n, _ := strconv.Atoi(values[1])
+	threshold, _ := strconv.Atoi(values[2])
+	// validate before the previous kit is replaced on the server
+	codes, err := app.vault.RecoveryCodes(n, threshold)
+	if err != nil {
+		return fmt.Errorf("creating recovery codes error %w", err)
+	}
+	verifier, err := app.vault.RecoveryVerifier()
+	if err != nil {
+		return fmt.Errorf("creating recovery codes error %w", err)
+	}
+	token, err := app.session.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token error %w", err)
+	}
+	if err := app.grpcClient.SetRecovery(ctx, token, app.email, values[0], values[4], verifier); err != nil {
+		return fmt.Errorf("saving recovery kit error %w", err)
+	}
+
+	kit := recoveryKit(app.email, threshold, codes)
+	if path := values[3]; path != "" {
+		if err := os.WriteFile(path, []byte(strings.Join(kit, "\n")+"\n"), 0o600); err != nil {
+			return fmt.Errorf("exporting recovery kit error %w", err)
+		}
+	}
+
+	p := tea.NewProgram(viewlist.Model{Msg: kit})
+	if _, err := p.Run(); err != nil {
+		return ErrViewModel
+	}
+
+	return nil
+}
+
+// commandEnableTOTP enrolls authenticator app of the user and shows backup codes, when the app is confirmed.
+func (app *AppClient) commandEnableTOTP(ctx context.Context) error {
+	model := viewshare.NewModel("enable two-factor authentication", "Password")
+	model.Inputs[0].EchoMode = textinput.EchoPassword
+	model.Inputs[0].EchoCharacter = '•'
+	values, err := app.formInputs(model)
+	if err != nil {
+		return err
+	}
+	password := values[0]
+
+	token, err := app.session.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token error %w", err)
+	}
+	secret, uri, err := app.grpcClient.EnrollTOTP(ctx, token, app.email, password)
+	if err != nil {
+		return fmt.Errorf("enrolling two-factor authentication error %w", err)
+	}
+
+	title := fmt.Sprintf("add the account to the authenticator app\n\nsecret: %s\nURI: %s\n\nenter the code shown by the app",
+		totp.Encode(secret), uri)
+	values, err = app.formInputs(viewshare.NewModel(title, "Code"))
+	if err != nil {
+		return err
+	}
+
+	backupCodes, err := app.grpcClient.ConfirmTOTP(ctx, token, app.email, password, values[0])
+	if err != nil {
+		return fmt.Errorf("enabling two-factor authentication error %w", err)
+	}
+
+	msg := []string{
+		"Two-factor authentication is enabled.",
+		"Backup codes replace the authenticator app once each, keep them in a safe place.",
+	}
+	for i, code := range backupCodes {
+		msg = append(msg, fmt.Sprintf("%d. %s", i+1, code))
+	}
+	p := tea.NewProgram(viewlist.Model{Msg: msg})
+	if _, err := p.Run(); err != nil {
+		return ErrViewModel
+	}
+
+	return nil
+}
+
+// commandChangePassword wraps master key of the vault with the new password and replaces the password on the server.
+// Items stay encrypted with the same vault key, recovery kit keeps working.
+// Other sessions are revoked, the current session and its connection to the keeper server stay.
+func (app *AppClient) commandChangePassword(ctx context.Context) error {
+	model := viewshare.NewModel("change password", "Current password", "New password", "Repeat new password",
+		"Code of the authenticator app or backup code (empty, if two-factor authentication is off)")
+	for i := 0; i < 3; i++ {
+		model.Inputs[i].EchoMode = textinput.EchoPassword
+		model.Inputs[i].EchoCharacter = '•'
+	}
+	values, err := app.formInputs(model)
+	if err != nil {
+		return err
+	}
+	if values[1] == "" || values[1] != values[2] {
+		return errors.New("new passwords do not match")
+	}
+
+	vaultKey, err := app.vault.WrapKey(app.email, values[1])
+	if err != nil {
+		return fmt.Errorf("wrapping vault key error %w", err)
+	}
+	token, err := app.session.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token error %w", err)
+	}
+	if err := app.grpcClient.ChangePassword(ctx, token, app.email, values[0], values[3], values[1], vaultKey); err != nil {
+		return fmt.Errorf("changing password error %w", err)
+	}
+
+	return nil
+}
+
+// commandDevices lists devices, where the user is logged in, and signs out the chosen device.
+// Connection of the signed out device to the keeper server is closed.
+func (app *AppClient) commandDevices(ctx context.Context) error {
+	token, err := app.session.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token error %w", err)
+	}
+	sessions, current, err := app.grpcClient.Sessions(ctx, token)
+	if err != nil {
+		return fmt.Errorf("listing devices error %w", err)
+	}
+
+	lines := []string{"devices"}
+	for i, session := range sessions {
+		line := fmt.Sprintf("%d. %s, %s, last seen %s", i+1, session.Device, session.Address,
+			session.LastSeenAt.Format(time.DateTime))
+		if session.ID == current {
+			line += " (this device)"
+		}
+		lines = append(lines, line)
+	}
+	values, err := app.formInputs(viewshare.NewModel(strings.Join(lines, "\n"),
+		"Number of the device to sign out (empty to cancel)"))
+	if err != nil {
+		return err
+	}
+	if values[0] == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(values[0])
+	if err != nil || n < 1 || n > len(sessions) {
+		return fmt.Errorf("invalid device number %q", values[0])
+	}
+
+	if err := app.grpcClient.RevokeSession(ctx, token, sessions[n-1].ID); err != nil {
+		return fmt.Errorf("signing out device error %w", err)
+	}
+	return nil
+}
+
+// commandActivity shows audit log of the account page by page: logins, failed logins, password changes.
+func (app *AppClient) commandActivity(ctx context.Context) error {
+	values, err := app.formInputs(viewshare.NewModel("account activity",
+		"Event types separated by commas: register, login, second_factor, password_change, password_reset, recovery "+
+			"(empty for all)",
+		"Outcome: success, failure or locked (empty for all)"))
+	if err != nil {
+		return err
+	}
+	filter := models.AuditFilter{Outcome: strings.TrimSpace(values[1]), Limit: activityPage}
+	for _, t := range strings.Split(values[0], ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter.Types = append(filter.Types, t)
+		}
+	}
+
+	var pageToken string
+	for {
+		token, err := app.session.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("getting access token error %w", err)
+		}
+		events, next, err := app.grpcClient.AuditEvents(ctx, token, filter, pageToken)
+		if err != nil {
+			return fmt.Errorf("listing account activity error %w", err)
+		}
+
+		lines := []string{"account activity"}
+		for _, event := range events {
+			lines = append(lines, fmt.Sprintf("%s %s: %s from %s, app %d", event.CreatedAt.Format(time.DateTime),
+				event.Type, event.Outcome, event.Address, event.AppID))
+		}
+		if len(events) == 0 {
+			lines = append(lines, "no events")
+		}
+		if next == "" {
+			p := tea.NewProgram(viewlist.Model{Msg: lines})
+			if _, err := p.Run(); err != nil {
+				return ErrViewModel
+			}
+			return nil
+		}
+
+		values, err := app.formInputs(viewshare.NewModel(strings.Join(lines, "\n"), "Show older events? (y/N)"))
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(strings.TrimSpace(values[0]), "y") {
+			return nil
+		}
+		pageToken = next
+	}
+}
+
+// commandDeleteAccount deletes account of the user after the password, the second factor code and the email
+// are entered. The keeper server deletes secrets of the account, local data is deleted, when the client stops.
+func (app *AppClient) commandDeleteAccount(ctx context.Context) error {
+	model := viewshare.NewModel("delete account\n\nall secrets of the account are deleted, it can not be undone",
+		"Password", "Code of the authenticator app or backup code (empty, if two-factor authentication is off)",
+		"Type your email to confirm")
+	model.Inputs[0].EchoMode = textinput.EchoPassword
+	model.Inputs[0].EchoCharacter = '•'
+	values, err := app.formInputs(model)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(values[2]) != app.email {
+		return errors.New("email does not match, the account is not deleted")
+	}
+
+	token, err := app.session.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("getting access token error %w", err)
+	}
+	if err := app.grpcClient.DeleteAccount(ctx, token, app.email, values[0], values[1]); err != nil {
+		return fmt.Errorf("deleting account error %w", err)
+	}
+	app.accountDeleted = true
+
+	p := tea.NewProgram(viewlist.Model{Msg: []string{
+		"The account is deleted.",
+		"Secrets of the account are deleted from the server, other devices are signed out.",
+		"Local data of this device is deleted, when the client stops.",
+	}})
+	if _, err := p.Run(); err != nil {
+		return ErrViewModel
+	}
+	return nil
+}
+
+// recovery restores the vault from recovery codes and sets new password of the user.
+func (app *AppClient) recovery(ctx context.Context) error {
+	model := viewshare.NewModel("recover account", "Email", "New password", "Recovery codes separated by commas")
+	model.Inputs[1].EchoMode = textinput.EchoPassword
+	model.Inputs[1].EchoCharacter = '•'
+	model.Inputs[2].CharLimit = 0
+	values, err := app.formInputs(model)
+	if err != nil {
+		return err
+	}
+
+	// vault is unlocked with the recovered key only to wrap it with the new password
+	defer app.vault.Lock()
+	if err := app.vault.Recover(strings.Split(values[2], ",")); err != nil {
+		return fmt.Errorf("restoring vault key error %w", err)
+	}
+	proof, err := app.vault.RecoveryProof()
+	if err != nil {
+		return fmt.Errorf("restoring vault key error %w", err)
+	}
+	vaultKey, err := app.vault.WrapKey(values[0], values[1])
+	if err != nil {
+		return fmt.Errorf("wrapping vault key error %w", err)
+	}
+
+	return app.grpcClient.Recover(ctx, values[0], proof, values[1], vaultKey)
+}
+
+// recoveryKit returns printable recovery kit.
+func recoveryKit(email string, threshold int, codes []string) []string {
+	kit := []string{
+		fmt.Sprintf("GophKeeper recovery kit for %s.", email),
+		fmt.Sprintf("Any %d of %d codes restore the vault, keep them in separate safe places.", threshold, len(codes)),
+	}
+	for i, code := range codes {
+		kit = append(kit, fmt.Sprintf("%d. %s", i+1, code))
+	}
+
+	return kit
+}
+
+// showConnectError tells the user why connection to the keeper server failed.
+func (app *AppClient) showConnectError(err error) {
+	msg := []string{"Failed connect to the server " + app.WSURL + ".", err.Error()}
+	var unknownAuthority x509.UnknownAuthorityError
+	switch {
+	case errors.Is(err, ws.ErrPinMismatch):
+		msg = []string{
+			"Public key of the server " + app.WSURL + " does not match the pinned key, the connection is closed.",
+			"The connection may be intercepted, the session token was not sent.",
+			"If the server key was replaced deliberately, set server_pin in the client config to the new pin.",
+			err.Error(),
+		}
+	case errors.As(err, &unknownAuthority):
+		msg = []string{
+			"Certificate of the server " + app.WSURL + " is not signed by the trusted CA, the connection is closed.",
+			"Check ca_cert_file in the client config.",
+			err.Error(),
+		}
+	}
+
+	p := tea.NewProgram(viewlist.Model{Msg: msg})
+	if _, err := p.Run(); err != nil {
+		app.log.Error("viewing connection error", logger.Err(err))
+	}
+}
+
+// formInputs runs the form and returns values of its inputs.
+func (app *AppClient) formInputs(model viewshare.Model) ([]string, error) {
+	p := tea.NewProgram(model)
+	m, err := p.Run()
+	if err != nil {
+		return nil, ErrViewModel
+	}
+
+	modelForm, ok := m.(viewshare.Model)
+	if !ok {
+		return nil, ErrRetrieveModel
+	}
+
+	if modelForm.State == "quit" {
+		return nil, ErrUserStoppedApp
+	}
+
+	values := make([]string, len(modelForm.Inputs))
+	for i := range modelForm.Inputs {
+		values[i] = modelForm.Inputs[i].Value()
+	}
+
+	return values, nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"time"
 
@@ -12,9 +13,32 @@ import (
 type ClientConfig struct {
 	QueryTime   time.Duration `yaml:"query_timeout" env-default:"2s"`
 	StoragePath string        `yaml:"storage_path" env-required:"true"`
+	BlobDir     string        `yaml:"blob_dir" env-default:"./blobs"`
 	GRPCAddress string        `yaml:"grpc_address" env-required:"true"`
-	WSURL       string        `yaml:"ws_url" env-required:"true"`
-	CaCertFile  string        `yaml:"ca_cert_file" env=required:"true"`
+	// AppID and AppSecret are issued by the administrator of the auth service
+	AppID     int    `yaml:"app_id" env-default:"1"`
+	AppSecret string `yaml:"app_secret" env:"CLIENT_APP_SECRET"`
+	// DeviceName is shown in the list of sessions, host name is used by default
+	DeviceName string `yaml:"device_name"`
+	WSURL      string `yaml:"ws_url" env-required:"true"`
+	// ServerPin is pin of the keeper server public key, "sha256/<base64 of SPKI hash>".
+	// Value "tofu" trusts the key seen on the first connection, the server is verified only with CA, when it is empty.
+	ServerPin string `yaml:"server_pin"`
+	// CaCertFile verifies the auth and the keeper servers, system roots are used, when it is empty.
+	// CertFile and KeyFile are client certificate, when the server requires mutual TLS.
+	CaCertFile string `yaml:"ca_cert_file"`
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+}
+
+// LogValue hides AppSecret, when the configuration is logged.
+func (c ClientConfig) LogValue() slog.Value {
+	// plain does not implement slog.LogValuer, its fields are logged as is
+	type plain ClientConfig
+	if c.AppSecret != "" {
+		c.AppSecret = "REDACTED"
+	}
+	return slog.AnyValue(plain(c))
 }
 
 func MustLoad() *ClientConfig {
@@ -2,35 +2,80 @@ package grpcclient
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	authv1 "github.com/SmoothWay/gophkeeper/api/gen"
+	"github.com/SmoothWay/gophkeeper/pkg/encrypt"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/SmoothWay/gophkeeper/pkg/password"
+	"github.com/SmoothWay/gophkeeper/pkg/srp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
+// retryAfterHeader is metadata key with seconds to wait, when login is locked after failed attempts.
+const retryAfterHeader = "retry-after"
+
+var (
+	ErrSessionExpired   = errors.New("session expired, please login again")
+	ErrTooManyAttempts  = errors.New("too many failed login attempts")
+	ErrEmailNotVerified = errors.New("email is not verified, enter the code sent to your email")
+	ErrInvalidApp       = errors.New("client application is not accepted by the server, check app_id and app_secret")
+	ErrServerProof      = errors.New("server did not prove that it knows the password verifier")
+)
+
 type GRPCClient struct {
 	conn   *grpc.ClientConn
 	client authv1.AuthClient
+	// appID and appSecret identify the client application registered in the auth service
+	appID     int32
+	appSecret string
+	// device is name of the device shown in the list of sessions
+	device string
+	// ticketSealKey decrypts the vault key returned by VerifyLogin after SRP login
+	ticketSealKey []byte
 }
 
-func NewGRPCClient(address string) (*GRPCClient, error) {
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+// NewGRPCClient returns client of the auth server at address, the connection is secured with tlsConfig.
+func NewGRPCClient(address string, tlsConfig *tls.Config, appID int, appSecret string, device string) (*GRPCClient, error) {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
 	if err != nil {
 		return nil, err
 	}
 	return &GRPCClient{
-		conn:   conn,
-		client: authv1.NewAuthClient(conn),
+		conn:      conn,
+		client:    authv1.NewAuthClient(conn),
+		appID:     int32(appID),
+		appSecret: appSecret,
+		device:    device,
 	}, nil
 }
 
+// Register creates account of the user, the password is checked against the password policy of the server,
+// the server checks it again and saves only its SRP verifier.
 func (c *GRPCClient) Register(ctx context.Context, login, password string) error {
-	req := authv1.RegisterRequest{Email: login, Password: password}
-	_, err := c.client.Register(ctx, &req)
+	if err := c.checkPassword(ctx, login, password); err != nil {
+		return err
+	}
+	verifier, err := newVerifier(login, password)
 	if err != nil {
+		return err
+	}
+	req := authv1.RegisterRequest{Email: login, Password: password, Verifier: verifier}
+	_, err = c.client.Register(ctx, &req)
+	if err != nil {
+		if weak := weakPasswordError(err); weak != nil {
+			return weak
+		}
 		if e, ok := status.FromError(err); ok {
 			switch e.Code() {
 			case codes.AlreadyExists:
@@ -46,22 +91,585 @@ func (c *GRPCClient) Register(ctx context.Context, login, password string) error
 	return nil
 }
 
-func (c *GRPCClient) Login(ctx context.Context, login, password string) (string, error) {
-	req := authv1.LoginRequest{Email: login, Password: password, AppId: 1}
+// Login returns access and refresh tokens and master key of the vault wrapped with the password,
+// the key is empty for accounts, which derive it from the password.
+// Password is checked with SRP, so it is not sent to the server on login.
+// Accounts without SRP verifier fail SRP login as wrong passwords do, they log in with the password once.
+// If the account has two-factor authentication, only login ticket is returned for VerifyLogin.
+func (c *GRPCClient) Login(ctx context.Context, login, password string) (models.Login, error) {
+	req := authv1.BeginLoginRequest{Email: login, AppId: c.appID, AppSecret: c.appSecret}
+
+	var header metadata.MD
+	challenge, err := c.client.BeginLogin(ctx, &req, grpc.Header(&header))
+	if err != nil {
+		return models.Login{}, loginError(err, header)
+	}
+	public, session, err := clientSession(login, password, challenge)
+	if err != nil {
+		return models.Login{}, err
+	}
+
+	res, err := c.client.FinishLogin(ctx, &authv1.FinishLoginRequest{
+		Handshake:    challenge.Handshake,
+		ClientPublic: public,
+		ClientProof:  session.ClientProof,
+		Device:       c.device,
+	}, grpc.Header(&header))
+	if status.Code(err) == codes.InvalidArgument {
+		return c.passwordLogin(ctx, login, password)
+	}
+	if err != nil {
+		return models.Login{}, loginError(err, header)
+	}
+	if !session.VerifyServer(res.ServerProof) {
+		return models.Login{}, ErrServerProof
+	}
+
+	if res.TotpRequired {
+		c.ticketSealKey = session.SealKey()
+		return loginFromResponse(res), nil
+	}
+	return unsealLogin(res, session.SealKey())
+}
+
+// passwordLogin logs in account, which has no SRP verifier yet, with the password and sends verifier
+// of the password, so the next logins use SRP. Logins of other accounts fail as wrong passwords do.
+func (c *GRPCClient) passwordLogin(ctx context.Context, login, password string) (models.Login, error) {
+	verifier, err := newVerifier(login, password)
+	if err != nil {
+		return models.Login{}, err
+	}
+	req := authv1.LoginRequest{
+		Email:     login,
+		Password:  password,
+		AppId:     c.appID,
+		AppSecret: c.appSecret,
+		Device:    c.device,
+		Verifier:  verifier,
+	}
+
+	var header metadata.MD
+	res, err := c.client.Login(ctx, &req, grpc.Header(&header))
+	if err != nil {
+		return models.Login{}, loginError(err, header)
+	}
+	c.ticketSealKey = nil
+	return loginFromResponse(res), nil
+}
+
+// passwordProof proves the password for new SRP handshake, requests, which require the password again,
+// carry the proof instead of the password.
+func (c *GRPCClient) passwordProof(ctx context.Context, login, password string) (*authv1.PasswordProof, error) {
+	req := authv1.BeginLoginRequest{Email: login, AppId: c.appID, AppSecret: c.appSecret}
 
-	res, err := c.client.Login(ctx, &req)
+	var header metadata.MD
+	challenge, err := c.client.BeginLogin(ctx, &req, grpc.Header(&header))
+	if err != nil {
+		return nil, loginError(err, header)
+	}
+	public, session, err := clientSession(login, password, challenge)
+	if err != nil {
+		return nil, err
+	}
+	return &authv1.PasswordProof{
+		Handshake:    challenge.Handshake,
+		ClientPublic: public,
+		ClientProof:  session.ClientProof,
+	}, nil
+}
+
+// clientSession computes SRP session of the password for the challenge of the server
+// and returns ephemeral public key of the client with the session.
+func clientSession(login, password string, challenge *authv1.BeginLoginResponse) ([]byte, srp.Session, error) {
+	private, public, err := srp.NewClient()
+	if err != nil {
+		return nil, srp.Session{}, err
+	}
+	session, err := srp.ClientSession(login, challenge.Salt, srp.Secret(password, challenge.Salt), private,
+		challenge.ServerPublic)
+	if err != nil {
+		return nil, srp.Session{}, fmt.Errorf("%w: %w", ErrServerProof, err)
+	}
+	return public, session, nil
+}
+
+// loginError returns error of the failed login.
+func loginError(err error, header metadata.MD) error {
+	if e, ok := status.FromError(err); ok {
+		switch e.Code() {
+		case codes.ResourceExhausted:
+			return lockedError(header)
+		case codes.FailedPrecondition:
+			return ErrEmailNotVerified
+		case codes.PermissionDenied:
+			return ErrInvalidApp
+		case codes.InvalidArgument:
+			return fmt.Errorf("invalid login or password")
+		}
+	}
+	return fmt.Errorf("something went wrong")
+}
+
+// checkPassword checks the new password against the password policy of the server before the slow derivation
+// of the verifier, the server checks the password again, see weakPasswordError.
+// Only prefix of SHA-1 hash of the password is sent to get breached passwords of its range.
+func (c *GRPCClient) checkPassword(ctx context.Context, login, newPassword string) error {
+	prefix := password.HashPrefix(newPassword)
+	res, err := c.client.GetPasswordPolicy(ctx, &authv1.GetPasswordPolicyRequest{HashPrefix: prefix})
+	if err != nil {
+		return fmt.Errorf("something went wrong")
+	}
+
+	breached := password.Range{Prefix: prefix, Counts: make(map[string]int, len(res.Breached))}
+	for _, b := range res.Breached {
+		breached.Counts[strings.ToUpper(b.HashSuffix)] = int(b.Count)
+	}
+	policy := password.Policy{
+		MinLength:  int(res.MinLength),
+		MinClasses: int(res.MinClasses),
+		MinEntropy: res.MinEntropy,
+		Breached:   breached,
+	}
+	if err := policy.Check(newPassword, login); err != nil {
+		var weak *password.Error
+		if errors.As(err, &weak) {
+			return fmt.Errorf("password is too weak: %w", weak)
+		}
+		return err
+	}
+	return nil
+}
+
+// weakPasswordError returns error with requirements of the password policy, which the new password does not satisfy.
+// It returns nil, if err is not rejection of the password.
+func weakPasswordError(err error) error {
+	e, ok := status.FromError(err)
+	if !ok || e.Code() != codes.InvalidArgument {
+		return nil
+	}
+	var violations []string
+	for _, detail := range e.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		if !ok {
+			continue
+		}
+		for _, v := range badRequest.GetFieldViolations() {
+			if v.GetField() == "password" {
+				violations = append(violations, v.GetDescription())
+			}
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("password is too weak: %s", strings.Join(violations, "; "))
+}
+
+// newVerifier computes SRP verifier of the password with new salt.
+func newVerifier(login, password string) (*authv1.PasswordVerifier, error) {
+	salt, err := srp.NewSalt()
+	if err != nil {
+		return nil, err
+	}
+	return &authv1.PasswordVerifier{Salt: salt, Verifier: srp.Verifier(login, salt, srp.Secret(password, salt))}, nil
+}
+
+// lockedError returns error with time to wait before the next login attempt.
+func lockedError(header metadata.MD) error {
+	values := header.Get(retryAfterHeader)
+	if len(values) == 0 {
+		return fmt.Errorf("%w, please try again later", ErrTooManyAttempts)
+	}
+	seconds, err := strconv.Atoi(values[0])
+	if err != nil {
+		return fmt.Errorf("%w, please try again later", ErrTooManyAttempts)
+	}
+	return fmt.Errorf("%w, please try again in %s", ErrTooManyAttempts, time.Duration(seconds)*time.Second)
+}
+
+// VerifyLogin completes login with the code of the authenticator app or backup code.
+func (c *GRPCClient) VerifyLogin(ctx context.Context, ticket string, code string) (models.Login, error) {
+	req := authv1.VerifyLoginRequest{LoginTicket: ticket, Code: code, Device: c.device}
+
+	res, err := c.client.VerifyLogin(ctx, &req)
+	if err != nil {
+		if e, ok := status.FromError(err); ok && e.Code() == codes.Unauthenticated {
+			return models.Login{}, fmt.Errorf("invalid code")
+		}
+		return models.Login{}, fmt.Errorf("something went wrong")
+	}
+	return unsealLogin(res, c.ticketSealKey)
+}
+
+// unsealLogin decrypts the vault key encrypted with the key of SRP session,
+// sealKey is empty after login with the password, the vault key is not encrypted then.
+func unsealLogin(res *authv1.LoginResponse, sealKey []byte) (models.Login, error) {
+	login := loginFromResponse(res)
+	if len(sealKey) == 0 || len(login.VaultKey) == 0 {
+		return login, nil
+	}
+	vaultKey, err := encrypt.Decrypt(sealKey, login.VaultKey)
+	if err != nil {
+		return models.Login{}, fmt.Errorf("decrypt vault key: %w", err)
+	}
+	login.VaultKey = vaultKey
+	return login, nil
+}
+
+func loginFromResponse(res *authv1.LoginResponse) models.Login {
+	if res.TotpRequired {
+		return models.Login{Ticket: res.LoginTicket}
+	}
+	return models.Login{
+		Tokens: models.Tokens{
+			AccessToken:  res.Token,
+			RefreshToken: res.RefreshToken,
+			ExpiresAt:    time.Unix(res.ExpiresAt, 0),
+		},
+		VaultKey: res.VaultKey,
+	}
+}
+
+// EnrollTOTP returns new second factor secret and provisioning URI for authenticator apps,
+// the password confirms the user.
+func (c *GRPCClient) EnrollTOTP(ctx context.Context, token, login, password string) ([]byte, string, error) {
+	proof, err := c.passwordProof(ctx, login, password)
+	if err != nil {
+		return nil, "", err
+	}
+	req := authv1.EnrollTOTPRequest{Token: token, Proof: proof}
+
+	var header metadata.MD
+	res, err := c.client.EnrollTOTP(ctx, &req, grpc.Header(&header))
 	if err != nil {
 		if e, ok := status.FromError(err); ok {
 			switch e.Code() {
+			case codes.ResourceExhausted:
+				return nil, "", lockedError(header)
 			case codes.InvalidArgument:
-				return "", fmt.Errorf("invalid login or argument")
-			default:
-				return "", fmt.Errorf("something went wrong")
+				return nil, "", fmt.Errorf("invalid password")
+			case codes.FailedPrecondition:
+				return nil, "", fmt.Errorf("two-factor authentication already enabled")
+			}
+		}
+		return nil, "", fmt.Errorf("something went wrong")
+	}
+	return res.Secret, res.Uri, nil
+}
+
+// ConfirmTOTP enables two-factor authentication and returns backup codes, the password confirms the user.
+func (c *GRPCClient) ConfirmTOTP(ctx context.Context, token, login, password, code string) ([]string, error) {
+	proof, err := c.passwordProof(ctx, login, password)
+	if err != nil {
+		return nil, err
+	}
+	req := authv1.ConfirmTOTPRequest{Token: token, Proof: proof, Code: code}
+
+	var header metadata.MD
+	res, err := c.client.ConfirmTOTP(ctx, &req, grpc.Header(&header))
+	if err != nil {
+		if e, ok := status.FromError(err); ok {
+			switch e.Code() {
+			case codes.ResourceExhausted:
+				return nil, lockedError(header)
+			case codes.InvalidArgument:
+				return nil, fmt.Errorf("invalid password or code")
+			case codes.FailedPrecondition:
+				return nil, fmt.Errorf("two-factor authentication already enabled")
 			}
 		}
-		return "", fmt.Errorf("something went wrong")
+		return nil, fmt.Errorf("something went wrong")
+	}
+	return res.BackupCodes, nil
+}
+
+// Refresh exchanges refresh token for new tokens, the refresh token can not be used again.
+func (c *GRPCClient) Refresh(ctx context.Context, refreshToken string) (models.Tokens, error) {
+	req := authv1.RefreshRequest{RefreshToken: refreshToken, AppId: c.appID}
+
+	res, err := c.client.Refresh(ctx, &req)
+	if err != nil {
+		if e, ok := status.FromError(err); ok && e.Code() == codes.Unauthenticated {
+			return models.Tokens{}, ErrSessionExpired
+		}
+		return models.Tokens{}, fmt.Errorf("something went wrong")
 	}
-	return res.Token, nil
+	return models.Tokens{
+		AccessToken:  res.Token,
+		RefreshToken: res.RefreshToken,
+		ExpiresAt:    time.Unix(res.ExpiresAt, 0),
+	}, nil
+}
+
+// Logout revokes refresh token on the server.
+func (c *GRPCClient) Logout(ctx context.Context, refreshToken string) error {
+	req := authv1.LogoutRequest{RefreshToken: refreshToken}
+
+	if _, err := c.client.Logout(ctx, &req); err != nil {
+		return fmt.Errorf("something went wrong")
+	}
+	return nil
+}
+
+// SetRecovery saves verifier of the new recovery kit, the password and the second factor code confirm the user.
+// code is empty, when two-factor authentication is not enabled.
+func (c *GRPCClient) SetRecovery(ctx context.Context, token, login, password, code string, verifier []byte) error {
+	proof, err := c.passwordProof(ctx, login, password)
+	if err != nil {
+		return err
+	}
+	req := authv1.SetRecoveryRequest{Token: token, Proof: proof, Code: code, Verifier: verifier}
+
+	var header metadata.MD
+	_, err = c.client.SetRecovery(ctx, &req, grpc.Header(&header))
+	if err != nil {
+		if e, ok := status.FromError(err); ok {
+			switch e.Code() {
+			case codes.ResourceExhausted:
+				return lockedError(header)
+			case codes.InvalidArgument:
+				return fmt.Errorf("invalid password or code")
+			}
+		}
+		return fmt.Errorf("something went wrong")
+	}
+	return nil
+}
+
+// Recover sets new password of the user with proof restored from the recovery kit.
+func (c *GRPCClient) Recover(ctx context.Context, login string, proof []byte, password string, vaultKey []byte) error {
+	if err := c.checkPassword(ctx, login, password); err != nil {
+		return err
+	}
+	verifier, err := newVerifier(login, password)
+	if err != nil {
+		return err
+	}
+	req := authv1.RecoverRequest{Email: login, Proof: proof, Password: password, VaultKey: vaultKey, Verifier: verifier}
+
+	_, err = c.client.Recover(ctx, &req)
+	if err != nil {
+		if weak := weakPasswordError(err); weak != nil {
+			return weak
+		}
+		if e, ok := status.FromError(err); ok && e.Code() == codes.InvalidArgument {
+			return fmt.Errorf("recovery codes do not match the account")
+		}
+		return fmt.Errorf("something went wrong")
+	}
+	return nil
+}
+
+// VerifyEmail confirms email of the user with the code from the mail.
+func (c *GRPCClient) VerifyEmail(ctx context.Context, code string) error {
+	if _, err := c.client.VerifyEmail(ctx, &authv1.VerifyEmailRequest{Token: code}); err != nil {
+		if e, ok := status.FromError(err); ok && e.Code() == codes.InvalidArgument {
+			return fmt.Errorf("invalid or expired code")
+		}
+		return fmt.Errorf("something went wrong")
+	}
+	return nil
+}
+
+// RequestPasswordReset sends password reset code to the email.
+func (c *GRPCClient) RequestPasswordReset(ctx context.Context, login string) error {
+	var header metadata.MD
+	_, err := c.client.RequestPasswordReset(ctx, &authv1.RequestPasswordResetRequest{Email: login}, grpc.Header(&header))
+	if err != nil {
+		if e, ok := status.FromError(err); ok {
+			switch e.Code() {
+			case codes.ResourceExhausted:
+				return lockedError(header)
+			case codes.InvalidArgument:
+				return fmt.Errorf("invalid email")
+			}
+		}
+		return fmt.Errorf("something went wrong")
+	}
+	return nil
+}
+
+// ResetPassword sets new password of the user with the code from the password reset mail.
+func (c *GRPCClient) ResetPassword(ctx context.Context, login, code, password string) error {
+	if err := c.checkPassword(ctx, login, password); err != nil {
+		return err
+	}
+	verifier, err := newVerifier(login, password)
+	if err != nil {
+		return err
+	}
+	req := authv1.ResetPasswordRequest{Token: code, Password: password, Verifier: verifier}
+	if _, err := c.client.ResetPassword(ctx, &req); err != nil {
+		if weak := weakPasswordError(err); weak != nil {
+			return weak
+		}
+		if e, ok := status.FromError(err); ok && e.Code() == codes.InvalidArgument {
+			return fmt.Errorf("invalid or expired code")
+		}
+		return fmt.Errorf("something went wrong")
+	}
+	return nil
+}
+
+// ChangePassword replaces password and wrapped master key of the vault on the server.
+// The old password and the second factor code confirm the user, code is empty,
+// when two-factor authentication is not enabled. Other sessions of the user are revoked, the current one stays.
+func (c *GRPCClient) ChangePassword(ctx context.Context, token, login, oldPassword, code, newPassword string,
+	vaultKey []byte) error {
+	if err := c.checkPassword(ctx, login, newPassword); err != nil {
+		return err
+	}
+	verifier, err := newVerifier(login, newPassword)
+	if err != nil {
+		return err
+	}
+	proof, err := c.passwordProof(ctx, login, oldPassword)
+	if err != nil {
+		return err
+	}
+	req := authv1.ChangePasswordRequest{
+		Token:       token,
+		Proof:       proof,
+		Code:        code,
+		NewPassword: newPassword,
+		VaultKey:    vaultKey,
+		AppId:       c.appID,
+		AppSecret:   c.appSecret,
+		Verifier:    verifier,
+	}
+
+	var header metadata.MD
+	_, err = c.client.ChangePassword(ctx, &req, grpc.Header(&header))
+	if err != nil {
+		if weak := weakPasswordError(err); weak != nil {
+			return weak
+		}
+		if e, ok := status.FromError(err); ok {
+			switch e.Code() {
+			case codes.ResourceExhausted:
+				return lockedError(header)
+			case codes.InvalidArgument:
+				return fmt.Errorf("invalid password or code")
+			case codes.PermissionDenied:
+				return ErrInvalidApp
+			}
+		}
+		return fmt.Errorf("something went wrong")
+	}
+	return nil
+}
+
+// Sessions returns active sessions of the user and id of the current session.
+func (c *GRPCClient) Sessions(ctx context.Context, token string) ([]models.Session, string, error) {
+	res, err := c.client.ListSessions(ctx, &authv1.ListSessionsRequest{Token: token})
+	if err != nil {
+		if e, ok := status.FromError(err); ok && e.Code() == codes.Unauthenticated {
+			return nil, "", ErrSessionExpired
+		}
+		return nil, "", fmt.Errorf("something went wrong")
+	}
+
+	var current string
+	sessions := make([]models.Session, 0, len(res.GetSessions()))
+	for _, session := range res.GetSessions() {
+		sessions = append(sessions, models.Session{
+			ID:         session.GetId(),
+			Device:     session.GetDevice(),
+			Address:    session.GetAddress(),
+			CreatedAt:  time.Unix(session.GetCreatedAt(), 0),
+			LastSeenAt: time.Unix(session.GetLastSeenAt(), 0),
+		})
+		if session.GetCurrent() {
+			current = session.GetId()
+		}
+	}
+	return sessions, current, nil
+}
+
+// AuditEvents returns page of the account activity selected by filter, newest first,
+// and token of the next page, it is empty on the last page.
+func (c *GRPCClient) AuditEvents(ctx context.Context, token string, filter models.AuditFilter,
+	pageToken string) ([]models.AuditEvent, string, error) {
+	req := &authv1.ListAuditEventsRequest{
+		Token:     token,
+		Types:     filter.Types,
+		Outcome:   filter.Outcome,
+		PageSize:  int32(filter.Limit),
+		PageToken: pageToken,
+	}
+	if !filter.Since.IsZero() {
+		req.Since = filter.Since.Unix()
+	}
+	if !filter.Until.IsZero() {
+		req.Until = filter.Until.Unix()
+	}
+	res, err := c.client.ListAuditEvents(ctx, req)
+	if err != nil {
+		if e, ok := status.FromError(err); ok {
+			switch e.Code() {
+			case codes.Unauthenticated:
+				return nil, "", ErrSessionExpired
+			case codes.InvalidArgument:
+				return nil, "", errors.New(e.Message())
+			}
+		}
+		return nil, "", fmt.Errorf("something went wrong")
+	}
+
+	events := make([]models.AuditEvent, 0, len(res.GetEvents()))
+	for _, event := range res.GetEvents() {
+		events = append(events, models.AuditEvent{
+			ID:        event.GetId(),
+			Type:      event.GetType(),
+			AppID:     int(event.GetAppId()),
+			Address:   event.GetAddress(),
+			Outcome:   event.GetOutcome(),
+			CreatedAt: time.Unix(event.GetCreatedAt(), 0),
+		})
+	}
+	return events, res.GetNextPageToken(), nil
+}
+
+// RevokeSession signs out the device of the session, its connection to the keeper server is closed.
+func (c *GRPCClient) RevokeSession(ctx context.Context, token string, sessionID string) error {
+	_, err := c.client.RevokeSession(ctx, &authv1.RevokeSessionRequest{Token: token, SessionId: sessionID})
+	if err != nil {
+		if e, ok := status.FromError(err); ok {
+			switch e.Code() {
+			case codes.Unauthenticated:
+				return ErrSessionExpired
+			case codes.NotFound:
+				return fmt.Errorf("the device is already signed out")
+			}
+		}
+		return fmt.Errorf("something went wrong")
+	}
+	return nil
+}
+
+// DeleteAccount deletes account of the user, the password and the second factor code are checked again.
+// code is empty, when two-factor authentication is not enabled.
+func (c *GRPCClient) DeleteAccount(ctx context.Context, token, login, password, code string) error {
+	proof, err := c.passwordProof(ctx, login, password)
+	if err != nil {
+		return err
+	}
+
+	var header metadata.MD
+	_, err = c.client.DeleteAccount(ctx, &authv1.DeleteAccountRequest{Token: token, Proof: proof, Code: code},
+		grpc.Header(&header))
+	if err != nil {
+		if e, ok := status.FromError(err); ok {
+			switch e.Code() {
+			case codes.ResourceExhausted:
+				return lockedError(header)
+			case codes.InvalidArgument:
+				return fmt.Errorf("invalid password or code")
+			}
+		}
+		return fmt.Errorf("something went wrong")
+	}
+	return nil
 }
 
 func (c *GRPCClient) Stop() {
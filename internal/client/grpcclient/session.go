@@ -0,0 +1,80 @@
+package grpcclient
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// refreshBefore is how long before expiration the access token is refreshed,
+// so the token does not expire while the message is on the way to the server.
+const refreshBefore = time.Minute
+
+type refresher interface {
+	Refresh(ctx context.Context, refreshToken string) (models.Tokens, error)
+	Logout(ctx context.Context, refreshToken string) error
+}
+
+// Session keeps tokens of the logged in user and refreshes access token before it expires.
+type Session struct {
+	client refresher
+	now    func() time.Time
+
+	mu     sync.Mutex
+	tokens models.Tokens
+}
+
+func NewSession(client refresher, tokens models.Tokens) *Session {
+	return &Session{
+		client: client,
+		now:    time.Now,
+		tokens: tokens,
+	}
+}
+
+// Token returns valid access token. It returns ErrSessionExpired, if the user has to login again.
+func (s *Session) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.now().Add(refreshBefore).Before(s.tokens.ExpiresAt) {
+		return s.tokens.AccessToken, nil
+	}
+	if s.tokens.RefreshToken == "" {
+		return "", ErrSessionExpired
+	}
+
+	tokens, err := s.client.Refresh(ctx, s.tokens.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+	s.tokens = tokens
+
+	return s.tokens.AccessToken, nil
+}
+
+// Logout revokes refresh token of the session.
+func (s *Session) Logout(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tokens.RefreshToken == "" {
+		return nil
+	}
+	if err := s.client.Logout(ctx, s.tokens.RefreshToken); err != nil {
+		return err
+	}
+	s.tokens = models.Tokens{}
+
+	return nil
+}
+
+// SetTokens replaces tokens of the session, when the server issues new session, e.g. after password change.
+func (s *Session) SetTokens(tokens models.Tokens) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = tokens
+}
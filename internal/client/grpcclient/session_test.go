@@ -0,0 +1,74 @@
+package grpcclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+type fakeRefresher struct {
+	refreshed []string
+	loggedOut []string
+	err       error
+}
+
+func (f *fakeRefresher) Refresh(_ context.Context, refreshToken string) (models.Tokens, error) {
+	if f.err != nil {
+		return models.Tokens{}, f.err
+	}
+	f.refreshed = append(f.refreshed, refreshToken)
+	return models.Tokens{
+		AccessToken:  "access" + refreshToken,
+		RefreshToken: refreshToken + "1",
+		ExpiresAt:    time.Unix(1000, 0).Add(15 * time.Minute),
+	}, nil
+}
+
+func (f *fakeRefresher) Logout(_ context.Context, refreshToken string) error {
+	f.loggedOut = append(f.loggedOut, refreshToken)
+	return nil
+}
+
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeRefresher{}
+	session := NewSession(client, models.Tokens{AccessToken: "access", RefreshToken: "r", ExpiresAt: time.Unix(1000, 0)})
+
+	session.now = func() time.Time { return time.Unix(900, 0) }
+	token, err := session.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "access", token)
+	assert.Empty(t, client.refreshed)
+
+	// token expires in less than a minute
+	session.now = func() time.Time { return time.Unix(950, 0) }
+	token, err = session.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "accessr", token)
+
+	token, err = session.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "accessr", token)
+	assert.Equal(t, []string{"r"}, client.refreshed, "refreshed token is reused until it expires")
+
+	require.NoError(t, session.Logout(ctx))
+	assert.Equal(t, []string{"r1"}, client.loggedOut)
+
+	session.now = func() time.Time { return time.Unix(2000, 0) }
+	_, err = session.Token(ctx)
+	assert.ErrorIs(t, err, ErrSessionExpired)
+}
+
+func TestSessionRevoked(t *testing.T) {
+	client := &fakeRefresher{err: ErrSessionExpired}
+	session := NewSession(client, models.Tokens{AccessToken: "access", RefreshToken: "r", ExpiresAt: time.Unix(1000, 0)})
+	session.now = func() time.Time { return time.Unix(1000, 0) }
+
+	_, err := session.Token(context.Background())
+	assert.ErrorIs(t, err, ErrSessionExpired)
+}
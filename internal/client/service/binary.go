@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -25,7 +24,11 @@ var (
 )
 
 func (s *Keeper) SendSaveBinary(ctx context.Context, bin models.Binary) error {
-	s.ch <- binaryToMsg(bin)
+	msg, err := s.itemToMsg(models.BinItem, bin.Key, bin.Created, bin)
+	if err != nil {
+		return err
+	}
+	s.ch <- msg
 
 	return s.saveBinary(ctx, bin)
 }
@@ -111,16 +114,8 @@ func ValidateBinary(bin models.Binary) ([]string, bool) {
 	if bin.Key == "" {
 		msg = append(msg, "file path cannot be empty")
 	}
-	if len(bin.Value) == 0 {
+	if len(bin.Value) == 0 && bin.Blob == nil {
 		msg = append(msg, "file cannot be empty")
 	}
 	return msg, len(msg) == 0
 }
-
-func binaryToMsg(bin models.Binary) models.Message {
-	value, _ := json.Marshal(bin)
-	return models.Message{
-		Type:  "new",
-		Value: value,
-	}
-}
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/SmoothWay/gophkeeper/pkg/encrypt"
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+const (
+	// chunkSize is size of the file content in one chunk of the blob.
+	chunkSize = 512 << 10
+	blobKeyID = "blob"
+)
+
+var (
+	ErrBlobIncomplete = errors.New("file content is not downloaded yet")
+	ErrBlobCorrupted  = errors.New("file content is corrupted")
+)
+
+// SendSaveBlob saves file at path too big to be sent inline.
+// Content of the file is encrypted in chunks with random key and uploaded separately,
+// the item referencing the blob is sent after the server has all chunks.
+// Upload continues after restart of the client, see ResumeUploads.
+func (s *Keeper) SendSaveBlob(ctx context.Context, bin models.Binary, path string) error {
+	const op = "service.Binary.SendSaveBlob"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("file path", path),
+	)
+
+	blob, err := s.cacheFile(path)
+	if err != nil {
+		log.Error("encrypt file error", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, ErrExtractFile)
+	}
+	bin.Value = nil
+	bin.Blob = &blob
+
+	if err := s.uploadStore.Save(ctx, bin); err != nil {
+		log.Error("save upload error", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, ErrInternal)
+	}
+	if err := s.saveBinary(ctx, bin); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.sendBlobStatus(blob.ID)
+
+	return nil
+}
+
+// ResumeUploads continues uploads of blobs interrupted earlier.
+func (s *Keeper) ResumeUploads(ctx context.Context) error {
+	const op = "service.Binary.ResumeUploads"
+
+	bins, err := s.uploadStore.All(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, bin := range bins {
+		s.sendBlobStatus(bin.Blob.ID)
+	}
+
+	return nil
+}
+
+// ExportBinary writes content of the binary item with key to the file at path.
+func (s *Keeper) ExportBinary(ctx context.Context, key string, path string) error {
+	const op = "service.Binary.ExportBinary"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("file path", path),
+	)
+
+	bin, err := s.binStore.ByKey(ctx, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// file appears only when its content is written and verified
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gophkeeper-")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if bin.Blob == nil {
+		_, err = tmp.Write(bin.Value)
+	} else {
+		err = s.writeBlob(tmp, *bin.Blob)
+	}
+	if errors.Is(err, ErrBlobCorrupted) {
+		log.Error("blob is corrupted, downloading it again", slog.String("blob id", bin.Blob.ID))
+		if err := s.blobCache.Delete(bin.Blob.ID); err != nil {
+			log.Error("delete blob error", logger.Err(err))
+		}
+		s.downloadBlob(*bin.Blob)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// cacheFile encrypts content of the file at path in chunks and writes them to the blob cache.
+func (s *Keeper) cacheFile(path string) (models.Blob, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return models.Blob{}, err
+	}
+	defer file.Close()
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return models.Blob{}, err
+	}
+	blob := models.Blob{ID: hex.EncodeToString(id), Key: make([]byte, 32)}
+	if _, err := rand.Read(blob.Key); err != nil {
+		return models.Blob{}, err
+	}
+
+	hash := sha256.New()
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(file, buf)
+		if n > 0 {
+			hash.Write(buf[:n])
+			if err := s.writeChunk(blob, buf[:n]); err != nil {
+				_ = s.blobCache.Delete(blob.ID)
+				return models.Blob{}, err
+			}
+			blob.Size += int64(n)
+			blob.Chunks++
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			_ = s.blobCache.Delete(blob.ID)
+			return models.Blob{}, err
+		}
+	}
+	if blob.Chunks == 0 {
+		return models.Blob{}, fmt.Errorf("file is empty")
+	}
+	blob.Hash = hash.Sum(nil)
+
+	return blob, nil
+}
+
+// writeChunk encrypts next chunk of the blob. Index of the chunk is encrypted with its content,
+// so the server can not reorder chunks.
+func (s *Keeper) writeChunk(blob models.Blob, data []byte) error {
+	plaintext := binary.BigEndian.AppendUint32(nil, uint32(blob.Chunks))
+	sealed, err := encrypt.Encrypt(blob.Key, blobKeyID, append(plaintext, data...))
+	if err != nil {
+		return err
+	}
+
+	return s.blobCache.WriteChunk(blob.ID, blob.Chunks, sealed)
+}
+
+// writeBlob decrypts chunks of the blob from the cache and writes them to w.
+func (s *Keeper) writeBlob(w io.Writer, blob models.Blob) error {
+	received, err := s.blobCache.Received(blob.ID)
+	if err != nil {
+		return err
+	}
+	if received < blob.Chunks {
+		s.downloadBlob(blob)
+		return ErrBlobIncomplete
+	}
+
+	hash := sha256.New()
+	for i := 0; i < blob.Chunks; i++ {
+		sealed, err := s.blobCache.ReadChunk(blob.ID, i)
+		if err != nil {
+			return err
+		}
+		plaintext, err := encrypt.Decrypt(blob.Key, sealed)
+		if err != nil || len(plaintext) < 4 || binary.BigEndian.Uint32(plaintext) != uint32(i) {
+			return ErrBlobCorrupted
+		}
+		hash.Write(plaintext[4:])
+		if _, err := w.Write(plaintext[4:]); err != nil {
+			return err
+		}
+	}
+	if !bytes.Equal(hash.Sum(nil), blob.Hash) {
+		return ErrBlobCorrupted
+	}
+
+	return nil
+}
+
+func (s *Keeper) sendBlobStatus(id string) {
+	value, _ := json.Marshal(models.BlobInfo{BlobID: id})
+	s.ch <- models.Message{Type: models.BlobStatus, Value: value}
+}
+
+// applyBlobStatus sends chunks of the blob the server does not have yet.
+func (s *Keeper) applyBlobStatus(ctx context.Context, info models.BlobInfo) {
+	const op = "service.Keeper.applyBlobStatus"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("blob id", info.BlobID),
+	)
+
+	bin, err := s.uploadStore.ByBlobID(ctx, info.BlobID)
+	if err != nil {
+		log.Warn("blob is not uploaded by the client", logger.Err(err))
+		return
+	}
+
+	// chunks are sent from another goroutine, so messages from the server are not blocked
+	go func(blob models.Blob) {
+		for i := info.Received; i < blob.Chunks; i++ {
+			data, err := s.blobCache.ReadChunk(blob.ID, i)
+			if err != nil {
+				log.Error("read blob chunk error", slog.Int("index", i), logger.Err(err))
+				return
+			}
+
+			value, _ := json.Marshal(models.Chunk{BlobID: blob.ID, Index: i, Data: data})
+			s.ch <- models.Message{Type: models.BlobChunk, Value: value}
+		}
+
+		value, _ := json.Marshal(models.BlobInfo{BlobID: blob.ID, Chunks: blob.Chunks})
+		s.ch <- models.Message{Type: models.BlobCommit, Value: value}
+	}(*bin.Blob)
+}
+
+// applyBlobCommit sends the item referencing the blob after upload of the blob is complete.
+func (s *Keeper) applyBlobCommit(ctx context.Context, info models.BlobInfo) {
+	const op = "service.Keeper.applyBlobCommit"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("blob id", info.BlobID),
+	)
+
+	bin, err := s.uploadStore.ByBlobID(ctx, info.BlobID)
+	if err != nil {
+		log.Warn("blob is not uploaded by the client", logger.Err(err))
+		return
+	}
+
+	env, err := s.sealItem(models.BinItem, bin.Key, bin.Created, bin)
+	if err != nil {
+		log.Error("seal binary error", logger.Err(err))
+		return
+	}
+	env.BlobID = bin.Blob.ID
+
+	msg, err := envelopeToMsg(env)
+	if err != nil {
+		log.Error("marshal binary error", logger.Err(err))
+		return
+	}
+	s.ch <- msg
+
+	if err := s.uploadStore.Delete(ctx, info.BlobID); err != nil {
+		log.Error("delete upload error", logger.Err(err))
+	}
+}
+
+// downloadBlob requests the first chunk of the blob missing in the cache.
+func (s *Keeper) downloadBlob(blob models.Blob) {
+	received, err := s.blobCache.Received(blob.ID)
+	if err != nil {
+		s.log.Error("query received chunks error", slog.String("op", "service.Keeper.downloadBlob"), logger.Err(err))
+		return
+	}
+	if received < blob.Chunks {
+		s.requestChunk(blob.ID, received)
+	}
+}
+
+func (s *Keeper) requestChunk(id string, index int) {
+	value, _ := json.Marshal(models.Chunk{BlobID: id, Index: index})
+	s.ch <- models.Message{Type: models.BlobRequest, Value: value}
+}
+
+// applyBlobChunk writes downloaded chunk to the cache and requests the next one.
+func (s *Keeper) applyBlobChunk(chunk models.Chunk) {
+	const op = "service.Keeper.applyBlobChunk"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("blob id", chunk.BlobID),
+	)
+
+	if err := s.blobCache.WriteChunk(chunk.BlobID, chunk.Index, chunk.Data); err != nil {
+		log.Error("write blob chunk error", slog.Int("index", chunk.Index), logger.Err(err))
+		return
+	}
+
+	if chunk.Index+1 < chunk.Chunks {
+		s.requestChunk(chunk.BlobID, chunk.Index+1)
+	}
+}
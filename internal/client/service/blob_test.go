@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+func TestBlob(t *testing.T) {
+	ctx := context.Background()
+	// two devices of the same user
+	laptop, laptopCh := newTestKeeper(t, "name@example.com")
+	phone, phoneCh := newTestKeeper(t, "name@example.com")
+
+	content := make([]byte, 2*chunkSize+100)
+	_, _ = rand.Read(content)
+	path := filepath.Join(t.TempDir(), "file.bin")
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+
+	bin := models.Binary{Type: models.BinItem, Tag: "tag", Key: "file.bin", Created: 1}
+	require.NoError(t, laptop.SendSaveBlob(ctx, bin, path))
+
+	status := <-laptopCh
+	require.Equal(t, models.BlobStatus, status.Type)
+	var info models.BlobInfo
+	require.NoError(t, json.Unmarshal(status.Value, &info))
+
+	// upload was interrupted after the first chunk
+	require.NoError(t, laptop.ResumeUploads(ctx))
+	require.Equal(t, status, <-laptopCh)
+	info.Received = 1
+	reply, _ := json.Marshal(info)
+	laptop.ApplyMessage(ctx, models.Message{Type: models.BlobStatus, Value: reply})
+
+	server := map[int][]byte{}
+	for i := 1; i < 3; i++ {
+		msg := <-laptopCh
+		require.Equal(t, models.BlobChunk, msg.Type)
+		var chunk models.Chunk
+		require.NoError(t, json.Unmarshal(msg.Value, &chunk))
+		require.Equal(t, i, chunk.Index)
+		assert.False(t, bytes.Contains(chunk.Data, content[i*chunkSize:i*chunkSize+32]), "chunk is encrypted")
+		server[i] = chunk.Data
+	}
+	server[0], _ = laptop.blobCache.ReadChunk(info.BlobID, 0)
+
+	commit := <-laptopCh
+	require.Equal(t, models.BlobCommit, commit.Type)
+	assert.JSONEq(t, `{"blob_id":"`+info.BlobID+`","received":0,"chunks":3,"size":0}`, string(commit.Value))
+
+	laptop.ApplyMessage(ctx, commit)
+	item := <-laptopCh
+	require.Equal(t, models.New, item.Type)
+	var env models.Envelope
+	require.NoError(t, json.Unmarshal(item.Value, &env))
+	assert.Equal(t, info.BlobID, env.BlobID)
+
+	// upload is complete, nothing to resume
+	require.NoError(t, laptop.ResumeUploads(ctx))
+	assert.Empty(t, laptopCh)
+
+	// the other device downloads chunks one by one
+	phone.ApplyMessage(ctx, models.Message{Type: models.Update, Value: item.Value})
+
+	out := filepath.Join(t.TempDir(), "out.bin")
+	for i := 0; i < 3; i++ {
+		if i == 1 {
+			assert.ErrorIs(t, phone.ExportBinary(ctx, "file.bin", out), ErrBlobIncomplete)
+			require.Equal(t, models.BlobRequest, (<-phoneCh).Type, "export requests missing chunk again")
+		}
+
+		msg := <-phoneCh
+		require.Equal(t, models.BlobRequest, msg.Type)
+		var request models.Chunk
+		require.NoError(t, json.Unmarshal(msg.Value, &request))
+		require.Equal(t, i, request.Index)
+
+		chunk, _ := json.Marshal(models.Chunk{BlobID: info.BlobID, Index: i, Chunks: 3, Data: server[i]})
+		phone.ApplyMessage(ctx, models.Message{Type: models.BlobChunk, Value: chunk})
+	}
+	assert.Empty(t, phoneCh)
+
+	require.NoError(t, phone.ExportBinary(ctx, "file.bin", out))
+	got, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	// chunks swapped by the server are detected
+	require.NoError(t, phone.blobCache.WriteChunk(info.BlobID, 0, server[1]))
+	require.NoError(t, phone.blobCache.WriteChunk(info.BlobID, 1, server[0]))
+	assert.ErrorIs(t, phone.ExportBinary(ctx, "file.bin", filepath.Join(t.TempDir(), "bad.bin")), ErrBlobCorrupted)
+	require.Equal(t, models.BlobRequest, (<-phoneCh).Type)
+}
@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 
@@ -12,7 +11,11 @@ import (
 )
 
 func (s *Keeper) SendSaveCard(ctx context.Context, card models.Card) error {
-	s.ch <- s.cardToMsg(card)
+	msg, err := s.itemToMsg(models.CardItem, card.Number, card.Created, card)
+	if err != nil {
+		return err
+	}
+	s.ch <- msg
 
 	return s.saveCard(ctx, card)
 }
@@ -65,11 +68,3 @@ func (s *Keeper) ValidateCard(card models.Card) ([]string, bool) {
 	}
 	return msg, len(msg) == 0
 }
-
-func (s *Keeper) cardToMsg(card models.Card) models.Message {
-	value, _ := json.Marshal(card)
-	return models.Message{
-		Type:  "new",
-		Value: value,
-	}
-}
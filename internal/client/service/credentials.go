@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 
@@ -11,7 +10,11 @@ import (
 )
 
 func (s *Keeper) SendSaveCredentials(ctx context.Context, cred models.Credentials) error {
-	s.ch <- credentialsToMsg(cred)
+	msg, err := s.itemToMsg(models.CredItem, cred.Login, cred.Created, cred)
+	if err != nil {
+		return err
+	}
+	s.ch <- msg
 
 	return s.saveCredentials(ctx, cred)
 }
@@ -64,11 +67,3 @@ func ValidateCredentials(cred models.Credentials) ([]string, bool) {
 	}
 	return msg, len(msg) == 0
 }
-
-func credentialsToMsg(cred models.Credentials) models.Message {
-	value, _ := json.Marshal(cred)
-	return models.Message{
-		Type:  "new",
-		Value: value,
-	}
-}
@@ -3,7 +3,9 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"sync"
 
 	"github.com/SmoothWay/gophkeeper/pkg/logger"
 	"github.com/SmoothWay/gophkeeper/pkg/models"
@@ -45,25 +47,100 @@ type CardStorager interface {
 	Update(ctx context.Context, card models.Card) error
 }
 
+type SharedStorager interface {
+	closeable
+	All(ctx context.Context) ([]models.SharedSecret, error)
+	Save(ctx context.Context, secret models.SharedSecret) error
+	Delete(ctx context.Context, from string, id string) error
+	DeleteAll(ctx context.Context) error
+}
+
+type TeamStorager interface {
+	closeable
+	All(ctx context.Context) ([]models.TeamSecret, error)
+	Save(ctx context.Context, secret models.TeamSecret) error
+	Retain(ctx context.Context, vaultIDs []int64) error
+}
+
+type UploadStorager interface {
+	closeable
+	All(ctx context.Context) ([]models.Binary, error)
+	ByBlobID(ctx context.Context, id string) (models.Binary, error)
+	Save(ctx context.Context, bin models.Binary) error
+	Delete(ctx context.Context, id string) error
+}
+
+// LegacyStorager keeps plaintext items saved before the local database was encrypted.
+type LegacyStorager interface {
+	closeable
+	Credentials(ctx context.Context) ([]models.Credentials, error)
+	Text(ctx context.Context) ([]models.Text, error)
+	Binary(ctx context.Context) ([]models.Binary, error)
+	Cards(ctx context.Context) ([]models.Card, error)
+	Clear(ctx context.Context) error
+}
+
+// BlobCacher keeps encrypted chunks of blobs uploaded or downloaded by the client.
+type BlobCacher interface {
+	WriteChunk(id string, index int, data []byte) error
+	ReadChunk(id string, index int) ([]byte, error)
+	Received(id string) (int, error)
+	Delete(id string) error
+}
+
+// Vault seals items before they are sent to the server.
+type Vault interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Open(data []byte) ([]byte, error)
+	ItemID(kind models.ItemType, key string) (string, error)
+	PublicKey() ([]byte, error)
+	SealTo(publicKey []byte, plaintext []byte) ([]byte, error)
+	OpenShared(data []byte) ([]byte, error)
+	NewTeamKey() ([]byte, error)
+	SealTeam(teamKey []byte, plaintext []byte) ([]byte, error)
+	OpenTeam(teamKey []byte, data []byte) ([]byte, error)
+	TeamItemID(teamKey []byte, kind models.ItemType, key string) (string, error)
+}
+
 type Keeper struct {
-	log       *slog.Logger
-	ch        chan models.Message
-	credStore CredentialsStorager
-	textStore TextStorager
-	binStore  BinaryStorager
-	cardStore CardStorager
+	log         *slog.Logger
+	ch          chan models.Message
+	vault       Vault
+	credStore   CredentialsStorager
+	textStore   TextStorager
+	binStore    BinaryStorager
+	cardStore   CardStorager
+	sharedStore SharedStorager
+	teamStore   TeamStorager
+	uploadStore UploadStorager
+	blobCache   BlobCacher
+
+	mu *sync.Mutex
+	// pending actions waiting for public key of the user, grouped by email
+	pending map[string][]func(publicKey []byte) error
+	// team vaults of the user by id
+	teams map[int64]teamVault
 }
 
-func NewKeeper(log *slog.Logger, ch chan models.Message, credStore CredentialsStorager,
-	textStore TextStorager, binStore BinaryStorager, cardStore CardStorager) *Keeper {
+func NewKeeper(log *slog.Logger, ch chan models.Message, vault Vault, credStore CredentialsStorager,
+	textStore TextStorager, binStore BinaryStorager, cardStore CardStorager, sharedStore SharedStorager,
+	teamStore TeamStorager, uploadStore UploadStorager, blobCache BlobCacher) *Keeper {
 
 	return &Keeper{
-		log:       log,
-		ch:        ch,
-		credStore: credStore,
-		textStore: textStore,
-		binStore:  binStore,
-		cardStore: cardStore,
+		log:         log,
+		ch:          ch,
+		vault:       vault,
+		credStore:   credStore,
+		textStore:   textStore,
+		binStore:    binStore,
+		cardStore:   cardStore,
+		sharedStore: sharedStore,
+		teamStore:   teamStore,
+		uploadStore: uploadStore,
+		blobCache:   blobCache,
+		mu:          &sync.Mutex{},
+		pending:     make(map[string][]func(publicKey []byte) error),
+		teams:       make(map[int64]teamVault),
 	}
 }
 
@@ -78,6 +155,34 @@ func (s *Keeper) ApplyMessage(ctx context.Context, msg models.Message) {
 		for _, value := range values {
 			s.apply(ctx, value)
 		}
+	case models.PublicKey:
+		s.applyPublicKey(msg.Value)
+	case models.Shared, models.Unshared:
+		var env models.ShareEnvelope
+		_ = json.Unmarshal(msg.Value, &env)
+
+		if msg.Type == models.Shared {
+			s.applyShared(ctx, env)
+		} else {
+			s.applyUnshared(ctx, env)
+		}
+	case models.SharedSnapshot:
+		s.applySharedSnapshot(ctx, msg.Value)
+	case models.Vaults:
+		s.applyVaults(ctx, msg.Value)
+	case models.BlobStatus, models.BlobCommit:
+		var info models.BlobInfo
+		_ = json.Unmarshal(msg.Value, &info)
+
+		if msg.Type == models.BlobStatus {
+			s.applyBlobStatus(ctx, info)
+		} else {
+			s.applyBlobCommit(ctx, info)
+		}
+	case models.BlobChunk:
+		var chunk models.Chunk
+		_ = json.Unmarshal(msg.Value, &chunk)
+		s.applyBlobChunk(chunk)
 	}
 }
 
@@ -98,6 +203,15 @@ func (s *Keeper) Stop() {
 	if err := s.credStore.Close(); err != nil {
 		log.Error("failed to close database connection for credentials storage")
 	}
+	if err := s.sharedStore.Close(); err != nil {
+		log.Error("failed to close database connection for shared storage")
+	}
+	if err := s.teamStore.Close(); err != nil {
+		log.Error("failed to close database connection for team storage")
+	}
+	if err := s.uploadStore.Close(); err != nil {
+		log.Error("failed to close database connection for upload storage")
+	}
 }
 
 func (s *Keeper) apply(ctx context.Context, value []byte) {
@@ -106,6 +220,29 @@ func (s *Keeper) apply(ctx context.Context, value []byte) {
 		slog.String("op", op),
 	)
 
+	var env models.Envelope
+	_ = json.Unmarshal(value, &env)
+
+	if env.VaultID != 0 {
+		s.applyTeamItem(ctx, env)
+		return
+	}
+
+	// the server never sees plaintext items, unsealed item is forged or stripped of encryption
+	if len(env.Data) == 0 {
+		log.Error("refused unsealed item", slog.String("item id", env.ID))
+		return
+	}
+	value, err := s.vault.Open(env.Data)
+	if err != nil {
+		log.Error(
+			"failed open sealed item",
+			slog.String("item id", env.ID),
+			logger.Err(err),
+		)
+		return
+	}
+
 	var header struct{ Type string }
 	_ = json.Unmarshal(value, &header)
 
@@ -129,6 +266,10 @@ func (s *Keeper) apply(ctx context.Context, value []byte) {
 		_ = json.Unmarshal(value, &bin)
 		if err := s.saveBinary(ctx, bin); err != nil {
 			log.Error("apply binary message error", logger.Err(err))
+			return
+		}
+		if bin.Blob != nil {
+			s.downloadBlob(*bin.Blob)
 		}
 
 	case models.CardItem.String():
@@ -139,3 +280,47 @@ func (s *Keeper) apply(ctx context.Context, value []byte) {
 		}
 	}
 }
+
+// itemToMsg seals item with the vault key and wraps it into the message for the server.
+func (s *Keeper) itemToMsg(kind models.ItemType, key string, created int64, item any) (models.Message, error) {
+	const op = "service.Keeper.itemToMsg"
+
+	env, err := s.sealItem(kind, key, created, item)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return envelopeToMsg(env)
+}
+
+// sealItem seals item with the vault key into the envelope.
+func (s *Keeper) sealItem(kind models.ItemType, key string, created int64, item any) (models.Envelope, error) {
+	plaintext, err := json.Marshal(item)
+	if err != nil {
+		return models.Envelope{}, err
+	}
+
+	id, err := s.vault.ItemID(kind, key)
+	if err != nil {
+		return models.Envelope{}, err
+	}
+
+	data, err := s.vault.Seal(plaintext)
+	if err != nil {
+		return models.Envelope{}, err
+	}
+
+	return models.Envelope{ID: id, Created: created, Data: data}, nil
+}
+
+func envelopeToMsg(env models.Envelope) (models.Message, error) {
+	value, err := json.Marshal(env)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("service.Keeper.envelopeToMsg: %w", err)
+	}
+
+	return models.Message{
+		Type:  models.New,
+		Value: value,
+	}, nil
+}
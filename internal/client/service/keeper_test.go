@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+func TestApplyUnsealed(t *testing.T) {
+	ctx := context.Background()
+	k, ch := newTestKeeper(t, "name@example.com")
+
+	// plaintext item injected by the server is refused
+	text := models.Text{Type: models.TextItem, Tag: "tag", Key: "forged", Value: "injected", Created: 1}
+	value, _ := json.Marshal(text)
+	k.ApplyMessage(ctx, models.Message{Type: models.Update, Value: value})
+	list, err := k.AllText(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	// sealed item of the vault is applied
+	text.Key = "sealed"
+	msg, err := k.itemToMsg(models.TextItem, text.Key, text.Created, text)
+	require.NoError(t, err)
+	k.ApplyMessage(ctx, models.Message{Type: models.Update, Value: msg.Value})
+	list, err = k.AllText(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Text{text}, list)
+	assert.Empty(t, ch)
+}
+
+// memLegacy keeps plaintext items in memory.
+type memLegacy struct {
+	creds   []models.Credentials
+	texts   []models.Text
+	bins    []models.Binary
+	cards   []models.Card
+	cleared bool
+}
+
+func (m *memLegacy) Close() error { return nil }
+
+func (m *memLegacy) Credentials(context.Context) ([]models.Credentials, error) { return m.creds, nil }
+
+func (m *memLegacy) Text(context.Context) ([]models.Text, error) { return m.texts, nil }
+
+func (m *memLegacy) Binary(context.Context) ([]models.Binary, error) { return m.bins, nil }
+
+func (m *memLegacy) Cards(context.Context) ([]models.Card, error) { return m.cards, nil }
+
+func (m *memLegacy) Clear(context.Context) error {
+	m.creds, m.texts, m.bins, m.cards = nil, nil, nil, nil
+	m.cleared = true
+	return nil
+}
+
+func TestImportLegacy(t *testing.T) {
+	ctx := context.Background()
+	k, ch := newTestKeeper(t, "name@example.com")
+
+	cred := models.Credentials{Type: models.CredItem, Tag: "tag", Login: "login", Password: "password", Created: 1}
+	text := models.Text{Type: models.TextItem, Tag: "tag", Key: "key", Value: "value", Created: 2}
+	legacy := &memLegacy{creds: []models.Credentials{cred}, texts: []models.Text{text}}
+
+	require.NoError(t, k.ImportLegacy(ctx, legacy))
+	assert.True(t, legacy.cleared)
+
+	// items are uploaded sealed
+	require.Len(t, ch, 2)
+	for i := 0; i < 2; i++ {
+		msg := <-ch
+		var env models.Envelope
+		require.NoError(t, json.Unmarshal(msg.Value, &env))
+		assert.NotEmpty(t, env.ID)
+		assert.NotContains(t, string(env.Data), "password")
+	}
+
+	creds, err := k.AllCredentials(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Credentials{cred}, creds)
+	texts, err := k.AllText(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Text{text}, texts)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// ImportLegacy seals and uploads items saved in plaintext before the local database was encrypted,
+// then deletes the plaintext. On error nothing is deleted and the import is repeated on the next login.
+func (s *Keeper) ImportLegacy(ctx context.Context, legacy LegacyStorager) error {
+	const op = "service.Keeper.ImportLegacy"
+
+	creds, err := legacy.Credentials(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, cred := range creds {
+		if err := s.SendSaveCredentials(ctx, cred); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	texts, err := legacy.Text(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, text := range texts {
+		if err := s.SendSaveText(ctx, text); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	bins, err := legacy.Binary(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, bin := range bins {
+		if err := s.SendSaveBinary(ctx, bin); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	cards, err := legacy.Cards(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, card := range cards {
+		if err := s.SendSaveCard(ctx, card); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := legacy.Clear(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+var ErrUnknownItemType = errors.New("unknown item type")
+
+// RegisterKey sends public key of the user to the server, so other users can share items with the user.
+func (s *Keeper) RegisterKey(ctx context.Context) error {
+	const op = "service.Keeper.RegisterKey"
+
+	publicKey, err := s.vault.PublicKey()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	value, _ := json.Marshal(models.UserKey{Key: publicKey})
+	s.ch <- models.Message{Type: models.PublicKey, Value: value}
+
+	return nil
+}
+
+// SendShare shares item of type kind with the user with email.
+// Item is sealed to public key of the recipient, when the server replies with the key.
+func (s *Keeper) SendShare(ctx context.Context, kind models.ItemType, key string, email string) error {
+	const op = "service.Keeper.SendShare"
+
+	item, created, err := s.item(ctx, kind, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	plaintext, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	id, err := s.vault.ItemID(kind, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.withPublicKey(email, func(publicKey []byte) error {
+		data, err := s.vault.SealTo(publicKey, plaintext)
+		if err != nil {
+			return err
+		}
+
+		msg, _ := json.Marshal(models.ShareEnvelope{ID: id, To: email, Created: created, Data: data})
+		s.ch <- models.Message{Type: models.Share, Value: msg}
+		return nil
+	})
+
+	return nil
+}
+
+// withPublicKey requests public key of the user with email from the server
+// and calls fn, when the server replies with the key.
+func (s *Keeper) withPublicKey(email string, fn func(publicKey []byte) error) {
+	s.mu.Lock()
+	s.pending[email] = append(s.pending[email], fn)
+	s.mu.Unlock()
+
+	value, _ := json.Marshal(models.UserKey{Email: email})
+	s.ch <- models.Message{Type: models.KeyRequest, Value: value}
+}
+
+// SendRevoke revokes access of the user with email to item of type kind.
+func (s *Keeper) SendRevoke(ctx context.Context, kind models.ItemType, key string, email string) error {
+	const op = "service.Keeper.SendRevoke"
+
+	id, err := s.vault.ItemID(kind, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	value, _ := json.Marshal(models.ShareEnvelope{ID: id, To: email})
+	s.ch <- models.Message{Type: models.Revoke, Value: value}
+
+	return nil
+}
+
+func (s *Keeper) AllShared(ctx context.Context) ([]models.SharedSecret, error) {
+	const op = "service.Shared.All"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	list, err := s.sharedStore.All(ctx)
+	if err != nil {
+		log.Error("query all shared items error", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, ErrInternal)
+	}
+
+	return list, nil
+}
+
+// item returns item of type kind from the local storage and its creation time.
+func (s *Keeper) item(ctx context.Context, kind models.ItemType, key string) (any, int64, error) {
+	switch kind {
+	case models.CredItem:
+		cred, err := s.credStore.ByLogin(ctx, key)
+		return cred, cred.Created, err
+	case models.TextItem:
+		text, err := s.textStore.ByKey(ctx, key)
+		return text, text.Created, err
+	case models.BinItem:
+		bin, err := s.binStore.ByKey(ctx, key)
+		return bin, bin.Created, err
+	case models.CardItem:
+		card, err := s.cardStore.ByNumber(ctx, key)
+		return card, card.Created, err
+	}
+
+	return nil, 0, ErrUnknownItemType
+}
+
+// applyPublicKey seals items waiting for the public key and sends them to the server.
+func (s *Keeper) applyPublicKey(value []byte) {
+	const op = "service.Keeper.applyPublicKey"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	var key models.UserKey
+	_ = json.Unmarshal(value, &key)
+
+	s.mu.Lock()
+	pending := s.pending[key.Email]
+	delete(s.pending, key.Email)
+	s.mu.Unlock()
+
+	if len(key.Key) == 0 {
+		log.Error("recipient has not registered public key, items are not shared", slog.Int("items", len(pending)))
+		return
+	}
+
+	for _, fn := range pending {
+		if err := fn(key.Key); err != nil {
+			log.Error("failed seal item to recipient", logger.Err(err))
+		}
+	}
+}
+
+// applyShared opens item shared with the user and saves it into the local storage.
+func (s *Keeper) applyShared(ctx context.Context, env models.ShareEnvelope) {
+	const op = "service.Keeper.applyShared"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("from", env.From),
+	)
+
+	item, err := s.vault.OpenShared(env.Data)
+	if err != nil {
+		log.Error("failed open shared item", logger.Err(err))
+		return
+	}
+
+	secret := models.SharedSecret{ID: env.ID, From: env.From, Created: env.Created, Item: item}
+	if err := s.sharedStore.Save(ctx, secret); err != nil {
+		log.Error("save shared item error", logger.Err(err))
+	}
+}
+
+// applySharedSnapshot replaces all shared items with items from the server.
+func (s *Keeper) applySharedSnapshot(ctx context.Context, value []byte) {
+	const op = "service.Keeper.applySharedSnapshot"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	var envs []models.ShareEnvelope
+	_ = json.Unmarshal(value, &envs)
+
+	if err := s.sharedStore.DeleteAll(ctx); err != nil {
+		log.Error("delete shared items error", logger.Err(err))
+		return
+	}
+	for _, env := range envs {
+		s.applyShared(ctx, env)
+	}
+}
+
+// applyUnshared deletes item, access to which was revoked by the owner.
+func (s *Keeper) applyUnshared(ctx context.Context, env models.ShareEnvelope) {
+	const op = "service.Keeper.applyUnshared"
+
+	if err := s.sharedStore.Delete(ctx, env.From, env.ID); err != nil {
+		s.log.Error("delete shared item error", slog.String("op", op), logger.Err(err))
+	}
+}
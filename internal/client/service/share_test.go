@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/internal/client/storage"
+	"github.com/SmoothWay/gophkeeper/internal/client/vault"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+func newTestKeeper(t *testing.T, email string) (*Keeper, chan models.Message) {
+	t.Helper()
+
+	v := vault.New()
+	require.NoError(t, v.Unlock(email, "password"))
+
+	path := filepath.Join(t.TempDir(), "client.db")
+	require.NoError(t, storage.Migrate(path))
+	cred, err := storage.NewCredentials(path, time.Second, v)
+	require.NoError(t, err)
+	text, err := storage.NewText(path, time.Second, v)
+	require.NoError(t, err)
+	bin, err := storage.NewBinary(path, time.Second, v)
+	require.NoError(t, err)
+	card, err := storage.NewCard(path, time.Second, v)
+	require.NoError(t, err)
+	shared, err := storage.NewShared(path, time.Second, v)
+	require.NoError(t, err)
+	team, err := storage.NewTeam(path, time.Second, v)
+	require.NoError(t, err)
+	uploads, err := storage.NewUploads(path, time.Second, v)
+	require.NoError(t, err)
+	blobs, err := storage.NewBlobCache(t.TempDir())
+	require.NoError(t, err)
+
+	ch := make(chan models.Message, 10)
+	k := NewKeeper(slog.New(slog.NewTextHandler(io.Discard, nil)), ch, v, cred, text, bin, card, shared, team, uploads, blobs)
+	t.Cleanup(k.Stop)
+
+	return k, ch
+}
+
+func TestShare(t *testing.T) {
+	ctx := context.Background()
+	owner, ownerCh := newTestKeeper(t, "owner@example.com")
+	recipient, recipientCh := newTestKeeper(t, "recipient@example.com")
+
+	require.NoError(t, recipient.RegisterKey(ctx))
+	register := <-recipientCh
+	require.Equal(t, models.PublicKey, register.Type)
+
+	text := models.Text{Type: models.TextItem, Tag: "tag", Key: "key", Value: "secret", Created: 1}
+	require.NoError(t, owner.saveText(ctx, text))
+
+	err := owner.SendShare(ctx, models.TextItem, "missing", "recipient@example.com")
+	assert.Error(t, err)
+
+	require.NoError(t, owner.SendShare(ctx, models.TextItem, "key", "recipient@example.com"))
+	request := <-ownerCh
+	require.Equal(t, models.KeyRequest, request.Type)
+	assert.JSONEq(t, `{"email":"recipient@example.com","key":null}`, string(request.Value))
+
+	// server replies with the key registered by the recipient
+	var key models.UserKey
+	require.NoError(t, json.Unmarshal(register.Value, &key))
+	key.Email = "recipient@example.com"
+	reply, _ := json.Marshal(key)
+	owner.ApplyMessage(ctx, models.Message{Type: models.PublicKey, Value: reply})
+
+	share := <-ownerCh
+	require.Equal(t, models.Share, share.Type)
+	assert.NotContains(t, string(share.Value), "secret")
+
+	// server adds sender and relays the item to the recipient
+	var env models.ShareEnvelope
+	require.NoError(t, json.Unmarshal(share.Value, &env))
+	env.From = "owner@example.com"
+	shared, _ := json.Marshal(env)
+	recipient.ApplyMessage(ctx, models.Message{Type: models.Shared, Value: shared})
+
+	list, err := recipient.AllShared(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "owner@example.com", list[0].From)
+	var got models.Text
+	require.NoError(t, json.Unmarshal(list[0].Item, &got))
+	assert.Equal(t, text, got)
+
+	require.NoError(t, owner.SendRevoke(ctx, models.TextItem, "key", "recipient@example.com"))
+	revoke := <-ownerCh
+	require.Equal(t, models.Revoke, revoke.Type)
+
+	unshared, _ := json.Marshal(models.ShareEnvelope{ID: env.ID, From: "owner@example.com"})
+	recipient.ApplyMessage(ctx, models.Message{Type: models.Unshared, Value: unshared})
+
+	list, err = recipient.AllShared(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+var (
+	ErrUnknownVault = errors.New("unknown team vault")
+	ErrReadOnly     = errors.New("team vault is read only for the user")
+)
+
+// teamVault is a team vault of the user with opened vault key.
+type teamVault struct {
+	models.TeamVault
+	key []byte
+}
+
+// CreateTeamVault creates team vault with name owned by the user.
+// Key of the vault is sealed to public key of the user.
+func (s *Keeper) CreateTeamVault(ctx context.Context, name string) error {
+	const op = "service.Keeper.CreateTeamVault"
+
+	teamKey, err := s.vault.NewTeamKey()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	publicKey, err := s.vault.PublicKey()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	wrapped, err := s.vault.SealTo(publicKey, teamKey)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	value, _ := json.Marshal(models.TeamVault{Name: name, Key: wrapped})
+	s.ch <- models.Message{Type: models.VaultCreate, Value: value}
+
+	return nil
+}
+
+// SetTeamMember sets role of the user with email in the team vault with name, empty role removes the user.
+// Key of the vault is sealed to public key of the member, when the server replies with the key.
+func (s *Keeper) SetTeamMember(ctx context.Context, name string, email string, role string) error {
+	const op = "service.Keeper.SetTeamMember"
+
+	team, err := s.teamByName(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if role == "" {
+		value, _ := json.Marshal(models.Member{VaultID: team.ID, Email: email})
+		s.ch <- models.Message{Type: models.VaultMember, Value: value}
+		return nil
+	}
+
+	s.withPublicKey(email, func(publicKey []byte) error {
+		wrapped, err := s.vault.SealTo(publicKey, team.key)
+		if err != nil {
+			return err
+		}
+
+		value, _ := json.Marshal(models.Member{VaultID: team.ID, Email: email, Role: role, Key: wrapped})
+		s.ch <- models.Message{Type: models.VaultMember, Value: value}
+		return nil
+	})
+
+	return nil
+}
+
+// SendTeamItem copies item of type kind from the local storage to the team vault with name.
+func (s *Keeper) SendTeamItem(ctx context.Context, name string, kind models.ItemType, key string) error {
+	const op = "service.Keeper.SendTeamItem"
+
+	team, err := s.teamByName(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if team.Role == models.RoleViewer {
+		return fmt.Errorf("%s: %w", op, ErrReadOnly)
+	}
+
+	item, created, err := s.item(ctx, kind, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	plaintext, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	id, err := s.vault.TeamItemID(team.key, kind, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	data, err := s.vault.SealTeam(team.key, plaintext)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	value, _ := json.Marshal(models.Envelope{ID: id, Created: created, Data: data, VaultID: team.ID})
+	s.ch <- models.Message{Type: models.New, Value: value}
+
+	return nil
+}
+
+// TeamVaults returns team vaults of the user ordered by name.
+func (s *Keeper) TeamVaults() []models.TeamVault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]models.TeamVault, 0, len(s.teams))
+	for _, team := range s.teams {
+		vault := team.TeamVault
+		vault.Key = nil
+		res = append(res, vault)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res
+}
+
+func (s *Keeper) AllTeam(ctx context.Context) ([]models.TeamSecret, error) {
+	const op = "service.Team.All"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	list, err := s.teamStore.All(ctx)
+	if err != nil {
+		log.Error("query all team items error", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, ErrInternal)
+	}
+
+	return list, nil
+}
+
+func (s *Keeper) teamByName(name string) (teamVault, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, team := range s.teams {
+		if team.Name == name {
+			return team, nil
+		}
+	}
+
+	return teamVault{}, ErrUnknownVault
+}
+
+// applyVaults opens keys of team vaults of the user and deletes items of vaults the user is no longer member of.
+func (s *Keeper) applyVaults(ctx context.Context, value []byte) {
+	const op = "service.Keeper.applyVaults"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	var vaults []models.TeamVault
+	_ = json.Unmarshal(value, &vaults)
+
+	teams := make(map[int64]teamVault, len(vaults))
+	ids := make([]int64, 0, len(vaults))
+	for _, vault := range vaults {
+		key, err := s.vault.OpenShared(vault.Key)
+		if err != nil {
+			log.Error("failed open team vault key", slog.Int64("vault_id", vault.ID), logger.Err(err))
+			continue
+		}
+		teams[vault.ID] = teamVault{TeamVault: vault, key: key}
+		ids = append(ids, vault.ID)
+	}
+
+	s.mu.Lock()
+	s.teams = teams
+	s.mu.Unlock()
+
+	if err := s.teamStore.Retain(ctx, ids); err != nil {
+		log.Error("delete items of removed team vaults error", logger.Err(err))
+	}
+}
+
+// applyTeamItem opens item of the team vault and saves it into the local storage.
+func (s *Keeper) applyTeamItem(ctx context.Context, env models.Envelope) {
+	const op = "service.Keeper.applyTeamItem"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("vault_id", env.VaultID),
+	)
+
+	s.mu.Lock()
+	team, ok := s.teams[env.VaultID]
+	s.mu.Unlock()
+	if !ok {
+		log.Error("item of unknown team vault")
+		return
+	}
+
+	item, err := s.vault.OpenTeam(team.key, env.Data)
+	if err != nil {
+		log.Error("failed open team item", slog.String("item id", env.ID), logger.Err(err))
+		return
+	}
+
+	secret := models.TeamSecret{VaultID: env.VaultID, ID: env.ID, Created: env.Created, Item: item}
+	if err := s.teamStore.Save(ctx, secret); err != nil {
+		log.Error("save team item error", logger.Err(err))
+	}
+}
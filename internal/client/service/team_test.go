@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+func TestTeamVault(t *testing.T) {
+	ctx := context.Background()
+	owner, ownerCh := newTestKeeper(t, "owner@example.com")
+	member, memberCh := newTestKeeper(t, "member@example.com")
+
+	require.NoError(t, member.RegisterKey(ctx))
+	register := <-memberCh
+
+	require.NoError(t, owner.CreateTeamVault(ctx, "team"))
+	create := <-ownerCh
+	require.Equal(t, models.VaultCreate, create.Type)
+
+	// server replies with vaults of the owner
+	var vault models.TeamVault
+	require.NoError(t, json.Unmarshal(create.Value, &vault))
+	vault.ID, vault.Role = 7, models.RoleOwner
+	vaults, _ := json.Marshal([]models.TeamVault{vault})
+	owner.ApplyMessage(ctx, models.Message{Type: models.Vaults, Value: vaults})
+	assert.Equal(t, []models.TeamVault{{ID: 7, Name: "team", Role: models.RoleOwner}}, owner.TeamVaults())
+
+	assert.ErrorIs(t, owner.SetTeamMember(ctx, "missing", "member@example.com", models.RoleViewer), ErrUnknownVault)
+	require.NoError(t, owner.SetTeamMember(ctx, "team", "member@example.com", models.RoleViewer))
+	request := <-ownerCh
+	require.Equal(t, models.KeyRequest, request.Type)
+
+	var key models.UserKey
+	require.NoError(t, json.Unmarshal(register.Value, &key))
+	key.Email = "member@example.com"
+	reply, _ := json.Marshal(key)
+	owner.ApplyMessage(ctx, models.Message{Type: models.PublicKey, Value: reply})
+
+	add := <-ownerCh
+	require.Equal(t, models.VaultMember, add.Type)
+	var m models.Member
+	require.NoError(t, json.Unmarshal(add.Value, &m))
+	assert.Equal(t, int64(7), m.VaultID)
+	assert.Equal(t, models.RoleViewer, m.Role)
+
+	// server sends vaults to the new member
+	vaults, _ = json.Marshal([]models.TeamVault{{ID: 7, Name: "team", Role: models.RoleViewer, Key: m.Key}})
+	member.ApplyMessage(ctx, models.Message{Type: models.Vaults, Value: vaults})
+
+	text := models.Text{Type: models.TextItem, Tag: "tag", Key: "key", Value: "secret", Created: 1}
+	require.NoError(t, owner.saveText(ctx, text))
+	require.NoError(t, member.saveText(ctx, text))
+	assert.ErrorIs(t, member.SendTeamItem(ctx, "team", models.TextItem, "key"), ErrReadOnly)
+
+	require.NoError(t, owner.SendTeamItem(ctx, "team", models.TextItem, "key"))
+	item := <-ownerCh
+	require.Equal(t, models.New, item.Type)
+	assert.NotContains(t, string(item.Value), "secret")
+
+	// server broadcasts the item to all members
+	member.ApplyMessage(ctx, models.Message{Type: models.Update, Value: item.Value})
+	list, err := member.AllTeam(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, int64(7), list[0].VaultID)
+	var got models.Text
+	require.NoError(t, json.Unmarshal(list[0].Item, &got))
+	assert.Equal(t, text, got)
+
+	// member is removed from the vault
+	member.ApplyMessage(ctx, models.Message{Type: models.Vaults, Value: []byte("[]")})
+	list, err = member.AllTeam(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	assert.Empty(t, member.TeamVaults())
+}
@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 
@@ -11,7 +10,11 @@ import (
 )
 
 func (s *Keeper) SendSaveText(ctx context.Context, text models.Text) error {
-	s.ch <- textToMsg(text)
+	msg, err := s.itemToMsg(models.TextItem, text.Key, text.Created, text)
+	if err != nil {
+		return err
+	}
+	s.ch <- msg
 
 	return s.saveText(ctx, text)
 }
@@ -64,11 +67,3 @@ func ValidateText(text models.Text) ([]string, bool) {
 	}
 	return msg, len(msg) == 0
 }
-
-func textToMsg(text models.Text) models.Message {
-	value, _ := json.Marshal(text)
-	return models.Message{
-		Type:  "new",
-		Value: value,
-	}
-}
@@ -10,12 +10,15 @@ import (
 	"github.com/SmoothWay/gophkeeper/pkg/models"
 )
 
+// BinaryStorage keeps binary items encrypted with cipher.
+// Rows are found by blind index of the key, so it is not stored in plaintext.
 type BinaryStorage struct {
 	db      *sql.DB
+	cipher  Cipher
 	timeout time.Duration
 }
 
-func NewBinary(storagePath string, timeout time.Duration) (*BinaryStorage, error) {
+func NewBinary(storagePath string, timeout time.Duration, cipher Cipher) (*BinaryStorage, error) {
 	db, err := newSQLDB(storagePath)
 	if err != nil {
 		return nil, err
@@ -23,6 +26,7 @@ func NewBinary(storagePath string, timeout time.Duration) (*BinaryStorage, error
 
 	return &BinaryStorage{
 		db:      db,
+		cipher:  cipher,
 		timeout: timeout,
 	}, nil
 }
@@ -33,7 +37,7 @@ func (b *BinaryStorage) All(ctx context.Context) ([]models.Binary, error) {
 	newCtx, cancel := context.WithTimeout(ctx, b.timeout)
 	defer cancel()
 
-	stmt, err := b.db.Prepare("SELECT tag, key, value, comment, created_at FROM binary")
+	stmt, err := b.db.Prepare("SELECT data FROM binary")
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -43,17 +47,23 @@ func (b *BinaryStorage) All(ctx context.Context) ([]models.Binary, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
 
 	bins := []models.Binary{}
 
 	for rows.Next() {
-		bin := models.Binary{Type: models.BinItem}
-		err = rows.Scan(&bin.Tag, &bin.Key, &bin.Value, &bin.Comment, &bin.Created)
-		if err != nil {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
 			continue
 		}
+
+		bin := models.Binary{}
+		if err = open(b.cipher, data, &bin); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
 		bins = append(bins, bin)
 	}
+
 	return bins, nil
 }
 
@@ -63,16 +73,19 @@ func (b *BinaryStorage) ByKey(ctx context.Context, key string) (models.Binary, e
 	newCtx, cancel := context.WithTimeout(ctx, b.timeout)
 	defer cancel()
 
-	stmt, err := b.db.Prepare("SELECT tag, key, value, comment, created_at FROM binary WHERE key = ?")
+	lookup, err := b.cipher.ItemID(models.BinItem, key)
 	if err != nil {
 		return models.Binary{}, fmt.Errorf("%s: %w", op, err)
 	}
-	defer stmt.Close()
 
-	row := stmt.QueryRowContext(newCtx, key)
+	stmt, err := b.db.Prepare("SELECT data FROM binary WHERE lookup = ?")
+	if err != nil {
+		return models.Binary{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
 
-	bin := models.Binary{Type: models.BinItem}
-	err = row.Scan(&bin.Tag, &bin.Key, &bin.Value, &bin.Comment, &bin.Created)
+	var data []byte
+	err = stmt.QueryRowContext(newCtx, lookup).Scan(&data)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.Binary{}, fmt.Errorf("%s: %w", op, ErrItemNotFound)
@@ -80,6 +93,12 @@ func (b *BinaryStorage) ByKey(ctx context.Context, key string) (models.Binary, e
 
 		return models.Binary{}, fmt.Errorf("%s: %w", op, err)
 	}
+
+	var bin models.Binary
+	if err = open(b.cipher, data, &bin); err != nil {
+		return models.Binary{}, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return bin, nil
 }
 
@@ -89,13 +108,18 @@ func (b *BinaryStorage) Save(ctx context.Context, bin models.Binary) error {
 	newCtx, cancel := context.WithTimeout(ctx, b.timeout)
 	defer cancel()
 
-	stmt, err := b.db.Prepare("INSERT INTO binary(tag, key, value, comment, created_at) VALUES(?, ?, ?, ?, ?)")
+	lookup, data, err := b.seal(bin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := b.db.Prepare("INSERT INTO binary(lookup, data) VALUES(?, ?)")
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(newCtx, bin.Tag, bin.Key, bin.Value, bin.Comment, bin.Created)
+	_, err = stmt.ExecContext(newCtx, lookup, data)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -109,13 +133,18 @@ func (b *BinaryStorage) Update(ctx context.Context, bin models.Binary) error {
 	newCtx, cancel := context.WithTimeout(ctx, b.timeout)
 	defer cancel()
 
-	stmt, err := b.db.Prepare("UPDATE binary SET tag = ?, value = ?, comment = ?, created_at = ? WHERE key = ?")
+	lookup, data, err := b.seal(bin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := b.db.Prepare("UPDATE binary SET data = ? WHERE lookup = ?")
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(newCtx, bin.Tag, bin.Value, bin.Comment, bin.Created, bin.Key)
+	_, err = stmt.ExecContext(newCtx, data, lookup)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -123,6 +152,20 @@ func (b *BinaryStorage) Update(ctx context.Context, bin models.Binary) error {
 	return nil
 }
 
+func (b *BinaryStorage) seal(bin models.Binary) (string, []byte, error) {
+	lookup, err := b.cipher.ItemID(models.BinItem, bin.Key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := seal(b.cipher, bin)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return lookup, data, nil
+}
+
 func (b *BinaryStorage) Close() error {
 	if err := b.db.Close(); err != nil {
 		return ErrInternalError
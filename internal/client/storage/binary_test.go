@@ -41,7 +41,7 @@ type BinaryTestSuite struct {
 
 func (ts *BinaryTestSuite) SetupSuite() {
 	_ = Migrate("client_test.db")
-	ts.testBinaryStorager, _ = NewBinary("client_test.db", time.Second*5)
+	ts.testBinaryStorager, _ = NewBinary("client_test.db", time.Second*5, testCipher(ts.T()))
 }
 
 func TestBinarySqlite(t *testing.T) {
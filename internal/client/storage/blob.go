@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+var ErrInvalidBlobID = errors.New("invalid blob id")
+
+// validBlobID matches blob ids generated by clients, it keeps ids safe to use as file names.
+var validBlobID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// BlobCache keeps chunks of blobs in the directory dir, one file per chunk.
+// Chunks are encrypted with the key of the blob, so they are stored as is.
+type BlobCache struct {
+	dir string
+}
+
+func NewBlobCache(dir string) (*BlobCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create blob cache directory: %w", err)
+	}
+
+	return &BlobCache{dir: dir}, nil
+}
+
+// WriteChunk writes chunk with index of the blob id.
+func (c *BlobCache) WriteChunk(id string, index int, data []byte) error {
+	const op = "storage.BlobCache.WriteChunk"
+
+	if !validBlobID.MatchString(id) {
+		return fmt.Errorf("%s: %w", op, ErrInvalidBlobID)
+	}
+
+	dir := filepath.Join(c.dir, id)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// chunk appears only when it is written completely
+	tmp, err := os.CreateTemp(dir, "tmp-")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := os.Rename(tmp.Name(), c.chunkPath(id, index)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReadChunk reads chunk with index of the blob id.
+func (c *BlobCache) ReadChunk(id string, index int) ([]byte, error) {
+	const op = "storage.BlobCache.ReadChunk"
+
+	if !validBlobID.MatchString(id) {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidBlobID)
+	}
+
+	data, err := os.ReadFile(c.chunkPath(id, index))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", op, ErrItemNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return data, nil
+}
+
+// Received returns number of chunks of the blob id without gaps from the first one.
+func (c *BlobCache) Received(id string) (int, error) {
+	const op = "storage.BlobCache.Received"
+
+	if !validBlobID.MatchString(id) {
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidBlobID)
+	}
+
+	n := 0
+	for {
+		_, err := os.Stat(c.chunkPath(id, n))
+		if errors.Is(err, os.ErrNotExist) {
+			return n, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		n++
+	}
+}
+
+// Delete deletes all chunks of the blob id.
+func (c *BlobCache) Delete(id string) error {
+	const op = "storage.BlobCache.Delete"
+
+	if !validBlobID.MatchString(id) {
+		return fmt.Errorf("%s: %w", op, ErrInvalidBlobID)
+	}
+
+	if err := os.RemoveAll(filepath.Join(c.dir, id)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *BlobCache) chunkPath(id string, index int) string {
+	return filepath.Join(c.dir, id, strconv.Itoa(index))
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobCache(t *testing.T) {
+	const id = "0123456789abcdef0123456789abcdef"
+
+	cache, err := NewBlobCache(t.TempDir())
+	require.NoError(t, err)
+
+	n, err := cache.Received(id)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	require.NoError(t, cache.WriteChunk(id, 0, []byte("chunk0")))
+	require.NoError(t, cache.WriteChunk(id, 2, []byte("chunk2")))
+
+	n, err = cache.Received(id)
+	require.NoError(t, err)
+	require.Equal(t, 1, n, "chunks after the gap are not counted")
+
+	require.NoError(t, cache.WriteChunk(id, 1, []byte("chunk1")))
+	n, err = cache.Received(id)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	data, err := cache.ReadChunk(id, 1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("chunk1"), data)
+
+	_, err = cache.ReadChunk(id, 3)
+	require.ErrorIs(t, err, ErrItemNotFound)
+
+	require.NoError(t, cache.Delete(id))
+	n, err = cache.Received(id)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	require.ErrorIs(t, cache.WriteChunk("../escape", 0, nil), ErrInvalidBlobID)
+	_, err = cache.ReadChunk("ABC", 0)
+	require.ErrorIs(t, err, ErrInvalidBlobID)
+}
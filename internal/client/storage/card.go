@@ -10,12 +10,15 @@ import (
 	"github.com/SmoothWay/gophkeeper/pkg/models"
 )
 
+// CardStorage keeps card items encrypted with cipher.
+// Rows are found by blind index of the number, so it is not stored in plaintext.
 type CardStorage struct {
 	db      *sql.DB
+	cipher  Cipher
 	timeout time.Duration
 }
 
-func NewCard(storagepath string, timeout time.Duration) (*CardStorage, error) {
+func NewCard(storagepath string, timeout time.Duration, cipher Cipher) (*CardStorage, error) {
 	db, err := newSQLDB(storagepath)
 	if err != nil {
 		return nil, err
@@ -23,6 +26,7 @@ func NewCard(storagepath string, timeout time.Duration) (*CardStorage, error) {
 
 	return &CardStorage{
 		db:      db,
+		cipher:  cipher,
 		timeout: timeout,
 	}, nil
 }
@@ -33,26 +37,33 @@ func (c *CardStorage) All(ctx context.Context) ([]models.Card, error) {
 	newCtx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	stmt, err := c.db.Prepare("SELECT tag, number, exp, cvv, comment, created_at FROM card")
+	stmt, err := c.db.Prepare("SELECT data FROM card")
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer stmt.Close()
 
 	rows, err := stmt.QueryContext(newCtx)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
 
 	cards := []models.Card{}
 
 	for rows.Next() {
-		card := models.Card{}
-		err = rows.Scan(&card.Tag, &card.Number, &card.Exp, &card)
-		if err != nil {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
 			continue
 		}
+
+		card := models.Card{}
+		if err = open(c.cipher, data, &card); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
 		cards = append(cards, card)
 	}
+
 	return cards, nil
 }
 
@@ -62,16 +73,19 @@ func (c *CardStorage) ByNumber(ctx context.Context, number string) (models.Card,
 	newCtx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	stmt, err := c.db.Prepare("SELECT tag, number, exp, cvv, comment, created_at FROM card WHERE number = ?")
+	lookup, err := c.cipher.ItemID(models.CardItem, number)
 	if err != nil {
 		return models.Card{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	row := stmt.QueryRowContext(newCtx, number)
-
-	card := models.Card{Type: models.CardItem}
+	stmt, err := c.db.Prepare("SELECT data FROM card WHERE lookup = ?")
+	if err != nil {
+		return models.Card{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
 
-	err = row.Scan(&card.Tag, &card.Number, &card.Exp, &card.Cvv, &card.Comment, &card.Created)
+	var data []byte
+	err = stmt.QueryRowContext(newCtx, lookup).Scan(&data)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.Card{}, fmt.Errorf("%s: %w", op, ErrItemNotFound)
@@ -80,8 +94,12 @@ func (c *CardStorage) ByNumber(ctx context.Context, number string) (models.Card,
 		return models.Card{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return card, nil
+	var card models.Card
+	if err = open(c.cipher, data, &card); err != nil {
+		return models.Card{}, fmt.Errorf("%s: %w", op, err)
+	}
 
+	return card, nil
 }
 
 func (c *CardStorage) Save(ctx context.Context, card models.Card) error {
@@ -90,15 +108,22 @@ func (c *CardStorage) Save(ctx context.Context, card models.Card) error {
 	newCtx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	stmt, err := c.db.Prepare("INSERT INTO card(tag, number, exp, cvv, comment, created_at) VALUES(?, ?, ?, ?, ?, ?)")
+	lookup, data, err := c.seal(card)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := c.db.Prepare("INSERT INTO card(lookup, data) VALUES(?, ?)")
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	defer stmt.Close()
 
-	_, err = stmt.ExecContext(newCtx, card.Tag, card.Number, card.Exp, card.Cvv, card.Comment, card.Created)
+	_, err = stmt.ExecContext(newCtx, lookup, data)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+
 	return nil
 }
 
@@ -108,12 +133,18 @@ func (c *CardStorage) Update(ctx context.Context, card models.Card) error {
 	newCtx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	stmt, err := c.db.Prepare("UPDATE card SET tag=?, exp=?, cvv=?, comment=?, created_at=? WHERE number=?")
+	lookup, data, err := c.seal(card)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := c.db.Prepare("UPDATE card SET data = ? WHERE lookup = ?")
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	defer stmt.Close()
 
-	_, err = stmt.ExecContext(newCtx, card.Tag, card.Exp, card.Cvv, card.Comment, card.Created, card.Number)
+	_, err = stmt.ExecContext(newCtx, data, lookup)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -121,6 +152,20 @@ func (c *CardStorage) Update(ctx context.Context, card models.Card) error {
 	return nil
 }
 
+func (c *CardStorage) seal(card models.Card) (string, []byte, error) {
+	lookup, err := c.cipher.ItemID(models.CardItem, card.Number)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := seal(c.cipher, card)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return lookup, data, nil
+}
+
 func (c *CardStorage) Close() error {
 	if err := c.db.Close(); err != nil {
 		return ErrInternalError
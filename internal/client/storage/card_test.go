@@ -41,7 +41,7 @@ type CardTestSuite struct {
 
 func (ts *CardTestSuite) SetupSuite() {
 	_ = Migrate("client_test.db")
-	ts.testCardStorager, _ = NewCard("client_test.db", time.Second*5)
+	ts.testCardStorager, _ = NewCard("client_test.db", time.Second*5, testCipher(ts.T()))
 }
 
 func TestCardSqlite(t *testing.T) {
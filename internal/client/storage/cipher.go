@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// Cipher encrypts items before they are written to the local database.
+// It is implemented by the vault, so the key is derived from the master
+// password on login and never stored on disk.
+type Cipher interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Open(data []byte) ([]byte, error)
+	ItemID(kind models.ItemType, key string) (string, error)
+}
+
+// seal marshals item and encrypts it with c.
+func seal(c Cipher, item any) ([]byte, error) {
+	plaintext, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("marshal item: %w", err)
+	}
+
+	return c.Seal(plaintext)
+}
+
+// open decrypts data produced by seal into item.
+func open(c Cipher, data []byte, item any) error {
+	plaintext, err := c.Open(data)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(plaintext, item); err != nil {
+		return fmt.Errorf("unmarshal item: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/internal/client/vault"
+)
+
+var (
+	testVault     *vault.Vault
+	testVaultOnce sync.Once
+)
+
+// testCipher returns vault unlocked with test credentials.
+// Key derivation is slow, so the vault is shared between suites.
+func testCipher(t *testing.T) Cipher {
+	t.Helper()
+
+	testVaultOnce.Do(func() {
+		testVault = vault.New()
+		require.NoError(t, testVault.Unlock("name@example.com", "password"))
+	})
+
+	return testVault
+}
+
+func TestEncryptedAtRest(t *testing.T) {
+	require.NoError(t, Migrate("client_test.db"))
+	cards, err := NewCard("client_test.db", time.Second*5, testCipher(t))
+	require.NoError(t, err)
+	defer cards.Close()
+
+	ctx := context.Background()
+	require.NoError(t, cards.clean(ctx))
+	defer cards.clean(ctx)
+
+	require.NoError(t, cards.Save(ctx, card1))
+
+	var lookup string
+	var data []byte
+	require.NoError(t, cards.db.QueryRowContext(ctx, "SELECT lookup, data FROM card").Scan(&lookup, &data))
+	assert.NotContains(t, lookup, card1.Number)
+	assert.False(t, bytes.Contains(data, []byte(card1.Number)))
+	assert.False(t, bytes.Contains(data, []byte(card1.Comment)))
+
+	locked := vault.New()
+	other, err := NewCard("client_test.db", time.Second*5, locked)
+	require.NoError(t, err)
+	defer other.Close()
+
+	_, err = other.All(ctx)
+	assert.ErrorIs(t, err, vault.ErrLocked)
+
+	require.NoError(t, locked.Unlock("name@example.com", "other password"))
+	_, err = other.ByNumber(ctx, card1.Number)
+	assert.ErrorIs(t, err, ErrItemNotFound, "other key must not find the item")
+	_, err = other.All(ctx)
+	assert.ErrorIs(t, err, vault.ErrCiphertext)
+}
@@ -10,18 +10,23 @@ import (
 	"github.com/SmoothWay/gophkeeper/pkg/models"
 )
 
+// Credentials keeps credentials items encrypted with cipher.
+// Rows are found by blind index of the login, so it is not stored in plaintext.
 type Credentials struct {
 	db      *sql.DB
+	cipher  Cipher
 	timeout time.Duration
 }
 
-func NewCredentials(storagePath string, timeout time.Duration) (*Credentials, error) {
+func NewCredentials(storagePath string, timeout time.Duration, cipher Cipher) (*Credentials, error) {
 	db, err := newSQLDB(storagePath)
 	if err != nil {
 		return nil, err
 	}
+
 	return &Credentials{
 		db:      db,
+		cipher:  cipher,
 		timeout: timeout,
 	}, nil
 }
@@ -32,7 +37,7 @@ func (s *Credentials) All(ctx context.Context) ([]models.Credentials, error) {
 	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	stmt, err := s.db.Prepare("SELECT tag, login, password, comment, created_at FROM credentials")
+	stmt, err := s.db.Prepare("SELECT data FROM credentials")
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -42,15 +47,20 @@ func (s *Credentials) All(ctx context.Context) ([]models.Credentials, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
 
 	res := []models.Credentials{}
 
 	for rows.Next() {
-		cred := models.Credentials{Type: models.CredItem}
-		err = rows.Scan(&cred.Tag, &cred.Login, &cred.Password, &cred.Comment, &cred.Comment)
-		if err != nil {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
 			continue
 		}
+
+		cred := models.Credentials{}
+		if err = open(s.cipher, data, &cred); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
 		res = append(res, cred)
 	}
 
@@ -63,23 +73,32 @@ func (s *Credentials) ByLogin(ctx context.Context, login string) (models.Credent
 	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	stmt, err := s.db.Prepare("SELECT tag, login, password, comment, created_at FROM credentials WHERE login = ?")
+	lookup, err := s.cipher.ItemID(models.CredItem, login)
 	if err != nil {
 		return models.Credentials{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	row := stmt.QueryRowContext(newCtx, login)
+	stmt, err := s.db.Prepare("SELECT data FROM credentials WHERE lookup = ?")
+	if err != nil {
+		return models.Credentials{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
 
-	cred := models.Credentials{Type: models.CredItem}
-	err = row.Scan(&cred.Tag, &cred.Login, &cred.Password, &cred.Comment, &cred.Created)
+	var data []byte
+	err = stmt.QueryRowContext(newCtx, lookup).Scan(&data)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return models.Credentials{}, fmt.Errorf("%s, %w", op, ErrItemNotFound)
+			return models.Credentials{}, fmt.Errorf("%s: %w", op, ErrItemNotFound)
 		}
 
 		return models.Credentials{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	var cred models.Credentials
+	if err = open(s.cipher, data, &cred); err != nil {
+		return models.Credentials{}, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return cred, nil
 }
 
@@ -89,13 +108,18 @@ func (s *Credentials) Save(ctx context.Context, cred models.Credentials) error {
 	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	stmt, err := s.db.Prepare("INSERT INTO credentials(tag, login, password, comment, created_at) VALUES (?, ?, ?, ?, ?)")
+	lookup, data, err := s.seal(cred)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := s.db.Prepare("INSERT INTO credentials(lookup, data) VALUES(?, ?)")
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(newCtx, cred.Tag, cred.Login, cred.Password, cred.Comment, cred.Created)
+	_, err = stmt.ExecContext(newCtx, lookup, data)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -109,13 +133,18 @@ func (s *Credentials) Update(ctx context.Context, cred models.Credentials) error
 	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	stmt, err := s.db.Prepare("UPDATE credentials SET tag=?, password=?, comment=?, created_at=? WHERE login=?")
+	lookup, data, err := s.seal(cred)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := s.db.Prepare("UPDATE credentials SET data = ? WHERE lookup = ?")
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(newCtx, cred.Tag, cred.Password, cred.Comment, cred.Created, cred.Login)
+	_, err = stmt.ExecContext(newCtx, data, lookup)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -123,6 +152,20 @@ func (s *Credentials) Update(ctx context.Context, cred models.Credentials) error
 	return nil
 }
 
+func (s *Credentials) seal(cred models.Credentials) (string, []byte, error) {
+	lookup, err := s.cipher.ItemID(models.CredItem, cred.Login)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := seal(s.cipher, cred)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return lookup, data, nil
+}
+
 func (s *Credentials) Close() error {
 	if err := s.db.Close(); err != nil {
 		return ErrInternalError
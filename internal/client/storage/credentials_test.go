@@ -41,7 +41,7 @@ type CredentialsTestSuite struct {
 
 func (ts *CredentialsTestSuite) SetupSuite() {
 	_ = Migrate("client_test.db")
-	ts.testCredentialsStorager, _ = NewCredentials("client_test.db", time.Second*5)
+	ts.testCredentialsStorager, _ = NewCredentials("client_test.db", time.Second*5, testCipher(ts.T()))
 }
 
 func TestCredentialsSqlite(t *testing.T) {
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// Legacy reads items saved in plaintext before the local database was encrypted.
+// The client seals and uploads them after login, then deletes them with Clear.
+type Legacy struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewLegacy(storagePath string, timeout time.Duration) (*Legacy, error) {
+	db, err := newSQLDB(storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Legacy{
+		db:      db,
+		timeout: timeout,
+	}, nil
+}
+
+func (s *Legacy) Credentials(ctx context.Context) ([]models.Credentials, error) {
+	const op = "storage.Legacy.Credentials"
+
+	res := []models.Credentials{}
+	err := s.query(ctx, `SELECT coalesce(tag, ''), login, coalesce(password, ''), coalesce(comment, ''),
+		coalesce(created_at, 0) FROM legacy_credentials`, func(rows *sql.Rows) error {
+		cred := models.Credentials{Type: models.CredItem}
+		if err := rows.Scan(&cred.Tag, &cred.Login, &cred.Password, &cred.Comment, &cred.Created); err != nil {
+			return err
+		}
+		res = append(res, cred)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res, nil
+}
+
+func (s *Legacy) Text(ctx context.Context) ([]models.Text, error) {
+	const op = "storage.Legacy.Text"
+
+	res := []models.Text{}
+	err := s.query(ctx, `SELECT coalesce(tag, ''), key, coalesce(value, ''), coalesce(comment, ''),
+		coalesce(created_at, 0) FROM legacy_text`, func(rows *sql.Rows) error {
+		text := models.Text{Type: models.TextItem}
+		if err := rows.Scan(&text.Tag, &text.Key, &text.Value, &text.Comment, &text.Created); err != nil {
+			return err
+		}
+		res = append(res, text)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res, nil
+}
+
+func (s *Legacy) Binary(ctx context.Context) ([]models.Binary, error) {
+	const op = "storage.Legacy.Binary"
+
+	res := []models.Binary{}
+	err := s.query(ctx, `SELECT coalesce(tag, ''), key, value, coalesce(comment, ''),
+		coalesce(created_at, 0) FROM legacy_binary`, func(rows *sql.Rows) error {
+		bin := models.Binary{Type: models.BinItem}
+		if err := rows.Scan(&bin.Tag, &bin.Key, &bin.Value, &bin.Comment, &bin.Created); err != nil {
+			return err
+		}
+		res = append(res, bin)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res, nil
+}
+
+func (s *Legacy) Cards(ctx context.Context) ([]models.Card, error) {
+	const op = "storage.Legacy.Cards"
+
+	res := []models.Card{}
+	err := s.query(ctx, `SELECT coalesce(tag, ''), number, coalesce(exp, ''), coalesce(cvv, 0), coalesce(comment, ''),
+		coalesce(created_at, 0) FROM legacy_card`, func(rows *sql.Rows) error {
+		card := models.Card{Type: models.CardItem}
+		if err := rows.Scan(&card.Tag, &card.Number, &card.Exp, &card.Cvv, &card.Comment, &card.Created); err != nil {
+			return err
+		}
+		res = append(res, card)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res, nil
+}
+
+// Clear deletes plaintext items, when they are sealed and uploaded.
+func (s *Legacy) Clear(ctx context.Context) error {
+	const op = "storage.Legacy.Clear"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(newCtx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range []string{"legacy_credentials", "legacy_text", "legacy_binary", "legacy_card"} {
+		if _, err := tx.ExecContext(newCtx, "DELETE FROM "+table); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// query calls scan for every row of the query.
+func (s *Legacy) query(ctx context.Context, query string, scan func(rows *sql.Rows) error) error {
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(newCtx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *Legacy) Close() error {
+	if err := s.db.Close(); err != nil {
+		return ErrInternalError
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+func TestLegacy(t *testing.T) {
+	require.NoError(t, Migrate("client_test.db"))
+	legacy, err := NewLegacy("client_test.db", time.Second*5)
+	require.NoError(t, err)
+	defer legacy.Close()
+	ctx := context.Background()
+	require.NoError(t, legacy.Clear(ctx))
+
+	_, err = legacy.db.ExecContext(ctx, `INSERT INTO legacy_credentials (tag, login, password, comment, created_at)
+		VALUES ('tag', 'login', 'password', NULL, 1)`)
+	require.NoError(t, err)
+	_, err = legacy.db.ExecContext(ctx, `INSERT INTO legacy_text (tag, key, value, comment, created_at)
+		VALUES ('tag', 'key', 'value', 'comment', 2)`)
+	require.NoError(t, err)
+	_, err = legacy.db.ExecContext(ctx, `INSERT INTO legacy_binary (tag, key, value, comment, created_at)
+		VALUES ('tag', 'file', x'0102', 'comment', 3)`)
+	require.NoError(t, err)
+	_, err = legacy.db.ExecContext(ctx, `INSERT INTO legacy_card (tag, number, exp, cvv, comment, created_at)
+		VALUES ('tag', '4111', '12/30', 123, 'comment', 4)`)
+	require.NoError(t, err)
+
+	creds, err := legacy.Credentials(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Credentials{{Type: models.CredItem, Tag: "tag", Login: "login", Password: "password",
+		Created: 1}}, creds)
+	texts, err := legacy.Text(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Text{{Type: models.TextItem, Tag: "tag", Key: "key", Value: "value", Comment: "comment",
+		Created: 2}}, texts)
+	bins, err := legacy.Binary(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Binary{{Type: models.BinItem, Tag: "tag", Key: "file", Value: []byte{1, 2},
+		Comment: "comment", Created: 3}}, bins)
+	cards, err := legacy.Cards(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Card{{Type: models.CardItem, Tag: "tag", Number: "4111", Exp: "12/30", Cvv: 123,
+		Comment: "comment", Created: 4}}, cards)
+
+	require.NoError(t, legacy.Clear(ctx))
+	creds, err = legacy.Credentials(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, creds)
+	cards, err = legacy.Cards(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, cards)
+}
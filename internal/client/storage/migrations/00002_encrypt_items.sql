@@ -0,0 +1,46 @@
+-- +goose Up
+-- Plaintext rows are kept in legacy tables, the client seals and uploads them after login
+-- and deletes them, see storage.Legacy.
+ALTER TABLE credentials RENAME TO legacy_credentials;
+ALTER TABLE text RENAME TO legacy_text;
+ALTER TABLE binary RENAME TO legacy_binary;
+ALTER TABLE card RENAME TO legacy_card;
+
+CREATE TABLE IF NOT EXISTS credentials
+(
+    id                 INTEGER PRIMARY KEY,
+    lookup             TEXT NOT NULL UNIQUE,
+    data               BLOB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS text
+(
+    id                 INTEGER PRIMARY KEY,
+    lookup             TEXT NOT NULL UNIQUE,
+    data               BLOB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS binary
+(
+    id                 INTEGER PRIMARY KEY,
+    lookup             TEXT NOT NULL UNIQUE,
+    data               BLOB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS card
+(
+    id                 INTEGER PRIMARY KEY,
+    lookup             TEXT NOT NULL UNIQUE,
+    data               BLOB NOT NULL
+);
+
+-- +goose Down
+DROP TABLE credentials;
+DROP TABLE text;
+DROP TABLE binary;
+DROP TABLE card;
+
+ALTER TABLE legacy_credentials RENAME TO credentials;
+ALTER TABLE legacy_text RENAME TO text;
+ALTER TABLE legacy_binary RENAME TO binary;
+ALTER TABLE legacy_card RENAME TO card;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS shared
+(
+    id                 INTEGER PRIMARY KEY,
+    lookup             TEXT NOT NULL UNIQUE,
+    data               BLOB NOT NULL
+);
+
+-- +goose Down
+DROP TABLE shared;
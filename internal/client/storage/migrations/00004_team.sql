@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS team
+(
+    id                 INTEGER PRIMARY KEY,
+    lookup             TEXT NOT NULL UNIQUE,
+    vault_id           INTEGER NOT NULL,
+    data               BLOB NOT NULL
+);
+
+-- +goose Down
+DROP TABLE team;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS server_pins
+(
+    url                TEXT PRIMARY KEY,
+    pin                TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE server_pins;
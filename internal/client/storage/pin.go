@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PinStorage keeps pins of servers trusted on first use.
+// Pins are public keys hashes, they are stored unencrypted and are readable before login.
+type PinStorage struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPins(storagePath string, timeout time.Duration) (*PinStorage, error) {
+	db, err := newSQLDB(storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PinStorage{
+		db:      db,
+		timeout: timeout,
+	}, nil
+}
+
+// ServerPin returns pin of the server at url, it is empty, when the server is not pinned yet.
+func (s *PinStorage) ServerPin(ctx context.Context, url string) (string, error) {
+	const op = "storage.Pins.ServerPin"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var pin string
+	err := s.db.QueryRowContext(newCtx, "SELECT pin FROM server_pins WHERE url = ?", url).Scan(&pin)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pin, nil
+}
+
+// SaveServerPin pins the server at url, pin of the server can not be replaced.
+func (s *PinStorage) SaveServerPin(ctx context.Context, url string, pin string) error {
+	const op = "storage.Pins.SaveServerPin"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(newCtx, "INSERT INTO server_pins(url, pin) VALUES(?, ?)", url, pin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *PinStorage) Close() error {
+	if err := s.db.Close(); err != nil {
+		return ErrInternalError
+	}
+
+	return nil
+}
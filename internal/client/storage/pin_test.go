@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerPins(t *testing.T) {
+	require.NoError(t, Migrate("client_test.db"))
+	pins, err := NewPins("client_test.db", time.Second*5)
+	require.NoError(t, err)
+	defer pins.Close()
+	ctx := context.Background()
+	_, err = pins.db.ExecContext(ctx, "DELETE FROM server_pins")
+	require.NoError(t, err)
+
+	pin, err := pins.ServerPin(ctx, "wss://localhost:4443/ws")
+	require.NoError(t, err)
+	require.Empty(t, pin)
+
+	require.NoError(t, pins.SaveServerPin(ctx, "wss://localhost:4443/ws", "sha256/pin1"))
+	pin, err = pins.ServerPin(ctx, "wss://localhost:4443/ws")
+	require.NoError(t, err)
+	require.Equal(t, "sha256/pin1", pin)
+
+	require.Error(t, pins.SaveServerPin(ctx, "wss://localhost:4443/ws", "sha256/pin2"), "pin is not replaced")
+	pin, err = pins.ServerPin(ctx, "wss://localhost:4443/ws")
+	require.NoError(t, err)
+	require.Equal(t, "sha256/pin1", pin)
+
+	pin, err = pins.ServerPin(ctx, "wss://example.com/ws")
+	require.NoError(t, err)
+	require.Empty(t, pin)
+}
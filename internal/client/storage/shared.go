@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// SharedStorage keeps items shared with the user by other users encrypted with cipher.
+type SharedStorage struct {
+	db      *sql.DB
+	cipher  Cipher
+	timeout time.Duration
+}
+
+func NewShared(storagePath string, timeout time.Duration, cipher Cipher) (*SharedStorage, error) {
+	db, err := newSQLDB(storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SharedStorage{
+		db:      db,
+		cipher:  cipher,
+		timeout: timeout,
+	}, nil
+}
+
+func (s *SharedStorage) All(ctx context.Context) ([]models.SharedSecret, error) {
+	const op = "storage.Shared.All"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	stmt, err := s.db.Prepare("SELECT data FROM shared")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(newCtx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	res := []models.SharedSecret{}
+
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			continue
+		}
+
+		secret := models.SharedSecret{}
+		if err = open(s.cipher, data, &secret); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		res = append(res, secret)
+	}
+
+	return res, nil
+}
+
+// Save inserts shared item or replaces the item shared earlier by the same user.
+func (s *SharedStorage) Save(ctx context.Context, secret models.SharedSecret) error {
+	const op = "storage.Shared.Save"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	lookup, err := s.lookup(secret.From, secret.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	data, err := seal(s.cipher, secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := s.db.Prepare("INSERT INTO shared(lookup, data) VALUES(?, ?) ON CONFLICT(lookup) DO UPDATE SET data = excluded.data")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(newCtx, lookup, data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Delete deletes item with id shared by the user from.
+func (s *SharedStorage) Delete(ctx context.Context, from string, id string) error {
+	const op = "storage.Shared.Delete"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	lookup, err := s.lookup(from, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.ExecContext(newCtx, "DELETE FROM shared WHERE lookup = ?", lookup)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteAll deletes all shared items, e.g. before applying fresh list from the server.
+func (s *SharedStorage) DeleteAll(ctx context.Context) error {
+	const op = "storage.Shared.DeleteAll"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(newCtx, "DELETE FROM shared"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *SharedStorage) lookup(from string, id string) (string, error) {
+	// item ids of different users may collide, so lookup includes the sender
+	return s.cipher.ItemID(models.SharedItem, from+"\x00"+id)
+}
+
+func (s *SharedStorage) Close() error {
+	if err := s.db.Close(); err != nil {
+		return ErrInternalError
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/stretchr/testify/suite"
+)
+
+var (
+	shared1 = models.SharedSecret{ID: "id1", From: "owner@example.com", Created: 1, Item: json.RawMessage(`{"type":"text","key":"key1"}`)}
+	shared2 = models.SharedSecret{ID: "id1", From: "other@example.com", Created: 1, Item: json.RawMessage(`{"type":"text","key":"key2"}`)}
+)
+
+func (s *SharedStorage) clean(ctx context.Context) error {
+	return s.DeleteAll(ctx)
+}
+
+type SharedTestSuite struct {
+	suite.Suite
+	*SharedStorage
+}
+
+func (ts *SharedTestSuite) SetupSuite() {
+	_ = Migrate("client_test.db")
+	ts.SharedStorage, _ = NewShared("client_test.db", time.Second*5, testCipher(ts.T()))
+}
+
+func TestSharedSqlite(t *testing.T) {
+	suite.Run(t, new(SharedTestSuite))
+}
+
+func (ts *SharedTestSuite) SetupTest() {
+	ts.Require().NoError(ts.clean(context.Background()))
+}
+
+func (ts *SharedTestSuite) TearDownTest() {
+	ts.Require().NoError(ts.clean(context.Background()))
+}
+
+func (ts *SharedTestSuite) TestSave() {
+	ts.Require().NoError(ts.Save(context.Background(), shared1))
+	ts.Require().NoError(ts.Save(context.Background(), shared2))
+
+	updated := shared1
+	updated.Item = json.RawMessage(`{"type":"text","key":"new"}`)
+	ts.Require().NoError(ts.Save(context.Background(), updated))
+
+	list, err := ts.All(context.Background())
+	ts.Require().NoError(err)
+	ts.ElementsMatch([]models.SharedSecret{updated, shared2}, list)
+}
+
+func (ts *SharedTestSuite) TestDelete() {
+	ts.Require().NoError(ts.Save(context.Background(), shared1))
+	ts.Require().NoError(ts.Save(context.Background(), shared2))
+
+	ts.Require().NoError(ts.Delete(context.Background(), shared1.From, shared1.ID))
+
+	list, err := ts.All(context.Background())
+	ts.Require().NoError(err)
+	ts.Equal([]models.SharedSecret{shared2}, list)
+}
@@ -20,7 +20,7 @@ func Migrate(storagePath string) error {
 		return err
 	}
 
-	err = migrate(db, 1)
+	err = migrate(db, 6)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// TeamStorage keeps items of team vaults of the user encrypted with cipher.
+type TeamStorage struct {
+	db      *sql.DB
+	cipher  Cipher
+	timeout time.Duration
+}
+
+func NewTeam(storagePath string, timeout time.Duration, cipher Cipher) (*TeamStorage, error) {
+	db, err := newSQLDB(storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TeamStorage{
+		db:      db,
+		cipher:  cipher,
+		timeout: timeout,
+	}, nil
+}
+
+func (s *TeamStorage) All(ctx context.Context) ([]models.TeamSecret, error) {
+	const op = "storage.Team.All"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	stmt, err := s.db.Prepare("SELECT data FROM team")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(newCtx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	res := []models.TeamSecret{}
+
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			continue
+		}
+
+		secret := models.TeamSecret{}
+		if err = open(s.cipher, data, &secret); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		res = append(res, secret)
+	}
+
+	return res, nil
+}
+
+// Save inserts item of the team vault or replaces the item with the same id.
+func (s *TeamStorage) Save(ctx context.Context, secret models.TeamSecret) error {
+	const op = "storage.Team.Save"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	lookup, err := s.lookup(secret.VaultID, secret.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	data, err := seal(s.cipher, secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := s.db.Prepare("INSERT INTO team(lookup, vault_id, data) VALUES(?, ?, ?) ON CONFLICT(lookup) DO UPDATE SET data = excluded.data")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(newCtx, lookup, secret.VaultID, data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Retain deletes items of all team vaults except vaults with vaultIDs,
+// e.g. after the user was removed from the vault.
+func (s *TeamStorage) Retain(ctx context.Context, vaultIDs []int64) error {
+	const op = "storage.Team.Retain"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ids := make([]string, 0, len(vaultIDs))
+	for _, id := range vaultIDs {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+
+	query := "DELETE FROM team"
+	if len(ids) > 0 {
+		query += " WHERE vault_id NOT IN (" + strings.Join(ids, ", ") + ")"
+	}
+
+	if _, err := s.db.ExecContext(newCtx, query); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *TeamStorage) lookup(vaultID int64, id string) (string, error) {
+	return s.cipher.ItemID(models.TeamItem, strconv.FormatInt(vaultID, 10)+"\x00"+id)
+}
+
+func (s *TeamStorage) Close() error {
+	if err := s.db.Close(); err != nil {
+		return ErrInternalError
+	}
+
+	return nil
+}
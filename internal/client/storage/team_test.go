@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/stretchr/testify/suite"
+)
+
+var (
+	team1 = models.TeamSecret{VaultID: 1, ID: "id1", Created: 1, Item: json.RawMessage(`{"type":"text","key":"key1"}`)}
+	team2 = models.TeamSecret{VaultID: 2, ID: "id1", Created: 1, Item: json.RawMessage(`{"type":"text","key":"key2"}`)}
+)
+
+type TeamTestSuite struct {
+	suite.Suite
+	*TeamStorage
+}
+
+func (ts *TeamTestSuite) SetupSuite() {
+	_ = Migrate("client_test.db")
+	ts.TeamStorage, _ = NewTeam("client_test.db", time.Second*5, testCipher(ts.T()))
+}
+
+func TestTeamSqlite(t *testing.T) {
+	suite.Run(t, new(TeamTestSuite))
+}
+
+func (ts *TeamTestSuite) SetupTest() {
+	ts.Require().NoError(ts.Retain(context.Background(), nil))
+}
+
+func (ts *TeamTestSuite) TearDownTest() {
+	ts.Require().NoError(ts.Retain(context.Background(), nil))
+}
+
+func (ts *TeamTestSuite) TestSave() {
+	ts.Require().NoError(ts.Save(context.Background(), team1))
+	ts.Require().NoError(ts.Save(context.Background(), team2))
+
+	updated := team1
+	updated.Item = json.RawMessage(`{"type":"text","key":"new"}`)
+	ts.Require().NoError(ts.Save(context.Background(), updated))
+
+	list, err := ts.All(context.Background())
+	ts.Require().NoError(err)
+	ts.ElementsMatch([]models.TeamSecret{updated, team2}, list)
+}
+
+func (ts *TeamTestSuite) TestRetain() {
+	ts.Require().NoError(ts.Save(context.Background(), team1))
+	ts.Require().NoError(ts.Save(context.Background(), team2))
+
+	ts.Require().NoError(ts.Retain(context.Background(), []int64{team2.VaultID}))
+
+	list, err := ts.All(context.Background())
+	ts.Require().NoError(err)
+	ts.Equal([]models.TeamSecret{team2}, list)
+}
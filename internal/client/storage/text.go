@@ -10,12 +10,15 @@ import (
 	"github.com/SmoothWay/gophkeeper/pkg/models"
 )
 
+// TextStorage keeps text items encrypted with cipher.
+// Rows are found by blind index of the key, so it is not stored in plaintext.
 type TextStorage struct {
 	db      *sql.DB
+	cipher  Cipher
 	timeout time.Duration
 }
 
-func NewText(storagePath string, timeout time.Duration) (*TextStorage, error) {
+func NewText(storagePath string, timeout time.Duration, cipher Cipher) (*TextStorage, error) {
 	db, err := newSQLDB(storagePath)
 	if err != nil {
 		return nil, err
@@ -23,6 +26,7 @@ func NewText(storagePath string, timeout time.Duration) (*TextStorage, error) {
 
 	return &TextStorage{
 		db:      db,
+		cipher:  cipher,
 		timeout: timeout,
 	}, nil
 }
@@ -33,25 +37,33 @@ func (t *TextStorage) All(ctx context.Context) ([]models.Text, error) {
 	newCtx, cancel := context.WithTimeout(ctx, t.timeout)
 	defer cancel()
 
-	stmt, err := t.db.Prepare("SELECT tag, key, value, comment, created_at FROM text")
+	stmt, err := t.db.Prepare("SELECT data FROM text")
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer stmt.Close()
 
 	rows, err := stmt.QueryContext(newCtx)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
+
+	res := []models.Text{}
 
-	res := make([]models.Text, 1)
 	for rows.Next() {
-		text := models.Text{}
-		err = rows.Scan(&text.Tag, &text.Key, &text.Value, &text.Comment)
-		if err != nil {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
 			continue
 		}
+
+		text := models.Text{}
+		if err = open(t.cipher, data, &text); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
 		res = append(res, text)
 	}
+
 	return res, nil
 }
 
@@ -61,21 +73,30 @@ func (t *TextStorage) ByKey(ctx context.Context, key string) (models.Text, error
 	newCtx, cancel := context.WithTimeout(ctx, t.timeout)
 	defer cancel()
 
-	stmt, err := t.db.Prepare("SELECT tag, key, value, comment, created_at FROM text WHERE key = ?")
+	lookup, err := t.cipher.ItemID(models.TextItem, key)
 	if err != nil {
 		return models.Text{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	row := stmt.QueryRowContext(newCtx, key)
-	text := models.Text{Type: models.TextItem}
-
-	err = row.Scan(&text.Tag, &text.Key, &text.Value, &text.Comment, &text.Created)
+	stmt, err := t.db.Prepare("SELECT data FROM text WHERE lookup = ?")
+	if err != nil {
+		return models.Text{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
 
+	var data []byte
+	err = stmt.QueryRowContext(newCtx, lookup).Scan(&data)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.Text{}, fmt.Errorf("%s: %w", op, ErrItemNotFound)
 		}
-		return models.Text{}, fmt.Errorf("%s, %w", op, err)
+
+		return models.Text{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var text models.Text
+	if err = open(t.cipher, data, &text); err != nil {
+		return models.Text{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return text, nil
@@ -87,12 +108,18 @@ func (t *TextStorage) Save(ctx context.Context, text models.Text) error {
 	newCtx, cancel := context.WithTimeout(ctx, t.timeout)
 	defer cancel()
 
-	stmt, err := t.db.Prepare("INSERT INTO text(tag, key, value, comment, created_at) VALUES(?, ?, ?, ?, ?)")
+	lookup, data, err := t.seal(text)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := t.db.Prepare("INSERT INTO text(lookup, data) VALUES(?, ?)")
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	defer stmt.Close()
 
-	_, err = stmt.ExecContext(newCtx, text.Tag, text.Key, text.Value, text.Comment, text.Created)
+	_, err = stmt.ExecContext(newCtx, lookup, data)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -106,12 +133,18 @@ func (t *TextStorage) Update(ctx context.Context, text models.Text) error {
 	newCtx, cancel := context.WithTimeout(ctx, t.timeout)
 	defer cancel()
 
-	stmt, err := t.db.Prepare("UPDATE text SET tag=?, value=?, cmment=?, created_at=? WHERE key=?")
+	lookup, data, err := t.seal(text)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := t.db.Prepare("UPDATE text SET data = ? WHERE lookup = ?")
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	defer stmt.Close()
 
-	_, err = stmt.ExecContext(newCtx, text.Tag, text.Value, text.Comment, text.Created, text.Key)
+	_, err = stmt.ExecContext(newCtx, data, lookup)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -119,9 +152,24 @@ func (t *TextStorage) Update(ctx context.Context, text models.Text) error {
 	return nil
 }
 
+func (t *TextStorage) seal(text models.Text) (string, []byte, error) {
+	lookup, err := t.cipher.ItemID(models.TextItem, text.Key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := seal(t.cipher, text)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return lookup, data, nil
+}
+
 func (t *TextStorage) Close() error {
 	if err := t.db.Close(); err != nil {
 		return ErrInternalError
 	}
+
 	return nil
 }
@@ -33,7 +33,7 @@ type TextTestSuite struct {
 
 func (ts *TextTestSuite) SetupSuite() {
 	_ = Migrate("client_test.db")
-	ts.testTextStorager, _ = NewText("client_test.db", time.Second*3)
+	ts.testTextStorager, _ = NewText("client_test.db", time.Second*3, testCipher(ts.T()))
 }
 
 func (ts *TextStorage) clean(ctx context.Context) error {
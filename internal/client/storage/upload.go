@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// UploadStorage keeps binary items, whose blobs are not uploaded to the server yet, encrypted with cipher.
+type UploadStorage struct {
+	db      *sql.DB
+	cipher  Cipher
+	timeout time.Duration
+}
+
+func NewUploads(storagePath string, timeout time.Duration, cipher Cipher) (*UploadStorage, error) {
+	db, err := newSQLDB(storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UploadStorage{
+		db:      db,
+		cipher:  cipher,
+		timeout: timeout,
+	}, nil
+}
+
+func (s *UploadStorage) All(ctx context.Context) ([]models.Binary, error) {
+	const op = "storage.Uploads.All"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	stmt, err := s.db.Prepare("SELECT data FROM uploads")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(newCtx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	res := []models.Binary{}
+
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			continue
+		}
+
+		bin := models.Binary{}
+		if err = open(s.cipher, data, &bin); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		res = append(res, bin)
+	}
+
+	return res, nil
+}
+
+// ByBlobID returns binary item referencing blob with id.
+func (s *UploadStorage) ByBlobID(ctx context.Context, id string) (models.Binary, error) {
+	const op = "storage.Uploads.ByBlobID"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	lookup, err := s.lookup(id)
+	if err != nil {
+		return models.Binary{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var data []byte
+	err = s.db.QueryRowContext(newCtx, "SELECT data FROM uploads WHERE lookup = ?", lookup).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Binary{}, fmt.Errorf("%s: %w", op, ErrItemNotFound)
+	}
+	if err != nil {
+		return models.Binary{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	bin := models.Binary{}
+	if err = open(s.cipher, data, &bin); err != nil {
+		return models.Binary{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return bin, nil
+}
+
+// Save inserts binary item referencing the blob or replaces the item referencing the same blob.
+func (s *UploadStorage) Save(ctx context.Context, bin models.Binary) error {
+	const op = "storage.Uploads.Save"
+
+	if bin.Blob == nil {
+		return fmt.Errorf("%s: binary item does not reference blob", op)
+	}
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	lookup, err := s.lookup(bin.Blob.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	data, err := seal(s.cipher, bin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := s.db.Prepare("INSERT INTO uploads(lookup, data) VALUES(?, ?) ON CONFLICT(lookup) DO UPDATE SET data = excluded.data")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(newCtx, lookup, data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Delete deletes binary item referencing blob with id, e.g. after the blob is uploaded.
+func (s *UploadStorage) Delete(ctx context.Context, id string) error {
+	const op = "storage.Uploads.Delete"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	lookup, err := s.lookup(id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.ExecContext(newCtx, "DELETE FROM uploads WHERE lookup = ?", lookup)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *UploadStorage) lookup(id string) (string, error) {
+	return s.cipher.ItemID(models.BinItem, "upload\x00"+id)
+}
+
+func (s *UploadStorage) Close() error {
+	if err := s.db.Close(); err != nil {
+		return ErrInternalError
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/stretchr/testify/suite"
+)
+
+var (
+	upload1 = models.Binary{Type: models.BinItem, Key: "file1", Created: 1,
+		Blob: &models.Blob{ID: "00000000000000000000000000000001", Size: 10, Chunks: 1, Key: []byte("key1"), Hash: []byte("hash1")}}
+	upload2 = models.Binary{Type: models.BinItem, Key: "file2", Created: 1,
+		Blob: &models.Blob{ID: "00000000000000000000000000000002", Size: 20, Chunks: 2, Key: []byte("key2"), Hash: []byte("hash2")}}
+)
+
+func (s *UploadStorage) clean(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM uploads")
+	return err
+}
+
+type UploadTestSuite struct {
+	suite.Suite
+	*UploadStorage
+}
+
+func (ts *UploadTestSuite) SetupSuite() {
+	_ = Migrate("client_test.db")
+	ts.UploadStorage, _ = NewUploads("client_test.db", time.Second*5, testCipher(ts.T()))
+}
+
+func TestUploadSqlite(t *testing.T) {
+	suite.Run(t, new(UploadTestSuite))
+}
+
+func (ts *UploadTestSuite) SetupTest() {
+	ts.Require().NoError(ts.clean(context.Background()))
+}
+
+func (ts *UploadTestSuite) TearDownTest() {
+	ts.Require().NoError(ts.clean(context.Background()))
+}
+
+func (ts *UploadTestSuite) TestSave() {
+	ts.Require().NoError(ts.Save(context.Background(), upload1))
+	ts.Require().NoError(ts.Save(context.Background(), upload2))
+
+	updated := upload1
+	updated.Tag = "new"
+	ts.Require().NoError(ts.Save(context.Background(), updated))
+
+	list, err := ts.All(context.Background())
+	ts.Require().NoError(err)
+	ts.ElementsMatch([]models.Binary{updated, upload2}, list)
+
+	bin, err := ts.ByBlobID(context.Background(), upload2.Blob.ID)
+	ts.Require().NoError(err)
+	ts.Equal(upload2, bin)
+
+	ts.Error(ts.Save(context.Background(), models.Binary{Type: models.BinItem, Key: "inline"}))
+}
+
+func (ts *UploadTestSuite) TestDelete() {
+	ts.Require().NoError(ts.Save(context.Background(), upload1))
+	ts.Require().NoError(ts.Save(context.Background(), upload2))
+
+	ts.Require().NoError(ts.Delete(context.Background(), upload1.Blob.ID))
+
+	_, err := ts.ByBlobID(context.Background(), upload1.Blob.ID)
+	ts.ErrorIs(err, ErrItemNotFound)
+
+	list, err := ts.All(context.Background())
+	ts.Require().NoError(err)
+	ts.Equal([]models.Binary{upload2}, list)
+}
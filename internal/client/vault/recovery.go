@@ -0,0 +1,149 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SmoothWay/gophkeeper/pkg/shamir"
+)
+
+// recoveryVersion is the format of recovery codes.
+const recoveryVersion byte = 1
+
+const (
+	fingerprintLen = 4
+	checksumLen    = 2
+	codeGroupLen   = 8
+)
+
+var (
+	ErrRecoveryCode  = errors.New("invalid recovery code")
+	ErrRecoveryCodes = errors.New("not enough recovery codes or codes of different recovery kits")
+)
+
+var codeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// RecoveryCodes splits master key of the unlocked vault into n recovery codes,
+// any threshold of which restore the vault with Recover.
+func (v *Vault) RecoveryCodes(n int, threshold int) ([]string, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.master == nil {
+		return nil, ErrLocked
+	}
+
+	// fingerprint detects that the key was combined from too few shares
+	secret := append(append([]byte(nil), v.master...), fingerprint(v.master)...)
+	defer clear(secret)
+
+	shares, err := shamir.Split(secret, n, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("split master key: %w", err)
+	}
+
+	codes := make([]string, len(shares))
+	for i, share := range shares {
+		codes[i] = encodeCode(share)
+		clear(share)
+	}
+
+	return codes, nil
+}
+
+// Recover restores master key from recovery codes and unlocks the vault.
+func (v *Vault) Recover(codes []string) error {
+	shares := make([][]byte, 0, len(codes))
+	for i, code := range codes {
+		share, err := decodeCode(code)
+		if err != nil {
+			return fmt.Errorf("code %d: %w", i+1, err)
+		}
+		shares = append(shares, share)
+	}
+
+	secret, err := shamir.Combine(shares)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRecoveryCodes, err)
+	}
+	if len(secret) != keyLen+fingerprintLen {
+		return ErrRecoveryCodes
+	}
+
+	master := secret[:keyLen]
+	if !bytes.Equal(fingerprint(master), secret[keyLen:]) {
+		return ErrRecoveryCodes
+	}
+
+	return v.unlock(master)
+}
+
+// RecoveryProof proves to the auth server, that the user has master key of the vault.
+// The server keeps only RecoveryVerifier of the proof.
+func (v *Vault) RecoveryProof() ([]byte, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.master == nil {
+		return nil, ErrLocked
+	}
+
+	return subKey(v.master, "gophkeeper vault recovery proof")
+}
+
+// RecoveryVerifier returns hash of the recovery proof, which the auth server compares proofs with.
+func (v *Vault) RecoveryVerifier() ([]byte, error) {
+	proof, err := v.RecoveryProof()
+	if err != nil {
+		return nil, err
+	}
+
+	verifier := sha256.Sum256(proof)
+	return verifier[:], nil
+}
+
+// encodeCode encodes share as groups of base32 characters with version and checksum.
+func encodeCode(share []byte) string {
+	payload := append([]byte{recoveryVersion}, share...)
+	sum := sha256.Sum256(payload)
+	encoded := codeEncoding.EncodeToString(append(payload, sum[:checksumLen]...))
+
+	groups := make([]string, 0, len(encoded)/codeGroupLen+1)
+	for len(encoded) > codeGroupLen {
+		groups = append(groups, encoded[:codeGroupLen])
+		encoded = encoded[codeGroupLen:]
+	}
+	groups = append(groups, encoded)
+
+	return strings.Join(groups, "-")
+}
+
+// decodeCode returns share encoded by encodeCode. Dashes, spaces and case are ignored.
+func decodeCode(code string) ([]byte, error) {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+
+	data, err := codeEncoding.DecodeString(code)
+	if err != nil || len(data) < 1+checksumLen+2 {
+		return nil, ErrRecoveryCode
+	}
+
+	payload, checksum := data[:len(data)-checksumLen], data[len(data)-checksumLen:]
+	sum := sha256.Sum256(payload)
+	if !bytes.Equal(sum[:checksumLen], checksum) {
+		return nil, fmt.Errorf("%w: checksum mismatch, check for typos", ErrRecoveryCode)
+	}
+	if payload[0] != recoveryVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrRecoveryCode, payload[0])
+	}
+
+	return payload[1:], nil
+}
+
+func fingerprint(master []byte) []byte {
+	sum := sha256.Sum256(append([]byte("gophkeeper recovery fingerprint"), master...))
+	return sum[:fingerprintLen]
+}
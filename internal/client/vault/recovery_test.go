@@ -0,0 +1,116 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+func TestRecover(t *testing.T) {
+	v := New()
+	require.NoError(t, v.Unlock("name@example.com", "forgotten"))
+	sealed, err := v.Seal([]byte("some secret"))
+	require.NoError(t, err)
+	id, err := v.ItemID(models.TextItem, "key")
+	require.NoError(t, err)
+
+	codes, err := v.RecoveryCodes(5, 3)
+	require.NoError(t, err)
+	require.Len(t, codes, 5)
+
+	// codes are typed by hand, so case and separators do not matter
+	typed := []string{codes[4], strings.ToLower(codes[0]), strings.ReplaceAll(codes[2], "-", " ")}
+
+	recovered := New()
+	require.NoError(t, recovered.Recover(typed))
+
+	opened, err := recovered.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "some secret", string(opened))
+	recoveredID, err := recovered.ItemID(models.TextItem, "key")
+	require.NoError(t, err)
+	assert.Equal(t, id, recoveredID)
+
+	proof, err := v.RecoveryProof()
+	require.NoError(t, err)
+	recoveredProof, err := recovered.RecoveryProof()
+	require.NoError(t, err)
+	assert.Equal(t, proof, recoveredProof)
+}
+
+func TestRecoverErrors(t *testing.T) {
+	v := New()
+	require.NoError(t, v.Unlock("name@example.com", "password"))
+	codes, err := v.RecoveryCodes(3, 3)
+	require.NoError(t, err)
+
+	other := New()
+	require.NoError(t, other.Unlock("other@example.com", "password"))
+	otherCodes, err := other.RecoveryCodes(3, 2)
+	require.NoError(t, err)
+
+	typo := []byte(codes[1])
+	if typo[0] == 'A' {
+		typo[0] = 'B'
+	} else {
+		typo[0] = 'A'
+	}
+
+	tests := []struct {
+		name  string
+		codes []string
+		err   error
+	}{
+		{name: "below threshold", codes: codes[:2], err: ErrRecoveryCodes},
+		{name: "one code", codes: codes[:1], err: ErrRecoveryCodes},
+		{name: "different kits", codes: []string{codes[0], codes[1], otherCodes[2]}, err: ErrRecoveryCodes},
+		{name: "typo", codes: []string{codes[0], string(typo), codes[2]}, err: ErrRecoveryCode},
+		{name: "not a code", codes: []string{codes[0], "hello", codes[2]}, err: ErrRecoveryCode},
+		{name: "duplicate", codes: []string{codes[0], codes[0], codes[1]}, err: ErrRecoveryCodes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recovered := New()
+			err := recovered.Recover(tt.codes)
+			assert.ErrorIs(t, err, tt.err)
+
+			_, err = recovered.Seal([]byte("data"))
+			assert.ErrorIs(t, err, ErrLocked)
+		})
+	}
+
+	_, err = New().RecoveryCodes(3, 2)
+	assert.ErrorIs(t, err, ErrLocked)
+	_, err = v.RecoveryCodes(3, 4)
+	assert.Error(t, err)
+}
+
+func TestWrapKey(t *testing.T) {
+	v := New()
+	require.NoError(t, v.Unlock("name@example.com", "old password"))
+	sealed, err := v.Seal([]byte("some secret"))
+	require.NoError(t, err)
+
+	wrapped, err := v.WrapKey("name@example.com", "new password")
+	require.NoError(t, err)
+
+	unwrapped := New()
+	require.NoError(t, unwrapped.UnlockWrapped("name@example.com", "new password", wrapped))
+	opened, err := unwrapped.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "some secret", string(opened))
+
+	err = New().UnlockWrapped("name@example.com", "old password", wrapped)
+	assert.ErrorIs(t, err, ErrCiphertext)
+
+	// accounts without wrapped key derive it from the password
+	legacy := New()
+	require.NoError(t, legacy.UnlockWrapped("name@example.com", "old password", nil))
+	opened, err = legacy.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "some secret", string(opened))
+}
@@ -0,0 +1,292 @@
+package vault
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/nacl/box"
+
+	"github.com/SmoothWay/gophkeeper/pkg/encrypt"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// Argon2id parameters used to derive the vault key from the master password.
+const (
+	kdfTime    = 3
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+	keyLen     = 32
+)
+
+// vaultKeyID marks envelopes sealed with the vault key,
+// teamKeyID marks envelopes sealed with the key of the team vault,
+// passwordKeyID marks master key wrapped with the master password.
+const (
+	vaultKeyID    = "vault"
+	teamKeyID     = "team"
+	passwordKeyID = "password"
+)
+
+var (
+	ErrLocked     = errors.New("vault is locked")
+	ErrCiphertext = errors.New("invalid ciphertext")
+	ErrPublicKey  = errors.New("invalid public key")
+)
+
+// Vault keeps the keys derived from the user's master password.
+// Items are sealed with the vault key before they leave the client,
+// so the server only stores and relays opaque ciphertext.
+type Vault struct {
+	mu *sync.RWMutex
+	// master key all other keys are derived from, see RecoveryCodes
+	master   []byte
+	encKey   []byte
+	indexKey []byte
+	// X25519 keypair used to receive items shared by other users.
+	shareKey  *[keyLen]byte
+	publicKey *[keyLen]byte
+}
+
+// New returns locked vault. Call Unlock after user logged in.
+func New() *Vault {
+	return &Vault{
+		mu: &sync.RWMutex{},
+	}
+}
+
+// Unlock derives vault keys from email and master password.
+// Email is used as salt, so every device of the user derives the same keys.
+func (v *Vault) Unlock(email string, password string) error {
+	return v.unlock(DeriveKey(email, password))
+}
+
+// UnlockWrapped unlocks vault with the master key wrapped with the master password by WrapKey.
+// Accounts without wrapped key derive master key from the password as Unlock does.
+func (v *Vault) UnlockWrapped(email string, password string, wrapped []byte) error {
+	if len(wrapped) == 0 {
+		return v.Unlock(email, password)
+	}
+
+	master, err := encrypt.Decrypt(DeriveKey(email, password), wrapped)
+	if err != nil {
+		return fmt.Errorf("unwrap master key: %w: %w", ErrCiphertext, err)
+	}
+
+	return v.unlock(master)
+}
+
+// WrapKey encrypts master key of the unlocked vault with the new master password,
+// so the vault keeps its keys when the password changes.
+func (v *Vault) WrapKey(email string, password string) ([]byte, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.master == nil {
+		return nil, ErrLocked
+	}
+
+	return encrypt.Encrypt(DeriveKey(email, password), passwordKeyID, v.master)
+}
+
+func (v *Vault) unlock(master []byte) error {
+	encKey, err := subKey(master, "gophkeeper vault encryption")
+	if err != nil {
+		return fmt.Errorf("derive encryption key: %w", err)
+	}
+	indexKey, err := subKey(master, "gophkeeper vault index")
+	if err != nil {
+		return fmt.Errorf("derive index key: %w", err)
+	}
+	shareKey, err := subKey(master, "gophkeeper vault share")
+	if err != nil {
+		return fmt.Errorf("derive share key: %w", err)
+	}
+	publicKey, err := curve25519.X25519(shareKey, curve25519.Basepoint)
+	if err != nil {
+		return fmt.Errorf("derive public key: %w", err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.master = master
+	v.encKey = encKey
+	v.indexKey = indexKey
+	v.shareKey = (*[keyLen]byte)(shareKey)
+	v.publicKey = (*[keyLen]byte)(publicKey)
+
+	return nil
+}
+
+// Lock forgets vault keys.
+func (v *Vault) Lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	clear(v.master)
+	clear(v.encKey)
+	clear(v.indexKey)
+	v.master = nil
+	v.encKey = nil
+	v.indexKey = nil
+	if v.shareKey != nil {
+		clear(v.shareKey[:])
+	}
+	v.shareKey = nil
+	v.publicKey = nil
+}
+
+// Seal encrypts plaintext into versioned envelope with random nonce.
+func (v *Vault) Seal(plaintext []byte) ([]byte, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.encKey == nil {
+		return nil, ErrLocked
+	}
+
+	return encrypt.Encrypt(v.encKey, vaultKeyID, plaintext)
+}
+
+// Open decrypts data produced by Seal.
+func (v *Vault) Open(data []byte) ([]byte, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.encKey == nil {
+		return nil, ErrLocked
+	}
+
+	plaintext, err := encrypt.Decrypt(v.encKey, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCiphertext, err)
+	}
+
+	return plaintext, nil
+}
+
+// ItemID returns opaque identifier of the item. It is stable for the same
+// item type and key, so the server can find the latest version of the item
+// without knowing what it is.
+func (v *Vault) ItemID(kind models.ItemType, key string) (string, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.indexKey == nil {
+		return "", ErrLocked
+	}
+
+	mac := hmac.New(sha256.New, v.indexKey)
+	mac.Write([]byte(kind.String()))
+	mac.Write([]byte{0})
+	mac.Write([]byte(key))
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// PublicKey returns X25519 public key of the user. Other users seal
+// items shared with the user to this key.
+func (v *Vault) PublicKey() ([]byte, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.publicKey == nil {
+		return nil, ErrLocked
+	}
+
+	return append([]byte(nil), v.publicKey[:]...), nil
+}
+
+// SealTo encrypts plaintext to the public key of another user.
+// Only the owner of the matching private key can open it.
+func (v *Vault) SealTo(publicKey []byte, plaintext []byte) ([]byte, error) {
+	if len(publicKey) != keyLen {
+		return nil, ErrPublicKey
+	}
+
+	return box.SealAnonymous(nil, plaintext, (*[keyLen]byte)(publicKey), rand.Reader)
+}
+
+// OpenShared decrypts data sealed to the public key of the user with SealTo.
+func (v *Vault) OpenShared(data []byte) ([]byte, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.shareKey == nil {
+		return nil, ErrLocked
+	}
+
+	plaintext, ok := box.OpenAnonymous(nil, data, v.publicKey, v.shareKey)
+	if !ok {
+		return nil, ErrCiphertext
+	}
+
+	return plaintext, nil
+}
+
+// NewTeamKey generates random key of the new team vault.
+func (v *Vault) NewTeamKey() ([]byte, error) {
+	key := make([]byte, keyLen)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// SealTeam encrypts plaintext with the key of the team vault.
+func (v *Vault) SealTeam(teamKey []byte, plaintext []byte) ([]byte, error) {
+	return encrypt.Encrypt(teamKey, teamKeyID, plaintext)
+}
+
+// OpenTeam decrypts data produced by SealTeam.
+func (v *Vault) OpenTeam(teamKey []byte, data []byte) ([]byte, error) {
+	plaintext, err := encrypt.Decrypt(teamKey, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCiphertext, err)
+	}
+
+	return plaintext, nil
+}
+
+// TeamItemID returns identifier of the item in the team vault.
+// It is the same for all members of the vault.
+func (v *Vault) TeamItemID(teamKey []byte, kind models.ItemType, key string) (string, error) {
+	indexKey, err := subKey(teamKey, "gophkeeper team vault index")
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, indexKey)
+	mac.Write([]byte(kind.String()))
+	mac.Write([]byte{0})
+	mac.Write([]byte(key))
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// DeriveKey derives master key from password with memory-hard Argon2id.
+func DeriveKey(email string, password string) []byte {
+	salt := sha256.Sum256([]byte("gophkeeper:" + strings.ToLower(strings.TrimSpace(email))))
+
+	return argon2.IDKey([]byte(password), salt[:16], kdfTime, kdfMemory, kdfThreads, keyLen)
+}
+
+func subKey(master []byte, info string) ([]byte, error) {
+	key := make([]byte, keyLen)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, nil, []byte(info)), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
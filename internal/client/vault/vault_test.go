@@ -0,0 +1,144 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+func TestSealOpen(t *testing.T) {
+	v := New()
+	require.NoError(t, v.Unlock("name@example.com", "password"))
+
+	sealed, err := v.Seal([]byte("some secret"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "some secret")
+
+	again, err := v.Seal([]byte("some secret"))
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again)
+
+	opened, err := v.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "some secret", string(opened))
+}
+
+func TestOpenWrongPassword(t *testing.T) {
+	v := New()
+	require.NoError(t, v.Unlock("name@example.com", "password"))
+	sealed, err := v.Seal([]byte("some secret"))
+	require.NoError(t, err)
+
+	other := New()
+	require.NoError(t, other.Unlock("name@example.com", "other password"))
+	_, err = other.Open(sealed)
+	assert.ErrorIs(t, err, ErrCiphertext)
+}
+
+func TestLocked(t *testing.T) {
+	v := New()
+
+	_, err := v.Seal([]byte("some secret"))
+	assert.ErrorIs(t, err, ErrLocked)
+
+	_, err = v.ItemID(models.CredItem, "login")
+	assert.ErrorIs(t, err, ErrLocked)
+
+	require.NoError(t, v.Unlock("name@example.com", "password"))
+	v.Lock()
+	_, err = v.Open([]byte("some data"))
+	assert.ErrorIs(t, err, ErrLocked)
+}
+
+func TestItemID(t *testing.T) {
+	v := New()
+	require.NoError(t, v.Unlock("Name@example.com", "password"))
+	other := New()
+	require.NoError(t, other.Unlock("name@example.com", "password"))
+
+	id1, err := v.ItemID(models.CredItem, "login")
+	require.NoError(t, err)
+	id2, err := other.ItemID(models.CredItem, "login")
+	require.NoError(t, err)
+	assert.Equal(t, id1, id2)
+
+	id3, err := v.ItemID(models.TextItem, "login")
+	require.NoError(t, err)
+	assert.NotEqual(t, id1, id3)
+}
+
+func TestShare(t *testing.T) {
+	owner := New()
+	require.NoError(t, owner.Unlock("owner@example.com", "password"))
+	recipient := New()
+	require.NoError(t, recipient.Unlock("recipient@example.com", "password"))
+
+	publicKey, err := recipient.PublicKey()
+	require.NoError(t, err)
+
+	sealed, err := owner.SealTo(publicKey, []byte("some secret"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "some secret")
+
+	_, err = owner.OpenShared(sealed)
+	assert.ErrorIs(t, err, ErrCiphertext)
+
+	opened, err := recipient.OpenShared(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "some secret", string(opened))
+
+	// keypair is derived from the master password, so it is the same on every device
+	again := New()
+	require.NoError(t, again.Unlock("recipient@example.com", "password"))
+	againKey, err := again.PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, publicKey, againKey)
+
+	_, err = owner.SealTo([]byte("short"), []byte("some secret"))
+	assert.ErrorIs(t, err, ErrPublicKey)
+
+	recipient.Lock()
+	_, err = recipient.OpenShared(sealed)
+	assert.ErrorIs(t, err, ErrLocked)
+	_, err = recipient.PublicKey()
+	assert.ErrorIs(t, err, ErrLocked)
+}
+
+func TestTeamKey(t *testing.T) {
+	owner := New()
+	require.NoError(t, owner.Unlock("owner@example.com", "password"))
+	member := New()
+	require.NoError(t, member.Unlock("member@example.com", "password"))
+
+	teamKey, err := owner.NewTeamKey()
+	require.NoError(t, err)
+
+	// team key is delivered to the member sealed to the member public key
+	publicKey, err := member.PublicKey()
+	require.NoError(t, err)
+	wrapped, err := owner.SealTo(publicKey, teamKey)
+	require.NoError(t, err)
+	memberKey, err := member.OpenShared(wrapped)
+	require.NoError(t, err)
+
+	sealed, err := owner.SealTeam(teamKey, []byte("team secret"))
+	require.NoError(t, err)
+	opened, err := member.OpenTeam(memberKey, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "team secret", string(opened))
+
+	_, err = member.OpenTeam(make([]byte, len(teamKey)), sealed)
+	assert.ErrorIs(t, err, ErrCiphertext)
+
+	id1, err := owner.TeamItemID(teamKey, models.CredItem, "login")
+	require.NoError(t, err)
+	id2, err := member.TeamItemID(memberKey, models.CredItem, "login")
+	require.NoError(t, err)
+	assert.Equal(t, id1, id2)
+	personal, err := owner.ItemID(models.CredItem, "login")
+	require.NoError(t, err)
+	assert.NotEqual(t, personal, id1)
+}
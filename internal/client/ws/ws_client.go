@@ -5,67 +5,141 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"sync"
 
 	"github.com/SmoothWay/gophkeeper/pkg/logger"
 	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/SmoothWay/gophkeeper/pkg/tlsconfig"
 	"github.com/gorilla/websocket"
 )
 
+// TrustOnFirstUse is value of the server pin, which trusts the server key seen on the first connection
+// and accepts only this key later.
+const TrustOnFirstUse = "tofu"
+
 var (
 	ErrConnectToServer = errors.New("failed establish websocket connection")
+	// ErrPinMismatch is returned by Run, when public key of the server does not match the pin.
+	ErrPinMismatch = errors.New("server public key does not match the pin")
 )
 
+// serverMessages are types of messages the server sends to the client.
+var serverMessages = map[models.MessageType]bool{
+	models.Update:         true,
+	models.Snapshot:       true,
+	models.Error:          true,
+	models.PublicKey:      true,
+	models.Shared:         true,
+	models.Unshared:       true,
+	models.SharedSnapshot: true,
+	models.Vaults:         true,
+	models.BlobStatus:     true,
+	models.BlobChunk:      true,
+	models.BlobCommit:     true,
+}
+
 type MessageService interface {
 	ApplyMessage(ctx context.Context, msg models.Message)
 }
 
+// TokenSource returns valid access token, it refreshes the token before it expires.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// PinStore keeps pins of servers trusted on first use.
+type PinStore interface {
+	// ServerPin returns pin of the server at url, it is empty, when the server is not known yet.
+	ServerPin(ctx context.Context, url string) (string, error)
+	SaveServerPin(ctx context.Context, url string, pin string) error
+}
+
 type WSClient struct {
-	log  *slog.Logger
-	conn *websocket.Conn
-	ch   chan models.Message
-	s    MessageService
-	url  string
+	log       *slog.Logger
+	conn      *websocket.Conn
+	ch        chan models.Message
+	s         MessageService
+	url       string
+	tlsConfig *tls.Config
+	pin       string
+	pins      PinStore
 }
 
-func NewWSClient(log *slog.Logger, ch chan models.Message, s MessageService, url string) *WSClient {
+// NewWSClient returns client of the server at url, the server certificate is verified with tlsConfig.
+// When pin is set, public key of the server has to match it too, see tlsconfig.SPKIPin.
+// Pin TrustOnFirstUse keeps key of the server seen first in pins.
+func NewWSClient(log *slog.Logger, ch chan models.Message, s MessageService, url string, tlsConfig *tls.Config,
+	pin string, pins PinStore) *WSClient {
 	return &WSClient{
-		log: log,
-		ch:  ch,
-		s:   s,
-		url: url,
+		log:       log,
+		ch:        ch,
+		s:         s,
+		url:       url,
+		tlsConfig: tlsConfig,
+		pin:       pin,
+		pins:      pins,
 	}
 }
 
-func (ws *WSClient) Run(ctx context.Context, interrupt chan struct{}, token string) {
+// Run connects to the server and starts exchange of messages, interrupt is closed, when the connection is lost.
+// It returns ErrPinMismatch, if public key of the server does not match the pin, the token is not sent then.
+func (ws *WSClient) Run(ctx context.Context, interrupt chan struct{}, tokens TokenSource) error {
 	const op = "ws.Run"
-	log := ws.log.With(
-		slog.String("op", op),
-	)
 
+	pin, err := ws.expectedPin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	var seen string
 	dialer := *websocket.DefaultDialer
-	dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	dialer.TLSClientConfig = ws.tlsConfig.Clone()
+	tlsconfig.Pin(dialer.TLSClientConfig, func(got string) error {
+		seen = got
+		if pin != "" && got != pin {
+			return fmt.Errorf("%w: expected %s, got %s", ErrPinMismatch, pin, got)
+		}
+		return nil
+	})
+
+	token, err := tokens.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
 	headers := make(map[string][]string)
 	headers["token"] = append(headers["token"], token)
 
-	var err error
 	ws.conn, _, err = dialer.DialContext(ctx, ws.url, headers)
-
 	if err != nil {
-		log.Error(
-			"failed establish websocket connection",
-			logger.Err(err),
-		)
-		close(interrupt)
-		return
+		return fmt.Errorf("%s: %w: %w", op, ErrConnectToServer, err)
+	}
+
+	if ws.pin == TrustOnFirstUse && pin == "" {
+		if err := ws.pins.SaveServerPin(ctx, ws.url, seen); err != nil {
+			ws.conn.Close()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		ws.log.Info("server key is trusted on first use", slog.String("url", ws.url), slog.String("pin", seen))
 	}
 
-	go ws.read(ctx, interrupt)
-	go ws.write(ctx, token, interrupt)
+	// both reader and writer stop the client, the channel is closed by the first of them
+	stop := sync.OnceFunc(func() { close(interrupt) })
+	go ws.read(ctx, stop)
+	go ws.write(ctx, tokens, stop)
+	return nil
+}
+
+// expectedPin returns pin the server key has to match, it is empty, when any key verified by CA is accepted.
+func (ws *WSClient) expectedPin(ctx context.Context) (string, error) {
+	if ws.pin != TrustOnFirstUse {
+		return ws.pin, nil
+	}
+	return ws.pins.ServerPin(ctx, ws.url)
 }
 
-func (ws *WSClient) read(ctx context.Context, interrupt chan struct{}) {
+func (ws *WSClient) read(ctx context.Context, stop func()) {
 	op := "ws.Run.read"
 	log := ws.log.With(
 		slog.String("op", op),
@@ -82,6 +156,16 @@ func (ws *WSClient) read(ctx context.Context, interrupt chan struct{}) {
 		default:
 			var header struct{ Type string }
 			mt, data, err := ws.conn.ReadMessage()
+			if websocket.IsCloseError(err, models.CloseSignedOut) {
+				log.Warn("the session is signed out from another device")
+				stop()
+				return
+			}
+			if websocket.IsCloseError(err, models.CloseAccountDeleted) {
+				log.Warn("the account is deleted")
+				stop()
+				return
+			}
 			if err != nil {
 				// TODO implement restoring connection to the server
 				log.Error(
@@ -95,7 +179,7 @@ func (ws *WSClient) read(ctx context.Context, interrupt chan struct{}) {
 				continue
 			}
 			err = json.Unmarshal(data, &header)
-			if err != nil || (header.Type != "update" && header.Type != "snapshot" && header.Type != "error") {
+			if err != nil || !serverMessages[models.MessageType(header.Type)] {
 				continue
 			}
 			var msg models.Message
@@ -109,7 +193,7 @@ func (ws *WSClient) read(ctx context.Context, interrupt chan struct{}) {
 				continue
 			}
 			if msg.Type == "error" && string(msg.Value) == "invalid token" {
-				close(interrupt)
+				stop()
 				return
 			}
 
@@ -118,7 +202,7 @@ func (ws *WSClient) read(ctx context.Context, interrupt chan struct{}) {
 	}
 }
 
-func (ws *WSClient) write(ctx context.Context, token string, interrupt chan struct{}) {
+func (ws *WSClient) write(ctx context.Context, tokens TokenSource, stop func()) {
 	op := "ws.Run.write"
 	log := ws.log.With(
 		slog.String("op", op),
@@ -131,12 +215,18 @@ func (ws *WSClient) write(ctx context.Context, token string, interrupt chan stru
 
 			return
 		case msg := <-ws.ch:
+			token, err := tokens.Token(ctx)
+			if err != nil {
+				log.Error("failed refresh access token", logger.Err(err))
+				stop()
+				return
+			}
 			msg.Token = token
 			data, _ := json.Marshal(msg)
-			err := ws.conn.WriteMessage(websocket.TextMessage, data)
+			err = ws.conn.WriteMessage(websocket.TextMessage, data)
 			if err != nil {
 				// TODO implement restoring connection to the server
-				close(interrupt)
+				stop()
 				return
 			}
 		}
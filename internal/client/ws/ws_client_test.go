@@ -0,0 +1,157 @@
+package ws
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"github.com/SmoothWay/gophkeeper/pkg/tlsconfig"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nopService struct{}
+
+func (nopService) ApplyMessage(context.Context, models.Message) {}
+
+type staticToken string
+
+func (t staticToken) Token(context.Context) (string, error) { return string(t), nil }
+
+type memPins map[string]string
+
+func (m memPins) ServerPin(_ context.Context, url string) (string, error) { return m[url], nil }
+
+func (m memPins) SaveServerPin(_ context.Context, url string, pin string) error {
+	m[url] = pin
+	return nil
+}
+
+// newServer starts websocket server, it returns url and certificate of the server.
+// Tokens received by the server are sent to tokens.
+func newServer(t *testing.T, tokens chan<- string) (string, *x509.Certificate) {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens <- r.Header.Get("token")
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return "wss" + strings.TrimPrefix(srv.URL, "https") + "/ws", srv.Certificate()
+}
+
+// trusting returns client TLS configuration trusting certs.
+func trusting(certs ...*x509.Certificate) *tls.Config {
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return &tls.Config{RootCAs: pool}
+}
+
+func run(t *testing.T, url string, tlsConfig *tls.Config, pin string, pins PinStore) error {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := NewWSClient(log, make(chan models.Message), nopService{}, url, tlsConfig, pin, pins)
+	err := client.Run(ctx, make(chan struct{}), staticToken("token"))
+	if err == nil {
+		t.Cleanup(func() { client.conn.Close() })
+	}
+	return err
+}
+
+func TestRunVerifiesServer(t *testing.T) {
+	tokens := make(chan string, 10)
+	url, cert := newServer(t, tokens)
+
+	require.NoError(t, run(t, url, trusting(cert), "", nil))
+	assert.Equal(t, "token", <-tokens)
+
+	err := run(t, url, trusting(), "", nil)
+	var unknownAuthority x509.UnknownAuthorityError
+	assert.ErrorAs(t, err, &unknownAuthority)
+	assert.Empty(t, tokens, "token is not sent to unverified server")
+}
+
+func TestRunPin(t *testing.T) {
+	tokens := make(chan string, 10)
+	url, cert := newServer(t, tokens)
+
+	require.NoError(t, run(t, url, trusting(cert), tlsconfig.SPKIPin(cert), nil))
+	assert.Equal(t, "token", <-tokens)
+
+	err := run(t, url, trusting(cert), "sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", nil)
+	assert.ErrorIs(t, err, ErrPinMismatch)
+	assert.Empty(t, tokens, "token is not sent to server with other key")
+}
+
+func TestRunTrustOnFirstUse(t *testing.T) {
+	tokens := make(chan string, 10)
+	url, cert := newServer(t, tokens)
+	pins := memPins{}
+
+	require.NoError(t, run(t, url, trusting(cert), TrustOnFirstUse, pins))
+	assert.Equal(t, tlsconfig.SPKIPin(cert), pins[url])
+	require.NoError(t, run(t, url, trusting(cert), TrustOnFirstUse, pins))
+
+	pins[url] = "sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	err := run(t, url, trusting(cert), TrustOnFirstUse, pins)
+	assert.ErrorIs(t, err, ErrPinMismatch)
+	assert.Equal(t, "sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", pins[url], "pin is not replaced")
+
+	err = run(t, url, trusting(), TrustOnFirstUse, memPins{})
+	var unknownAuthority x509.UnknownAuthorityError
+	assert.ErrorAs(t, err, &unknownAuthority, "first key is trusted only after CA verification")
+}
+
+func TestRunInterruptOnce(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		msg := websocket.FormatCloseMessage(models.CloseSignedOut, "session revoked")
+		_ = conn.WriteMessage(websocket.CloseMessage, msg)
+		_ = conn.Close()
+	}))
+	t.Cleanup(srv.Close)
+	url := "wss" + strings.TrimPrefix(srv.URL, "https") + "/ws"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan models.Message, 10)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client := NewWSClient(log, ch, nopService{}, url, trusting(srv.Certificate()), "", nil)
+	interrupt := make(chan struct{})
+	require.NoError(t, client.Run(ctx, interrupt, staticToken("token")))
+
+	<-interrupt
+	// the writer fails on the closed connection too and does not close interrupt again
+	ch <- models.Message{Type: models.Update}
+	require.Eventually(t, func() bool { return len(ch) == 0 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+}
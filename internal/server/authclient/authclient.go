@@ -0,0 +1,106 @@
+// Package authclient fetches public keys of the auth service, which verify access tokens,
+// sessions revoked by users and deleted accounts.
+package authclient
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	authv1 "github.com/SmoothWay/gophkeeper/api/gen"
+	"github.com/SmoothWay/gophkeeper/pkg/jwt"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+type Client struct {
+	conn     *grpc.ClientConn
+	client   authv1.AuthClient
+	internal authv1.InternalClient
+	token    string
+}
+
+// New returns client of the auth service at address, the connection is secured with tlsConfig.
+// Calls of the internal service are authorized with the service token.
+func New(address string, tlsConfig *tls.Config, token string) (*Client, error) {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		conn:     conn,
+		client:   authv1.NewAuthClient(conn),
+		internal: authv1.NewInternalClient(conn),
+		token:    token,
+	}, nil
+}
+
+// PublicKeys returns public keys of the auth service by key id.
+func (c *Client) PublicKeys(ctx context.Context) (map[string]ed25519.PublicKey, error) {
+	const op = "authclient.PublicKeys"
+
+	res, err := c.client.GetPublicKeys(ctx, &authv1.GetPublicKeysRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(res.GetKeys()))
+	for _, key := range res.GetKeys() {
+		if key.GetAlg() != jwt.Alg || len(key.GetKey()) != ed25519.PublicKeySize {
+			continue
+		}
+		keys[key.GetKid()] = ed25519.PublicKey(key.GetKey())
+	}
+
+	return keys, nil
+}
+
+// RevokedSessions returns ids of sessions revoked after the position after and the position to poll the next
+// sessions after. When after is zero, sessions revoked after since are returned.
+func (c *Client) RevokedSessions(ctx context.Context, after int64, since time.Time) ([]string, int64, error) {
+	const op = "authclient.RevokedSessions"
+
+	res, err := c.internal.RevokedSessions(c.authorize(ctx), &authv1.RevokedSessionsRequest{After: after,
+		Since: since.UnixMilli()})
+	if err != nil {
+		return nil, after, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res.GetSessionIds(), res.GetUntil(), nil
+}
+
+// DeletedAccounts returns accounts deleted after the position after in order of deletion and the position to poll
+// the next accounts after. When after is zero, accounts deleted after since are returned.
+func (c *Client) DeletedAccounts(ctx context.Context, after int64, since time.Time) ([]models.DeletedAccount, int64,
+	error) {
+	const op = "authclient.DeletedAccounts"
+
+	res, err := c.internal.DeletedAccounts(c.authorize(ctx), &authv1.DeletedAccountsRequest{After: after,
+		Since: since.UnixMilli()})
+	if err != nil {
+		return nil, after, fmt.Errorf("%s: %w", op, err)
+	}
+
+	accounts := make([]models.DeletedAccount, 0, len(res.GetAccounts()))
+	for _, account := range res.GetAccounts() {
+		accounts = append(accounts, models.DeletedAccount{
+			UserID:    account.GetUserId(),
+			DeletedAt: time.UnixMilli(account.GetDeletedAt()),
+			Seq:       account.GetSeq(),
+		})
+	}
+	return accounts, res.GetUntil(), nil
+}
+
+// authorize adds the service token to metadata of the call.
+func (c *Client) authorize(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
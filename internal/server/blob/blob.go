@@ -0,0 +1,200 @@
+// Package blob keeps encrypted blobs uploaded by clients in chunks on the file system.
+//
+// Chunks are staged in separate files while the upload is in progress,
+// so an interrupted upload continues from the first missing chunk.
+// Complete upload is assembled into one file, chunks are read from it by offset.
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+var (
+	ErrInvalidID     = errors.New("invalid blob id")
+	ErrMissingChunk  = errors.New("blob chunk is missing")
+	ErrChunkSize     = errors.New("blob chunks have different size")
+	ErrOutOfRange    = errors.New("blob chunk is out of range")
+	ErrBlobNotExists = errors.New("blob does not exist")
+)
+
+// validID matches blob ids generated by clients, it keeps ids safe to use as file names.
+var validID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Store keeps blobs in the directory dir.
+type Store struct {
+	dir string
+}
+
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "parts"), 0o700); err != nil {
+		return nil, fmt.Errorf("create blob directory: %w", err)
+	}
+
+	return &Store{dir: dir}, nil
+}
+
+// ValidID reports whether id is valid blob id.
+func ValidID(id string) bool {
+	return validID.MatchString(id)
+}
+
+// WriteChunk stages chunk with index of the blob id.
+func (s *Store) WriteChunk(id string, index int, data []byte) error {
+	if !ValidID(id) {
+		return ErrInvalidID
+	}
+
+	dir := s.partsDir(id)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	// chunk appears only when it is written completely
+	tmp, err := os.CreateTemp(dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, strconv.Itoa(index)))
+}
+
+// Received returns number of staged chunks of the blob id without gaps from the first one.
+func (s *Store) Received(id string) (int, error) {
+	if !ValidID(id) {
+		return 0, ErrInvalidID
+	}
+
+	n := 0
+	for {
+		_, err := os.Stat(filepath.Join(s.partsDir(id), strconv.Itoa(n)))
+		if errors.Is(err, os.ErrNotExist) {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		n++
+	}
+}
+
+// Assemble joins staged chunks of the blob id into the blob and deletes them.
+// All chunks except the last one must have the same size.
+// It returns size of the blob and size of its chunks.
+func (s *Store) Assemble(id string, chunks int) (int64, int64, error) {
+	if !ValidID(id) {
+		return 0, 0, ErrInvalidID
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "tmp-")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var size, chunkSize int64
+	for i := 0; i < chunks; i++ {
+		n, err := s.copyChunk(tmp, id, i)
+		if err != nil {
+			return 0, 0, err
+		}
+		switch {
+		case i == 0:
+			chunkSize = n
+		case n > chunkSize || (i < chunks-1 && n != chunkSize):
+			return 0, 0, fmt.Errorf("chunk %d: %w", i, ErrChunkSize)
+		}
+		size += n
+	}
+
+	if err := tmp.Close(); err != nil {
+		return 0, 0, err
+	}
+	if err := os.Rename(tmp.Name(), s.path(id)); err != nil {
+		return 0, 0, err
+	}
+	if err := os.RemoveAll(s.partsDir(id)); err != nil {
+		return 0, 0, err
+	}
+
+	return size, chunkSize, nil
+}
+
+// ReadChunk reads length bytes of the assembled blob id from offset.
+func (s *Store) ReadChunk(id string, offset int64, length int64) ([]byte, error) {
+	if !ValidID(id) {
+		return nil, ErrInvalidID
+	}
+
+	f, err := os.Open(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, length)
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrOutOfRange
+	}
+
+	return buf[:n], nil
+}
+
+// Delete deletes the blob id and its staged chunks.
+func (s *Store) Delete(id string) error {
+	if !ValidID(id) {
+		return ErrInvalidID
+	}
+
+	if err := os.RemoveAll(s.partsDir(id)); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Store) copyChunk(w io.Writer, id string, index int) (int64, error) {
+	f, err := os.Open(filepath.Join(s.partsDir(id), strconv.Itoa(index)))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("chunk %d: %w", index, ErrMissingChunk)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return io.Copy(w, f)
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+func (s *Store) partsDir(id string) string {
+	return filepath.Join(s.dir, "parts", id)
+}
@@ -0,0 +1,84 @@
+package blob
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testID = "0123456789abcdef0123456789abcdef"
+
+func TestStore(t *testing.T) {
+	s, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	chunks := [][]byte{bytes.Repeat([]byte("a"), 4), bytes.Repeat([]byte("b"), 4), []byte("cc")}
+
+	n, err := s.Received(testID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// interrupted upload continues from the first missing chunk
+	require.NoError(t, s.WriteChunk(testID, 0, chunks[0]))
+	require.NoError(t, s.WriteChunk(testID, 2, chunks[2]))
+	n, err = s.Received(testID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, _, err = s.Assemble(testID, 3)
+	assert.ErrorIs(t, err, ErrMissingChunk)
+
+	require.NoError(t, s.WriteChunk(testID, 1, chunks[1]))
+	n, err = s.Received(testID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	size, chunkSize, err := s.Assemble(testID, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), size)
+	assert.Equal(t, int64(4), chunkSize)
+
+	for i, chunk := range chunks {
+		got, err := s.ReadChunk(testID, int64(i)*chunkSize, chunkSize)
+		require.NoError(t, err)
+		assert.Equal(t, chunk, got)
+	}
+	_, err = s.ReadChunk(testID, 3*chunkSize, chunkSize)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+
+	_, err = os.Stat(filepath.Join(s.dir, "parts", testID))
+	assert.ErrorIs(t, err, os.ErrNotExist, "staged chunks are deleted")
+
+	require.NoError(t, s.Delete(testID))
+	_, err = s.ReadChunk(testID, 0, chunkSize)
+	assert.ErrorIs(t, err, ErrBlobNotExists)
+}
+
+func TestAssembleChunkSize(t *testing.T) {
+	s, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, s.WriteChunk(testID, 0, []byte("aaaa")))
+	require.NoError(t, s.WriteChunk(testID, 1, []byte("bb")))
+	require.NoError(t, s.WriteChunk(testID, 2, []byte("cccc")))
+
+	_, _, err = s.Assemble(testID, 3)
+	assert.ErrorIs(t, err, ErrChunkSize)
+}
+
+func TestInvalidID(t *testing.T) {
+	s, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	for _, id := range []string{"", "../../etc/passwd", "0123456789ABCDEF0123456789ABCDEF", testID + "0"} {
+		assert.ErrorIs(t, s.WriteChunk(id, 0, []byte("data")), ErrInvalidID, id)
+		_, err := s.Received(id)
+		assert.ErrorIs(t, err, ErrInvalidID, id)
+		_, err = s.ReadChunk(id, 0, 1)
+		assert.ErrorIs(t, err, ErrInvalidID, id)
+	}
+}
@@ -0,0 +1,47 @@
+package clients
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Conn is websocket connection of the user session. Messages are written by the handler of the connection
+// and by senders of updates from other connections, websocket.Conn supports one writer at a time,
+// so all messages are written under the lock.
+type Conn struct {
+	ws *websocket.Conn
+	mu sync.Mutex
+}
+
+func NewConn(ws *websocket.Conn) *Conn {
+	return &Conn{ws: ws}
+}
+
+// WriteMessage writes the message, concurrent writes wait for each other.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ws.WriteMessage(messageType, data)
+}
+
+// WriteControl writes the control message, it is safe to call concurrently with WriteMessage.
+func (c *Conn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	return c.ws.WriteControl(messageType, data, deadline)
+}
+
+// ReadMessage reads the next message, only the handler of the connection reads it.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	return c.ws.ReadMessage()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.ws.RemoteAddr()
+}
+
+func (c *Conn) Close() error {
+	return c.ws.Close()
+}
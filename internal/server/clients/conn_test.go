@@ -0,0 +1,33 @@
+package clients
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnConcurrentWrites(t *testing.T) {
+	client, conn := connect(t)
+
+	const writers, messages = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < messages; j++ {
+				assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("update")))
+			}
+		}()
+	}
+
+	for i := 0; i < writers*messages; i++ {
+		_, data, err := client.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, "update", string(data), "messages are not interleaved")
+	}
+	wg.Wait()
+}
@@ -0,0 +1,99 @@
+package clients
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// DeletedSource returns accounts deleted after the position after in order of deletion and the position to poll
+// the next accounts after. When after is zero, accounts deleted after since are returned.
+type DeletedSource interface {
+	DeletedAccounts(ctx context.Context, after int64, since time.Time) ([]models.DeletedAccount, int64, error)
+}
+
+// UserPurger deletes data of deleted accounts.
+// LastDeletion returns time and position of the latest purged account, they are zero, if no accounts were purged.
+type UserPurger interface {
+	DeleteUser(ctx context.Context, account models.DeletedAccount) error
+	LastDeletion(ctx context.Context) (models.DeletedAccount, error)
+}
+
+// WatchDeleted polls deleted accounts every interval, closes their connections and deletes their data,
+// until ctx is done.
+// Polling resumes after the latest purged account, so accounts deleted while the server was down are purged too.
+// Accounts, which data failed to be deleted, are polled again.
+func (m *UserConnMap) WatchDeleted(ctx context.Context, log *slog.Logger, source DeletedSource, purger UserPurger,
+	interval time.Duration) {
+	const op = "clients.WatchDeleted"
+	log = log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var after int64
+	var since time.Time
+	started := false
+	for {
+		if !started {
+			last, err := purger.LastDeletion(ctx)
+			if err != nil {
+				log.Error("failed get last deletion", logger.Err(err))
+			} else {
+				// deletions purged before the position was kept are polled by time
+				after, since, started = last.Seq, last.DeletedAt, true
+				// access tokens of accounts deleted recently are still valid, their connections are refused again
+				m.refuseDeleted(ctx, log, source, m.now().Add(-revokedRetention))
+			}
+		}
+		if started {
+			after = m.purge(ctx, log, source, purger, after, since)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refuseDeleted refuses connections of accounts deleted after since.
+func (m *UserConnMap) refuseDeleted(ctx context.Context, log *slog.Logger, source DeletedSource, since time.Time) {
+	accounts, _, err := source.DeletedAccounts(ctx, 0, since)
+	if err != nil {
+		log.Error("failed get recently deleted accounts", logger.Err(err))
+		return
+	}
+	for _, account := range accounts {
+		m.Delete(account.UserID)
+	}
+}
+
+// purge deletes accounts deleted after the position after, or after since, when after is zero,
+// and returns the position to poll the next accounts after.
+// Accounts are deleted in order, the position of the last deleted account is returned on error.
+func (m *UserConnMap) purge(ctx context.Context, log *slog.Logger, source DeletedSource, purger UserPurger,
+	after int64, since time.Time) int64 {
+	accounts, until, err := source.DeletedAccounts(ctx, after, since)
+	if err != nil {
+		log.Error("failed get deleted accounts", logger.Err(err))
+		return after
+	}
+
+	for _, account := range accounts {
+		log := log.With(slog.Int64("user_id", account.UserID))
+		if closed := m.Delete(account.UserID); closed > 0 {
+			log.Info("connections of deleted account closed", slog.Int("count", closed))
+		}
+		if err := purger.DeleteUser(ctx, account); err != nil {
+			log.Error("failed delete account data", logger.Err(err))
+			return after
+		}
+		after = account.Seq
+	}
+	return until
+}
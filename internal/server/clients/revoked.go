@@ -0,0 +1,45 @@
+package clients
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+)
+
+// RevokedSource returns ids of sessions revoked after the position after and the position to poll the next sessions
+// after. When after is zero, sessions revoked after since are returned.
+type RevokedSource interface {
+	RevokedSessions(ctx context.Context, after int64, since time.Time) ([]string, int64, error)
+}
+
+// WatchRevoked polls revoked sessions every interval and closes their connections, until ctx is done.
+func (m *UserConnMap) WatchRevoked(ctx context.Context, log *slog.Logger, source RevokedSource, interval time.Duration) {
+	const op = "clients.WatchRevoked"
+	log = log.With(slog.String("op", op))
+
+	// sessions revoked while the server was down may still have valid access tokens
+	since := m.now().Add(-revokedRetention)
+	var after int64
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sessions, until, err := source.RevokedSessions(ctx, after, since)
+		if err != nil {
+			log.Error("failed get revoked sessions", logger.Err(err))
+		} else {
+			after = until
+			if closed := m.Revoke(sessions); closed > 0 {
+				log.Info("connections of revoked sessions closed", slog.Int("count", closed))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,33 +1,164 @@
 package clients
 
 import (
+	"errors"
 	"sync"
+	"time"
 
+	"github.com/SmoothWay/gophkeeper/pkg/models"
 	"github.com/gorilla/websocket"
 )
 
+// revokedRetention is how long revoked sessions are remembered,
+// it is longer than lifetime of access tokens, which carry the session id.
+const revokedRetention = time.Hour
+
+// closeTimeout limits sending of the close message to the client.
+const closeTimeout = time.Second
+
+var (
+	ErrSessionRevoked = errors.New("session revoked")
+	ErrAccountDeleted = errors.New("account deleted")
+)
+
+type sessionConn struct {
+	session string
+	conn    *Conn
+}
+
+// UserConnMap keeps websocket connections of users by login sessions.
 type UserConnMap struct {
 	mu    *sync.RWMutex
-	value map[int64][]*websocket.Conn
+	value map[int64][]sessionConn
+	// revoked keeps time, when the session was revoked
+	revoked map[string]time.Time
+	// deleted keeps time, when the account was deleted
+	deleted map[int64]time.Time
+	now     func() time.Time
 }
 
 func NewWSConnMap() *UserConnMap {
 	return &UserConnMap{
-		mu:    &sync.RWMutex{},
-		value: make(map[int64][]*websocket.Conn),
+		mu:      &sync.RWMutex{},
+		value:   make(map[int64][]sessionConn),
+		revoked: make(map[string]time.Time),
+		deleted: make(map[int64]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Put adds connection of the user session. It returns ErrSessionRevoked, if the session is revoked,
+// and ErrAccountDeleted, if the account of the user is deleted.
+func (m *UserConnMap) Put(userId int64, session string, conn *Conn) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.deleted[userId]; ok {
+		return ErrAccountDeleted
 	}
+	if _, ok := m.revoked[session]; ok {
+		return ErrSessionRevoked
+	}
+	m.value[userId] = append(m.value[userId], sessionConn{session: session, conn: conn})
+	return nil
 }
 
-func (m *UserConnMap) Put(userId int64, conn *websocket.Conn) {
+// Remove removes closed connection of the user.
+func (m *UserConnMap) Remove(userId int64, conn *Conn) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	m.value[userId] = append(m.value[userId], conn)
+	conns := m.value[userId]
+	for i := range conns {
+		if conns[i].conn == conn {
+			m.value[userId] = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(m.value[userId]) == 0 {
+		delete(m.value, userId)
+	}
 }
 
-func (m *UserConnMap) UserCons(userId int64) []*websocket.Conn {
+func (m *UserConnMap) UserCons(userId int64) []*Conn {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	return m.value[userId]
+	conns := make([]*Conn, 0, len(m.value[userId]))
+	for _, c := range m.value[userId] {
+		conns = append(conns, c.conn)
+	}
+	return conns
+}
+
+// Revoked reports whether the session is revoked.
+func (m *UserConnMap) Revoked(session string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.revoked[session]
+	return ok
+}
+
+// Revoke closes connections of the revoked sessions with models.CloseSignedOut code
+// and refuses new connections of the sessions. It returns number of closed connections.
+func (m *UserConnMap) Revoke(sessions []string) int {
+	m.mu.Lock()
+	now := m.now()
+	for session, at := range m.revoked {
+		if now.Sub(at) > revokedRetention {
+			delete(m.revoked, session)
+		}
+	}
+	var closing []*Conn
+	for _, session := range sessions {
+		m.revoked[session] = now
+	}
+	for userId, conns := range m.value {
+		kept := conns[:0]
+		for _, c := range conns {
+			if _, ok := m.revoked[c.session]; ok {
+				closing = append(closing, c.conn)
+				continue
+			}
+			kept = append(kept, c)
+		}
+		if len(kept) == 0 {
+			delete(m.value, userId)
+		} else {
+			m.value[userId] = kept
+		}
+	}
+	m.mu.Unlock()
+
+	msg := websocket.FormatCloseMessage(models.CloseSignedOut, ErrSessionRevoked.Error())
+	for _, conn := range closing {
+		// WriteControl is safe to call concurrently with writing of messages
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeTimeout))
+		_ = conn.Close()
+	}
+	return len(closing)
+}
+
+// Delete closes connections of the deleted account with models.CloseAccountDeleted code
+// and refuses new connections of the user. It returns number of closed connections.
+func (m *UserConnMap) Delete(userId int64) int {
+	m.mu.Lock()
+	now := m.now()
+	for id, at := range m.deleted {
+		if now.Sub(at) > revokedRetention {
+			delete(m.deleted, id)
+		}
+	}
+	m.deleted[userId] = now
+	closing := m.value[userId]
+	delete(m.value, userId)
+	m.mu.Unlock()
+
+	msg := websocket.FormatCloseMessage(models.CloseAccountDeleted, ErrAccountDeleted.Error())
+	for _, c := range closing {
+		_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeTimeout))
+		_ = c.conn.Close()
+	}
+	return len(closing)
 }
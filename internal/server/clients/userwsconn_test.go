@@ -0,0 +1,255 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// connect returns client and server side of new websocket connection.
+func connect(t *testing.T) (*websocket.Conn, *Conn) {
+	t.Helper()
+
+	accepted := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		accepted <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client, NewConn(<-accepted)
+}
+
+func TestRevoke(t *testing.T) {
+	conns := NewWSConnMap()
+
+	laptop, laptopConn := connect(t)
+	phone, phoneConn := connect(t)
+	require.NoError(t, conns.Put(1, "laptop", laptopConn))
+	require.NoError(t, conns.Put(1, "phone", phoneConn))
+	assert.Len(t, conns.UserCons(1), 2)
+
+	assert.Equal(t, 1, conns.Revoke([]string{"phone", "unknown"}))
+	assert.Equal(t, []*Conn{laptopConn}, conns.UserCons(1))
+	assert.True(t, conns.Revoked("phone"))
+	assert.False(t, conns.Revoked("laptop"))
+
+	_, _, err := phone.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, models.CloseSignedOut), "client is told that the session is revoked")
+
+	_, reconnected := connect(t)
+	assert.ErrorIs(t, conns.Put(1, "phone", reconnected), ErrSessionRevoked)
+
+	require.NoError(t, laptop.WriteMessage(websocket.TextMessage, []byte("ping")), "other sessions stay connected")
+	conns.Remove(1, laptopConn)
+	assert.Empty(t, conns.UserCons(1))
+
+	now := time.Now()
+	conns.now = func() time.Time { return now.Add(2 * revokedRetention) }
+	conns.Revoke(nil)
+	assert.False(t, conns.Revoked("phone"), "revoked sessions are forgotten after access tokens expire")
+}
+
+// fakeSource returns the sessions once.
+type fakeSource struct {
+	mu       sync.Mutex
+	sessions []string
+	after    []int64
+	since    []time.Time
+}
+
+func (f *fakeSource) RevokedSessions(_ context.Context, after int64, since time.Time) ([]string, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.after = append(f.after, after)
+	f.since = append(f.since, since)
+	sessions := f.sessions
+	f.sessions = nil
+	return sessions, after + 1, nil
+}
+
+func TestWatchRevoked(t *testing.T) {
+	conns := NewWSConnMap()
+	phone, phoneConn := connect(t)
+	require.NoError(t, conns.Put(1, "phone", phoneConn))
+
+	source := &fakeSource{sessions: []string{"phone"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		conns.WatchRevoked(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), source, time.Millisecond)
+		close(done)
+	}()
+
+	_, _, err := phone.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, models.CloseSignedOut))
+
+	require.Eventually(t, func() bool {
+		source.mu.Lock()
+		defer source.mu.Unlock()
+		return len(source.after) > 1
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Zero(t, source.after[0], "sessions revoked while the server was down are polled by time")
+	assert.WithinDuration(t, time.Now().Add(-revokedRetention), source.since[0], time.Second)
+	assert.Equal(t, int64(1), source.after[1], "polling continues after the returned position")
+}
+
+func TestDelete(t *testing.T) {
+	conns := NewWSConnMap()
+
+	phone, phoneConn := connect(t)
+	other, otherConn := connect(t)
+	require.NoError(t, conns.Put(1, "phone", phoneConn))
+	require.NoError(t, conns.Put(2, "other", otherConn))
+
+	assert.Equal(t, 1, conns.Delete(1))
+	assert.Empty(t, conns.UserCons(1))
+	_, _, err := phone.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, models.CloseAccountDeleted), "client is told that the account is deleted")
+
+	_, reconnected := connect(t)
+	assert.ErrorIs(t, conns.Put(1, "laptop", reconnected), ErrAccountDeleted)
+	require.NoError(t, other.WriteMessage(websocket.TextMessage, []byte("ping")), "other users stay connected")
+
+	now := time.Now()
+	conns.now = func() time.Time { return now.Add(2 * revokedRetention) }
+	assert.Zero(t, conns.Delete(3))
+	assert.NoError(t, conns.Put(1, "laptop", reconnected), "deleted accounts are forgotten after access tokens expire")
+}
+
+// deletedPoll is a call of fakeDeleted.DeletedAccounts.
+type deletedPoll struct {
+	after int64
+	since time.Time
+}
+
+// fakeDeleted returns the accounts deleted after the position or the time, purge of the account in fail fails once.
+type fakeDeleted struct {
+	mu       sync.Mutex
+	accounts []models.DeletedAccount
+	fail     int64
+	last     models.DeletedAccount
+	polls    []deletedPoll
+	purged   []int64
+}
+
+func (f *fakeDeleted) DeletedAccounts(_ context.Context, after int64, since time.Time) ([]models.DeletedAccount, int64,
+	error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.polls = append(f.polls, deletedPoll{after: after, since: since})
+	var accounts []models.DeletedAccount
+	until := after
+	for _, account := range f.accounts {
+		if after > 0 && account.Seq > after || after == 0 && account.DeletedAt.After(since) {
+			accounts = append(accounts, account)
+		}
+		until = max(until, account.Seq)
+	}
+	return accounts, until, nil
+}
+
+func (f *fakeDeleted) DeleteUser(_ context.Context, account models.DeletedAccount) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if account.UserID == f.fail {
+		f.fail = 0
+		return errors.New("database is down")
+	}
+	f.purged = append(f.purged, account.UserID)
+	return nil
+}
+
+func (f *fakeDeleted) LastDeletion(_ context.Context) (models.DeletedAccount, error) {
+	return f.last, nil
+}
+
+// watchDeleted runs WatchDeleted, until deleted is polled n times.
+func watchDeleted(t *testing.T, conns *UserConnMap, deleted *fakeDeleted, n int) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		conns.WatchDeleted(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), deleted, deleted, time.Millisecond)
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		deleted.mu.Lock()
+		defer deleted.mu.Unlock()
+		return len(deleted.polls) >= n
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
+
+func TestWatchDeleted(t *testing.T) {
+	conns := NewWSConnMap()
+	phone, phoneConn := connect(t)
+	require.NoError(t, conns.Put(2, "phone", phoneConn))
+
+	now := time.Now()
+	deleted := &fakeDeleted{
+		accounts: []models.DeletedAccount{
+			{UserID: 1, DeletedAt: now.Add(-2 * revokedRetention), Seq: 10},
+			{UserID: 2, DeletedAt: now.Add(-time.Minute), Seq: 11},
+			{UserID: 3, DeletedAt: now.Add(-time.Minute), Seq: 12},
+		},
+		fail: 2,
+		last: models.DeletedAccount{DeletedAt: now.Add(-3 * revokedRetention)},
+	}
+	watchDeleted(t, conns, deleted, 4)
+
+	_, _, err := phone.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, models.CloseAccountDeleted))
+
+	assert.Zero(t, deleted.polls[0].after, "connections of accounts deleted recently are refused")
+	assert.WithinDuration(t, now.Add(-revokedRetention), deleted.polls[0].since, time.Second)
+	assert.Equal(t, deletedPoll{since: deleted.last.DeletedAt}, deleted.polls[1],
+		"deletions purged before the position was kept are polled by time")
+	assert.Equal(t, int64(10), deleted.polls[2].after, "failed account is polled again")
+	assert.Equal(t, int64(12), deleted.polls[3].after)
+	assert.Equal(t, []int64{1, 2, 3}, deleted.purged)
+}
+
+func TestWatchDeletedResume(t *testing.T) {
+	conns := NewWSConnMap()
+	now := time.Now()
+	deleted := &fakeDeleted{
+		accounts: []models.DeletedAccount{
+			{UserID: 1, DeletedAt: now.Add(-time.Minute), Seq: 10},
+			// committed after the deletion at the position 10, but recorded earlier
+			{UserID: 2, DeletedAt: now.Add(-2 * time.Minute), Seq: 11},
+		},
+		last: models.DeletedAccount{UserID: 1, DeletedAt: now.Add(-time.Minute), Seq: 10},
+	}
+	watchDeleted(t, conns, deleted, 2)
+
+	assert.Equal(t, int64(10), deleted.polls[1].after, "polling resumes after the last purged account")
+	assert.Equal(t, []int64{2}, deleted.purged)
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"flag"
+	"log/slog"
 	"os"
 	"time"
 
@@ -9,18 +10,87 @@ import (
 )
 
 type Config struct {
-	DatabaseURL  string        `yaml:"database_url" env-required:"true"`
-	QueryTimeout time.Duration `yaml:"query_timeout" env-default:"2s"`
-	CertFile     string        `yaml:"cert_file" env-required:"true"`
-	KeyFile      string        `yaml:"key_file" env-required:"true"`
-	Key          string        `yaml:"key" env-required:"true"`
-	WS           WSConfig      `yaml:"ws"`
+	DatabaseURL  string          `yaml:"database_url" env-required:"true"`
+	QueryTimeout time.Duration   `yaml:"query_timeout" env-default:"2s"`
+	CertFile     string          `yaml:"cert_file" env-required:"true"`
+	KeyFile      string          `yaml:"key_file" env-required:"true"`
+	BlobDir      string          `yaml:"blob_dir" env-default:"./blobs"`
+	Key          string          `yaml:"key" env:"SERVER_LEGACY_KEY"`
+	MasterKey    MasterKeyConfig `yaml:"master_key"`
+	Rotation     RotationConfig  `yaml:"rotation"`
+	Auth         AuthConfig      `yaml:"auth"`
+	WS           WSConfig        `yaml:"ws"`
+}
+
+// AuthConfig configures the auth service, which publishes public keys verifying access tokens.
+// Sessions revoked by users are polled every SessionsPoll to close their connections,
+// deleted accounts are polled every AccountsPoll to delete their data.
+// The auth service is verified with CACertFile, CertFile and KeyFile are client certificate for mutual TLS.
+// Token is the service token of the auth service, which authorizes polling, it is required.
+type AuthConfig struct {
+	Address      string        `yaml:"address" env-default:"localhost:44044"`
+	CACertFile   string        `yaml:"ca_cert_file"`
+	CertFile     string        `yaml:"cert_file"`
+	KeyFile      string        `yaml:"key_file"`
+	Token        string        `yaml:"token" env:"SERVER_AUTH_TOKEN" env-required:"true"`
+	KeysTTL      time.Duration `yaml:"keys_ttl" env-default:"10m"`
+	SessionsPoll time.Duration `yaml:"sessions_poll" env-default:"5s"`
+	AccountsPoll time.Duration `yaml:"accounts_poll" env-default:"10s"`
 }
 
 type WSConfig struct {
 	Address string `yaml:"address"`
 }
 
+// MasterKeyConfig configures where server master keys come from.
+// Retired keys stay in the provider until all rows are re-encrypted with the active key.
+type MasterKeyConfig struct {
+	Provider string    `yaml:"provider" env-default:"file"`
+	Active   string    `yaml:"active" env-default:"default"`
+	Path     string    `yaml:"path" env-default:"./keys/master.keys"`
+	Env      string    `yaml:"env" env-default:"SERVER_MASTER_KEYS"`
+	KMS      KMSConfig `yaml:"kms"`
+}
+
+// KMSConfig configures HTTP key management service, which unwraps master keys.
+type KMSConfig struct {
+	URL         string             `yaml:"url"`
+	Token       string             `yaml:"token" env:"SERVER_KMS_TOKEN"`
+	KeyID       string             `yaml:"key_id"`
+	Timeout     time.Duration      `yaml:"timeout" env-default:"5s"`
+	WrappedKeys []WrappedKeyConfig `yaml:"wrapped_keys"`
+}
+
+// WrappedKeyConfig is master key encrypted by the KMS key.
+type WrappedKeyConfig struct {
+	ID         string `yaml:"id"`
+	Ciphertext string `yaml:"ciphertext"`
+}
+
+// RotationConfig configures background re-encryption of rows encrypted with retired keys.
+type RotationConfig struct {
+	Interval  time.Duration `yaml:"interval" env-default:"1m"`
+	BatchSize int           `yaml:"batch_size" env-default:"100"`
+}
+
+// LogValue hides keys, tokens and the database URL with credentials, when the configuration is logged.
+func (c Config) LogValue() slog.Value {
+	// plain does not implement slog.LogValuer, its fields are logged as is
+	type plain Config
+	c.DatabaseURL = redact(c.DatabaseURL)
+	c.Key = redact(c.Key)
+	c.Auth.Token = redact(c.Auth.Token)
+	c.MasterKey.KMS.Token = redact(c.MasterKey.KMS.Token)
+	return slog.AnyValue(plain(c))
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "REDACTED"
+}
+
 // MustLoad parses the file into the configuration structure Config.
 // Prefix "Must" method name means that the method does not return an error. It executes or throws panic.
 func MustLoad() *Config {
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/clients"
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// handleBlob handles messages to upload and download blobs in chunks.
+// Replies are sent only to the connection of the request.
+func (h *Handler) handleBlob(ctx context.Context, conn *clients.Conn, user models.User, msg models.Message) {
+	op := "ws.handleBlob"
+	log := h.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", user.ID),
+		slog.String("message type", msg.Type.String()),
+	)
+
+	var reply models.Message
+	var err error
+	switch msg.Type {
+	case models.BlobStatus:
+		reply, err = h.service.BlobStatus(ctx, user.ID, msg)
+	case models.BlobChunk:
+		// chunks are not confirmed, client asks BlobStatus to continue interrupted upload
+		if err = h.service.SaveChunk(ctx, user.ID, msg); err == nil {
+			return
+		}
+	case models.BlobCommit:
+		reply, err = h.service.CommitBlob(ctx, user.ID, msg)
+	case models.BlobRequest:
+		reply, err = h.service.BlobChunk(ctx, user.ID, msg)
+	}
+	if err != nil {
+		log.Error("failed handle blob message", logger.Err(err))
+		reply = models.Message{Type: models.Error, Value: []byte(err.Error())}
+	}
+
+	data, _ := json.Marshal(reply)
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		log.Error(
+			"error sending message to user",
+			slog.String("address", conn.RemoteAddr().String()),
+			logger.Err(err),
+		)
+	}
+}
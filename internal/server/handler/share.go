@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/clients"
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// handleShare handles messages to register public key of the user
+// and to share items with other users.
+func (h *Handler) handleShare(ctx context.Context, conn *clients.Conn, user models.User, msg models.Message) {
+	op := "ws.handleShare"
+	log := h.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", user.ID),
+		slog.String("message type", msg.Type.String()),
+	)
+
+	switch msg.Type {
+	case models.PublicKey:
+		if err := h.service.RegisterKey(ctx, user, msg); err != nil {
+			log.Error("failed register public key", logger.Err(err))
+		}
+
+	case models.KeyRequest:
+		reply, err := h.service.PublicKey(ctx, msg)
+		if err != nil {
+			log.Error("failed query public key", logger.Err(err))
+			return
+		}
+		data, _ := json.Marshal(reply)
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			log.Error(
+				"error sending message to user",
+				slog.String("address", conn.RemoteAddr().String()),
+				logger.Err(err),
+			)
+		}
+
+	case models.Share, models.Revoke:
+		share := h.service.Share
+		if msg.Type == models.Revoke {
+			share = h.service.Revoke
+		}
+
+		recipientID, update, err := share(ctx, user, msg)
+		if err != nil {
+			log.Error("failed share item", logger.Err(err))
+			errMsg, _ := json.Marshal(models.Message{Type: models.Error, Value: []byte(err.Error())})
+			_ = conn.WriteMessage(websocket.TextMessage, errMsg)
+			return
+		}
+
+		go h.sendUpdates(recipientID, update)
+	}
+}
+
+// sendShares sends to the user all items shared with the user by other users.
+func (h *Handler) sendShares(ctx context.Context, conn *clients.Conn, userID int64) {
+	op := "ws.sendShares"
+	log := h.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	shares, err := h.service.Shares(ctx, userID)
+	if err != nil {
+		log.Error("failed collect shared items for user", logger.Err(err))
+		return
+	}
+
+	msg, _ := json.Marshal(shares)
+	if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+		log.Error(
+			"error sending message to user",
+			slog.String("address", conn.RemoteAddr().String()),
+			logger.Err(err),
+		)
+	}
+}
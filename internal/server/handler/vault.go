@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/clients"
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// handleVault handles messages to create team vaults and manage their members.
+func (h *Handler) handleVault(ctx context.Context, conn *clients.Conn, user models.User, msg models.Message) {
+	op := "ws.handleVault"
+	log := h.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", user.ID),
+		slog.String("message type", msg.Type.String()),
+	)
+
+	switch msg.Type {
+	case models.VaultCreate:
+		vaults, err := h.service.CreateVault(ctx, user, msg)
+		if err != nil {
+			log.Error("failed create vault", logger.Err(err))
+			errMsg, _ := json.Marshal(models.Message{Type: models.Error, Value: []byte(err.Error())})
+			_ = conn.WriteMessage(websocket.TextMessage, errMsg)
+			return
+		}
+
+		go h.sendUpdates(user.ID, vaults)
+
+	case models.VaultMember:
+		memberID, err := h.service.SetMember(ctx, user, msg)
+		if err != nil {
+			log.Error("failed set vault member", logger.Err(err))
+			errMsg, _ := json.Marshal(models.Message{Type: models.Error, Value: []byte(err.Error())})
+			_ = conn.WriteMessage(websocket.TextMessage, errMsg)
+			return
+		}
+
+		vaults, err := h.service.Vaults(ctx, memberID)
+		if err != nil {
+			log.Error("failed collect vaults of member", logger.Err(err))
+			return
+		}
+		snapshot, err := h.service.Snapshot(ctx, memberID)
+		if err != nil {
+			log.Error("failed collect snapshot of member", logger.Err(err))
+			return
+		}
+
+		// vault keys must be delivered before items of the vault
+		go func() {
+			h.sendUpdates(memberID, vaults)
+			h.sendUpdates(memberID, snapshot)
+		}()
+	}
+}
+
+// sendVaults sends to the user all team vaults of the user.
+func (h *Handler) sendVaults(ctx context.Context, conn *clients.Conn, userID int64) {
+	op := "ws.sendVaults"
+	log := h.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	vaults, err := h.service.Vaults(ctx, userID)
+	if err != nil {
+		log.Error("failed collect vaults for user", logger.Err(err))
+		return
+	}
+
+	msg, _ := json.Marshal(vaults)
+	if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+		log.Error(
+			"error sending message to user",
+			slog.String("address", conn.RemoteAddr().String()),
+			logger.Err(err),
+		)
+	}
+}
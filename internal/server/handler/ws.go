@@ -3,11 +3,13 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/SmoothWay/gophkeeper/internal/server/clients"
-	"github.com/SmoothWay/gophkeeper/internal/server/lib"
+	"github.com/SmoothWay/gophkeeper/pkg/jwt"
 	"github.com/SmoothWay/gophkeeper/pkg/logger"
 	"github.com/SmoothWay/gophkeeper/pkg/models"
 	"github.com/gorilla/websocket"
@@ -17,6 +19,24 @@ type IService interface {
 	Snapshot(ctx context.Context, userID int64) (models.Message, error)
 	Save(ctx context.Context, userID int64, msg models.Message) error
 	Validate(msg models.Message) (models.Message, error)
+	RegisterKey(ctx context.Context, user models.User, msg models.Message) error
+	PublicKey(ctx context.Context, msg models.Message) (models.Message, error)
+	Share(ctx context.Context, user models.User, msg models.Message) (int64, models.Message, error)
+	Revoke(ctx context.Context, user models.User, msg models.Message) (int64, models.Message, error)
+	Shares(ctx context.Context, userID int64) (models.Message, error)
+	CreateVault(ctx context.Context, user models.User, msg models.Message) (models.Message, error)
+	SetMember(ctx context.Context, user models.User, msg models.Message) (int64, error)
+	Vaults(ctx context.Context, userID int64) (models.Message, error)
+	Authorize(ctx context.Context, userID int64, msg models.Message) ([]int64, error)
+	BlobStatus(ctx context.Context, userID int64, msg models.Message) (models.Message, error)
+	SaveChunk(ctx context.Context, userID int64, msg models.Message) error
+	CommitBlob(ctx context.Context, userID int64, msg models.Message) (models.Message, error)
+	BlobChunk(ctx context.Context, userID int64, msg models.Message) (models.Message, error)
+}
+
+// TokenVerifier verifies access tokens issued by the auth service.
+type TokenVerifier interface {
+	ParseClaims(accessToken string) (jwt.Claims, error)
 }
 
 // Handler handle request for establish connection from user.
@@ -24,14 +44,16 @@ type IService interface {
 type Handler struct {
 	log        *slog.Logger
 	service    IService
+	tokens     TokenVerifier
 	wsUpgrader *websocket.Upgrader
 	conns      *clients.UserConnMap
 }
 
-func NewHandler(log *slog.Logger, s IService, conns *clients.UserConnMap) *Handler {
+func NewHandler(log *slog.Logger, s IService, tokens TokenVerifier, conns *clients.UserConnMap) *Handler {
 	return &Handler{
 		log:        log,
 		service:    s,
+		tokens:     tokens,
 		wsUpgrader: &websocket.Upgrader{},
 		conns:      conns,
 	}
@@ -45,7 +67,7 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	conn, err := h.wsUpgrader.Upgrade(w, r, nil)
+	ws, err := h.wsUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Error(
 			"failed establish websocket connection",
@@ -53,9 +75,11 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	}
+	// updates from other connections of the user are written concurrently with replies of the handler
+	conn := clients.NewConn(ws)
 
 	token := r.Header.Get("token")
-	userID, err := lib.ParseToken(token)
+	claims, err := h.tokens.ParseClaims(token)
 	if err != nil {
 		log.Error(
 			"invalid token",
@@ -67,8 +91,23 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		_ = conn.Close()
 		return
 	}
+	user, userID := claims.User, claims.User.ID
+
+	if err := h.conns.Put(userID, claims.SessionID, conn); err != nil {
+		log.Info("connection refused", slog.Int64("user_id", userID), logger.Err(err))
+		code := models.CloseSignedOut
+		if errors.Is(err, clients.ErrAccountDeleted) {
+			code = models.CloseAccountDeleted
+		}
+		msg := websocket.FormatCloseMessage(code, err.Error())
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		_ = conn.Close()
+		return
+	}
+	defer h.conns.Remove(userID, conn)
 
-	h.conns.Put(userID, conn)
+	// keys of team vaults are needed to open vault items from the snapshot
+	h.sendVaults(ctx, conn, userID)
 	snapshot, err := h.service.Snapshot(ctx, userID)
 	if err != nil {
 		log.Error(
@@ -98,6 +137,7 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 			logger.Err(err),
 		)
 	}
+	h.sendShares(ctx, conn, userID)
 
 	for {
 		select {
@@ -107,13 +147,14 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		default:
 			mt, data, err := conn.ReadMessage()
 			if err != nil {
-				log.Error(
-					"error listening client connection",
+				// the connection is closed by the client or by revocation of the session
+				log.Info(
+					"client connection closed",
 					slog.Int64("user_id", userID),
 					slog.String("address", conn.RemoteAddr().String()),
 					logger.Err(err),
 				)
-				continue
+				return
 			}
 			if mt != websocket.TextMessage {
 				log.Info(
@@ -135,7 +176,16 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			_, err = lib.ParseToken(mesg.Token)
+			// access token is refreshed by the client while the connection is open,
+			// every message carries the current token of the same user and session
+			tokenClaims, err := h.tokens.ParseClaims(mesg.Token)
+			switch {
+			case err != nil:
+			case tokenClaims.User.ID != userID:
+				err = errors.New("token is issued to another user")
+			case tokenClaims.SessionID != claims.SessionID:
+				err = errors.New("token is issued to another session")
+			}
 			if err != nil {
 				log.Error(
 					"invalid token",
@@ -147,6 +197,18 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			switch mesg.Type {
+			case models.PublicKey, models.KeyRequest, models.Share, models.Revoke:
+				h.handleShare(ctx, conn, user, mesg)
+				continue
+			case models.VaultCreate, models.VaultMember:
+				h.handleVault(ctx, conn, user, mesg)
+				continue
+			case models.BlobStatus, models.BlobChunk, models.BlobCommit, models.BlobRequest:
+				h.handleBlob(ctx, conn, user, mesg)
+				continue
+			}
+
 			updateMsg, err := h.service.Validate(mesg)
 			if err != nil {
 				log.Error(
@@ -157,6 +219,17 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 				)
 				continue
 			}
+			recipients, err := h.service.Authorize(ctx, userID, mesg)
+			if err != nil {
+				log.Error(
+					"user is not allowed to save message",
+					slog.Int64("user_id", userID),
+					logger.Err(err),
+				)
+				errMsg, _ := json.Marshal(models.Message{Type: models.Error, Value: []byte(err.Error())})
+				_ = conn.WriteMessage(websocket.TextMessage, errMsg)
+				continue
+			}
 			err = h.service.Save(ctx, userID, mesg)
 			if err != nil {
 				log.Error(
@@ -168,7 +241,9 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			go h.sendUpdates(userID, updateMsg)
+			for _, id := range recipients {
+				go h.sendUpdates(id, updateMsg)
+			}
 		}
 	}
 
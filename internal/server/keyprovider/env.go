@@ -0,0 +1,28 @@
+package keyprovider
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Env reads master keys from the environment variable.
+// Variable contains comma separated "id:base64 key" entries.
+type Env struct {
+	name string
+}
+
+func NewEnv(name string) *Env {
+	return &Env{name: name}
+}
+
+func (p *Env) Keys(_ context.Context) (map[string][]byte, error) {
+	const op = "keyprovider.Env.Keys"
+
+	keys, err := parseKeys(os.Getenv(p.name))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, p.name, err)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,67 @@
+package keyprovider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// File reads master keys from the file.
+// File contains one "id:base64 key" entry per line.
+type File struct {
+	path string
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (p *File) Keys(_ context.Context) (map[string][]byte, error) {
+	const op = "keyprovider.File.Keys"
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys, err := parseKeys(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// CreateFile writes file with new random key id, if the file does not exist, so the key is not kept in the repository.
+// It reports whether the file was created.
+func CreateFile(path string, id string) (bool, error) {
+	const op = "keyprovider.CreateFile"
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "%s:%s\n", id, base64.StdEncoding.EncodeToString(key)); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := f.Sync(); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,138 @@
+package keyprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/config"
+)
+
+var ErrKMS = errors.New("kms request failed")
+
+// KMS unwraps master keys with remote key management service.
+// Configuration contains only master keys wrapped by the KMS key,
+// plaintext keys exist only in the server memory.
+//
+// Service should provide endpoints:
+//
+//	POST /wrap   {"key_id": "...", "plaintext": "base64"}  -> {"ciphertext": "base64"}
+//	POST /unwrap {"key_id": "...", "ciphertext": "base64"} -> {"plaintext": "base64"}
+type KMS struct {
+	client  *http.Client
+	url     string
+	token   string
+	keyID   string
+	wrapped []config.WrappedKeyConfig
+}
+
+type kmsRequest struct {
+	KeyID      string `json:"key_id"`
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+type kmsResponse struct {
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+func NewKMS(cfg config.KMSConfig) *KMS {
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &KMS{
+		client:  &http.Client{Timeout: timeout},
+		url:     strings.TrimRight(cfg.URL, "/"),
+		token:   cfg.Token,
+		keyID:   cfg.KeyID,
+		wrapped: cfg.WrappedKeys,
+	}
+}
+
+func (p *KMS) Keys(ctx context.Context) (map[string][]byte, error) {
+	const op = "keyprovider.KMS.Keys"
+
+	if len(p.wrapped) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrNoKeys)
+	}
+
+	keys := make(map[string][]byte, len(p.wrapped))
+	for _, w := range p.wrapped {
+		ciphertext, err := base64.StdEncoding.DecodeString(w.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", op, w.ID, ErrInvalidFormat)
+		}
+
+		key, err := p.Unwrap(ctx, ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", op, w.ID, err)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("%s: key %q: %w: key should be %d bytes long", op, w.ID, ErrInvalidFormat, keySize)
+		}
+		keys[w.ID] = key
+	}
+
+	return keys, nil
+}
+
+// Wrap encrypts new master key with the KMS key. Result can be put into wrapped keys configuration.
+func (p *KMS) Wrap(ctx context.Context, plaintext []byte) ([]byte, error) {
+	var res kmsResponse
+	if err := p.call(ctx, "/wrap", kmsRequest{KeyID: p.keyID, Plaintext: plaintext}, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Ciphertext, nil
+}
+
+// Unwrap decrypts master key wrapped by the KMS key.
+func (p *KMS) Unwrap(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	var res kmsResponse
+	if err := p.call(ctx, "/unwrap", kmsRequest{KeyID: p.keyID, Ciphertext: ciphertext}, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Plaintext, nil
+}
+
+func (p *KMS) call(ctx context.Context, path string, in kmsRequest, out *kmsResponse) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrKMS, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s %s", ErrKMS, path, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: %w", ErrKMS, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package keyprovider
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/config"
+)
+
+const keySize = 32
+
+var (
+	ErrNoKeys         = errors.New("no master keys provided")
+	ErrInvalidFormat  = errors.New("invalid master keys format")
+	ErrUnknownBackend = errors.New("unknown key provider")
+)
+
+// KeyProvider provides server master keys by key id.
+type KeyProvider interface {
+	Keys(ctx context.Context) (map[string][]byte, error)
+}
+
+// New creates key provider configured in cfg.
+func New(cfg config.MasterKeyConfig) (KeyProvider, error) {
+	switch cfg.Provider {
+	case "file":
+		return NewFile(cfg.Path), nil
+	case "env":
+		return NewEnv(cfg.Env), nil
+	case "kms":
+		return NewKMS(cfg.KMS), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, cfg.Provider)
+	}
+}
+
+// parseKeys parses master keys in format "id:base64 key", one key per line or comma separated.
+// Empty lines and lines starting with # are ignored.
+func parseKeys(data string) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+
+	entries := strings.FieldsFunc(data, func(r rune) bool {
+		return r == '\n' || r == ','
+	})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return nil, fmt.Errorf("%w: entry should be id:key", ErrInvalidFormat)
+		}
+
+		key, err := decodeKey(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	return keys, nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key should be base64 encoded", ErrInvalidFormat)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("%w: key should be %d bytes long", ErrInvalidFormat, keySize)
+	}
+
+	return key, nil
+}
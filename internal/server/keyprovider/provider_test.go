@@ -0,0 +1,165 @@
+package keyprovider
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/config"
+	"github.com/SmoothWay/gophkeeper/pkg/encrypt"
+)
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func TestFile(t *testing.T) {
+	k1, k2 := newKey(t), newKey(t)
+	path := filepath.Join(t.TempDir(), "master.keys")
+	data := "# comment\n\nk1:" + base64.StdEncoding.EncodeToString(k1) + "\n k2 : " + base64.StdEncoding.EncodeToString(k2) + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	keys, err := NewFile(path).Keys(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"k1": k1, "k2": k2}, keys)
+
+	_, err = NewFile(filepath.Join(t.TempDir(), "missing")).Keys(context.Background())
+	assert.Error(t, err)
+}
+
+func TestCreateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "master.keys")
+
+	created, err := CreateFile(path, "k1")
+	require.NoError(t, err)
+	assert.True(t, created)
+	keys, err := NewFile(path).Keys(context.Background())
+	require.NoError(t, err)
+	require.Len(t, keys["k1"], keySize)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// existing keys are kept
+	created, err = CreateFile(path, "k2")
+	require.NoError(t, err)
+	assert.False(t, created)
+	again, err := NewFile(path).Keys(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, keys, again)
+}
+
+func TestEnv(t *testing.T) {
+	k1, k2 := newKey(t), newKey(t)
+	t.Setenv("TEST_MASTER_KEYS", "k1:"+base64.StdEncoding.EncodeToString(k1)+",k2:"+base64.StdEncoding.EncodeToString(k2))
+
+	keys, err := NewEnv("TEST_MASTER_KEYS").Keys(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"k1": k1, "k2": k2}, keys)
+
+	_, err = NewEnv("TEST_MASTER_KEYS_UNSET").Keys(context.Background())
+	assert.ErrorIs(t, err, ErrNoKeys)
+}
+
+func TestParseKeysErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "without id", data: base64.StdEncoding.EncodeToString(newKey(t))},
+		{name: "empty id", data: ":" + base64.StdEncoding.EncodeToString(newKey(t))},
+		{name: "not base64", data: "k1:not base64"},
+		{name: "short key", data: "k1:" + base64.StdEncoding.EncodeToString([]byte("short"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseKeys(tt.data)
+			assert.ErrorIs(t, err, ErrInvalidFormat)
+		})
+	}
+}
+
+// stubKMS is local KMS implementation which wraps keys with AES-GCM.
+func stubKMS(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+
+	kek := newKey(t)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var req kmsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.KeyID != "master" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var res kmsResponse
+		var err error
+		switch r.URL.Path {
+		case "/wrap":
+			res.Ciphertext, err = encrypt.Encrypt(kek, req.KeyID, req.Plaintext)
+		case "/unwrap":
+			res.Plaintext, err = encrypt.Decrypt(kek, req.Ciphertext)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	}))
+}
+
+func TestKMS(t *testing.T) {
+	srv := stubKMS(t, "secret-token")
+	defer srv.Close()
+
+	cfg := config.KMSConfig{URL: srv.URL + "/", Token: "secret-token", KeyID: "master"}
+	k1 := newKey(t)
+	wrapped, err := NewKMS(cfg).Wrap(context.Background(), k1)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(wrapped, k1))
+
+	cfg.WrappedKeys = []config.WrappedKeyConfig{{ID: "k1", Ciphertext: base64.StdEncoding.EncodeToString(wrapped)}}
+	keys, err := NewKMS(cfg).Keys(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"k1": k1}, keys)
+
+	cfg.Token = "wrong-token"
+	_, err = NewKMS(cfg).Keys(context.Background())
+	assert.ErrorIs(t, err, ErrKMS)
+
+	cfg.Token = "secret-token"
+	cfg.WrappedKeys = []config.WrappedKeyConfig{{ID: "k1", Ciphertext: base64.StdEncoding.EncodeToString([]byte("garbage"))}}
+	_, err = NewKMS(cfg).Keys(context.Background())
+	assert.ErrorIs(t, err, ErrKMS)
+}
+
+func TestNew(t *testing.T) {
+	p, err := New(config.MasterKeyConfig{Provider: "env", Env: "TEST_MASTER_KEYS"})
+	require.NoError(t, err)
+	assert.IsType(t, &Env{}, p)
+
+	_, err = New(config.MasterKeyConfig{Provider: "vault"})
+	assert.ErrorIs(t, err, ErrUnknownBackend)
+}
@@ -1,31 +1,118 @@
 package lib
 
 import (
-	"errors"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"sync"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/SmoothWay/gophkeeper/pkg/jwt"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+	"golang.org/x/sync/singleflight"
 )
 
-const secret = "test-secret"
+const (
+	// minRefetch limits fetching of keys for tokens with unknown key id
+	// and while the auth service is unavailable.
+	minRefetch   = 10 * time.Second
+	fetchTimeout = 5 * time.Second
+)
+
+// KeyFetcher returns public keys of the auth service, which verify access tokens, by key id.
+type KeyFetcher interface {
+	PublicKeys(ctx context.Context) (map[string]ed25519.PublicKey, error)
+}
+
+// Verifier verifies access tokens with public keys fetched from the auth service.
+// Keys are cached for ttl. Token with unknown key id triggers fetching earlier,
+// so new signing key of the auth service is picked up right after rotation.
+type Verifier struct {
+	fetcher KeyFetcher
+	ttl     time.Duration
+	now     func() time.Time
+
+	fetching singleflight.Group
+
+	mu      sync.Mutex
+	keys    map[string]ed25519.PublicKey
+	fetched time.Time
+	// attempted is time of the last fetching, successful or not
+	attempted time.Time
+}
+
+func NewVerifier(fetcher KeyFetcher, ttl time.Duration) *Verifier {
+	return &Verifier{
+		fetcher: fetcher,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+func (v *Verifier) ParseToken(accessToken string) (int64, error) {
+	user, err := v.ParseUser(accessToken)
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
 
-func ParseToken(accessToken string) (int64, error) {
-	token, err := jwt.ParseWithClaims(accessToken, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
+// ParseUser returns id and email of the user from the access token.
+func (v *Verifier) ParseUser(accessToken string) (models.User, error) {
+	return jwt.Parse(accessToken, v.key)
+}
+
+// ParseClaims returns user and session from the access token.
+func (v *Verifier) ParseClaims(accessToken string) (jwt.Claims, error) {
+	return jwt.ParseClaims(accessToken, v.key)
+}
+
+func (v *Verifier) key(kid string) (ed25519.PublicKey, error) {
+	v.mu.Lock()
+	key, ok := v.keys[kid]
+	now := v.now()
+	cached := (ok && now.Sub(v.fetched) < v.ttl) || now.Sub(v.attempted) < minRefetch
+	v.mu.Unlock()
+
+	if cached {
+		if !ok {
+			return nil, jwt.ErrUnknownKey
 		}
+		return key, nil
+	}
 
-		return []byte(secret), nil
+	// concurrent requests wait for one fetching, the lock is not held meanwhile,
+	// so tokens with cached keys are verified while the auth service is slow
+	res, err, _ := v.fetching.Do("keys", func() (any, error) {
+		return v.fetch(now)
 	})
 	if err != nil {
-		return 0, err
+		// auth service is unavailable, cached keys are still valid
+		if ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("fetch public keys: %w", err)
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return 0, errors.New("token claims are not of type *tokenClaims")
+
+	if key, ok = res.(map[string]ed25519.PublicKey)[kid]; !ok {
+		return nil, jwt.ErrUnknownKey
 	}
-	if int64(claims["exp"].(float64)) < time.Now().Unix() {
-		return 0, errors.New("token has expired")
+	return key, nil
+}
+
+// fetch fetches public keys from the auth service and caches them.
+func (v *Verifier) fetch(now time.Time) (map[string]ed25519.PublicKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	keys, err := v.fetcher.PublicKeys(ctx)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.attempted = now
+	if err != nil {
+		return nil, err
 	}
-	return int64(claims["uid"].(float64)), nil
+	v.keys, v.fetched = keys, now
+	return keys, nil
 }
@@ -1,6 +1,9 @@
 package lib
 
 import (
+	"context"
+	"crypto/ed25519"
+	"errors"
 	"testing"
 	"time"
 
@@ -11,13 +14,133 @@ import (
 	"github.com/SmoothWay/gophkeeper/pkg/models"
 )
 
-func TestParseToken(t *testing.T) {
-	user := models.User{ID: 10, Email: "name@example.com", PassHash: []byte("hash")}
-	app := models.App{ID: 1, Name: "gophkeeper", Secret: "test-secret"}
-	token, err := jwt.NewToken(user, app, time.Hour)
+// fakeAuth is the auth service, which rotates signing keys.
+type fakeAuth struct {
+	keys    map[string]ed25519.PrivateKey
+	fetches int
+	err     error
+}
+
+func (f *fakeAuth) PublicKeys(_ context.Context) (map[string]ed25519.PublicKey, error) {
+	f.fetches++
+	if f.err != nil {
+		return nil, f.err
+	}
+	keys := make(map[string]ed25519.PublicKey)
+	for kid, key := range f.keys {
+		keys[kid] = key.Public().(ed25519.PublicKey)
+	}
+	return keys, nil
+}
+
+func (f *fakeAuth) token(t *testing.T, kid string, user models.User) string {
+	t.Helper()
+
+	if _, ok := f.keys[kid]; !ok {
+		key, err := jwt.GenerateKey()
+		require.NoError(t, err)
+		f.keys[kid] = key
+	}
+	signer, err := jwt.NewSigner(kid, f.keys)
+	require.NoError(t, err)
+	token, err := signer.NewToken(user, models.App{ID: 1}, "session", time.Now(), time.Hour)
 	require.NoError(t, err)
+	return token
+}
+
+func TestParseUser(t *testing.T) {
+	user := models.User{ID: 10, Email: "name@example.com"}
+	auth := &fakeAuth{keys: make(map[string]ed25519.PrivateKey)}
+	verifier := NewVerifier(auth, time.Hour)
+	now := time.Unix(1000, 0)
+	verifier.now = func() time.Time { return now }
 
-	userID, err := ParseToken(token)
+	token := auth.token(t, "k1", user)
+	parsed, err := verifier.ParseUser(token)
+	require.NoError(t, err)
+	assert.Equal(t, user, parsed)
+	userID, err := verifier.ParseToken(token)
 	require.NoError(t, err)
 	assert.Equal(t, user.ID, userID)
+	assert.Equal(t, 1, auth.fetches, "keys are cached")
+
+	// signing key is rotated, token with new key id triggers fetching once the limit allows it
+	rotated := auth.token(t, "k2", user)
+	_, err = verifier.ParseUser(rotated)
+	assert.ErrorIs(t, err, jwt.ErrUnknownKey)
+	assert.Equal(t, 1, auth.fetches)
+
+	now = now.Add(minRefetch)
+	_, err = verifier.ParseUser(rotated)
+	require.NoError(t, err)
+	assert.Equal(t, 2, auth.fetches)
+
+	// retired key is removed from the auth service and stops working when cache expires
+	delete(auth.keys, "k1")
+	_, err = verifier.ParseUser(token)
+	require.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = verifier.ParseUser(token)
+	assert.ErrorIs(t, err, jwt.ErrUnknownKey)
+
+	// cached keys are used while the auth service is unavailable
+	auth.err = errors.New("unavailable")
+	now = now.Add(2 * time.Hour)
+	_, err = verifier.ParseUser(rotated)
+	require.NoError(t, err)
+}
+
+func TestParseUserInvalid(t *testing.T) {
+	auth := &fakeAuth{keys: make(map[string]ed25519.PrivateKey), err: errors.New("unavailable")}
+	verifier := NewVerifier(auth, time.Hour)
+
+	_, err := verifier.ParseUser(auth.token(t, "k1", models.User{ID: 10}))
+	assert.Error(t, err)
+
+	_, err = verifier.ParseUser("not a token")
+	assert.ErrorIs(t, err, jwt.ErrInvalidToken)
+}
+
+// slowAuth is the auth service, which responds after release is closed.
+type slowAuth struct {
+	*fakeAuth
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s slowAuth) PublicKeys(ctx context.Context) (map[string]ed25519.PublicKey, error) {
+	close(s.started)
+	<-s.release
+	return s.fakeAuth.PublicKeys(ctx)
+}
+
+func TestParseUserSlowFetch(t *testing.T) {
+	user := models.User{ID: 10, Email: "name@example.com"}
+	auth := &fakeAuth{keys: make(map[string]ed25519.PrivateKey)}
+	verifier := NewVerifier(auth, time.Hour)
+	now := time.Unix(1000, 0)
+	verifier.now = func() time.Time { return now }
+
+	token := auth.token(t, "k1", user)
+	_, err := verifier.ParseUser(token)
+	require.NoError(t, err)
+
+	slow := slowAuth{fakeAuth: auth, started: make(chan struct{}), release: make(chan struct{})}
+	verifier.fetcher = slow
+	now = now.Add(minRefetch)
+	rotated := auth.token(t, "k2", user)
+	done := make(chan error)
+	go func() {
+		_, err := verifier.ParseUser(rotated)
+		done <- err
+	}()
+	<-slow.started
+
+	// fetching does not block tokens with cached keys
+	_, err = verifier.ParseUser(token)
+	require.NoError(t, err)
+
+	close(slow.release)
+	require.NoError(t, <-done)
+	assert.Equal(t, 2, auth.fetches)
 }
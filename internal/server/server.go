@@ -1,17 +1,28 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 
+	"github.com/SmoothWay/gophkeeper/internal/server/authclient"
+	"github.com/SmoothWay/gophkeeper/internal/server/blob"
 	"github.com/SmoothWay/gophkeeper/internal/server/clients"
 	"github.com/SmoothWay/gophkeeper/internal/server/config"
 	"github.com/SmoothWay/gophkeeper/internal/server/handler"
+	"github.com/SmoothWay/gophkeeper/internal/server/keyprovider"
+	"github.com/SmoothWay/gophkeeper/internal/server/lib"
 	"github.com/SmoothWay/gophkeeper/internal/server/service"
 	"github.com/SmoothWay/gophkeeper/internal/server/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/encrypt"
+	"github.com/SmoothWay/gophkeeper/pkg/tlsconfig"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// defaultKeyID is id of the legacy key from "key" config field.
+const defaultKeyID = "default"
+
 type App struct {
 	db *pgxpool.Pool
 }
@@ -21,10 +32,47 @@ func Run(log *slog.Logger, cfg *config.Config) {
 	if err != nil {
 		panic(err)
 	}
+	if cfg.MasterKey.Provider == "file" {
+		created, err := keyprovider.CreateFile(cfg.MasterKey.Path, cfg.MasterKey.Active)
+		if err != nil {
+			panic(err)
+		}
+		if created {
+			log.Info("master key generated", slog.String("id", cfg.MasterKey.Active),
+				slog.String("path", cfg.MasterKey.Path))
+		}
+	}
+	provider, err := keyprovider.New(cfg.MasterKey)
+	if err != nil {
+		panic(err)
+	}
+	keys, err := newKeyring(context.Background(), provider, cfg)
+	if err != nil {
+		panic(err)
+	}
+	blobs, err := blob.New(cfg.BlobDir)
+	if err != nil {
+		panic(err)
+	}
 	storageKeeper := storage.NewKeeperPostgres(db, cfg.QueryTimeout)
-	serviceKeeper := service.New(log, storageKeeper, cfg.Key)
+	serviceKeeper := service.New(log, storageKeeper, blobs, keys, cfg.Key)
+	go serviceKeeper.RunReencryption(context.Background(), cfg.Rotation.Interval, cfg.Rotation.BatchSize)
+	authTLS, err := tlsconfig.Client(cfg.Auth.CACertFile, cfg.Auth.CertFile, cfg.Auth.KeyFile)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.Auth.Token == "" {
+		log.Warn("service token of the auth service is not configured, polling of the auth service fails")
+	}
+	auth, err := authclient.New(cfg.Auth.Address, authTLS, cfg.Auth.Token)
+	if err != nil {
+		panic(err)
+	}
+	defer auth.Close()
 	conns := clients.NewWSConnMap()
-	h := handler.NewHandler(log, serviceKeeper, conns)
+	go conns.WatchRevoked(context.Background(), log, auth, cfg.Auth.SessionsPoll)
+	go conns.WatchDeleted(context.Background(), log, auth, serviceKeeper, cfg.Auth.AccountsPoll)
+	h := handler.NewHandler(log, serviceKeeper, lib.NewVerifier(auth, cfg.Auth.KeysTTL), conns)
 
 	http.HandleFunc("/ws", h.Handle)
 
@@ -34,3 +82,18 @@ func Run(log *slog.Logger, cfg *config.Config) {
 		panic(err)
 	}
 }
+
+// newKeyring obtains server master keys from the key provider.
+// Legacy key from "key" field is available with id "default", if provider has no such key.
+func newKeyring(ctx context.Context, provider keyprovider.KeyProvider, cfg *config.Config) (*encrypt.Keyring, error) {
+	keys, err := provider.Keys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("obtain master keys: %w", err)
+	}
+
+	if _, ok := keys[defaultKeyID]; !ok && cfg.Key != "" {
+		keys[defaultKeyID] = encrypt.DeriveKey(cfg.Key)
+	}
+
+	return encrypt.NewKeyring(cfg.MasterKey.Active, keys)
+}
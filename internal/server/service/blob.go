@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/blob"
+	"github.com/SmoothWay/gophkeeper/internal/server/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// Limits of blobs uploaded by clients.
+const (
+	maxChunkSize = 1 << 20
+	maxChunks    = 1 << 16
+)
+
+// BlobStatus starts or continues upload of the blob by the user.
+// It returns message with number of chunks the server already has.
+func (s *Service) BlobStatus(ctx context.Context, userID int64, msg models.Message) (models.Message, error) {
+	const op = "servicekeeper.BlobStatus"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	var info models.BlobInfo
+	if err := json.Unmarshal(msg.Value, &info); err != nil || !blob.ValidID(info.BlobID) {
+		log.Error("invalid blob status message")
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+
+	b, err := s.upload(ctx, info.BlobID, userID)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	info = models.BlobInfo{BlobID: b.ID, Chunks: b.Chunks, Size: b.Size, Received: b.Chunks}
+	if !b.Complete {
+		if info.Received, err = s.blobs.Received(b.ID); err != nil {
+			log.Error("query received chunks error", logger.Err(err))
+			return models.Message{}, ErrInternal
+		}
+	}
+
+	value, _ := json.Marshal(info)
+	return models.Message{Type: models.BlobStatus, Value: value}, nil
+}
+
+// SaveChunk saves chunk of the blob uploaded by the user.
+func (s *Service) SaveChunk(ctx context.Context, userID int64, msg models.Message) error {
+	const op = "servicekeeper.SaveChunk"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	var chunk models.Chunk
+	err := json.Unmarshal(msg.Value, &chunk)
+	switch {
+	case err != nil, !blob.ValidID(chunk.BlobID):
+		log.Error("invalid blob chunk message")
+		return fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	case chunk.Index < 0 || chunk.Index >= maxChunks, len(chunk.Data) == 0, len(chunk.Data) > maxChunkSize:
+		log.Error("blob chunk exceeds limits", slog.Int("index", chunk.Index), slog.Int("size", len(chunk.Data)))
+		return fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+
+	b, err := s.upload(ctx, chunk.BlobID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if b.Complete {
+		// complete blob is immutable, items reference it
+		return fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	if err := s.blobs.WriteChunk(chunk.BlobID, chunk.Index, chunk.Data); err != nil {
+		log.Error("write blob chunk error", logger.Err(err))
+		return ErrInternal
+	}
+
+	return nil
+}
+
+// CommitBlob assembles uploaded chunks into the blob.
+// It returns message with size of the blob, after that items can reference the blob.
+func (s *Service) CommitBlob(ctx context.Context, userID int64, msg models.Message) (models.Message, error) {
+	const op = "servicekeeper.CommitBlob"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	var info models.BlobInfo
+	err := json.Unmarshal(msg.Value, &info)
+	if err != nil || !blob.ValidID(info.BlobID) || info.Chunks <= 0 || info.Chunks > maxChunks {
+		log.Error("invalid blob commit message")
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+
+	b, err := s.upload(ctx, info.BlobID, userID)
+	if err != nil {
+		return models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !b.Complete {
+		size, chunkSize, err := s.blobs.Assemble(b.ID, info.Chunks)
+		if err != nil {
+			log.Error("assemble blob error", slog.String("blob_id", b.ID), logger.Err(err))
+			if errors.Is(err, blob.ErrMissingChunk) || errors.Is(err, blob.ErrChunkSize) {
+				return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+			}
+			return models.Message{}, ErrInternal
+		}
+
+		b.Chunks, b.ChunkSize, b.Size, b.Complete = info.Chunks, chunkSize, size, true
+		if err := s.storage.CompleteBlob(ctx, b); err != nil {
+			log.Error("complete blob error", logger.Err(err))
+			return models.Message{}, ErrInternal
+		}
+		log.Info("blob uploaded", slog.String("blob_id", b.ID), slog.Int64("size", size))
+	}
+
+	value, _ := json.Marshal(models.BlobInfo{BlobID: b.ID, Received: b.Chunks, Chunks: b.Chunks, Size: b.Size})
+	return models.Message{Type: models.BlobCommit, Value: value}, nil
+}
+
+// BlobChunk returns message with requested chunk of the complete blob.
+// Only the user, who uploaded the blob, and members of team vaults with items referencing the blob can read it,
+// content of the blob is encrypted with the key from the item.
+func (s *Service) BlobChunk(ctx context.Context, userID int64, msg models.Message) (models.Message, error) {
+	const op = "servicekeeper.BlobChunk"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	var chunk models.Chunk
+	if err := json.Unmarshal(msg.Value, &chunk); err != nil || !blob.ValidID(chunk.BlobID) {
+		log.Error("invalid blob request message")
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+
+	b, err := s.storage.Blob(ctx, chunk.BlobID)
+	if err != nil {
+		log.Error("query blob error", slog.String("blob_id", chunk.BlobID), logger.Err(err))
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+	if !b.Complete || chunk.Index < 0 || chunk.Index >= b.Chunks {
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+	ok, err := s.storage.CanReadBlob(ctx, b.ID, userID)
+	if err != nil {
+		log.Error("query blob access error", slog.String("blob_id", b.ID), logger.Err(err))
+		return models.Message{}, ErrInternal
+	}
+	if !ok {
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	data, err := s.blobs.ReadChunk(b.ID, int64(chunk.Index)*b.ChunkSize, b.ChunkSize)
+	if err != nil {
+		log.Error("read blob chunk error", logger.Err(err))
+		return models.Message{}, ErrInternal
+	}
+
+	value, _ := json.Marshal(models.Chunk{BlobID: b.ID, Index: chunk.Index, Chunks: b.Chunks, Data: data})
+	return models.Message{Type: models.BlobChunk, Value: value}, nil
+}
+
+// checkItemBlob checks that the item of the user may reference the blob:
+// the blob is uploaded completely and the user can read it, see BlobChunk.
+func (s *Service) checkItemBlob(ctx context.Context, id string, userID int64) error {
+	b, err := s.storage.Blob(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			return ErrInvalidMessage
+		}
+		return err
+	}
+	if !b.Complete {
+		return ErrInvalidMessage
+	}
+	ok, err := s.storage.CanReadBlob(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// deleteBlobs deletes files of blobs, which are not referenced anymore, a file left after error is never read.
+func (s *Service) deleteBlobs(log *slog.Logger, ids []string) {
+	for _, id := range ids {
+		if err := s.blobs.Delete(id); err != nil {
+			log.Error("deleting blob error", slog.String("blob_id", id), logger.Err(err))
+		}
+	}
+}
+
+// upload returns blob uploaded by the user, upload is started if needed.
+// Only the user who started upload of the blob can continue it.
+func (s *Service) upload(ctx context.Context, id string, userID int64) (storage.Blob, error) {
+	b, err := s.storage.CreateBlob(ctx, id, userID)
+	if err != nil {
+		s.log.Error("create blob error", slog.String("op", "servicekeeper.upload"), logger.Err(err))
+		return storage.Blob{}, ErrInternal
+	}
+	if b.UserID != userID {
+		return storage.Blob{}, ErrForbidden
+	}
+
+	return b, nil
+}
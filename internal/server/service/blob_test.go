@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+const testBlobID = "0123456789abcdef0123456789abcdef"
+
+func blobMsg(t *testing.T, kind models.MessageType, value any) models.Message {
+	t.Helper()
+
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+	return models.Message{Type: kind, Value: data}
+}
+
+func TestBlobUpload(t *testing.T) {
+	ctx := context.Background()
+	st := &memStorage{}
+	s := newTestService(t, st, "old")
+
+	status := func(userID int64) (models.BlobInfo, error) {
+		reply, err := s.BlobStatus(ctx, userID, blobMsg(t, models.BlobStatus, models.BlobInfo{BlobID: testBlobID}))
+		if err != nil {
+			return models.BlobInfo{}, err
+		}
+		var info models.BlobInfo
+		require.NoError(t, json.Unmarshal(reply.Value, &info))
+		return info, nil
+	}
+
+	info, err := status(1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, info.Received)
+
+	chunks := [][]byte{[]byte("chunk-0"), []byte("chunk-1"), []byte("c-2")}
+	require.NoError(t, s.SaveChunk(ctx, 1, blobMsg(t, models.BlobChunk, models.Chunk{BlobID: testBlobID, Index: 0, Data: chunks[0]})))
+	require.NoError(t, s.SaveChunk(ctx, 1, blobMsg(t, models.BlobChunk, models.Chunk{BlobID: testBlobID, Index: 1, Data: chunks[1]})))
+
+	// upload continues after reconnect
+	info, err = status(1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, info.Received)
+
+	// only the user, who started upload, can continue it
+	_, err = status(2)
+	assert.ErrorIs(t, err, ErrForbidden)
+	err = s.SaveChunk(ctx, 2, blobMsg(t, models.BlobChunk, models.Chunk{BlobID: testBlobID, Index: 2, Data: chunks[2]}))
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = s.CommitBlob(ctx, 1, blobMsg(t, models.BlobCommit, models.BlobInfo{BlobID: testBlobID, Chunks: 3}))
+	assert.ErrorIs(t, err, ErrInvalidMessage, "chunk is missing")
+
+	// item cannot reference incomplete blob
+	item := blobMsg(t, models.New, models.Envelope{ID: "item", Created: 1, Data: []byte("sealed"), BlobID: testBlobID})
+	assert.ErrorIs(t, s.Save(ctx, 1, item), ErrInvalidMessage)
+
+	require.NoError(t, s.SaveChunk(ctx, 1, blobMsg(t, models.BlobChunk, models.Chunk{BlobID: testBlobID, Index: 2, Data: chunks[2]})))
+	reply, err := s.CommitBlob(ctx, 1, blobMsg(t, models.BlobCommit, models.BlobInfo{BlobID: testBlobID, Chunks: 3}))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(reply.Value, &info))
+	assert.Equal(t, models.BlobInfo{BlobID: testBlobID, Received: 3, Chunks: 3, Size: 17}, info)
+
+	err = s.SaveChunk(ctx, 1, blobMsg(t, models.BlobChunk, models.Chunk{BlobID: testBlobID, Index: 0, Data: []byte("changed")}))
+	assert.ErrorIs(t, err, ErrForbidden, "complete blob is immutable")
+
+	require.NoError(t, s.Save(ctx, 1, item))
+	require.Len(t, st.items, 1)
+	assert.Equal(t, testBlobID, st.items[0].BlobID)
+
+	// other users can neither download the blob nor reference it
+	_, err = s.BlobChunk(ctx, 2, blobMsg(t, models.BlobRequest, models.Chunk{BlobID: testBlobID, Index: 0}))
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.ErrorIs(t, s.Save(ctx, 2, item), ErrForbidden)
+
+	for i, chunk := range chunks {
+		reply, err := s.BlobChunk(ctx, 1, blobMsg(t, models.BlobRequest, models.Chunk{BlobID: testBlobID, Index: i}))
+		require.NoError(t, err)
+		assert.Equal(t, models.BlobChunk, reply.Type)
+
+		var got models.Chunk
+		require.NoError(t, json.Unmarshal(reply.Value, &got))
+		assert.Equal(t, models.Chunk{BlobID: testBlobID, Index: i, Chunks: 3, Data: chunk}, got)
+	}
+	_, err = s.BlobChunk(ctx, 1, blobMsg(t, models.BlobRequest, models.Chunk{BlobID: testBlobID, Index: 3}))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
+
+// uploadBlob uploads blob of one chunk by the user.
+func uploadBlob(t *testing.T, s *Service, userID int64, id string) {
+	t.Helper()
+
+	ctx := context.Background()
+	require.NoError(t, s.SaveChunk(ctx, userID, blobMsg(t, models.BlobChunk, models.Chunk{BlobID: id, Data: []byte("chunk")})))
+	_, err := s.CommitBlob(ctx, userID, blobMsg(t, models.BlobCommit, models.BlobInfo{BlobID: id, Chunks: 1}))
+	require.NoError(t, err)
+}
+
+func TestTeamBlob(t *testing.T) {
+	ctx := context.Background()
+	st := &memStorage{}
+	s := newTestService(t, st, "old")
+	for _, user := range []models.User{owner, recipient} {
+		require.NoError(t, s.RegisterKey(ctx, user, keyMsg(t, models.UserKey{Key: bytes.Repeat([]byte{1}, publicKeyLen)})))
+	}
+	_, err := s.CreateVault(ctx, owner, vaultMsg(t, models.VaultCreate, models.TeamVault{Name: "team", Key: []byte("owner key")}))
+	require.NoError(t, err)
+	_, err = s.SetMember(ctx, owner, vaultMsg(t, models.VaultMember, models.Member{VaultID: 1, Email: recipient.Email, Role: models.RoleEditor, Key: []byte("editor key")}))
+	require.NoError(t, err)
+
+	uploadBlob(t, s, recipient.ID, testBlobID)
+	request := blobMsg(t, models.BlobRequest, models.Chunk{BlobID: testBlobID})
+	_, err = s.BlobChunk(ctx, owner.ID, request)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	item := vaultMsg(t, models.New, models.Envelope{ID: "file", VaultID: 1, Created: 1, Data: []byte("v1"), BlobID: testBlobID})
+	require.NoError(t, s.Save(ctx, recipient.ID, item))
+	_, err = s.BlobChunk(ctx, owner.ID, request)
+	require.NoError(t, err, "members of the vault read the blob")
+
+	// blob of the superseded version is deleted
+	const otherBlobID = "fedcba9876543210fedcba9876543210"
+	uploadBlob(t, s, owner.ID, otherBlobID)
+	item = vaultMsg(t, models.New, models.Envelope{ID: "file", VaultID: 1, Created: 2, Data: []byte("v2"), BlobID: otherBlobID})
+	require.NoError(t, s.Save(ctx, owner.ID, item))
+	assert.NotContains(t, st.blobs, testBlobID)
+	_, err = s.blobs.ReadChunk(testBlobID, 0, 5)
+	assert.Error(t, err, "blob file is deleted")
+	_, err = s.BlobChunk(ctx, recipient.ID, blobMsg(t, models.BlobRequest, models.Chunk{BlobID: otherBlobID}))
+	require.NoError(t, err)
+}
+
+func TestBlobInvalid(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, &memStorage{}, "old")
+
+	tests := []struct {
+		name  string
+		chunk models.Chunk
+	}{
+		{name: "path in id", chunk: models.Chunk{BlobID: "../../etc/passwd", Data: []byte("data")}},
+		{name: "negative index", chunk: models.Chunk{BlobID: testBlobID, Index: -1, Data: []byte("data")}},
+		{name: "too many chunks", chunk: models.Chunk{BlobID: testBlobID, Index: maxChunks, Data: []byte("data")}},
+		{name: "empty chunk", chunk: models.Chunk{BlobID: testBlobID}},
+		{name: "large chunk", chunk: models.Chunk{BlobID: testBlobID, Data: make([]byte, maxChunkSize+1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.SaveChunk(ctx, 1, blobMsg(t, models.BlobChunk, tt.chunk))
+			assert.ErrorIs(t, err, ErrInvalidMessage)
+		})
+	}
+
+	_, err := s.BlobChunk(ctx, 1, blobMsg(t, models.BlobRequest, models.Chunk{BlobID: testBlobID}))
+	assert.ErrorIs(t, err, ErrInvalidMessage, "unknown blob")
+}
@@ -11,149 +11,136 @@ import (
 	"github.com/SmoothWay/gophkeeper/pkg/models"
 )
 
+// Validate checks that message contains item sealed on the client.
+// Server does not have vault key, so item content is not inspected.
 func (s *Service) Validate(msg models.Message) (models.Message, error) {
 	const op = "servicekeeper.Validate"
 	log := s.log.With(
 		slog.String("op", op),
 	)
 
-	var kind struct{ Type string }
-	err := json.Unmarshal(msg.Value, &kind)
+	var env models.Envelope
+	err := json.Unmarshal(msg.Value, &env)
 	if err != nil {
 		log.Error(
-			"failed extract value from message",
-			slog.String(`msg should contains "Type" field`, string(msg.Value)),
+			`failed unmarshal message value into "Envelope" model`,
 			logger.Err(err),
 		)
 		return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
 	}
-	switch kind.Type {
-	case models.CredItem.String():
-		var cred models.Credentials
-		err := json.Unmarshal(msg.Value, &cred)
-		if err != nil {
-			log.Error(
-				`failed unmarshal message value into "Credentials" model`,
-				slog.String(`msg`, string(msg.Value)),
-				logger.Err(err),
-			)
-			return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
-		}
-	case models.TextItem.String():
-		var text models.Text
-		err = json.Unmarshal(msg.Value, &text)
-		if err != nil {
-			log.Error(
-				`failed unmarshal message value into "Text" model`,
-				slog.String(`msg`, string(msg.Value)),
-				logger.Err(err),
-			)
-			return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
-		}
-	case models.BinItem.String():
-		var bin models.Binary
-		err = json.Unmarshal(msg.Value, &bin)
-		if err != nil {
-			log.Error(
-				`failed unmarshal message value into "Binary" model`,
-				slog.String(`msg`, string(msg.Value)),
-				logger.Err(err),
-			)
-			return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
-		}
-	case models.CardItem.String():
-		var card models.Card
-		err = json.Unmarshal(msg.Value, &card)
-		if err != nil {
-			log.Error(
-				`failed unmarshal message value into "Card" model`,
-				slog.String(`msg`, string(msg.Value)),
-				logger.Err(err),
-			)
-			return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
-		}
-	default:
-		log.Error(
-			`failed unmarshal message into existing models`,
-			slog.String(`unknown message type`, kind.Type),
-		)
+
+	switch {
+	case env.ID == "":
+		log.Error("sealed item without id")
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	case len(env.Data) == 0:
+		log.Error("sealed item without data", slog.String("item id", env.ID))
 		return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
 	}
+
 	return models.Message{Type: models.Update, Value: msg.Value}, nil
 }
 
-func (s *Service) convertItemListToMessage(items []storage.Item) models.Message {
-	const op = "servicekeeper.ConvertItemListToMessage"
+// decryptItems decrypts data of items with the data key dek.
+// Items which cannot be decrypted are skipped.
+func (s *Service) decryptItems(items []storage.Item, dek []byte) [][]byte {
+	const op = "servicekeeper.decryptItems"
 	log := s.log.With(
 		slog.String("op", op),
 	)
 
 	values := make([][]byte, 0, len(items))
 	for _, item := range items {
-		decoded := encrypt.DecodeMsg(string(item.Data), s.key)
-		values = append(values, []byte(decoded))
+		decoded, err := s.decrypt(item.Data, dek)
+		if err != nil {
+			log.Error(
+				"failed decrypt item, item skipped",
+				logger.Err(err),
+			)
+			continue
+		}
+		values = append(values, decoded)
 	}
+
+	return values
+}
+
+func convertValuesToMessage(values [][]byte) models.Message {
 	msg, _ := json.Marshal(values)
-	log.Info(
-		"items converted",
-		slog.Int("number of added items into message", len(items)),
-	)
 
 	return models.Message{Type: models.Snapshot, Value: msg}
 }
 
-func (s *Service) convertMessageToItem(userID int64, msg models.Message) storage.Item {
-	const op = "servicekeeper.ConvertItemListToMessage"
+func (s *Service) convertMessageToItem(userID int64, dek []byte, msg models.Message) (storage.Item, error) {
+	const op = "servicekeeper.ConvertMessageToItem"
 	log := s.log.With(
 		slog.String("op", op),
 		slog.Int64("user_id", userID),
 	)
 
-	var item storage.Item
-	item.Data = []byte(encrypt.EncodeMsg(msg.Value, s.key))
-	item.UserID = userID
-
-	var kind struct{ Type string }
-	_ = json.Unmarshal(msg.Value, &kind)
+	var env models.Envelope
+	_ = json.Unmarshal(msg.Value, &env)
 
-	switch kind.Type {
-	case models.CredItem.String():
-		var cred models.Credentials
-		_ = json.Unmarshal(msg.Value, &cred)
-
-		item.Kind = encrypt.EncodeMsg([]byte(models.CredItem.String()), s.key)
-		item.Key = encrypt.EncodeMsg([]byte(cred.Login), s.key)
-		item.CreatedAt = cred.Created
+	item, err := sealItem(dek, models.SealedItem.String(), env.ID, msg.Value)
+	if err != nil {
+		return storage.Item{}, fmt.Errorf("%s: %w", op, err)
+	}
+	item.UserID = userID
+	item.VaultID = env.VaultID
+	item.BlobID = env.BlobID
+	item.CreatedAt = env.Created
 
-	case models.TextItem.String():
-		var text models.Text
-		_ = json.Unmarshal(msg.Value, &text)
+	log.Info(
+		"message converted",
+		slog.String("item id", env.ID),
+	)
 
-		item.Kind = encrypt.EncodeMsg([]byte(models.TextItem.String()), s.key)
-		item.Key = encrypt.EncodeMsg([]byte(text.Key), s.key)
-		item.CreatedAt = text.Created
+	return item, nil
+}
 
-	case models.BinItem.String():
-		var bin models.Binary
-		_ = json.Unmarshal(msg.Value, &bin)
+// sealItem encrypts item type, key and data with the user data key dek
+// and computes blind index of the item.
+func sealItem(dek []byte, kind string, key string, data []byte) (storage.Item, error) {
+	var item storage.Item
+	var err error
 
-		item.Kind = encrypt.EncodeMsg([]byte(models.BinItem.String()), s.key)
-		item.Key = encrypt.EncodeMsg([]byte(bin.Key), s.key)
-		item.CreatedAt = bin.Created
+	if item.Kind, err = encrypt.Encrypt(dek, userKeyID, []byte(kind)); err != nil {
+		return storage.Item{}, err
+	}
+	if item.Key, err = encrypt.Encrypt(dek, userKeyID, []byte(key)); err != nil {
+		return storage.Item{}, err
+	}
+	if item.Data, err = encrypt.Encrypt(dek, userKeyID, data); err != nil {
+		return storage.Item{}, err
+	}
+	item.Lookup = blindIndex(dek, kind, key)
+	item.KeyID = userKeyID
 
-	case models.CardItem.String():
-		var card models.Card
-		_ = json.Unmarshal(msg.Value, &card)
+	return item, nil
+}
 
-		item.Kind = encrypt.EncodeMsg([]byte(models.CardItem.String()), s.key)
-		item.Key = encrypt.EncodeMsg([]byte(card.Number), s.key)
-		item.CreatedAt = card.Created
+// decrypt decrypts stored item field with the user data key dek.
+// Rows written before user keys were introduced are encrypted with the server key
+// or stored in legacy hex format.
+func (s *Service) decrypt(data []byte, dek []byte) ([]byte, error) {
+	if encrypt.IsLegacy(data) {
+		decoded, err := encrypt.DecodeMsg(string(data), s.secret)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(decoded), nil
 	}
 
-	log.Info(
-		"message converted",
-		slog.String("msg value", string(msg.Value)),
-	)
+	env, err := encrypt.ParseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	if env.KeyID != userKeyID {
+		return s.keys.Decrypt(data)
+	}
+	if dek == nil {
+		return nil, storage.ErrUserKeyNotFound
+	}
 
-	return item
+	return env.Open(dek)
 }
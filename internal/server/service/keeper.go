@@ -2,11 +2,14 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/SmoothWay/gophkeeper/internal/server/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/encrypt"
 	"github.com/SmoothWay/gophkeeper/pkg/logger"
 	"github.com/SmoothWay/gophkeeper/pkg/models"
 )
@@ -15,28 +18,72 @@ var (
 	ErrInvalidMessage = errors.New("invalid message")
 	ErrMakeSnapshot   = errors.New("get snapshot error")
 	ErrInternal       = errors.New("internal error")
+	ErrUnknownUser    = errors.New("user has not registered public key")
+	ErrForbidden      = errors.New("permission denied")
 )
 
 //go:generate mockgen -source=keeper.go -destination=../storage/mocks/mock.go
 type Storager interface {
 	Snapshot(ctx context.Context, userID int64) ([]storage.Item, error)
-	Save(ctx context.Context, item storage.Item) error
+	Save(ctx context.Context, item storage.Item) ([]string, error)
+	StaleItems(ctx context.Context, keyID string, afterID int64, limit int) ([]storage.Item, error)
+	UpdateItem(ctx context.Context, item storage.Item, updated storage.Item) error
+	UserKey(ctx context.Context, userID int64) (storage.UserKey, error)
+	SaveUserKey(ctx context.Context, key storage.UserKey) (storage.UserKey, error)
+	DeleteUserKey(ctx context.Context, userID int64) error
+	StaleUserKeys(ctx context.Context, kekID string, afterUserID int64, limit int) ([]storage.UserKey, error)
+	UpdateUserKey(ctx context.Context, key storage.UserKey, wrapped []byte, kekID string) error
+	SavePublicKey(ctx context.Context, key storage.PublicKey) error
+	PublicKey(ctx context.Context, email string) (storage.PublicKey, error)
+	SaveShare(ctx context.Context, share storage.Share) error
+	DeleteShare(ctx context.Context, ownerID int64, recipientID int64, itemID string) error
+	Shares(ctx context.Context, recipientID int64) ([]storage.Share, error)
+	VaultSnapshot(ctx context.Context, vaultID int64) ([]storage.Item, error)
+	CreateVault(ctx context.Context, vault storage.Vault, owner storage.VaultMember) (int64, error)
+	Membership(ctx context.Context, vaultID int64, userID int64) (storage.Membership, error)
+	Memberships(ctx context.Context, userID int64) ([]storage.Membership, error)
+	Members(ctx context.Context, vaultID int64) ([]int64, error)
+	SaveMember(ctx context.Context, member storage.VaultMember) error
+	DeleteMember(ctx context.Context, vaultID int64, userID int64) error
+	CreateBlob(ctx context.Context, id string, userID int64) (storage.Blob, error)
+	Blob(ctx context.Context, id string) (storage.Blob, error)
+	CanReadBlob(ctx context.Context, id string, userID int64) (bool, error)
+	CompleteBlob(ctx context.Context, blob storage.Blob) error
+	DeleteUserData(ctx context.Context, userID int64, deletedAt time.Time, seq int64) ([]string, error)
+	LastDeletion(ctx context.Context) (time.Time, int64, error)
 }
 
+// BlobStorager keeps encrypted content of files uploaded in chunks.
+type BlobStorager interface {
+	WriteChunk(id string, index int, data []byte) error
+	Received(id string) (int, error)
+	Assemble(id string, chunks int) (int64, int64, error)
+	ReadChunk(id string, offset int64, length int64) ([]byte, error)
+	Delete(id string) error
+}
+
+// Service encrypts items of every user with the user data key.
+// Data keys are stored wrapped by the server key encryption key from keys.
 type Service struct {
 	log     *slog.Logger
 	storage Storager
-	key     string
+	blobs   BlobStorager
+	keys    *encrypt.Keyring
+	// secret is used to read rows stored in legacy format.
+	secret string
 }
 
-func New(log *slog.Logger, s Storager, key string) *Service {
+func New(log *slog.Logger, s Storager, blobs BlobStorager, keys *encrypt.Keyring, secret string) *Service {
 	return &Service{
 		log:     log,
 		storage: s,
-		key:     key,
+		blobs:   blobs,
+		keys:    keys,
+		secret:  secret,
 	}
 }
 
+// Snapshot returns actual items of the user and of all team vaults of the user.
 func (s *Service) Snapshot(ctx context.Context, userID int64) (models.Message, error) {
 	const op = "servicekeeper.Snapshot"
 	log := s.log.With(
@@ -53,7 +100,49 @@ func (s *Service) Snapshot(ctx context.Context, userID int64) (models.Message, e
 		return models.Message{}, fmt.Errorf("%s: %w", op, ErrMakeSnapshot)
 	}
 
-	return s.convertItemListToMessage(res), nil
+	dek, err := s.userKey(ctx, userID, false)
+	if err != nil && !errors.Is(err, storage.ErrUserKeyNotFound) {
+		log.Error(
+			"query user key error",
+			logger.Err(err),
+		)
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrMakeSnapshot)
+	}
+	values := s.decryptItems(res, dek)
+
+	memberships, err := s.storage.Memberships(ctx, userID)
+	if err != nil {
+		log.Error(
+			"query team vaults error",
+			logger.Err(err),
+		)
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrMakeSnapshot)
+	}
+	for _, m := range memberships {
+		items, err := s.storage.VaultSnapshot(ctx, m.VaultID)
+		if err != nil {
+			log.Error(
+				"query team vault snapshot error",
+				slog.Int64("vault_id", m.VaultID),
+				logger.Err(err),
+			)
+			return models.Message{}, fmt.Errorf("%s: %w", op, ErrMakeSnapshot)
+		}
+
+		// items of the team vault are encrypted with data key of the vault owner
+		dek, err := s.userKey(ctx, m.OwnerID, false)
+		if err != nil && !errors.Is(err, storage.ErrUserKeyNotFound) {
+			log.Error(
+				"query vault owner key error",
+				slog.Int64("vault_id", m.VaultID),
+				logger.Err(err),
+			)
+			return models.Message{}, fmt.Errorf("%s: %w", op, ErrMakeSnapshot)
+		}
+		values = append(values, s.decryptItems(items, dek)...)
+	}
+
+	return convertValuesToMessage(values), nil
 }
 
 func (s *Service) Save(ctx context.Context, userID int64, msg models.Message) error {
@@ -63,16 +152,60 @@ func (s *Service) Save(ctx context.Context, userID int64, msg models.Message) er
 		slog.Int64("user_id", userID),
 	)
 
-	item := s.convertMessageToItem(userID, msg)
-	if err := s.storage.Save(ctx, item); err != nil {
+	// items of the team vault are stored as items of the vault owner
+	ownerID := userID
+	var env models.Envelope
+	_ = json.Unmarshal(msg.Value, &env)
+	if env.VaultID != 0 {
+		m, err := s.storage.Membership(ctx, env.VaultID, userID)
+		if err != nil {
+			log.Error(
+				"query team vault error",
+				slog.Int64("vault_id", env.VaultID),
+				logger.Err(err),
+			)
+			return ErrForbidden
+		}
+		ownerID = m.OwnerID
+	}
+	if env.BlobID != "" {
+		// item may reference only content, which is uploaded completely and is available to the user
+		if err := s.checkItemBlob(ctx, env.BlobID, userID); err != nil {
+			log.Error("item references invalid blob", slog.String("blob_id", env.BlobID), logger.Err(err))
+			if errors.Is(err, ErrInvalidMessage) || errors.Is(err, ErrForbidden) {
+				return err
+			}
+			return ErrInternal
+		}
+	}
+
+	dek, err := s.userKey(ctx, ownerID, true)
+	if err != nil {
+		log.Error(
+			"query user key error",
+			logger.Err(err),
+		)
+		return ErrInternal
+	}
+
+	item, err := s.convertMessageToItem(ownerID, dek, msg)
+	if err != nil {
+		log.Error(
+			"encrypting new item error",
+			logger.Err(err),
+		)
+		return ErrInternal
+	}
+
+	blobs, err := s.storage.Save(ctx, item)
+	if err != nil {
 		log.Error(
 			"saving new item error",
-			slog.String("item type", item.Kind),
-			slog.String("item key", item.Key),
 			logger.Err(err),
 		)
 		return ErrInternal
 	}
+	s.deleteBlobs(log, blobs)
 
 	return nil
 }
@@ -0,0 +1,540 @@
+package service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/blob"
+	"github.com/SmoothWay/gophkeeper/internal/server/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/encrypt"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+const testSecret = "test-secret"
+
+type memStorage struct {
+	items      []storage.Item
+	keys       map[int64]storage.UserKey
+	publicKeys map[string]storage.PublicKey
+	shares     []storage.Share
+	vaults     []storage.Vault
+	members    []storage.VaultMember
+	blobs      map[string]storage.Blob
+	deleted    map[int64]models.DeletedAccount
+}
+
+func (m *memStorage) CreateBlob(_ context.Context, id string, userID int64) (storage.Blob, error) {
+	if m.blobs == nil {
+		m.blobs = make(map[string]storage.Blob)
+	}
+	if _, ok := m.blobs[id]; !ok {
+		m.blobs[id] = storage.Blob{ID: id, UserID: userID}
+	}
+	return m.blobs[id], nil
+}
+
+func (m *memStorage) Blob(_ context.Context, id string) (storage.Blob, error) {
+	b, ok := m.blobs[id]
+	if !ok {
+		return storage.Blob{}, storage.ErrBlobNotFound
+	}
+	return b, nil
+}
+
+func (m *memStorage) CanReadBlob(_ context.Context, id string, userID int64) (bool, error) {
+	if m.blobs[id].UserID == userID {
+		return true, nil
+	}
+	for _, item := range m.items {
+		if item.BlobID == id && item.VaultID != 0 && slices.ContainsFunc(m.members, func(member storage.VaultMember) bool {
+			return member.VaultID == item.VaultID && member.UserID == userID
+		}) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memStorage) CompleteBlob(_ context.Context, b storage.Blob) error {
+	m.blobs[b.ID] = b
+	return nil
+}
+
+func (m *memStorage) VaultSnapshot(_ context.Context, vaultID int64) ([]storage.Item, error) {
+	latest := make(map[string]storage.Item)
+	for _, item := range m.items {
+		if item.VaultID == vaultID {
+			latest[string(item.Lookup)] = item
+		}
+	}
+	var res []storage.Item
+	for _, item := range latest {
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+func (m *memStorage) CreateVault(_ context.Context, vault storage.Vault, owner storage.VaultMember) (int64, error) {
+	vault.ID = int64(len(m.vaults) + 1)
+	m.vaults = append(m.vaults, vault)
+	owner.VaultID = vault.ID
+	m.members = append(m.members, owner)
+	return vault.ID, nil
+}
+
+func (m *memStorage) Membership(_ context.Context, vaultID int64, userID int64) (storage.Membership, error) {
+	for _, member := range m.members {
+		if member.VaultID == vaultID && member.UserID == userID {
+			vault := m.vaults[vaultID-1]
+			return storage.Membership{VaultID: vaultID, Name: vault.Name, OwnerID: vault.OwnerID, Role: member.Role, WrappedKey: member.WrappedKey}, nil
+		}
+	}
+	return storage.Membership{}, storage.ErrNotMember
+}
+
+func (m *memStorage) Memberships(ctx context.Context, userID int64) ([]storage.Membership, error) {
+	var res []storage.Membership
+	for _, member := range m.members {
+		if member.UserID == userID {
+			membership, _ := m.Membership(ctx, member.VaultID, userID)
+			res = append(res, membership)
+		}
+	}
+	return res, nil
+}
+
+func (m *memStorage) Members(_ context.Context, vaultID int64) ([]int64, error) {
+	var res []int64
+	for _, member := range m.members {
+		if member.VaultID == vaultID {
+			res = append(res, member.UserID)
+		}
+	}
+	return res, nil
+}
+
+func (m *memStorage) SaveMember(ctx context.Context, member storage.VaultMember) error {
+	_ = m.DeleteMember(ctx, member.VaultID, member.UserID)
+	m.members = append(m.members, member)
+	return nil
+}
+
+func (m *memStorage) DeleteMember(_ context.Context, vaultID int64, userID int64) error {
+	var res []storage.VaultMember
+	for _, member := range m.members {
+		if member.VaultID != vaultID || member.UserID != userID {
+			res = append(res, member)
+		}
+	}
+	m.members = res
+	return nil
+}
+
+func (m *memStorage) SavePublicKey(_ context.Context, key storage.PublicKey) error {
+	if m.publicKeys == nil {
+		m.publicKeys = make(map[string]storage.PublicKey)
+	}
+	m.publicKeys[key.Email] = key
+	return nil
+}
+
+func (m *memStorage) PublicKey(_ context.Context, email string) (storage.PublicKey, error) {
+	key, ok := m.publicKeys[email]
+	if !ok {
+		return storage.PublicKey{}, storage.ErrPublicKeyNotFound
+	}
+	return key, nil
+}
+
+func (m *memStorage) SaveShare(ctx context.Context, share storage.Share) error {
+	_ = m.DeleteShare(ctx, share.OwnerID, share.RecipientID, share.ItemID)
+	m.shares = append(m.shares, share)
+	return nil
+}
+
+func (m *memStorage) DeleteShare(_ context.Context, ownerID int64, recipientID int64, itemID string) error {
+	var res []storage.Share
+	for _, share := range m.shares {
+		if share.OwnerID != ownerID || share.RecipientID != recipientID || share.ItemID != itemID {
+			res = append(res, share)
+		}
+	}
+	m.shares = res
+	return nil
+}
+
+func (m *memStorage) Shares(_ context.Context, recipientID int64) ([]storage.Share, error) {
+	var res []storage.Share
+	for _, share := range m.shares {
+		if share.RecipientID == recipientID {
+			res = append(res, share)
+		}
+	}
+	return res, nil
+}
+
+func (m *memStorage) UserKey(_ context.Context, userID int64) (storage.UserKey, error) {
+	key, ok := m.keys[userID]
+	if !ok {
+		return storage.UserKey{}, storage.ErrUserKeyNotFound
+	}
+	return key, nil
+}
+
+func (m *memStorage) SaveUserKey(ctx context.Context, key storage.UserKey) (storage.UserKey, error) {
+	if m.keys == nil {
+		m.keys = make(map[int64]storage.UserKey)
+	}
+	if _, ok := m.keys[key.UserID]; !ok {
+		m.keys[key.UserID] = key
+	}
+	return m.UserKey(ctx, key.UserID)
+}
+
+func (m *memStorage) DeleteUserKey(_ context.Context, userID int64) error {
+	delete(m.keys, userID)
+	return nil
+}
+
+func (m *memStorage) StaleUserKeys(_ context.Context, kekID string, afterUserID int64, limit int) ([]storage.UserKey, error) {
+	var res []storage.UserKey
+	for userID := afterUserID + 1; len(res) < limit && userID <= int64(len(m.keys)); userID++ {
+		if key, ok := m.keys[userID]; ok && key.KEKID != kekID {
+			res = append(res, key)
+		}
+	}
+	return res, nil
+}
+
+func (m *memStorage) UpdateUserKey(_ context.Context, key storage.UserKey, wrapped []byte, kekID string) error {
+	if m.keys[key.UserID].KEKID == key.KEKID {
+		m.keys[key.UserID] = storage.UserKey{UserID: key.UserID, WrappedKey: wrapped, KEKID: kekID}
+	}
+	return nil
+}
+
+func (m *memStorage) StaleItems(_ context.Context, keyID string, afterID int64, limit int) ([]storage.Item, error) {
+	var res []storage.Item
+	for _, item := range m.items {
+		if (item.KeyID != keyID || item.Lookup == nil) && item.ID > afterID && len(res) < limit {
+			res = append(res, item)
+		}
+	}
+	return res, nil
+}
+
+func (m *memStorage) UpdateItem(_ context.Context, item storage.Item, updated storage.Item) error {
+	for i := range m.items {
+		if m.items[i].ID == item.ID && m.items[i].KeyID == item.KeyID {
+			m.items[i].Kind = updated.Kind
+			m.items[i].Key = updated.Key
+			m.items[i].Lookup = updated.Lookup
+			m.items[i].Data = updated.Data
+			m.items[i].KeyID = updated.KeyID
+		}
+	}
+	return nil
+}
+
+func (m *memStorage) Snapshot(_ context.Context, userID int64) ([]storage.Item, error) {
+	var res []storage.Item
+	for _, item := range m.items {
+		if item.UserID == userID && item.VaultID == 0 {
+			res = append(res, item)
+		}
+	}
+	return res, nil
+}
+
+func (m *memStorage) Save(_ context.Context, item storage.Item) ([]string, error) {
+	item.ID = int64(len(m.items) + 1)
+	m.items = append(m.items, item)
+
+	// the latest version of every item is actual
+	type group struct {
+		userID, vaultID int64
+		lookup          string
+	}
+	actual := make(map[group]storage.Item)
+	for _, it := range m.items {
+		g := group{it.UserID, it.VaultID, string(it.Lookup)}
+		if cur, ok := actual[g]; !ok || it.CreatedAt > cur.CreatedAt || it.CreatedAt == cur.CreatedAt && it.ID > cur.ID {
+			actual[g] = it
+		}
+	}
+	var blobs []string
+	for i, it := range m.items {
+		if it.BlobID == "" || it.UserID != item.UserID || it.VaultID != item.VaultID || string(it.Lookup) != string(item.Lookup) {
+			continue
+		}
+		referenced := false
+		for _, cur := range actual {
+			referenced = referenced || cur.BlobID == it.BlobID
+		}
+		if !referenced {
+			if _, ok := m.blobs[it.BlobID]; ok {
+				delete(m.blobs, it.BlobID)
+				blobs = append(blobs, it.BlobID)
+			}
+			m.items[i].BlobID = ""
+		}
+	}
+	return blobs, nil
+}
+
+func (m *memStorage) DeleteUserData(_ context.Context, userID int64, deletedAt time.Time, seq int64) ([]string,
+	error) {
+	owned := make(map[int64]bool)
+	for _, vault := range m.vaults {
+		owned[vault.ID] = vault.OwnerID == userID
+	}
+	m.items = slices.DeleteFunc(m.items, func(item storage.Item) bool {
+		return item.VaultID == 0 && item.UserID == userID || owned[item.VaultID]
+	})
+	m.members = slices.DeleteFunc(m.members, func(member storage.VaultMember) bool {
+		return member.UserID == userID || owned[member.VaultID]
+	})
+	m.shares = slices.DeleteFunc(m.shares, func(share storage.Share) bool {
+		return share.OwnerID == userID || share.RecipientID == userID
+	})
+	maps.DeleteFunc(m.publicKeys, func(_ string, key storage.PublicKey) bool { return key.UserID == userID })
+	delete(m.keys, userID)
+
+	var blobs []string
+	for id, b := range m.blobs {
+		if b.UserID == userID && !slices.ContainsFunc(m.items, func(item storage.Item) bool { return item.BlobID == id }) {
+			delete(m.blobs, id)
+			blobs = append(blobs, id)
+		}
+	}
+
+	if m.deleted == nil {
+		m.deleted = make(map[int64]models.DeletedAccount)
+	}
+	if _, ok := m.deleted[userID]; !ok {
+		m.deleted[userID] = models.DeletedAccount{UserID: userID, DeletedAt: deletedAt, Seq: seq}
+	}
+	return blobs, nil
+}
+
+func (m *memStorage) LastDeletion(_ context.Context) (time.Time, int64, error) {
+	var last time.Time
+	var seq int64
+	for _, account := range m.deleted {
+		if account.DeletedAt.After(last) {
+			last = account.DeletedAt
+		}
+		seq = max(seq, account.Seq)
+	}
+	return last, seq, nil
+}
+
+func newTestService(t *testing.T, st Storager, active string) *Service {
+	t.Helper()
+
+	keys, err := encrypt.NewKeyring(active, map[string][]byte{
+		"old": encrypt.DeriveKey("old secret"),
+		"new": encrypt.DeriveKey("new secret"),
+	})
+	require.NoError(t, err)
+
+	blobs, err := blob.New(t.TempDir())
+	require.NoError(t, err)
+
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), st, blobs, keys, testSecret)
+}
+
+// legacyItem returns item stored before blind index was introduced.
+func legacyItem(t *testing.T, id int64, userID int64, key string, data []byte) storage.Item {
+	t.Helper()
+
+	return storage.Item{
+		ID:     id,
+		UserID: userID,
+		Kind:   legacyHex(t, models.SealedItem.String()),
+		Key:    legacyHex(t, key),
+		Data:   data,
+	}
+}
+
+// legacyHex encrypts msg with testSecret in legacy hex format, which the server only decrypts now.
+// Nonce is derived from the secret, see encrypt.DecodeMsg.
+func legacyHex(t *testing.T, msg string) []byte {
+	t.Helper()
+
+	key := sha256.Sum256([]byte(testSecret))
+	block, err := aes.NewCipher(key[:])
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	return []byte(hex.EncodeToString(gcm.Seal(nil, key[len(key)-gcm.NonceSize():], []byte(msg), nil)))
+}
+
+func sealedMsg(t *testing.T, id string) models.Message {
+	t.Helper()
+
+	value, err := json.Marshal(models.Envelope{ID: id, Created: 1, Data: []byte("sealed " + id)})
+	require.NoError(t, err)
+	return models.Message{Type: models.New, Value: value}
+}
+
+func TestSaveSnapshot(t *testing.T) {
+	st := &memStorage{}
+	s := newTestService(t, st, "old")
+
+	msg := sealedMsg(t, "item-1")
+	require.NoError(t, s.Save(context.Background(), 1, msg))
+	require.Len(t, st.items, 1)
+	assert.Equal(t, userKeyID, st.items[0].KeyID)
+	assert.NotContains(t, string(st.items[0].Data), "item-1")
+	require.Contains(t, st.keys, int64(1))
+	assert.Equal(t, "old", st.keys[1].KEKID)
+
+	snapshot, err := s.Snapshot(context.Background(), 1)
+	require.NoError(t, err)
+
+	var values [][]byte
+	require.NoError(t, json.Unmarshal(snapshot.Value, &values))
+	require.Len(t, values, 1)
+	assert.JSONEq(t, string(msg.Value), string(values[0]))
+}
+
+func TestSnapshotLegacyRows(t *testing.T) {
+	st := &memStorage{items: []storage.Item{
+		legacyItem(t, 1, 1, "item-1", legacyHex(t, `{"type":"text"}`)),
+		legacyItem(t, 2, 1, "item-2", []byte("broken")),
+	}}
+	s := newTestService(t, st, "old")
+
+	snapshot, err := s.Snapshot(context.Background(), 1)
+	require.NoError(t, err)
+
+	var values [][]byte
+	require.NoError(t, json.Unmarshal(snapshot.Value, &values))
+	require.Len(t, values, 1)
+	assert.JSONEq(t, `{"type":"text"}`, string(values[0]))
+}
+
+func TestValidate(t *testing.T) {
+	s := newTestService(t, &memStorage{}, "old")
+
+	update, err := s.Validate(sealedMsg(t, "item-1"))
+	require.NoError(t, err)
+	assert.Equal(t, models.Update, update.Type)
+
+	_, err = s.Validate(models.Message{Type: models.New, Value: []byte(`{"type":"cred","login":"l"}`)})
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	_, err = s.Validate(models.Message{Type: models.New, Value: []byte(`{"id":"item-1"}`)})
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
+
+func TestShred(t *testing.T) {
+	st := &memStorage{}
+	s := newTestService(t, st, "old")
+
+	require.NoError(t, s.Save(context.Background(), 1, sealedMsg(t, "item-1")))
+	require.NoError(t, s.Save(context.Background(), 2, sealedMsg(t, "item-2")))
+	assert.NotEqual(t, st.keys[1].WrappedKey, st.keys[2].WrappedKey)
+
+	require.NoError(t, s.Shred(context.Background(), 1))
+
+	var values [][]byte
+	snapshot, err := s.Snapshot(context.Background(), 1)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(snapshot.Value, &values))
+	assert.Empty(t, values)
+
+	snapshot, err = s.Snapshot(context.Background(), 2)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(snapshot.Value, &values))
+	assert.Len(t, values, 1)
+}
+
+func TestReencrypt(t *testing.T) {
+	oldKeys, err := encrypt.NewKeyring("old", map[string][]byte{"old": encrypt.DeriveKey("old secret")})
+	require.NoError(t, err)
+	serverEncrypted, err := oldKeys.Encrypt([]byte(`{"id":"item-0"}`))
+	require.NoError(t, err)
+
+	st := &memStorage{items: []storage.Item{
+		legacyItem(t, 1, 1, "item-4", legacyHex(t, `{"type":"text"}`)),
+		legacyItem(t, 2, 1, "item-5", []byte("broken")),
+		legacyItem(t, 3, 2, "item-0", serverEncrypted),
+	}}
+	st.items[2].KeyID = "old"
+
+	before := newTestService(t, st, "old")
+	for _, id := range []string{"item-1", "item-2", "item-3"} {
+		require.NoError(t, before.Save(context.Background(), 1, sealedMsg(t, id)))
+	}
+
+	after := newTestService(t, st, "new")
+	n, err := after.Reencrypt(context.Background(), 2)
+	require.NoError(t, err)
+	// user key of the first user is rewrapped, key of the second user is created with the active key,
+	// legacy and server encrypted items are re-encrypted with user keys
+	assert.Equal(t, 3, n)
+
+	for _, key := range st.keys {
+		assert.Equal(t, "new", key.KEKID)
+	}
+	for i, item := range st.items {
+		if i == 1 {
+			assert.Equal(t, "", item.KeyID, "undecryptable item must be left as is")
+			continue
+		}
+		assert.Equal(t, userKeyID, item.KeyID)
+		env, err := encrypt.ParseEnvelope(item.Data)
+		require.NoError(t, err)
+		assert.Equal(t, userKeyID, env.KeyID)
+		assert.NotNil(t, item.Lookup)
+	}
+	dek, err := after.userKey(context.Background(), 1, false)
+	require.NoError(t, err)
+	assert.Equal(t, blindIndex(dek, models.SealedItem.String(), "item-4"), st.items[0].Lookup)
+
+	snapshot, err := after.Snapshot(context.Background(), 1)
+	require.NoError(t, err)
+	var values [][]byte
+	require.NoError(t, json.Unmarshal(snapshot.Value, &values))
+	assert.Len(t, values, 4)
+
+	snapshot, err = after.Snapshot(context.Background(), 2)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(snapshot.Value, &values))
+	assert.Len(t, values, 1)
+
+	n, err = after.Reencrypt(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestBlindIndex(t *testing.T) {
+	st := &memStorage{}
+	s := newTestService(t, st, "old")
+
+	require.NoError(t, s.Save(context.Background(), 1, sealedMsg(t, "item-1")))
+	require.NoError(t, s.Save(context.Background(), 1, sealedMsg(t, "item-1")))
+	require.NoError(t, s.Save(context.Background(), 2, sealedMsg(t, "item-1")))
+	require.Len(t, st.items, 3)
+
+	assert.Equal(t, st.items[0].Lookup, st.items[1].Lookup)
+	assert.NotEqual(t, st.items[0].Lookup, st.items[2].Lookup)
+	assert.NotEqual(t, st.items[0].Key, st.items[1].Key, "key must not be encrypted deterministically")
+	assert.NotContains(t, string(st.items[0].Key), "item-1")
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+)
+
+// Reencrypt rewraps user data keys wrapped by retired server keys with the active key,
+// then re-encrypts with user data keys all items encrypted with server keys,
+// stored in legacy format or without blind index. Rows are processed in batches of batchSize.
+// It returns number of updated rows.
+func (s *Service) Reencrypt(ctx context.Context, batchSize int) (int, error) {
+	const op = "servicekeeper.Reencrypt"
+
+	keys, err := s.rewrapUserKeys(ctx, batchSize)
+	if err != nil {
+		return keys, fmt.Errorf("%s: %w", op, err)
+	}
+
+	items, err := s.reencryptItems(ctx, batchSize)
+	if err != nil {
+		return keys + items, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys + items, nil
+}
+
+func (s *Service) rewrapUserKeys(ctx context.Context, batchSize int) (int, error) {
+	const op = "servicekeeper.rewrapUserKeys"
+	active := s.keys.ActiveID()
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("active key", active),
+	)
+
+	var afterUserID int64
+	var total int
+	for {
+		keys, err := s.storage.StaleUserKeys(ctx, active, afterUserID, batchSize)
+		if err != nil {
+			return total, err
+		}
+		if len(keys) == 0 {
+			return total, nil
+		}
+
+		for _, key := range keys {
+			afterUserID = key.UserID
+
+			dek, err := s.keys.Decrypt(key.WrappedKey)
+			if err != nil {
+				log.Error(
+					"failed unwrap user key, key skipped",
+					slog.Int64("user_id", key.UserID),
+					slog.String("key id", key.KEKID),
+					logger.Err(err),
+				)
+				continue
+			}
+
+			wrapped, err := s.keys.Encrypt(dek)
+			if err != nil {
+				return total, err
+			}
+
+			if err := s.storage.UpdateUserKey(ctx, key, wrapped, active); err != nil {
+				return total, err
+			}
+			total++
+		}
+	}
+}
+
+func (s *Service) reencryptItems(ctx context.Context, batchSize int) (int, error) {
+	const op = "servicekeeper.reencryptItems"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	var afterID int64
+	var total int
+	deks := make(map[int64][]byte)
+	for {
+		items, err := s.storage.StaleItems(ctx, userKeyID, afterID, batchSize)
+		if err != nil {
+			return total, err
+		}
+		if len(items) == 0 {
+			return total, nil
+		}
+
+		for _, item := range items {
+			afterID = item.ID
+
+			dek, ok := deks[item.UserID]
+			if !ok {
+				dek, err = s.userKey(ctx, item.UserID, true)
+				if err != nil {
+					return total, err
+				}
+				deks[item.UserID] = dek
+			}
+
+			updated, err := s.resealItem(item, dek)
+			if err != nil {
+				log.Error(
+					"failed decrypt item, item skipped",
+					slog.Int64("item id", item.ID),
+					slog.String("key id", item.KeyID),
+					logger.Err(err),
+				)
+				continue
+			}
+
+			if err := s.storage.UpdateItem(ctx, item, updated); err != nil {
+				return total, err
+			}
+			total++
+		}
+	}
+}
+
+// resealItem decrypts all item fields and encrypts them again with the user data key.
+func (s *Service) resealItem(item storage.Item, dek []byte) (storage.Item, error) {
+	kind, err := s.decrypt(item.Kind, dek)
+	if err != nil {
+		return storage.Item{}, err
+	}
+	key, err := s.decrypt(item.Key, dek)
+	if err != nil {
+		return storage.Item{}, err
+	}
+	data, err := s.decrypt(item.Data, dek)
+	if err != nil {
+		return storage.Item{}, err
+	}
+
+	return sealItem(dek, string(kind), string(key), data)
+}
+
+// RunReencryption calls Reencrypt every interval until ctx is done.
+func (s *Service) RunReencryption(ctx context.Context, interval time.Duration, batchSize int) {
+	const op = "servicekeeper.RunReencryption"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.Reencrypt(ctx, batchSize)
+		if err != nil {
+			log.Error("re-encryption error", logger.Err(err))
+		}
+		if n > 0 {
+			log.Info("rows re-encrypted", slog.Int("number of rows", n))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/storage"
+)
+
+func TestReencryptPostgres(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	db, err := storage.New(databaseURL, time.Second*5)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(ctx, "TRUNCATE store, user_keys, public_keys, shares, vaults, vault_members, blobs")
+	require.NoError(t, err)
+	defer func() {
+		_, _ = db.Exec(ctx, "TRUNCATE store, user_keys, public_keys, shares, vaults, vault_members, blobs")
+	}()
+
+	keeper := storage.NewKeeperPostgres(db, time.Second*5)
+
+	before := newTestService(t, keeper, "old")
+	for _, id := range []string{"item-1", "item-2", "item-3"} {
+		require.NoError(t, before.Save(ctx, 1, sealedMsg(t, id)))
+	}
+
+	// server restarted with the new active key, old key is still known
+	after := newTestService(t, keeper, "new")
+
+	snapshot, err := after.Snapshot(ctx, 1)
+	require.NoError(t, err)
+	var values [][]byte
+	require.NoError(t, json.Unmarshal(snapshot.Value, &values))
+	assert.Len(t, values, 3)
+
+	n, err := after.Reencrypt(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "only user key is rewrapped, items are encrypted with user key")
+
+	staleKeys, err := keeper.StaleUserKeys(ctx, "new", 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, staleKeys)
+
+	stale, err := keeper.StaleItems(ctx, userKeyID, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+
+	snapshot, err = after.Snapshot(ctx, 1)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(snapshot.Value, &values))
+	assert.Len(t, values, 3)
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// publicKeyLen is length of X25519 public key.
+const publicKeyLen = 32
+
+// RegisterKey saves public key of the user, so other users can share items with the user.
+// Email is taken from the token of the user, not from the message.
+func (s *Service) RegisterKey(ctx context.Context, user models.User, msg models.Message) error {
+	const op = "servicekeeper.RegisterKey"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", user.ID),
+	)
+
+	var key models.UserKey
+	if err := json.Unmarshal(msg.Value, &key); err != nil || len(key.Key) != publicKeyLen || user.Email == "" {
+		log.Error("invalid public key message")
+		return fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+
+	err := s.storage.SavePublicKey(ctx, storage.PublicKey{UserID: user.ID, Email: user.Email, Key: key.Key})
+	if err != nil {
+		log.Error(
+			"saving public key error",
+			logger.Err(err),
+		)
+		return ErrInternal
+	}
+
+	return nil
+}
+
+// PublicKey returns reply to the key request with public key of the requested user.
+// Key in reply is empty, if the user has not registered public key.
+func (s *Service) PublicKey(ctx context.Context, msg models.Message) (models.Message, error) {
+	const op = "servicekeeper.PublicKey"
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	var req models.UserKey
+	if err := json.Unmarshal(msg.Value, &req); err != nil || req.Email == "" {
+		log.Error("invalid key request message")
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+
+	key, err := s.storage.PublicKey(ctx, req.Email)
+	if err != nil && !errors.Is(err, storage.ErrPublicKeyNotFound) {
+		log.Error(
+			"query public key error",
+			logger.Err(err),
+		)
+		return models.Message{}, ErrInternal
+	}
+
+	value, _ := json.Marshal(models.UserKey{Email: req.Email, Key: key.Key})
+
+	return models.Message{Type: models.PublicKey, Value: value}, nil
+}
+
+// Share saves item sealed by the user to the public key of the recipient.
+// It returns id of the recipient and message with the item for the recipient.
+func (s *Service) Share(ctx context.Context, user models.User, msg models.Message) (int64, models.Message, error) {
+	const op = "servicekeeper.Share"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", user.ID),
+	)
+
+	env, err := parseShare(msg)
+	if err != nil || len(env.Data) == 0 {
+		log.Error("invalid share message")
+		return 0, models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+
+	recipient, err := s.recipient(ctx, env.To)
+	if err != nil {
+		log.Error(
+			"query recipient error",
+			logger.Err(err),
+		)
+		return 0, models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.storage.SaveShare(ctx, storage.Share{
+		ItemID:      env.ID,
+		OwnerID:     user.ID,
+		OwnerEmail:  user.Email,
+		RecipientID: recipient.UserID,
+		Data:        env.Data,
+		CreatedAt:   env.Created,
+	})
+	if err != nil {
+		log.Error(
+			"saving share error",
+			logger.Err(err),
+		)
+		return 0, models.Message{}, ErrInternal
+	}
+
+	env.From = user.Email
+	value, _ := json.Marshal(env)
+
+	return recipient.UserID, models.Message{Type: models.Shared, Value: value}, nil
+}
+
+// Revoke deletes item shared by the user with the recipient.
+// It returns id of the recipient and message for the recipient to remove the item.
+func (s *Service) Revoke(ctx context.Context, user models.User, msg models.Message) (int64, models.Message, error) {
+	const op = "servicekeeper.Revoke"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", user.ID),
+	)
+
+	env, err := parseShare(msg)
+	if err != nil {
+		log.Error("invalid revoke message")
+		return 0, models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+
+	recipient, err := s.recipient(ctx, env.To)
+	if err != nil {
+		log.Error(
+			"query recipient error",
+			logger.Err(err),
+		)
+		return 0, models.Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.storage.DeleteShare(ctx, user.ID, recipient.UserID, env.ID); err != nil {
+		log.Error(
+			"deleting share error",
+			logger.Err(err),
+		)
+		return 0, models.Message{}, ErrInternal
+	}
+
+	value, _ := json.Marshal(models.ShareEnvelope{ID: env.ID, From: user.Email, To: env.To})
+
+	return recipient.UserID, models.Message{Type: models.Unshared, Value: value}, nil
+}
+
+// Shares returns all items shared with the user.
+func (s *Service) Shares(ctx context.Context, userID int64) (models.Message, error) {
+	const op = "servicekeeper.Shares"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	shares, err := s.storage.Shares(ctx, userID)
+	if err != nil {
+		log.Error(
+			"query shares error",
+			logger.Err(err),
+		)
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrMakeSnapshot)
+	}
+
+	values := make([]models.ShareEnvelope, 0, len(shares))
+	for _, share := range shares {
+		values = append(values, models.ShareEnvelope{
+			ID:      share.ItemID,
+			From:    share.OwnerEmail,
+			Created: share.CreatedAt,
+			Data:    share.Data,
+		})
+	}
+	value, _ := json.Marshal(values)
+
+	return models.Message{Type: models.SharedSnapshot, Value: value}, nil
+}
+
+func parseShare(msg models.Message) (models.ShareEnvelope, error) {
+	var env models.ShareEnvelope
+	if err := json.Unmarshal(msg.Value, &env); err != nil {
+		return models.ShareEnvelope{}, err
+	}
+	if env.ID == "" || env.To == "" {
+		return models.ShareEnvelope{}, ErrInvalidMessage
+	}
+
+	return env, nil
+}
+
+// recipient returns public key of the user with email.
+// Items can be shared only with users who have registered public key.
+func (s *Service) recipient(ctx context.Context, email string) (storage.PublicKey, error) {
+	key, err := s.storage.PublicKey(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrPublicKeyNotFound) {
+			return storage.PublicKey{}, ErrUnknownUser
+		}
+		return storage.PublicKey{}, ErrInternal
+	}
+
+	return key, nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+var (
+	owner     = models.User{ID: 1, Email: "owner@example.com"}
+	recipient = models.User{ID: 2, Email: "recipient@example.com"}
+)
+
+func keyMsg(t *testing.T, key models.UserKey) models.Message {
+	t.Helper()
+
+	value, err := json.Marshal(key)
+	require.NoError(t, err)
+	return models.Message{Type: models.PublicKey, Value: value}
+}
+
+func shareMsg(t *testing.T, msgType models.MessageType, env models.ShareEnvelope) models.Message {
+	t.Helper()
+
+	value, err := json.Marshal(env)
+	require.NoError(t, err)
+	return models.Message{Type: msgType, Value: value}
+}
+
+func TestPublicKey(t *testing.T) {
+	s := newTestService(t, &memStorage{}, "old")
+	ctx := context.Background()
+	publicKey := bytes.Repeat([]byte{1}, publicKeyLen)
+
+	err := s.RegisterKey(ctx, recipient, keyMsg(t, models.UserKey{Key: []byte("short")}))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	// email of the key is taken from the token
+	require.NoError(t, s.RegisterKey(ctx, recipient, keyMsg(t, models.UserKey{Email: owner.Email, Key: publicKey})))
+
+	reply, err := s.PublicKey(ctx, models.Message{Type: models.KeyRequest, Value: []byte(`{"email":"recipient@example.com"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, models.PublicKey, reply.Type)
+	var key models.UserKey
+	require.NoError(t, json.Unmarshal(reply.Value, &key))
+	assert.Equal(t, models.UserKey{Email: recipient.Email, Key: publicKey}, key)
+
+	reply, err = s.PublicKey(ctx, models.Message{Type: models.KeyRequest, Value: []byte(`{"email":"owner@example.com"}`)})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(reply.Value, &key))
+	assert.Empty(t, key.Key)
+}
+
+func TestShareRevoke(t *testing.T) {
+	st := &memStorage{}
+	s := newTestService(t, st, "old")
+	ctx := context.Background()
+
+	_, _, err := s.Share(ctx, owner, shareMsg(t, models.Share, models.ShareEnvelope{ID: "item-1", To: recipient.Email, Data: []byte("sealed")}))
+	assert.ErrorIs(t, err, ErrUnknownUser)
+
+	require.NoError(t, s.RegisterKey(ctx, recipient, keyMsg(t, models.UserKey{Key: bytes.Repeat([]byte{1}, publicKeyLen)})))
+
+	_, _, err = s.Share(ctx, owner, shareMsg(t, models.Share, models.ShareEnvelope{ID: "item-1", To: recipient.Email}))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	// sender cannot impersonate another user
+	recipientID, update, err := s.Share(ctx, owner, shareMsg(t, models.Share,
+		models.ShareEnvelope{ID: "item-1", From: "admin@example.com", To: recipient.Email, Created: 1, Data: []byte("sealed")}))
+	require.NoError(t, err)
+	assert.Equal(t, recipient.ID, recipientID)
+	assert.Equal(t, models.Shared, update.Type)
+	var env models.ShareEnvelope
+	require.NoError(t, json.Unmarshal(update.Value, &env))
+	assert.Equal(t, owner.Email, env.From)
+	assert.Equal(t, []byte("sealed"), env.Data)
+
+	_, _, err = s.Share(ctx, owner, shareMsg(t, models.Share, models.ShareEnvelope{ID: "item-2", To: recipient.Email, Created: 1, Data: []byte("sealed")}))
+	require.NoError(t, err)
+
+	snapshot, err := s.Shares(ctx, recipient.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.SharedSnapshot, snapshot.Type)
+	var shares []models.ShareEnvelope
+	require.NoError(t, json.Unmarshal(snapshot.Value, &shares))
+	assert.Len(t, shares, 2)
+
+	recipientID, update, err = s.Revoke(ctx, owner, shareMsg(t, models.Revoke, models.ShareEnvelope{ID: "item-1", To: recipient.Email}))
+	require.NoError(t, err)
+	assert.Equal(t, recipient.ID, recipientID)
+	assert.Equal(t, models.Unshared, update.Type)
+	require.NoError(t, json.Unmarshal(update.Value, &env))
+	assert.Equal(t, models.ShareEnvelope{ID: "item-1", From: owner.Email, To: recipient.Email}, env)
+
+	snapshot, err = s.Shares(ctx, recipient.ID)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(snapshot.Value, &shares))
+	require.Len(t, shares, 1)
+	assert.Equal(t, "item-2", shares[0].ID)
+
+	snapshot, err = s.Shares(ctx, owner.ID)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(snapshot.Value, &shares))
+	assert.Empty(t, shares)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// DeleteUser deletes all data of the account deleted at the auth server.
+// Data key of the user is deleted with the items, so copies of the items left in backups can not be decrypted.
+// Deletion of the same user again does nothing.
+func (s *Service) DeleteUser(ctx context.Context, account models.DeletedAccount) error {
+	const op = "servicekeeper.DeleteUser"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", account.UserID),
+	)
+
+	blobs, err := s.storage.DeleteUserData(ctx, account.UserID, account.DeletedAt, account.Seq)
+	if err != nil {
+		log.Error("deleting user data error", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, ErrInternal)
+	}
+	s.deleteBlobs(log, blobs)
+
+	log.Info("user data deleted", slog.Int("blobs", len(blobs)))
+	return nil
+}
+
+// LastDeletion returns time and position of the latest deletion at the auth server, which data is deleted.
+func (s *Service) LastDeletion(ctx context.Context) (models.DeletedAccount, error) {
+	const op = "servicekeeper.LastDeletion"
+
+	at, seq, err := s.storage.LastDeletion(ctx)
+	if err != nil {
+		return models.DeletedAccount{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return models.DeletedAccount{DeletedAt: at, Seq: seq}, nil
+}
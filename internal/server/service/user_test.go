@@ -0,0 +1,65 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+func TestDeleteUser(t *testing.T) {
+	st := &memStorage{}
+	s := newTestService(t, st, "old")
+	ctx := context.Background()
+	for _, user := range []models.User{owner, recipient} {
+		require.NoError(t, s.RegisterKey(ctx, user, keyMsg(t, models.UserKey{Key: bytes.Repeat([]byte{1}, publicKeyLen)})))
+	}
+
+	require.NoError(t, s.SaveChunk(ctx, owner.ID, blobMsg(t, models.BlobChunk, models.Chunk{BlobID: testBlobID, Data: []byte("chunk")})))
+	_, err := s.CommitBlob(ctx, owner.ID, blobMsg(t, models.BlobCommit, models.BlobInfo{BlobID: testBlobID, Chunks: 1}))
+	require.NoError(t, err)
+	item := blobMsg(t, models.New, models.Envelope{ID: "file", Created: 1, Data: []byte("sealed"), BlobID: testBlobID})
+	require.NoError(t, s.Save(ctx, owner.ID, item))
+	_, err = s.CreateVault(ctx, owner, vaultMsg(t, models.VaultCreate, models.TeamVault{Name: "team", Key: []byte("owner key")}))
+	require.NoError(t, err)
+	_, err = s.SetMember(ctx, owner, vaultMsg(t, models.VaultMember, models.Member{VaultID: 1, Email: recipient.Email, Role: models.RoleEditor, Key: []byte("editor key")}))
+	require.NoError(t, err)
+	require.NoError(t, s.Save(ctx, owner.ID, vaultItemMsg(t, 1, "team-item")))
+	require.NoError(t, s.Save(ctx, recipient.ID, sealedMsg(t, "item")))
+
+	last, err := s.LastDeletion(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, last)
+
+	deletedAt := time.Unix(1_700_000_000, 0)
+	require.NoError(t, s.DeleteUser(ctx, models.DeletedAccount{UserID: owner.ID, DeletedAt: deletedAt, Seq: 7}))
+
+	var values [][]byte
+	snapshot, err := s.Snapshot(ctx, owner.ID)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(snapshot.Value, &values))
+	assert.Empty(t, values)
+	assert.NotContains(t, st.keys, owner.ID, "data key is shredded")
+	_, err = s.blobs.ReadChunk(testBlobID, 0, 5)
+	assert.Error(t, err, "blob file is deleted")
+
+	vaults, err := s.Vaults(ctx, recipient.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[]`, string(vaults.Value), "team vault of the deleted owner is deleted")
+	snapshot, err = s.Snapshot(ctx, recipient.ID)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(snapshot.Value, &values))
+	assert.Len(t, values, 1, "items of other users are kept")
+
+	require.NoError(t, s.DeleteUser(ctx, models.DeletedAccount{UserID: owner.ID, DeletedAt: deletedAt.Add(time.Hour),
+		Seq: 8}))
+	last, err = s.LastDeletion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeletedAccount{DeletedAt: deletedAt, Seq: 7}, last)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/encrypt"
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+)
+
+// userKeyID marks items encrypted with the data key of the user, server keys can not have this id.
+const userKeyID = encrypt.DataKeyID
+
+const userKeySize = 32
+
+// userKey returns unwrapped data key of the user.
+// If create is true, new random key is generated for the user without key.
+func (s *Service) userKey(ctx context.Context, userID int64, create bool) ([]byte, error) {
+	key, err := s.storage.UserKey(ctx, userID)
+	if errors.Is(err, storage.ErrUserKeyNotFound) && create {
+		key, err = s.newUserKey(ctx, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.keys.Decrypt(key.WrappedKey)
+}
+
+func (s *Service) newUserKey(ctx context.Context, userID int64) (storage.UserKey, error) {
+	dek := make([]byte, userKeySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return storage.UserKey{}, fmt.Errorf("generate user key: %w", err)
+	}
+
+	wrapped, err := s.keys.Encrypt(dek)
+	if err != nil {
+		return storage.UserKey{}, err
+	}
+
+	return s.storage.SaveUserKey(ctx, storage.UserKey{
+		UserID:     userID,
+		WrappedKey: wrapped,
+		KEKID:      s.keys.ActiveID(),
+	})
+}
+
+// blindIndex returns keyed hash of item type and key. It is the same for all
+// versions of the item, so storage can find the latest one, but unlike
+// deterministic encryption it cannot be reversed or compared between users.
+func blindIndex(dek []byte, kind string, key string) []byte {
+	derive := hmac.New(sha256.New, dek)
+	derive.Write([]byte("gophkeeper blind index"))
+
+	mac := hmac.New(sha256.New, derive.Sum(nil))
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(key))
+
+	return mac.Sum(nil)
+}
+
+// Shred deletes data key of the user, so none of the user items can be decrypted anymore.
+func (s *Service) Shred(ctx context.Context, userID int64) error {
+	const op = "servicekeeper.Shred"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	if err := s.storage.DeleteUserKey(ctx, userID); err != nil {
+		log.Error("deleting user key error", logger.Err(err))
+		return ErrInternal
+	}
+
+	log.Info("user key deleted")
+	return nil
+}
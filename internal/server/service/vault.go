@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/SmoothWay/gophkeeper/internal/server/storage"
+	"github.com/SmoothWay/gophkeeper/pkg/logger"
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+// CreateVault creates team vault owned by the user.
+// It returns message with all team vaults of the user.
+func (s *Service) CreateVault(ctx context.Context, user models.User, msg models.Message) (models.Message, error) {
+	const op = "servicekeeper.CreateVault"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", user.ID),
+	)
+
+	var vault models.TeamVault
+	if err := json.Unmarshal(msg.Value, &vault); err != nil || vault.Name == "" || len(vault.Key) == 0 {
+		log.Error("invalid create vault message")
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+
+	id, err := s.storage.CreateVault(ctx,
+		storage.Vault{Name: vault.Name, OwnerID: user.ID},
+		storage.VaultMember{UserID: user.ID, Role: models.RoleOwner, WrappedKey: vault.Key},
+	)
+	if err != nil {
+		log.Error(
+			"creating vault error",
+			logger.Err(err),
+		)
+		return models.Message{}, ErrInternal
+	}
+	log.Info("vault created", slog.Int64("vault_id", id))
+
+	return s.Vaults(ctx, user.ID)
+}
+
+// SetMember adds user to the team vault, changes role of the member or removes the member.
+// Only owner of the vault can manage its members. It returns id of the member.
+func (s *Service) SetMember(ctx context.Context, user models.User, msg models.Message) (int64, error) {
+	const op = "servicekeeper.SetMember"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", user.ID),
+	)
+
+	var member models.Member
+	if err := json.Unmarshal(msg.Value, &member); err != nil || member.VaultID == 0 || member.Email == "" {
+		log.Error("invalid vault member message")
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+
+	if err := s.authorize(ctx, member.VaultID, user.ID, models.RoleOwner); err != nil {
+		log.Error(
+			"user cannot manage vault members",
+			slog.Int64("vault_id", member.VaultID),
+			logger.Err(err),
+		)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	recipient, err := s.recipient(ctx, member.Email)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if recipient.UserID == user.ID {
+		// vault must always have an owner
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+
+	switch member.Role {
+	case "":
+		err = s.storage.DeleteMember(ctx, member.VaultID, recipient.UserID)
+	case models.RoleEditor, models.RoleViewer:
+		if len(member.Key) == 0 {
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+		}
+		err = s.storage.SaveMember(ctx, storage.VaultMember{
+			VaultID:    member.VaultID,
+			UserID:     recipient.UserID,
+			Role:       member.Role,
+			WrappedKey: member.Key,
+		})
+	default:
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidMessage)
+	}
+	if err != nil {
+		log.Error(
+			"saving vault member error",
+			logger.Err(err),
+		)
+		return 0, ErrInternal
+	}
+
+	return recipient.UserID, nil
+}
+
+// Vaults returns message with all team vaults of the user.
+func (s *Service) Vaults(ctx context.Context, userID int64) (models.Message, error) {
+	const op = "servicekeeper.Vaults"
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+	)
+
+	memberships, err := s.storage.Memberships(ctx, userID)
+	if err != nil {
+		log.Error(
+			"query team vaults error",
+			logger.Err(err),
+		)
+		return models.Message{}, fmt.Errorf("%s: %w", op, ErrInternal)
+	}
+
+	vaults := make([]models.TeamVault, 0, len(memberships))
+	for _, m := range memberships {
+		vaults = append(vaults, models.TeamVault{ID: m.VaultID, Name: m.Name, Role: m.Role, Key: m.WrappedKey})
+	}
+	value, _ := json.Marshal(vaults)
+
+	return models.Message{Type: models.Vaults, Value: value}, nil
+}
+
+// Authorize checks that the user can save item from msg.
+// It returns ids of the users who receive the update: the user itself
+// for personal items and all members for items of the team vault.
+func (s *Service) Authorize(ctx context.Context, userID int64, msg models.Message) ([]int64, error) {
+	const op = "servicekeeper.Authorize"
+
+	var env models.Envelope
+	_ = json.Unmarshal(msg.Value, &env)
+	if env.VaultID == 0 {
+		return []int64{userID}, nil
+	}
+
+	if err := s.authorize(ctx, env.VaultID, userID, models.RoleOwner, models.RoleEditor); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	members, err := s.storage.Members(ctx, env.VaultID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrInternal)
+	}
+
+	return members, nil
+}
+
+// authorize checks that the user is member of the vault with one of roles.
+func (s *Service) authorize(ctx context.Context, vaultID int64, userID int64, roles ...string) error {
+	m, err := s.storage.Membership(ctx, vaultID, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotMember) {
+			return ErrForbidden
+		}
+		return ErrInternal
+	}
+
+	for _, role := range roles {
+		if m.Role == role {
+			return nil
+		}
+	}
+
+	return ErrForbidden
+}
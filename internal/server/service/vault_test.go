@@ -0,0 +1,107 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+func vaultMsg(t *testing.T, msgType models.MessageType, value any) models.Message {
+	t.Helper()
+
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+	return models.Message{Type: msgType, Value: data}
+}
+
+func vaultItemMsg(t *testing.T, vaultID int64, id string) models.Message {
+	t.Helper()
+
+	return vaultMsg(t, models.New, models.Envelope{ID: id, VaultID: vaultID, Created: 1, Data: []byte("sealed " + id)})
+}
+
+func TestVaults(t *testing.T) {
+	st := &memStorage{}
+	s := newTestService(t, st, "old")
+	ctx := context.Background()
+	viewer := models.User{ID: 3, Email: "viewer@example.com"}
+	for _, user := range []models.User{owner, recipient, viewer} {
+		require.NoError(t, s.RegisterKey(ctx, user, keyMsg(t, models.UserKey{Key: bytes.Repeat([]byte{1}, publicKeyLen)})))
+	}
+
+	_, err := s.CreateVault(ctx, owner, vaultMsg(t, models.VaultCreate, models.TeamVault{Name: "team"}))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	vaults, err := s.CreateVault(ctx, owner, vaultMsg(t, models.VaultCreate, models.TeamVault{Name: "team", Key: []byte("owner key")}))
+	require.NoError(t, err)
+	assert.Equal(t, models.Vaults, vaults.Type)
+	assert.JSONEq(t, `[{"id":1,"name":"team","role":"owner","key":"b3duZXIga2V5"}]`, string(vaults.Value))
+
+	// only owner manages members
+	_, err = s.SetMember(ctx, recipient, vaultMsg(t, models.VaultMember, models.Member{VaultID: 1, Email: recipient.Email, Role: models.RoleEditor, Key: []byte("key")}))
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = s.SetMember(ctx, owner, vaultMsg(t, models.VaultMember, models.Member{VaultID: 1, Email: owner.Email, Role: models.RoleViewer, Key: []byte("key")}))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	_, err = s.SetMember(ctx, owner, vaultMsg(t, models.VaultMember, models.Member{VaultID: 1, Email: recipient.Email, Role: models.RoleOwner, Key: []byte("key")}))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	memberID, err := s.SetMember(ctx, owner, vaultMsg(t, models.VaultMember, models.Member{VaultID: 1, Email: recipient.Email, Role: models.RoleEditor, Key: []byte("editor key")}))
+	require.NoError(t, err)
+	assert.Equal(t, recipient.ID, memberID)
+	_, err = s.SetMember(ctx, owner, vaultMsg(t, models.VaultMember, models.Member{VaultID: 1, Email: viewer.Email, Role: models.RoleViewer, Key: []byte("viewer key")}))
+	require.NoError(t, err)
+
+	// viewer cannot write, editor's update is sent to all members
+	_, err = s.Authorize(ctx, viewer.ID, vaultItemMsg(t, 1, "item-1"))
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = s.Authorize(ctx, 10, vaultItemMsg(t, 1, "item-1"))
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	members, err := s.Authorize(ctx, recipient.ID, vaultItemMsg(t, 1, "item-1"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{owner.ID, recipient.ID, viewer.ID}, members)
+
+	personal, err := s.Authorize(ctx, recipient.ID, sealedMsg(t, "item-2"))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{recipient.ID}, personal)
+
+	require.NoError(t, s.Save(ctx, recipient.ID, vaultItemMsg(t, 1, "item-1")))
+	require.NoError(t, s.Save(ctx, recipient.ID, sealedMsg(t, "item-2")))
+	require.Len(t, st.items, 2)
+	assert.Equal(t, owner.ID, st.items[0].UserID, "vault items are encrypted with the key of the owner")
+	assert.Equal(t, int64(1), st.items[0].VaultID)
+
+	for _, user := range []models.User{owner, viewer} {
+		snapshot, err := s.Snapshot(ctx, user.ID)
+		require.NoError(t, err)
+		var values [][]byte
+		require.NoError(t, json.Unmarshal(snapshot.Value, &values))
+		require.Len(t, values, 1)
+		assert.JSONEq(t, string(vaultItemMsg(t, 1, "item-1").Value), string(values[0]))
+	}
+
+	snapshot, err := s.Snapshot(ctx, recipient.ID)
+	require.NoError(t, err)
+	var values [][]byte
+	require.NoError(t, json.Unmarshal(snapshot.Value, &values))
+	assert.Len(t, values, 2)
+
+	// removed member loses access
+	_, err = s.SetMember(ctx, owner, vaultMsg(t, models.VaultMember, models.Member{VaultID: 1, Email: recipient.Email}))
+	require.NoError(t, err)
+	_, err = s.Authorize(ctx, recipient.ID, vaultItemMsg(t, 1, "item-1"))
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.ErrorIs(t, s.Save(ctx, recipient.ID, vaultItemMsg(t, 1, "item-1")), ErrForbidden)
+
+	vaults, err = s.Vaults(ctx, recipient.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[]`, string(vaults.Value))
+}
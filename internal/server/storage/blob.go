@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateBlob registers upload of the blob by the user, if the blob is not registered yet.
+// It returns the registered blob, which may belong to another user.
+func (s *Keeper) CreateBlob(ctx context.Context, id string, userID int64) (Blob, error) {
+	const op = "storage.server.CreateBlob"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx, "INSERT INTO blobs (id, user_id) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING", id, userID)
+	if err != nil {
+		return Blob{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.Blob(ctx, id)
+}
+
+// Blob returns blob by id. It returns ErrBlobNotFound, if upload of the blob was not started.
+func (s *Keeper) Blob(ctx context.Context, id string) (Blob, error) {
+	const op = "storage.server.Blob"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx,
+		"SELECT (id, user_id, chunks, chunk_size, size, complete) FROM blobs WHERE id = $1", id)
+	if err != nil {
+		return Blob{}, fmt.Errorf("%s: %w", op, err)
+	}
+	res, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[Blob])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Blob{}, fmt.Errorf("%s: %w", op, ErrBlobNotFound)
+		}
+		return Blob{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return res, nil
+}
+
+// CanReadBlob reports whether the user uploaded the blob or is member of the team vault, item of which references it.
+func (s *Keeper) CanReadBlob(ctx context.Context, id string, userID int64) (bool, error) {
+	const op = "storage.server.CanReadBlob"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var ok bool
+	err := s.db.QueryRow(newCtx, `SELECT EXISTS (SELECT 1 FROM blobs WHERE id = $1 AND user_id = $2)
+		OR EXISTS (SELECT 1 FROM store s JOIN vault_members m ON m.vault_id = s.vault_id
+			WHERE s.blob_id = $1 AND m.user_id = $2)`, id, userID).Scan(&ok)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return ok, nil
+}
+
+// CompleteBlob marks the blob as uploaded and saves its layout.
+func (s *Keeper) CompleteBlob(ctx context.Context, blob Blob) error {
+	const op = "storage.server.CompleteBlob"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx,
+		"UPDATE blobs SET chunks = $1, chunk_size = $2, size = $3, complete = TRUE WHERE id = $4",
+		blob.Chunks, blob.ChunkSize, blob.Size, blob.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -22,16 +22,42 @@ func NewKeeperPostgres(db *pgxpool.Pool, timeout time.Duration) *Keeper {
 }
 
 // Snapshot collect all actual user data with unique keys.
+// Items of team vaults are not included, see VaultSnapshot.
 func (s *Keeper) Snapshot(ctx context.Context, userID int64) ([]Item, error) {
 	const op = "storage.server.Snapshot"
 
 	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
+	// rows written before blind index was introduced are grouped by deterministic type and key
 	rows, err := s.db.Query(newCtx,
-		`select (t1.user_id, t1.type, t1.key, s.data, t1.created_at_client) from
-		(select user_id, type, key, max(created_at_client) as created_at_client from store where user_id=$1 group by user_id, type, key) as t1
-		left join store as s ON t1.type = s.type AND t1.key = s.key AND t1.created_at_client=s.created_at_client`, userID)
+		`select distinct on (coalesce(lookup, type || key))
+		(id, user_id, coalesce(vault_id, 0), type, key, lookup, data, coalesce(key_id, ''), created_at_client, coalesce(blob_id, '')) from store
+		where user_id = $1 and vault_id is null
+		order by coalesce(lookup, type || key), created_at_client desc, id desc`, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	res, err := pgx.CollectRows(rows, pgx.RowTo[Item])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return res, nil
+}
+
+// VaultSnapshot collect all actual items of the team vault with unique keys.
+func (s *Keeper) VaultSnapshot(ctx context.Context, vaultID int64) ([]Item, error) {
+	const op = "storage.server.VaultSnapshot"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx,
+		`select distinct on (lookup)
+		(id, user_id, vault_id, type, key, lookup, data, coalesce(key_id, ''), created_at_client, coalesce(blob_id, '')) from store
+		where vault_id = $1
+		order by lookup, created_at_client desc, id desc`, vaultID)
 
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -44,15 +70,91 @@ func (s *Keeper) Snapshot(ctx context.Context, userID int64) ([]Item, error) {
 }
 
 // Save method insert into database user encrypted message.
-func (s *Keeper) Save(ctx context.Context, item Item) error {
+// Blobs referenced only by versions of the item superseded by the new one are deleted,
+// it returns ids of the deleted blobs, which files should be deleted.
+func (s *Keeper) Save(ctx context.Context, item Item) ([]string, error) {
 	const op = "storage.server.Save"
 
 	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
 	defer cancel()
 
-	_, err := s.db.Exec(newCtx, "INSERT INTO store (user_id, type, key, data, created_at_client) values ($1, $2, $3, $4, $5);",
-		item.UserID, item.Kind, item.Key, item.Data, item.CreatedAt)
+	tx, err := s.db.Begin(newCtx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(newCtx) }()
+
+	_, err = tx.Exec(newCtx, `INSERT INTO store (user_id, vault_id, type, key, lookup, data, key_id, created_at_client, blob_id)
+		values ($1, nullif($2::bigint, 0), $3, $4, $5, $6, $7, $8, nullif($9, ''));`,
+		item.UserID, item.VaultID, item.Kind, item.Key, item.Lookup, item.Data, item.KeyID, item.CreatedAt, item.BlobID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// blob of the item version is garbage, when no actual version of any item references it,
+	// rows without blind index are grouped differently and are treated as actual
+	rows, err := tx.Query(newCtx, `SELECT DISTINCT v.blob_id FROM store v
+		WHERE v.user_id = $1 AND v.vault_id IS NOT DISTINCT FROM nullif($2::bigint, 0) AND v.lookup = $3
+		AND v.blob_id IS NOT NULL AND NOT EXISTS (
+			SELECT 1 FROM store s WHERE s.blob_id = v.blob_id AND (s.lookup IS NULL OR NOT EXISTS (
+				SELECT 1 FROM store n WHERE n.user_id = s.user_id AND n.vault_id IS NOT DISTINCT FROM s.vault_id
+				AND n.lookup = s.lookup AND (n.created_at_client, n.id) > (s.created_at_client, s.id))))`,
+		item.UserID, item.VaultID, item.Lookup)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	blobs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, id := range blobs {
+		if _, err := tx.Exec(newCtx, "UPDATE store SET blob_id = NULL WHERE blob_id = $1", id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if _, err := tx.Exec(newCtx, "DELETE FROM blobs WHERE id = $1", id); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(newCtx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return blobs, nil
+}
+
+// StaleItems returns items which are not encrypted with the key keyID or have no blind index.
+// Items are ordered by id, afterID is used to continue from the previous batch.
+func (s *Keeper) StaleItems(ctx context.Context, keyID string, afterID int64, limit int) ([]Item, error) {
+	const op = "storage.server.StaleItems"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx,
+		`select (id, user_id, coalesce(vault_id, 0), type, key, lookup, data, coalesce(key_id, ''), created_at_client, coalesce(blob_id, '')) from store
+		where (key_id is distinct from $1 or lookup is null) and id > $2 order by id limit $3`, keyID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	res, err := pgx.CollectRows(rows, pgx.RowTo[Item])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return res, nil
+}
+
+// UpdateItem replaces encrypted fields of the item with fields of updated.
+// Update is skipped, if item was re-encrypted concurrently and its key id is no longer item.KeyID.
+func (s *Keeper) UpdateItem(ctx context.Context, item Item, updated Item) error {
+	const op = "storage.server.UpdateItem"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
 
+	_, err := s.db.Exec(newCtx,
+		`UPDATE store SET type = $1, key = $2, lookup = $3, data = $4, key_id = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6 AND coalesce(key_id, '') = $7`,
+		updated.Kind, updated.Key, updated.Lookup, updated.Data, updated.KeyID, item.ID, item.KeyID)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
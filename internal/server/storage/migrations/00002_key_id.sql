@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE store ADD COLUMN IF NOT EXISTS key_id VARCHAR;
+
+CREATE INDEX IF NOT EXISTS store_key_id_idx ON store (key_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS store_key_id_idx;
+ALTER TABLE store DROP COLUMN IF EXISTS key_id;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS user_keys
+(
+    user_id      BIGINT PRIMARY KEY,
+    wrapped_key  BYTEA NOT NULL,
+    kek_id       VARCHAR NOT NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE user_keys;
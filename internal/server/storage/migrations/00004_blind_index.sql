@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE store ALTER COLUMN type TYPE BYTEA USING convert_to(type, 'UTF8');
+ALTER TABLE store ALTER COLUMN key TYPE BYTEA USING convert_to(key, 'UTF8');
+ALTER TABLE store ADD COLUMN IF NOT EXISTS lookup BYTEA;
+
+CREATE INDEX IF NOT EXISTS store_user_lookup_idx ON store (user_id, lookup);
+
+-- +goose Down
+DROP INDEX IF EXISTS store_user_lookup_idx;
+ALTER TABLE store DROP COLUMN IF EXISTS lookup;
+ALTER TABLE store ALTER COLUMN key TYPE VARCHAR USING convert_from(key, 'UTF8');
+ALTER TABLE store ALTER COLUMN type TYPE VARCHAR USING convert_from(type, 'UTF8');
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS public_keys
+(
+    user_id      BIGINT PRIMARY KEY,
+    email        VARCHAR NOT NULL UNIQUE,
+    public_key   BYTEA NOT NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS shares
+(
+    id                 BIGSERIAL PRIMARY KEY,
+    item_id            VARCHAR NOT NULL,
+    owner_id           BIGINT NOT NULL,
+    owner_email        VARCHAR NOT NULL,
+    recipient_id       BIGINT NOT NULL,
+    data               BYTEA NOT NULL,
+    created_at_client  BIGINT,
+    created_at         TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner_id, recipient_id, item_id)
+);
+
+CREATE INDEX IF NOT EXISTS shares_recipient_idx ON shares (recipient_id);
+
+-- +goose Down
+DROP TABLE shares;
+DROP TABLE public_keys;
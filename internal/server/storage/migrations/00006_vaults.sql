@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS vaults
+(
+    id           BIGSERIAL PRIMARY KEY,
+    name         VARCHAR NOT NULL,
+    owner_id     BIGINT NOT NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS vault_members
+(
+    vault_id     BIGINT NOT NULL REFERENCES vaults (id) ON DELETE CASCADE,
+    user_id      BIGINT NOT NULL,
+    role         VARCHAR NOT NULL,
+    wrapped_key  BYTEA NOT NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (vault_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS vault_members_user_idx ON vault_members (user_id);
+
+ALTER TABLE store ADD COLUMN IF NOT EXISTS vault_id BIGINT;
+CREATE INDEX IF NOT EXISTS store_vault_lookup_idx ON store (vault_id, lookup);
+
+-- +goose Down
+DROP INDEX IF EXISTS store_vault_lookup_idx;
+ALTER TABLE store DROP COLUMN IF EXISTS vault_id;
+DROP TABLE vault_members;
+DROP TABLE vaults;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS blobs
+(
+    id           VARCHAR PRIMARY KEY,
+    user_id      BIGINT NOT NULL,
+    chunks       INTEGER NOT NULL DEFAULT 0,
+    chunk_size   BIGINT NOT NULL DEFAULT 0,
+    size         BIGINT NOT NULL DEFAULT 0,
+    complete     BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE store ADD COLUMN IF NOT EXISTS blob_id VARCHAR REFERENCES blobs (id);
+
+-- +goose Down
+ALTER TABLE store DROP COLUMN IF EXISTS blob_id;
+DROP TABLE blobs;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- deleted_users keeps accounts, which data is deleted, polling of deleted accounts resumes after the latest one
+CREATE TABLE IF NOT EXISTS deleted_users
+(
+    user_id      BIGINT PRIMARY KEY,
+    deleted_at   TIMESTAMPTZ NOT NULL,
+    purged_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE deleted_users;
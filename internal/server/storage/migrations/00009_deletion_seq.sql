@@ -0,0 +1,6 @@
+-- +goose Up
+-- seq is position of the deletion at the auth server, polling of deleted accounts resumes after the latest one
+ALTER TABLE deleted_users ADD COLUMN seq BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE deleted_users DROP COLUMN seq;
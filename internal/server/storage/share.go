@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SavePublicKey inserts or replaces public key of the user.
+func (s *Keeper) SavePublicKey(ctx context.Context, key PublicKey) error {
+	const op = "storage.server.SavePublicKey"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx,
+		`INSERT INTO public_keys (user_id, email, public_key) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET email = $2, public_key = $3, updated_at = CURRENT_TIMESTAMP`,
+		key.UserID, key.Email, key.Key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// PublicKey returns public key of the user with email.
+// It returns ErrPublicKeyNotFound, if user has not registered the key.
+func (s *Keeper) PublicKey(ctx context.Context, email string) (PublicKey, error) {
+	const op = "storage.server.PublicKey"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx, "SELECT (user_id, email, public_key) FROM public_keys WHERE email = $1", email)
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	key, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[PublicKey])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return PublicKey{}, fmt.Errorf("%s: %w", op, ErrPublicKeyNotFound)
+		}
+		return PublicKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return key, nil
+}
+
+// SaveShare inserts share or replaces data of the item shared earlier with the same recipient.
+func (s *Keeper) SaveShare(ctx context.Context, share Share) error {
+	const op = "storage.server.SaveShare"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx,
+		`INSERT INTO shares (item_id, owner_id, owner_email, recipient_id, data, created_at_client) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (owner_id, recipient_id, item_id)
+		DO UPDATE SET owner_email = $3, data = $5, created_at_client = $6, updated_at = CURRENT_TIMESTAMP`,
+		share.ItemID, share.OwnerID, share.OwnerEmail, share.RecipientID, share.Data, share.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeleteShare deletes item of the owner shared with the recipient.
+func (s *Keeper) DeleteShare(ctx context.Context, ownerID int64, recipientID int64, itemID string) error {
+	const op = "storage.server.DeleteShare"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx,
+		"DELETE FROM shares WHERE owner_id = $1 AND recipient_id = $2 AND item_id = $3",
+		ownerID, recipientID, itemID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Shares returns all items shared with the recipient.
+func (s *Keeper) Shares(ctx context.Context, recipientID int64) ([]Share, error) {
+	const op = "storage.server.Shares"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx,
+		`SELECT (item_id, owner_id, owner_email, recipient_id, data, coalesce(created_at_client, 0)) FROM shares
+		WHERE recipient_id = $1 ORDER BY id`, recipientID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	res, err := pgx.CollectRows(rows, pgx.RowTo[Share])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return res, nil
+}
@@ -10,7 +10,11 @@ import (
 )
 
 var (
-	ErrInternal = errors.New("internal error")
+	ErrInternal          = errors.New("internal error")
+	ErrUserKeyNotFound   = errors.New("user key not found")
+	ErrPublicKeyNotFound = errors.New("public key not found")
+	ErrNotMember         = errors.New("user is not member of the vault")
+	ErrBlobNotFound      = errors.New("blob not found")
 )
 
 func New(databaseURL string, timeout time.Duration) (*pgxpool.Pool, error) {
@@ -22,7 +26,7 @@ func New(databaseURL string, timeout time.Duration) (*pgxpool.Pool, error) {
 		return nil, fmt.Errorf("init database error: %w", err)
 	}
 
-	if err = migrate(pool, 1); err != nil {
+	if err = migrate(pool, 9); err != nil {
 		return nil, fmt.Errorf("migrate database error: %w", err)
 	}
 
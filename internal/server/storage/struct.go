@@ -1,9 +1,79 @@
 package storage
 
+// Item is encrypted user item.
+// Kind and Key are encrypted with random nonce, Lookup is blind index of them,
+// which is the same for all versions of the item.
+// Items of the team vault have VaultID and UserID of the vault owner.
+// BlobID references content of the item uploaded separately in chunks.
 type Item struct {
+	ID        int64
 	UserID    int64
-	Kind      string
-	Key       string
+	VaultID   int64
+	Kind      []byte
+	Key       []byte
+	Lookup    []byte
 	Data      []byte
+	KeyID     string
 	CreatedAt int64
+	BlobID    string
+}
+
+// UserKey is data key of the user wrapped by the server key encryption key.
+type UserKey struct {
+	UserID     int64
+	WrappedKey []byte
+	KEKID      string
+}
+
+// PublicKey is X25519 public key registered by the user.
+type PublicKey struct {
+	UserID int64
+	Email  string
+	Key    []byte
+}
+
+// Share is an item sealed by the owner to the public key of the recipient.
+type Share struct {
+	ItemID      string
+	OwnerID     int64
+	OwnerEmail  string
+	RecipientID int64
+	Data        []byte
+	CreatedAt   int64
+}
+
+// Vault is a team vault, items of which are available to all vault members.
+type Vault struct {
+	ID      int64
+	Name    string
+	OwnerID int64
+}
+
+// VaultMember is member of the team vault. WrappedKey is the vault key
+// sealed on the client to the public key of the member.
+type VaultMember struct {
+	VaultID    int64
+	UserID     int64
+	Role       string
+	WrappedKey []byte
+}
+
+// Membership is a team vault with role and key of the member.
+type Membership struct {
+	VaultID    int64
+	Name       string
+	OwnerID    int64
+	Role       string
+	WrappedKey []byte
+}
+
+// Blob is encrypted content uploaded by the user in chunks.
+// All chunks except the last one have ChunkSize bytes.
+type Blob struct {
+	ID        string
+	UserID    int64
+	Chunks    int
+	ChunkSize int64
+	Size      int64
+	Complete  bool
 }
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DeleteUserData deletes items, keys, shares and team vaults owned by the deleted user
+// and records the deletion with its position seq at the auth server, deletion of the same user again does nothing.
+// Items of team vaults of other owners are kept. It returns ids of the deleted blobs, which files should be deleted.
+func (s *Keeper) DeleteUserData(ctx context.Context, userID int64, deletedAt time.Time, seq int64) ([]string, error) {
+	const op = "storage.server.DeleteUserData"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.Begin(newCtx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(newCtx) }()
+
+	queries := []string{
+		"DELETE FROM store WHERE user_id = $1 AND vault_id IS NULL",
+		"DELETE FROM store WHERE vault_id IN (SELECT id FROM vaults WHERE owner_id = $1)",
+		// members of the owned vaults are deleted by cascade
+		"DELETE FROM vaults WHERE owner_id = $1",
+		"DELETE FROM vault_members WHERE user_id = $1",
+		"DELETE FROM shares WHERE owner_id = $1 OR recipient_id = $1",
+		"DELETE FROM public_keys WHERE user_id = $1",
+		// items encrypted with the data key can not be decrypted without it
+		"DELETE FROM user_keys WHERE user_id = $1",
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(newCtx, query, userID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	rows, err := tx.Query(newCtx, `DELETE FROM blobs b WHERE b.user_id = $1
+		AND NOT EXISTS (SELECT 1 FROM store s WHERE s.blob_id = b.id) RETURNING b.id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	var blobs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		blobs = append(blobs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.Exec(newCtx, `INSERT INTO deleted_users (user_id, deleted_at, seq) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO NOTHING`, userID, deletedAt, seq)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(newCtx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return blobs, nil
+}
+
+// LastDeletion returns time and position of the latest deletion of the user data,
+// they are zero, if no users were deleted.
+func (s *Keeper) LastDeletion(ctx context.Context) (time.Time, int64, error) {
+	const op = "storage.server.LastDeletion"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var last *time.Time
+	var seq int64
+	err := s.db.QueryRow(newCtx, "SELECT max(deleted_at), coalesce(max(seq), 0) FROM deleted_users").Scan(&last, &seq)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if last == nil {
+		return time.Time{}, seq, nil
+	}
+	return *last, seq, nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// UserKey returns wrapped data key of the user.
+// It returns ErrUserKeyNotFound, if user has no key yet or the key was deleted.
+func (s *Keeper) UserKey(ctx context.Context, userID int64) (UserKey, error) {
+	const op = "storage.server.UserKey"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx, "SELECT (user_id, wrapped_key, kek_id) FROM user_keys WHERE user_id = $1", userID)
+	if err != nil {
+		return UserKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	key, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[UserKey])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return UserKey{}, fmt.Errorf("%s: %w", op, ErrUserKeyNotFound)
+		}
+		return UserKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return key, nil
+}
+
+// SaveUserKey inserts wrapped data key of the user, if user has no key yet.
+// It returns the key stored in database, which is not the same as key
+// if it was inserted concurrently by another connection of the user.
+func (s *Keeper) SaveUserKey(ctx context.Context, key UserKey) (UserKey, error) {
+	const op = "storage.server.SaveUserKey"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx,
+		"INSERT INTO user_keys (user_id, wrapped_key, kek_id) VALUES ($1, $2, $3) ON CONFLICT (user_id) DO NOTHING",
+		key.UserID, key.WrappedKey, key.KEKID)
+	if err != nil {
+		return UserKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.UserKey(ctx, key.UserID)
+}
+
+// DeleteUserKey deletes data key of the user.
+// Items encrypted with the key cannot be decrypted anymore.
+func (s *Keeper) DeleteUserKey(ctx context.Context, userID int64) error {
+	const op = "storage.server.DeleteUserKey"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx, "DELETE FROM user_keys WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// StaleUserKeys returns user keys which are not wrapped by the key encryption key kekID.
+func (s *Keeper) StaleUserKeys(ctx context.Context, kekID string, afterUserID int64, limit int) ([]UserKey, error) {
+	const op = "storage.server.StaleUserKeys"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx,
+		`SELECT (user_id, wrapped_key, kek_id) FROM user_keys
+		WHERE kek_id <> $1 AND user_id > $2 ORDER BY user_id LIMIT $3`, kekID, afterUserID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	res, err := pgx.CollectRows(rows, pgx.RowTo[UserKey])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return res, nil
+}
+
+// UpdateUserKey replaces wrapped data key of the user.
+// Update is skipped, if the key was rewrapped concurrently and its kek id is no longer key.KEKID.
+func (s *Keeper) UpdateUserKey(ctx context.Context, key UserKey, wrapped []byte, kekID string) error {
+	const op = "storage.server.UpdateUserKey"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx,
+		"UPDATE user_keys SET wrapped_key = $1, kek_id = $2, updated_at = CURRENT_TIMESTAMP WHERE user_id = $3 AND kek_id = $4",
+		wrapped, kekID, key.UserID, key.KEKID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
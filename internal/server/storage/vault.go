@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateVault creates team vault and adds the owner as its member.
+// It returns id of the new vault.
+func (s *Keeper) CreateVault(ctx context.Context, vault Vault, owner VaultMember) (int64, error) {
+	const op = "storage.server.CreateVault"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.Begin(newCtx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback(newCtx) }()
+
+	var id int64
+	err = tx.QueryRow(newCtx, "INSERT INTO vaults (name, owner_id) VALUES ($1, $2) RETURNING id",
+		vault.Name, vault.OwnerID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.Exec(newCtx, "INSERT INTO vault_members (vault_id, user_id, role, wrapped_key) VALUES ($1, $2, $3, $4)",
+		id, owner.UserID, owner.Role, owner.WrappedKey)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(newCtx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+// Membership returns team vault with role of the user in it.
+// It returns ErrNotMember, if vault does not exist or user is not its member.
+func (s *Keeper) Membership(ctx context.Context, vaultID int64, userID int64) (Membership, error) {
+	const op = "storage.server.Membership"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx,
+		`SELECT (v.id, v.name, v.owner_id, m.role, m.wrapped_key) FROM vaults v
+		JOIN vault_members m ON m.vault_id = v.id
+		WHERE v.id = $1 AND m.user_id = $2`, vaultID, userID)
+	if err != nil {
+		return Membership{}, fmt.Errorf("%s: %w", op, err)
+	}
+	res, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[Membership])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Membership{}, fmt.Errorf("%s: %w", op, ErrNotMember)
+		}
+		return Membership{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return res, nil
+}
+
+// Memberships returns all team vaults of the user.
+func (s *Keeper) Memberships(ctx context.Context, userID int64) ([]Membership, error) {
+	const op = "storage.server.Memberships"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx,
+		`SELECT (v.id, v.name, v.owner_id, m.role, m.wrapped_key) FROM vaults v
+		JOIN vault_members m ON m.vault_id = v.id
+		WHERE m.user_id = $1 ORDER BY v.id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	res, err := pgx.CollectRows(rows, pgx.RowTo[Membership])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return res, nil
+}
+
+// Members returns ids of all members of the team vault.
+func (s *Keeper) Members(ctx context.Context, vaultID int64) ([]int64, error) {
+	const op = "storage.server.Members"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rows, err := s.db.Query(newCtx, "SELECT user_id FROM vault_members WHERE vault_id = $1 ORDER BY user_id", vaultID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	res, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return res, nil
+}
+
+// SaveMember adds member to the team vault or changes role and key of the member.
+func (s *Keeper) SaveMember(ctx context.Context, member VaultMember) error {
+	const op = "storage.server.SaveMember"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx,
+		`INSERT INTO vault_members (vault_id, user_id, role, wrapped_key) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (vault_id, user_id) DO UPDATE SET role = $3, wrapped_key = $4, updated_at = CURRENT_TIMESTAMP`,
+		member.VaultID, member.UserID, member.Role, member.WrappedKey)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// DeleteMember removes member from the team vault.
+func (s *Keeper) DeleteMember(ctx context.Context, vaultID int64, userID int64) error {
+	const op = "storage.server.DeleteMember"
+
+	newCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.Exec(newCtx, "DELETE FROM vault_members WHERE vault_id = $1 AND user_id = $2", vaultID, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -3,50 +3,149 @@ package encrypt
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"crypto/sha256"
-	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 )
 
-func EncodeMsg(msgBytes []byte, secret string) string {
-	key := sha256.Sum256([]byte(secret))
+// Version1 is envelope format with AES-256-GCM and random nonce.
+const Version1 byte = 1
 
-	aesblock, err := aes.NewCipher(key[:])
-	if err != nil {
-		panic(err)
+const (
+	keySize   = 32
+	nonceSize = 12
+)
+
+var (
+	ErrInvalidKey         = errors.New("invalid encryption key")
+	ErrInvalidKeyID       = errors.New("invalid key id")
+	ErrMalformed          = errors.New("malformed ciphertext")
+	ErrUnsupportedVersion = errors.New("unsupported envelope version")
+	ErrDecrypt            = errors.New("message authentication failed")
+)
+
+// Envelope is encrypted message with the metadata required to decrypt it.
+//
+// Binary layout:
+//
+//	version (1 byte) | key id length (1 byte) | key id | nonce (12 bytes) | ciphertext
+//
+// Version and key id are authenticated as additional data.
+type Envelope struct {
+	Version    byte
+	KeyID      string
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// Marshal returns binary representation of the envelope.
+func (e Envelope) Marshal() []byte {
+	res := make([]byte, 0, len(e.header())+len(e.Nonce)+len(e.Ciphertext))
+	res = append(res, e.header()...)
+	res = append(res, e.Nonce...)
+	res = append(res, e.Ciphertext...)
+
+	return res
+}
+
+func (e Envelope) header() []byte {
+	res := make([]byte, 0, 2+len(e.KeyID))
+	res = append(res, e.Version, byte(len(e.KeyID)))
+
+	return append(res, e.KeyID...)
+}
+
+// ParseEnvelope parses binary representation of the envelope.
+// It returns ErrMalformed or ErrUnsupportedVersion, if data is not a valid envelope.
+func ParseEnvelope(data []byte) (Envelope, error) {
+	if len(data) < 2 {
+		return Envelope{}, ErrMalformed
 	}
-	aesgcm, err := cipher.NewGCM(aesblock)
-	if err != nil {
-		panic(err)
+	if data[0] != Version1 {
+		return Envelope{}, ErrUnsupportedVersion
 	}
 
-	nonce := key[len(key)-aesgcm.NonceSize():]
+	idLen := int(data[1])
+	if len(data) < 2+idLen+nonceSize {
+		return Envelope{}, ErrMalformed
+	}
 
-	dst := aesgcm.Seal(nil, nonce, msgBytes, nil) // зашифровываем
-	return hex.EncodeToString(dst)
+	return Envelope{
+		Version:    data[0],
+		KeyID:      string(data[2 : 2+idLen]),
+		Nonce:      data[2+idLen : 2+idLen+nonceSize],
+		Ciphertext: data[2+idLen+nonceSize:],
+	}, nil
 }
 
-func DecodeMsg(msg string, secret string) string {
-	key := sha256.Sum256([]byte(secret))
+// Encrypt encrypts plaintext with 32 bytes key and returns marshaled envelope.
+// Key id is stored in the envelope, so the caller can find the key for decryption.
+func Encrypt(key []byte, keyID string, plaintext []byte) ([]byte, error) {
+	if len(keyID) > 255 {
+		return nil, ErrInvalidKeyID
+	}
 
-	aesblock, err := aes.NewCipher(key[:])
+	aead, err := newAEAD(key)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	aesgcm, err := cipher.NewGCM(aesblock)
+
+	env := Envelope{
+		Version: Version1,
+		KeyID:   keyID,
+		Nonce:   make([]byte, nonceSize),
+	}
+	if _, err := io.ReadFull(rand.Reader, env.Nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plaintext, env.header())
+
+	return env.Marshal(), nil
+}
+
+// Decrypt decrypts marshaled envelope with key.
+func Decrypt(key []byte, data []byte) ([]byte, error) {
+	env, err := ParseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return env.Open(key)
+}
+
+// Open decrypts envelope with key.
+func (e Envelope) Open(key []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	decodedMsg, err := hex.DecodeString(msg)
+	plaintext, err := aead.Open(nil, e.Nonce, e.Ciphertext, e.header())
 	if err != nil {
-		panic(err)
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
+
+// DeriveKey turns secret from configuration into 32 bytes key.
+func DeriveKey(secret string) []byte {
+	key := sha256.Sum256([]byte(secret))
+
+	return key[:]
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, ErrInvalidKey
 	}
 
-	nonce := key[len(key)-aesgcm.NonceSize():]
-	decrypted, err := aesgcm.Open(nil, nonce, decodedMsg, nil)
+	block, err := aes.NewCipher(key)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
 	}
 
-	return string(decrypted)
+	return cipher.NewGCM(block)
 }
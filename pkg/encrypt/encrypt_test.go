@@ -1,17 +1,138 @@
 package encrypt
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func TestEncodeMsg(t *testing.T) {
+// encodeLegacy encrypts message in legacy hex format, which is only decrypted now.
+func encodeLegacy(t *testing.T, msg string, secret string) string {
+	t.Helper()
+
+	key := sha256.Sum256([]byte(secret))
+	aesblock, err := aes.NewCipher(key[:])
+	require.NoError(t, err)
+	aesgcm, err := cipher.NewGCM(aesblock)
+	require.NoError(t, err)
+
+	nonce := key[len(key)-aesgcm.NonceSize():]
+	return hex.EncodeToString(aesgcm.Seal(nil, nonce, []byte(msg), nil))
+}
+
+func TestDecodeMsg(t *testing.T) {
 	sourceMsg := "some text"
 	key := "testpassword"
-	encodeHash := EncodeMsg([]byte(sourceMsg), key)
+	encodeHash := encodeLegacy(t, sourceMsg, key)
 	require.NotEmpty(t, sourceMsg)
-	resultMsg := DecodeMsg(encodeHash, key)
+	resultMsg, err := DecodeMsg(encodeHash, key)
+	require.NoError(t, err)
 	require.NotEmpty(t, resultMsg)
 	require.Equal(t, sourceMsg, resultMsg)
 }
+
+func TestDecodeMsgInvalid(t *testing.T) {
+	_, err := DecodeMsg("not hex", "testpassword")
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	_, err = DecodeMsg(encodeLegacy(t, "some text", "testpassword"), "other")
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
+
+func TestEncrypt(t *testing.T) {
+	key := DeriveKey("testpassword")
+
+	first, err := Encrypt(key, "key-1", []byte("some text"))
+	require.NoError(t, err)
+	second, err := Encrypt(key, "key-1", []byte("some text"))
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second, "nonce must be random")
+
+	env, err := ParseEnvelope(first)
+	require.NoError(t, err)
+	assert.Equal(t, Version1, env.Version)
+	assert.Equal(t, "key-1", env.KeyID)
+	assert.Len(t, env.Nonce, nonceSize)
+
+	plaintext, err := Decrypt(key, first)
+	require.NoError(t, err)
+	assert.Equal(t, "some text", string(plaintext))
+}
+
+func TestDecryptErrors(t *testing.T) {
+	key := DeriveKey("testpassword")
+	data, err := Encrypt(key, "key-1", []byte("some text"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  []byte
+		data []byte
+		err  error
+	}{
+		{name: "empty", key: key, data: nil, err: ErrMalformed},
+		{name: "short", key: key, data: data[:10], err: ErrMalformed},
+		{name: "version", key: key, data: append([]byte{2}, data[1:]...), err: ErrUnsupportedVersion},
+		{name: "wrong key", key: DeriveKey("other"), data: data, err: ErrDecrypt},
+		{name: "short key", key: key[:16], data: data, err: ErrInvalidKey},
+		{name: "tampered key id", key: key, data: append(append([]byte{}, data[:2]...), append([]byte("key-2"), data[7:]...)...), err: ErrDecrypt},
+		{name: "tampered ciphertext", key: key, data: append(append([]byte{}, data[:len(data)-1]...), data[len(data)-1]^1), err: ErrDecrypt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decrypt(tt.key, tt.data)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestIsLegacy(t *testing.T) {
+	data, err := Encrypt(DeriveKey("testpassword"), "key-1", []byte("some text"))
+	require.NoError(t, err)
+
+	assert.False(t, IsLegacy(data))
+	assert.False(t, IsLegacy(nil))
+	assert.True(t, IsLegacy([]byte(encodeLegacy(t, "some text", "testpassword"))))
+}
+
+func TestKeyring(t *testing.T) {
+	old, err := NewKeyring("old", map[string][]byte{"old": DeriveKey("old secret")})
+	require.NoError(t, err)
+	data, err := old.Encrypt([]byte("some text"))
+	require.NoError(t, err)
+
+	rotated, err := NewKeyring("new", map[string][]byte{
+		"old": DeriveKey("old secret"),
+		"new": DeriveKey("new secret"),
+	})
+	require.NoError(t, err)
+
+	plaintext, err := rotated.Decrypt(data)
+	require.NoError(t, err)
+	assert.Equal(t, "some text", string(plaintext))
+
+	reencrypted, err := rotated.Encrypt(plaintext)
+	require.NoError(t, err)
+	env, err := ParseEnvelope(reencrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "new", env.KeyID)
+
+	_, err = old.Decrypt(reencrypted)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestNewKeyringErrors(t *testing.T) {
+	_, err := NewKeyring("missing", map[string][]byte{"key": DeriveKey("secret")})
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = NewKeyring("key", map[string][]byte{"key": []byte("short")})
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = NewKeyring("key", map[string][]byte{"key": DeriveKey("secret"), DataKeyID: DeriveKey("other")})
+	assert.ErrorIs(t, err, ErrInvalidKeyID, "id of data keys is reserved")
+}
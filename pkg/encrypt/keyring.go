@@ -0,0 +1,74 @@
+package encrypt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DataKeyID marks envelopes encrypted with data keys of users, server keys can not have this id.
+const DataKeyID = "user"
+
+var ErrUnknownKey = errors.New("unknown key id")
+
+// Keyring holds active key used for encryption and retired keys,
+// which are still needed to decrypt data encrypted before rotation.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// NewKeyring creates keyring with the active key id and all known keys.
+// Every key must be 32 bytes long, id DataKeyID is reserved.
+func NewKeyring(active string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key %q: %w", active, ErrUnknownKey)
+	}
+
+	ring := &Keyring{
+		active: active,
+		keys:   make(map[string][]byte, len(keys)),
+	}
+	for id, key := range keys {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key %q: %w", id, ErrInvalidKey)
+		}
+		if len(id) > 255 || id == DataKeyID {
+			return nil, fmt.Errorf("key %q: %w", id, ErrInvalidKeyID)
+		}
+		ring.keys[id] = key
+	}
+
+	return ring, nil
+}
+
+// ActiveID returns id of the key used for encryption.
+func (k *Keyring) ActiveID() string {
+	return k.active
+}
+
+// Key returns key by id.
+func (k *Keyring) Key(id string) ([]byte, bool) {
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+// Encrypt encrypts plaintext with the active key.
+func (k *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	return Encrypt(k.keys[k.active], k.active, plaintext)
+}
+
+// Decrypt decrypts envelope with the key it was encrypted with.
+// It returns ErrUnknownKey, if the key is not in the keyring.
+func (k *Keyring) Decrypt(data []byte) ([]byte, error) {
+	env, err := ParseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := k.keys[env.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("key %q: %w", env.KeyID, ErrUnknownKey)
+	}
+
+	return env.Open(key)
+}
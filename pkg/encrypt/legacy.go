@@ -0,0 +1,52 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// DecodeMsg decrypts message in legacy hex format.
+// Nonce of the format is derived from the secret, so the same message always has the same ciphertext,
+// the format is only decrypted to migrate old data, use Encrypt for new data.
+func DecodeMsg(msg string, secret string) (string, error) {
+	key := sha256.Sum256([]byte(secret))
+
+	aesblock, err := aes.NewCipher(key[:])
+	if err != nil {
+		return "", err
+	}
+	aesgcm, err := cipher.NewGCM(aesblock)
+	if err != nil {
+		return "", err
+	}
+
+	decodedMsg, err := hex.DecodeString(msg)
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	nonce := key[len(key)-aesgcm.NonceSize():]
+	decrypted, err := aesgcm.Open(nil, nonce, decodedMsg, nil)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	return string(decrypted), nil
+}
+
+// IsLegacy reports whether data is in legacy hex format.
+// Envelopes start with version byte, which is never a hex digit.
+func IsLegacy(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	for _, c := range data {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,25 +1,172 @@
+// Package jwt issues and verifies access tokens signed with Ed25519 keys.
+//
+// Id of the signing key is put into the "kid" header, so signing keys can be rotated:
+// new tokens are signed with the active key, tokens signed with retired keys
+// stay valid while the public keys of retired keys are published.
 package jwt
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/SmoothWay/gophkeeper/pkg/models"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func NewToken(user models.User, app models.App, duration time.Duration) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+// Alg is the signing algorithm of access tokens.
+const Alg = "EdDSA"
 
-	claims := token.Claims.(jwt.MapClaims)
-	claims["uid"] = user.ID
-	claims["email"] = user.Email
-	claims["exp"] = time.Now().Add(duration).Unix()
-	claims["app_id"] = app.ID
+var (
+	ErrUnknownKey   = errors.New("unknown signing key")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrInvalidKeys  = errors.New("invalid signing keys")
+)
+
+// Signer signs access tokens with the active key.
+type Signer struct {
+	kid    string
+	key    ed25519.PrivateKey
+	public map[string]ed25519.PublicKey
+}
+
+// NewSigner returns Signer, which signs tokens with the key active and publishes public keys of all keys.
+func NewSigner(active string, keys map[string]ed25519.PrivateKey) (*Signer, error) {
+	key, ok := keys[active]
+	if !ok {
+		return nil, fmt.Errorf("%w: active key %q not found", ErrInvalidKeys, active)
+	}
+
+	public := make(map[string]ed25519.PublicKey, len(keys))
+	for kid, k := range keys {
+		public[kid] = k.Public().(ed25519.PublicKey)
+	}
+
+	return &Signer{kid: active, key: key, public: public}, nil
+}
+
+// NewToken returns access token of the user issued at now, sessionID is put into the "sid" claim.
+func (s *Signer) NewToken(
+	user models.User, app models.App, sessionID string, now time.Time, duration time.Duration,
+) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+		"uid":    user.ID,
+		"email":  user.Email,
+		"iat":    now.Unix(),
+		"exp":    now.Add(duration).Unix(),
+		"app_id": app.ID,
+		"sid":    sessionID,
+	})
+	token.Header["kid"] = s.kid
+
+	return token.SignedString(s.key)
+}
+
+// PublicKeys returns public keys of the active and retired signing keys by key id.
+func (s *Signer) PublicKeys() map[string]ed25519.PublicKey {
+	return s.public
+}
+
+// KeyFunc returns public key with id kid.
+type KeyFunc func(kid string) (ed25519.PublicKey, error)
+
+// Claims are claims of the access token.
+// SessionID is id of the login session, it is empty in tokens issued before sessions were introduced.
+type Claims struct {
+	User      models.User
+	SessionID string
+}
+
+// Parse verifies access token with the public key from keys and returns id and email of the user.
+func Parse(accessToken string, keys KeyFunc) (models.User, error) {
+	claims, err := ParseClaims(accessToken, keys)
+	if err != nil {
+		return models.User{}, err
+	}
+	return claims.User, nil
+}
 
-	tokenString, err := token.SignedString([]byte(app.Secret))
+// ParseClaims verifies access token with the public key from keys and returns its claims.
+func ParseClaims(accessToken string, keys KeyFunc) (Claims, error) {
+	return ParseClaimsAt(accessToken, keys, time.Now())
+}
+
+// ParseClaimsAt is ParseClaims, which checks expiration of the token at now.
+func ParseClaimsAt(accessToken string, keys KeyFunc, now time.Time) (Claims, error) {
+	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, ErrUnknownKey
+		}
+		return keys(kid)
+	},
+		jwt.WithValidMethods([]string{Alg}),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
 	if err != nil {
-		return "", err
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return Claims{}, ErrInvalidToken
+	}
+	uid, ok := claims["uid"].(float64)
+	if !ok {
+		return Claims{}, fmt.Errorf("%w: user id is missing", ErrInvalidToken)
 	}
+	email, _ := claims["email"].(string)
+	sid, _ := claims["sid"].(string)
+
+	return Claims{User: models.User{ID: int64(uid), Email: email}, SessionID: sid}, nil
+}
+
+// ParseKeys parses signing keys, one "id:base64 seed" entry per line.
+func ParseKeys(data string) (map[string]ed25519.PrivateKey, error) {
+	keys := make(map[string]ed25519.PrivateKey)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kid, encoded, ok := strings.Cut(line, ":")
+		if !ok || kid == "" {
+			return nil, fmt.Errorf("%w: malformed entry", ErrInvalidKeys)
+		}
+		seed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%w: key %q", ErrInvalidKeys, kid)
+		}
+		keys[kid] = ed25519.NewKeyFromSeed(seed)
+	}
+
+	return keys, nil
+}
+
+// FormatKeys formats signing keys in the format of ParseKeys.
+func FormatKeys(keys map[string]ed25519.PrivateKey) string {
+	ids := make([]string, 0, len(keys))
+	for kid := range keys {
+		ids = append(ids, kid)
+	}
+	sort.Strings(ids)
+
+	var b strings.Builder
+	for _, kid := range ids {
+		fmt.Fprintf(&b, "%s:%s\n", kid, base64.StdEncoding.EncodeToString(keys[kid].Seed()))
+	}
+	return b.String()
+}
 
-	return tokenString, nil
+// GenerateKey returns new signing key.
+func GenerateKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	return key, err
 }
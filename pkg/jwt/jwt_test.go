@@ -0,0 +1,91 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/SmoothWay/gophkeeper/pkg/models"
+)
+
+func testKeys(t *testing.T, ids ...string) map[string]ed25519.PrivateKey {
+	t.Helper()
+
+	keys := make(map[string]ed25519.PrivateKey)
+	for _, kid := range ids {
+		key, err := GenerateKey()
+		require.NoError(t, err)
+		keys[kid] = key
+	}
+	return keys
+}
+
+func TestSigner(t *testing.T) {
+	keys := testKeys(t, "k1", "k2")
+	user := models.User{ID: 10, Email: "name@example.com"}
+	app := models.App{ID: 1}
+
+	_, err := NewSigner("missing", keys)
+	require.ErrorIs(t, err, ErrInvalidKeys)
+
+	old, err := NewSigner("k1", keys)
+	require.NoError(t, err)
+	signer, err := NewSigner("k2", keys)
+	require.NoError(t, err)
+	assert.Len(t, signer.PublicKeys(), 2)
+
+	lookup := func(kid string) (ed25519.PublicKey, error) {
+		key, ok := signer.PublicKeys()[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		return key, nil
+	}
+
+	// tokens signed with the retired key are valid
+	for _, s := range []*Signer{old, signer} {
+		token, err := s.NewToken(user, app, "session", time.Now(), time.Hour)
+		require.NoError(t, err)
+		parsed, err := Parse(token, lookup)
+		require.NoError(t, err)
+		assert.Equal(t, user, parsed)
+		claims, err := ParseClaims(token, lookup)
+		require.NoError(t, err)
+		assert.Equal(t, Claims{User: user, SessionID: "session"}, claims)
+	}
+
+	expired, err := signer.NewToken(user, app, "session", time.Now().Add(-2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	_, err = Parse(expired, lookup)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	other, err := NewSigner("k1", testKeys(t, "k1"))
+	require.NoError(t, err)
+	forged, err := other.NewToken(user, app, "session", time.Now(), time.Hour)
+	require.NoError(t, err)
+	_, err = Parse(forged, lookup)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	hmac, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"uid": 10, "exp": time.Now().Add(time.Hour).Unix()}).
+		SignedString([]byte("test-secret"))
+	require.NoError(t, err)
+	_, err = Parse(hmac, lookup)
+	assert.ErrorIs(t, err, ErrInvalidToken, "tokens signed with shared secret are rejected")
+}
+
+func TestKeys(t *testing.T) {
+	keys := testKeys(t, "k1", "k2")
+
+	parsed, err := ParseKeys("# signing keys\n" + FormatKeys(keys))
+	require.NoError(t, err)
+	assert.Equal(t, keys, parsed)
+
+	_, err = ParseKeys("k1")
+	assert.ErrorIs(t, err, ErrInvalidKeys)
+	_, err = ParseKeys("k1:c2hvcnQ=")
+	assert.ErrorIs(t, err, ErrInvalidKeys)
+}
@@ -1,7 +1,10 @@
 package models
 
+// App is client application registered in the auth service.
+// Secret of the application is kept only hashed, disabled application can not login or refresh tokens.
 type App struct {
-	ID     int
-	Name   string
-	Secret string
+	ID         int
+	Name       string
+	SecretHash []byte
+	Disabled   bool
 }
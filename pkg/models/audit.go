@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Types of audit events.
+const (
+	AuditRegister       = "register"
+	AuditLogin          = "login"
+	AuditSecondFactor   = "second_factor"
+	AuditPasswordChange = "password_change"
+	AuditPasswordReset  = "password_reset"
+	AuditRecovery       = "recovery"
+	AuditRecoveryKit    = "recovery_kit"
+	AuditAccountDeleted = "account_deleted"
+)
+
+// Outcomes of audit events.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	// OutcomeLocked is outcome of the login refused, while login is locked after failed attempts.
+	OutcomeLocked = "locked"
+)
+
+// AuditEvent is record of the audit log of the auth service.
+// UserID is 0, when the event does not belong to known account, e.g. login with unknown email.
+type AuditEvent struct {
+	ID        int64
+	Type      string
+	UserID    int64
+	Email     string
+	AppID     int
+	Address   string
+	Outcome   string
+	CreatedAt time.Time
+}
+
+// AuditFilter selects audit events of the user, empty fields do not filter.
+// Events are returned newest first, BeforeID continues the list after the event with the id.
+type AuditFilter struct {
+	UserID   int64
+	Types    []string
+	Outcome  string
+	Since    time.Time
+	Until    time.Time
+	BeforeID int64
+	Limit    int
+}
@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 type MessageType string
 type ItemType string
 
@@ -16,6 +18,46 @@ const (
 	New      MessageType = "new"
 	Snapshot MessageType = "snapshot"
 	Error    MessageType = "error"
+
+	// PublicKey registers public key of the user or carries public key
+	// of another user in reply to KeyRequest.
+	PublicKey  MessageType = "public_key"
+	KeyRequest MessageType = "key_request"
+	// Share and Revoke are sent by the owner of the item.
+	Share  MessageType = "share"
+	Revoke MessageType = "revoke"
+	// Shared, Unshared and SharedSnapshot are sent to the recipient of the item.
+	Shared         MessageType = "shared"
+	Unshared       MessageType = "unshared"
+	SharedSnapshot MessageType = "shared_snapshot"
+
+	// VaultCreate and VaultMember are sent by the user to manage team vaults.
+	VaultCreate MessageType = "vault_create"
+	VaultMember MessageType = "vault_member"
+	// Vaults is sent to the user with all team vaults of the user.
+	Vaults MessageType = "vaults"
+
+	// BlobStatus asks how many chunks of the blob the server has, the server replies with the same type.
+	// BlobChunk uploads chunk of the blob or carries chunk in reply to BlobRequest.
+	// BlobCommit completes upload of the blob, the server confirms it with the same type.
+	BlobStatus  MessageType = "blob_status"
+	BlobChunk   MessageType = "blob_chunk"
+	BlobCommit  MessageType = "blob_commit"
+	BlobRequest MessageType = "blob_request"
+)
+
+// Websocket close codes, which the server sends, when the session of the client is revoked
+// or the account of the user is deleted.
+const (
+	CloseSignedOut      = 4001
+	CloseAccountDeleted = 4002
+)
+
+// Roles of the team vault members.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
 )
 
 const (
@@ -23,6 +65,10 @@ const (
 	TextItem ItemType = "text"
 	BinItem  ItemType = "bin"
 	CardItem ItemType = "card"
+
+	SealedItem ItemType = "sealed"
+	SharedItem ItemType = "shared"
+	TeamItem   ItemType = "team"
 )
 
 type Credentials struct {
@@ -34,15 +80,44 @@ type Credentials struct {
 	Created  int64    `json:"created"`
 }
 
+// Binary is a file. Large files are uploaded in chunks separately from the item,
+// then Value is empty and Blob references the uploaded content.
 type Binary struct {
 	Type    ItemType `json:"type"`
 	Tag     string   `json:"tag"`
 	Key     string   `json:"key"`
 	Value   []byte   `json:"value"`
+	Blob    *Blob    `json:"blob,omitempty"`
 	Comment string   `json:"comment"`
 	Created int64    `json:"created"`
 }
 
+// Blob is content of the file encrypted in chunks with random Key.
+// Hash is SHA-256 of the file content.
+type Blob struct {
+	ID     string `json:"id"`
+	Size   int64  `json:"size"`
+	Chunks int    `json:"chunks"`
+	Key    []byte `json:"key"`
+	Hash   []byte `json:"hash"`
+}
+
+// Chunk is encrypted part of the blob. Chunks is number of chunks of the blob.
+type Chunk struct {
+	BlobID string `json:"blob_id"`
+	Index  int    `json:"index"`
+	Chunks int    `json:"chunks,omitempty"`
+	Data   []byte `json:"data,omitempty"`
+}
+
+// BlobInfo describes upload of the blob. Received is number of chunks stored by the server.
+type BlobInfo struct {
+	BlobID   string `json:"blob_id"`
+	Received int    `json:"received"`
+	Chunks   int    `json:"chunks"`
+	Size     int64  `json:"size"`
+}
+
 type Card struct {
 	Type    ItemType `json:"type"`
 	Tag     string   `json:"tag"`
@@ -62,6 +137,68 @@ type Text struct {
 	Created int64    `json:"created"`
 }
 
+// Envelope is an item sealed on the client with the vault key.
+// The server stores and relays it without access to the item content.
+// Items of the team vault have VaultID and are sealed with the team vault key.
+// BlobID references content of the item uploaded in chunks.
+type Envelope struct {
+	ID      string `json:"id"`
+	VaultID int64  `json:"vault_id,omitempty"`
+	BlobID  string `json:"blob_id,omitempty"`
+	Created int64  `json:"created"`
+	Data    []byte `json:"data"`
+}
+
+// TeamVault is a team vault of the user.
+// Key is the vault key sealed to the public key of the user.
+type TeamVault struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+	Key  []byte `json:"key"`
+}
+
+// Member sets role of the user with email in the team vault.
+// Key is the vault key sealed to the public key of the user, empty role removes the user.
+type Member struct {
+	VaultID int64  `json:"vault_id"`
+	Email   string `json:"email"`
+	Role    string `json:"role"`
+	Key     []byte `json:"key"`
+}
+
+// UserKey is X25519 public key of the user, which is used to share items with the user.
+type UserKey struct {
+	Email string `json:"email"`
+	Key   []byte `json:"key"`
+}
+
+// ShareEnvelope is an item sealed to the public key of the recipient.
+// From is set by the server, Data is empty when share is revoked.
+type ShareEnvelope struct {
+	ID      string `json:"id"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Created int64  `json:"created"`
+	Data    []byte `json:"data"`
+}
+
+// SharedSecret is an item shared with the user by another user.
+type SharedSecret struct {
+	ID      string          `json:"id"`
+	From    string          `json:"from"`
+	Created int64           `json:"created"`
+	Item    json.RawMessage `json:"item"`
+}
+
+// TeamSecret is an item of the team vault.
+type TeamSecret struct {
+	VaultID int64           `json:"vault_id"`
+	ID      string          `json:"id"`
+	Created int64           `json:"created"`
+	Item    json.RawMessage `json:"item"`
+}
+
 type Message struct {
 	Token string      `json:"token"`
 	Type  MessageType `json:"type"`
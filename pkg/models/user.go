@@ -1,7 +1,154 @@
 package models
 
+import "time"
+
 type User struct {
-	ID       int64
-	Email    string
+	ID    int64
+	Email string
+	// PassHash is bcrypt hash of the password of accounts, which have not logged in since SRP was introduced,
+	// it is replaced with SRP verifier on the next login with the password.
 	PassHash []byte
+	// VaultKey is master key of the client vault wrapped with the password.
+	VaultKey []byte
+	// RecoveryVerifier is hash of the proof the user restores from the recovery kit.
+	RecoveryVerifier []byte
+	// TOTPSecret is secret of the second factor, it is used on login when TOTPEnabled.
+	// TOTPCounter is time period of the last accepted code, codes can not be reused.
+	TOTPSecret  []byte
+	TOTPEnabled bool
+	TOTPCounter int64
+	// EmailVerified is set, when the user confirmed the email with the token sent to it.
+	EmailVerified bool
+	// SRPSalt and SRPVerifier check the password on SRP login, they are empty for accounts,
+	// which have not logged in since SRP was introduced, such accounts log in with the password once.
+	SRPSalt     []byte
+	SRPVerifier []byte
+}
+
+// RefreshToken is a refresh token issued to the user, only hash of the token is stored.
+// Tokens rotated from the same login share Family.
+type RefreshToken struct {
+	ID        int64
+	Hash      []byte
+	Family    string
+	UserID    int64
+	AppID     int
+	ExpiresAt time.Time
+	Used      bool
+	Revoked   bool
+}
+
+// Device is the client device, which logs in, Address is its network address seen by the server.
+type Device struct {
+	Name    string
+	Address string
+}
+
+// Session is login of the user on the device.
+// ID of the session is Family of refresh tokens rotated from the login, the session ends when they are revoked.
+type Session struct {
+	ID         string
+	UserID     int64
+	AppID      int
+	Device     string
+	Address    string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+}
+
+// DeletedAccount is account deleted by the user, the keeper server deletes data of the account.
+// Seq is position of the deletion, the keeper server polls deletions after the latest purged one.
+type DeletedAccount struct {
+	UserID    int64
+	DeletedAt time.Time
+	Seq       int64
+}
+
+// LoginTicket is issued after the password of the user with second factor is checked.
+// Login is completed with the ticket and the second factor code.
+type LoginTicket struct {
+	Hash      []byte
+	UserID    int64
+	AppID     int
+	ExpiresAt time.Time
+	Attempts  int
+	// SealKey encrypts the vault key, when login was started with SRP, see Login.ServerProof.
+	SealKey []byte
+}
+
+// PasswordVerifier is SRP verifier of the password with its salt, it is computed by the client,
+// so the server can check the password without knowing it.
+type PasswordVerifier struct {
+	Salt     []byte
+	Verifier []byte
+}
+
+// LoginChallenge is the first step of SRP login, the client answers it with its public key and proof of the password.
+type LoginChallenge struct {
+	Handshake    string
+	Salt         []byte
+	ServerPublic []byte
+}
+
+// PasswordProof answers LoginChallenge, requests, which require the password again, carry it instead of the password.
+type PasswordProof struct {
+	Handshake    string
+	ClientPublic []byte
+	ClientProof  []byte
+}
+
+// Handshake keeps ephemeral private key of the server between the steps of SRP login.
+type Handshake struct {
+	Hash       []byte
+	Email      string
+	AppID      int
+	PrivateKey []byte
+	ExpiresAt  time.Time
+}
+
+// Login is result of the login. Ticket is set instead of tokens, when the user has to enter second factor code.
+// After SRP login VaultKey is encrypted with the session key and ServerProof proves that the server knows the verifier.
+type Login struct {
+	Tokens      Tokens
+	VaultKey    []byte
+	Ticket      string
+	ServerProof []byte
+}
+
+// Purposes of email tokens.
+const (
+	EmailVerify   = "verify"
+	PasswordReset = "reset"
+)
+
+// EmailToken is sent to the email of the user to prove that the user owns the email.
+type EmailToken struct {
+	Hash      []byte
+	UserID    int64
+	Purpose   string
+	ExpiresAt time.Time
+}
+
+// Mail is message waiting for delivery in the outbox.
+type Mail struct {
+	ID      int64
+	To      string
+	Subject string
+	Body    string
+}
+
+// LoginFailures counts failed logins of the account or client address.
+// Login is refused until LockedUntil.
+type LoginFailures struct {
+	Key         string
+	Failures    int
+	LockedUntil time.Time
+}
+
+// Tokens are issued to the user on login and on refresh.
+// ExpiresAt is expiration time of the access token.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
 }
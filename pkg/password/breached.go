@@ -0,0 +1,114 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PrefixLen is length of the SHA-1 hash prefix, which names the range.
+const PrefixLen = 5
+
+var ErrInvalidPrefix = errors.New("invalid hash prefix")
+
+// Range is breached passwords, which SHA-1 hashes start with Prefix.
+// Counts maps the rest 35 hex digits of the hash in upper case to number of breaches.
+// The client gets the range of the password by the prefix of its hash, so the password is not revealed.
+type Range struct {
+	Prefix string
+	Counts map[string]int
+}
+
+// HashPrefix returns prefix of SHA-1 hash of the password, which names its range.
+func HashPrefix(password string) string {
+	return passwordHash(password)[:PrefixLen]
+}
+
+// Count returns number of breaches of the password, it is 0, if the password is not in the range.
+func (r Range) Count(password string) (int, error) {
+	hash := passwordHash(password)
+	if hash[:PrefixLen] != r.Prefix {
+		return 0, fmt.Errorf("password is not in the range %s", r.Prefix)
+	}
+	return r.Counts[hash[PrefixLen:]], nil
+}
+
+// RangeDir finds passwords in breached password lists downloaded in HIBP k-anonymity range format.
+// The directory has file PREFIX.txt for every 5 hex digits prefix of SHA-1 hash of the password,
+// every line of the file is SUFFIX:COUNT, the rest 35 hex digits of the hash and number of breaches.
+// Only the file of the prefix is read, so checks are fast without loading the whole list.
+type RangeDir struct {
+	dir string
+}
+
+// NewRangeDir returns checker of the range files in dir.
+func NewRangeDir(dir string) (*RangeDir, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("breached passwords dir: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached passwords dir: %s is not a directory", dir)
+	}
+	return &RangeDir{dir: dir}, nil
+}
+
+// Count returns number of breaches of the password, it is 0, if the password is not in the list.
+func (d *RangeDir) Count(password string) (int, error) {
+	r, err := d.Range(HashPrefix(password))
+	if err != nil {
+		return 0, err
+	}
+	return r.Count(password)
+}
+
+// Range returns breached passwords of the prefix, padding lines with zero count are skipped.
+// It returns ErrInvalidPrefix, if prefix is not 5 hex digits.
+func (d *RangeDir) Range(prefix string) (Range, error) {
+	prefix = strings.ToUpper(prefix)
+	if _, err := hex.DecodeString(prefix + "0"); err != nil || len(prefix) != PrefixLen {
+		return Range{}, ErrInvalidPrefix
+	}
+	r := Range{Prefix: prefix, Counts: make(map[string]int)}
+
+	f, err := os.Open(filepath.Join(d.dir, prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return r, nil
+		}
+		return Range{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		suffix, c, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		count, err := strconv.Atoi(c)
+		if err != nil {
+			return Range{}, fmt.Errorf("invalid line of range file %s: %q", prefix, line)
+		}
+		if count > 0 {
+			r.Counts[strings.ToUpper(suffix)] = count
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Range{}, err
+	}
+	return r, nil
+}
+
+// passwordHash returns SHA-1 hash of the password in upper case hex.
+func passwordHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,205 @@
+package password
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+//go:embed common.txt
+var commonText string
+
+// common ranks frequent passwords and words, rank 1 is the most frequent.
+var common = rankWords(strings.Fields(commonText))
+
+// keyboardRows are rows of QWERTY keyboard, runs of adjacent keys are easy to guess.
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// leet maps substitutions people use in words to the letters.
+var leet = map[rune]rune{'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '@': 'a', '$': 's', '!': 'i'}
+
+// minMatch is length of the shortest pattern, shorter parts are counted as random characters.
+const minMatch = 3
+
+// Entropy estimates in bits how hard the password is to guess in the style of zxcvbn.
+// The password is split into patterns attackers try first: common words and passwords, words of inputs,
+// repeated characters, sequences, keyboard runs and years. Every pattern adds bits of its number of guesses,
+// other characters add bits of their character class.
+func Entropy(password string, inputs ...string) float64 {
+	words := make(map[string]int, len(common))
+	for word, rank := range common {
+		words[word] = rank
+	}
+	for _, input := range inputs {
+		for _, token := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len([]rune(token)) >= minMatch {
+				words[token] = 1
+			}
+		}
+	}
+
+	runes := []rune(password)
+	var bits float64
+	for i := 0; i < len(runes); {
+		n, b := bestMatch(runes[i:], words)
+		if n < minMatch {
+			n, b = 1, math.Log2(cardinality(runes[i]))
+		}
+		bits += b
+		i += n
+	}
+	return bits
+}
+
+// bestMatch returns length and bits of the longest pattern at the beginning of runes.
+func bestMatch(runes []rune, words map[string]int) (int, float64) {
+	var length int
+	var bits float64
+	match := func(n int, b float64) {
+		if n > length || n == length && b < bits {
+			length, bits = n, b
+		}
+	}
+
+	for n := minMatch; n <= len(runes); n++ {
+		part := runes[:n]
+		word, substituted := unleet(part)
+		if rank, ok := words[word]; ok {
+			b := math.Log2(float64(rank) + 1)
+			if hasUpper(part) {
+				b++
+			}
+			if substituted {
+				b++
+			}
+			match(n, b)
+		}
+	}
+
+	if n := repeatLen(runes); n >= minMatch {
+		match(n, math.Log2(cardinality(runes[0]))+math.Log2(float64(n)))
+	}
+	if n, step := sequenceLen(runes); n >= minMatch {
+		b := math.Log2(cardinality(runes[0]))
+		if runes[0] == 'a' || runes[0] == 'A' || runes[0] == '0' || runes[0] == '1' {
+			b = 1
+		}
+		if step < 0 {
+			b++
+		}
+		match(n, b+math.Log2(float64(n)))
+	}
+	if n := keyboardLen(runes); n >= minMatch {
+		match(n, math.Log2(float64(len(strings.Join(keyboardRows, ""))))+math.Log2(float64(n)))
+	}
+	if len(runes) >= 4 && isYear(runes[:4]) {
+		match(4, math.Log2(200))
+	}
+	return length, bits
+}
+
+// repeatLen returns length of the run of the same character.
+func repeatLen(runes []rune) int {
+	n := 1
+	for n < len(runes) && runes[n] == runes[0] {
+		n++
+	}
+	return n
+}
+
+// sequenceLen returns length and step of the run of consecutive characters, such as "abc" or "987".
+func sequenceLen(runes []rune) (int, rune) {
+	if len(runes) < 2 {
+		return len(runes), 0
+	}
+	step := runes[1] - runes[0]
+	if step != 1 && step != -1 {
+		return 1, 0
+	}
+	n := 2
+	for n < len(runes) && runes[n]-runes[n-1] == step && cardinality(runes[n]) == cardinality(runes[0]) {
+		n++
+	}
+	return n, step
+}
+
+// keyboardLen returns length of the run of adjacent keys of the same keyboard row in either direction.
+func keyboardLen(runes []rune) int {
+	best := 0
+	for _, row := range keyboardRows {
+		for _, r := range []string{row, reverse(row)} {
+			n := 0
+			start := strings.IndexRune(r, unicode.ToLower(runes[0]))
+			for start >= 0 && n < len(runes) && start+n < len(r) && rune(r[start+n]) == unicode.ToLower(runes[n]) {
+				n++
+			}
+			best = max(best, n)
+		}
+	}
+	return best
+}
+
+func isYear(runes []rune) bool {
+	s := string(runes)
+	if strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return false
+	}
+	return strings.HasPrefix(s, "19") || strings.HasPrefix(s, "20")
+}
+
+// unleet returns lowercase word with leet substitutions replaced by letters.
+func unleet(runes []rune) (string, bool) {
+	var b strings.Builder
+	substituted := false
+	for _, r := range runes {
+		if l, ok := leet[r]; ok {
+			r, substituted = l, true
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String(), substituted
+}
+
+func hasUpper(runes []rune) bool {
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// cardinality returns number of characters in the class of r.
+func cardinality(r rune) float64 {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return 26
+	case r >= '0' && r <= '9':
+		return 10
+	case r < unicode.MaxASCII:
+		return 33
+	default:
+		return 100
+	}
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+func rankWords(words []string) map[string]int {
+	ranks := make(map[string]int, len(words))
+	for i, word := range words {
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}
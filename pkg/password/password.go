@@ -0,0 +1,90 @@
+// Package password checks strength of master passwords: length, character classes, entropy estimate
+// and presence in breached password lists stored locally in HIBP range file format.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrWeak is matched by errors returned for passwords, which do not satisfy the policy.
+var ErrWeak = errors.New("weak password")
+
+// Error lists requirements of the policy, which the password does not satisfy.
+type Error struct {
+	Violations []string
+}
+
+func (e *Error) Error() string {
+	return strings.Join(e.Violations, "; ")
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrWeak
+}
+
+// BreachChecker returns how many times the password appeared in data breaches.
+type BreachChecker interface {
+	Count(password string) (int, error)
+}
+
+// Policy is requirements of passwords, zero fields are not checked.
+// MinEntropy is in bits, see Entropy. Breached rejects passwords known from data breaches.
+type Policy struct {
+	MinLength  int
+	MinClasses int
+	MinEntropy float64
+	Breached   BreachChecker
+}
+
+// Check returns *Error, if the password does not satisfy the policy.
+// inputs are user data, e.g. email, which make the password easy to guess.
+func (p Policy) Check(password string, inputs ...string) error {
+	var violations []string
+	if n := len([]rune(password)); n < p.MinLength {
+		violations = append(violations, fmt.Sprintf("password must be at least %d characters long", p.MinLength))
+	}
+	if Classes(password) < p.MinClasses {
+		violations = append(violations, fmt.Sprintf(
+			"password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", p.MinClasses))
+	}
+	if p.MinEntropy > 0 && Entropy(password, inputs...) < p.MinEntropy {
+		violations = append(violations,
+			"password is too easy to guess, avoid common words, names, dates, sequences and repeated characters")
+	}
+	if p.Breached != nil {
+		count, err := p.Breached.Count(password)
+		if err != nil {
+			return fmt.Errorf("check breached passwords: %w", err)
+		}
+		if count > 0 {
+			violations = append(violations,
+				fmt.Sprintf("password appeared in data breaches %d times, choose another one", count))
+		}
+	}
+
+	if len(violations) > 0 {
+		return &Error{Violations: violations}
+	}
+	return nil
+}
+
+// Classes returns number of character classes in the password: lowercase and uppercase letters, digits, symbols.
+func Classes(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
@@ -0,0 +1,103 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBreached reports the listed passwords as breached.
+type fakeBreached map[string]int
+
+func (f fakeBreached) Count(password string) (int, error) {
+	return f[password], nil
+}
+
+func TestCheck(t *testing.T) {
+	policy := Policy{MinLength: 12, MinClasses: 2, MinEntropy: 40, Breached: fakeBreached{"Xq7#vB2!pL9@": 3}}
+
+	assert.NoError(t, policy.Check("mauve kettle drifts 42 lanterns"))
+	assert.NoError(t, policy.Check("jQ8!rT4#wZ1%"))
+
+	err := policy.Check("short")
+	require.ErrorIs(t, err, ErrWeak)
+	var weak *Error
+	require.True(t, errors.As(err, &weak))
+	assert.Len(t, weak.Violations, 3, "length, classes and entropy")
+
+	tests := map[string]string{
+		"onlylowercaseletters": "classes",
+		"P@ssw0rd2024!":        "common password with year",
+		"qwertyuiop123":        "keyboard run and sequence",
+		"aaaaaaaaaaaaaaaa1":    "repeated characters",
+		"Xq7#vB2!pL9@":         "breached",
+	}
+	for password, reason := range tests {
+		assert.ErrorIs(t, policy.Check(password), ErrWeak, reason)
+	}
+
+	assert.ErrorIs(t, policy.Check("johnsmith1985!", "john.smith@example.com"), ErrWeak, "user inputs")
+	assert.NoError(t, Policy{}.Check("x"), "zero policy checks nothing")
+}
+
+func TestEntropy(t *testing.T) {
+	assert.Less(t, Entropy("password"), 5.0)
+	assert.Less(t, Entropy("P4ssw0rd"), Entropy("password")+3, "leet substitutions are cheap to guess")
+	assert.Less(t, Entropy("abcdefghij"), 10.0)
+	assert.Less(t, Entropy("9876543210"), 10.0)
+	assert.Less(t, Entropy("asdfghjkl"), 10.0)
+	assert.Less(t, Entropy("smith", "john.smith@example.com"), Entropy("smith"))
+	assert.Greater(t, Entropy("k9#Lm2$pQ7!z"), 50.0)
+}
+
+func TestClasses(t *testing.T) {
+	assert.Equal(t, 0, Classes(""))
+	assert.Equal(t, 1, Classes("abc"))
+	assert.Equal(t, 4, Classes("aB3$"))
+	assert.Equal(t, 3, Classes("пароль 1"), "letters of any alphabet")
+}
+
+func TestRangeDir(t *testing.T) {
+	dir := t.TempDir()
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	data := "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n" +
+		"1E4C9B93F3F0682250B6CF8331B7EE68FD8:9659365\r\n" +
+		"1E4C9B93F3F0682250B6CF8331B7EE68FD9:0\r\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte(data), 0o600))
+
+	breached, err := NewRangeDir(dir)
+	require.NoError(t, err)
+
+	count, err := breached.Count("password")
+	require.NoError(t, err)
+	assert.Equal(t, 9659365, count)
+
+	count, err = breached.Count("not in the list")
+	require.NoError(t, err)
+	assert.Zero(t, count, "range file of the prefix is missing")
+
+	r, err := breached.Range("5baa6")
+	require.NoError(t, err)
+	assert.Equal(t, Range{Prefix: "5BAA6", Counts: map[string]int{
+		"0018A45C4D1DEF81644B54AB7F969B88D65": 1,
+		"1E4C9B93F3F0682250B6CF8331B7EE68FD8": 9659365,
+	}}, r, "padding lines are skipped")
+	assert.Equal(t, "5BAA6", HashPrefix("password"))
+	count, err = r.Count("password")
+	require.NoError(t, err)
+	assert.Equal(t, 9659365, count)
+	_, err = r.Count("not in the list")
+	assert.Error(t, err, "password of other range")
+
+	for _, prefix := range []string{"../5B", "5BAA", "5BAA6G", "5BAAG"} {
+		_, err = breached.Range(prefix)
+		assert.ErrorIs(t, err, ErrInvalidPrefix, prefix)
+	}
+
+	_, err = NewRangeDir(filepath.Join(dir, "5BAA6.txt"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,36 @@
+package shamir
+
+// Arithmetic in GF(2^8) with the AES reducing polynomial x^8 + x^4 + x^3 + x + 1.
+// Addition is XOR. Multiplication is branch free and does not use lookup tables,
+// so timing does not depend on the secret.
+
+func mul(a byte, b byte) byte {
+	var p byte
+	for i := 0; i < 8; i++ {
+		p ^= -(b & 1) & a
+		carry := -(a >> 7)
+		a = (a << 1) ^ (0x1b & carry)
+		b >>= 1
+	}
+
+	return p
+}
+
+// inv returns multiplicative inverse of a as a^254, inverse of 0 is 0.
+func inv(a byte) byte {
+	b := mul(a, a)   // a^2
+	c := mul(a, b)   // a^3
+	b = mul(c, c)    // a^6
+	b = mul(b, b)    // a^12
+	c = mul(b, c)    // a^15
+	b = mul(b, b)    // a^24
+	b = mul(b, b)    // a^48
+	b = mul(b, c)    // a^63
+	b = mul(b, b)    // a^126
+	b = mul(a, b)    // a^127
+	return mul(b, b) // a^254
+}
+
+func div(a byte, b byte) byte {
+	return mul(a, inv(b))
+}
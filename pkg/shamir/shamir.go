@@ -0,0 +1,117 @@
+// Package shamir implements Shamir's secret sharing over GF(2^8).
+//
+// Every byte of the secret is the constant term of its own random polynomial
+// of degree threshold-1. Share i holds the values of all polynomials at x = i,
+// so any threshold shares reconstruct the secret and fewer reveal nothing about it.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// MaxShares is the maximum number of shares, x = 0 is reserved for the secret.
+const MaxShares = 255
+
+var (
+	ErrThreshold    = errors.New("threshold must be between 2 and the number of shares")
+	ErrShares       = errors.New("number of shares must be between 2 and 255")
+	ErrEmptySecret  = errors.New("secret is empty")
+	ErrTooFewShares = errors.New("at least two shares are required")
+	ErrMalformed    = errors.New("malformed share")
+	ErrDuplicate    = errors.New("duplicate share")
+)
+
+// Split splits secret into n shares, any threshold of which reconstruct the secret.
+// Share layout is x (1 byte) | y (len(secret) bytes).
+func Split(secret []byte, n int, threshold int) ([][]byte, error) {
+	return split(rand.Reader, secret, n, threshold)
+}
+
+func split(random io.Reader, secret []byte, n int, threshold int) ([][]byte, error) {
+	switch {
+	case len(secret) == 0:
+		return nil, ErrEmptySecret
+	case n < 2 || n > MaxShares:
+		return nil, ErrShares
+	case threshold < 2 || threshold > n:
+		return nil, ErrThreshold
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	coeffs := make([]byte, threshold)
+	defer clear(coeffs)
+	for j, b := range secret {
+		coeffs[0] = b
+		if _, err := io.ReadFull(random, coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			shares[i][j+1] = evaluate(coeffs, shares[i][0])
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine reconstructs the secret from shares produced by Split.
+// It can not detect that fewer than threshold shares are given,
+// the result is a wrong secret then, so callers should verify it.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, ErrTooFewShares
+	}
+
+	size := len(shares[0])
+	xs := make([]byte, len(shares))
+	seen := make(map[byte]bool, len(shares))
+	for i, share := range shares {
+		if len(share) < 2 || len(share) != size || share[0] == 0 {
+			return nil, ErrMalformed
+		}
+		if seen[share[0]] {
+			return nil, ErrDuplicate
+		}
+		seen[share[0]] = true
+		xs[i] = share[0]
+	}
+
+	// Lagrange basis polynomials evaluated at x = 0 do not depend on the secret byte
+	basis := make([]byte, len(shares))
+	for i := range xs {
+		basis[i] = 1
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			basis[i] = mul(basis[i], div(xs[j], xs[i]^xs[j]))
+		}
+	}
+
+	secret := make([]byte, size-1)
+	for j := range secret {
+		var b byte
+		for i, share := range shares {
+			b ^= mul(share[j+1], basis[i])
+		}
+		secret[j] = b
+	}
+
+	return secret, nil
+}
+
+// evaluate evaluates polynomial with coeffs at x with Horner's method.
+func evaluate(coeffs []byte, x byte) byte {
+	var y byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coeffs[i]
+	}
+
+	return y
+}
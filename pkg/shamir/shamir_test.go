@@ -0,0 +1,136 @@
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMul(t *testing.T) {
+	// example from FIPS-197, section 4.2
+	assert.Equal(t, byte(0xc1), mul(0x57, 0x83))
+	assert.Equal(t, byte(0xfe), mul(0x57, 0x13))
+
+	for a := 0; a < 256; a++ {
+		assert.Equal(t, byte(0), mul(byte(a), 0))
+		assert.Equal(t, byte(a), mul(byte(a), 1))
+		if a == 0 {
+			continue
+		}
+		assert.Equal(t, byte(1), mul(byte(a), inv(byte(a))), "inverse of %d", a)
+		for b := 1; b < 256; b++ {
+			require.Equal(t, byte(a), div(mul(byte(a), byte(b)), byte(b)))
+		}
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+
+	shares, err := Split(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+	for i, share := range shares {
+		assert.Equal(t, byte(i+1), share[0])
+		assert.Len(t, share, len(secret)+1)
+		assert.False(t, bytes.Contains(share, secret))
+	}
+
+	// every subset of threshold or more shares reconstructs the secret
+	for mask := 0; mask < 1<<len(shares); mask++ {
+		var subset [][]byte
+		for i := range shares {
+			if mask&(1<<i) != 0 {
+				subset = append(subset, shares[i])
+			}
+		}
+		if len(subset) < 2 {
+			continue
+		}
+
+		got, err := Combine(subset)
+		require.NoError(t, err)
+		if len(subset) >= 3 {
+			assert.Equal(t, secret, got, "shares %05b", mask)
+		} else {
+			assert.NotEqual(t, secret, got, "shares %05b", mask)
+		}
+	}
+}
+
+func TestSplitRandom(t *testing.T) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+
+	shares, err := Split(secret, MaxShares, MaxShares)
+	require.NoError(t, err)
+	got, err := Combine(shares)
+	require.NoError(t, err)
+	assert.Equal(t, secret, got)
+
+	again, err := Split(secret, MaxShares, MaxShares)
+	require.NoError(t, err)
+	assert.NotEqual(t, shares, again, "coefficients must be random")
+}
+
+func TestSplitErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    []byte
+		n         int
+		threshold int
+		err       error
+	}{
+		{name: "empty secret", secret: nil, n: 3, threshold: 2, err: ErrEmptySecret},
+		{name: "one share", secret: []byte("s"), n: 1, threshold: 1, err: ErrShares},
+		{name: "too many shares", secret: []byte("s"), n: 256, threshold: 2, err: ErrShares},
+		{name: "threshold one", secret: []byte("s"), n: 3, threshold: 1, err: ErrThreshold},
+		{name: "threshold above shares", secret: []byte("s"), n: 3, threshold: 4, err: ErrThreshold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Split(tt.secret, tt.n, tt.threshold)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestCombineErrors(t *testing.T) {
+	shares, err := Split([]byte("secret"), 3, 2)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		shares [][]byte
+		err    error
+	}{
+		{name: "no shares", shares: nil, err: ErrTooFewShares},
+		{name: "one share", shares: shares[:1], err: ErrTooFewShares},
+		{name: "different length", shares: [][]byte{shares[0], shares[1][:3]}, err: ErrMalformed},
+		{name: "zero x", shares: [][]byte{shares[0], append([]byte{0}, shares[1][1:]...)}, err: ErrMalformed},
+		{name: "empty share", shares: [][]byte{{1}, {2}}, err: ErrMalformed},
+		{name: "duplicate", shares: [][]byte{shares[0], shares[0]}, err: ErrDuplicate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Combine(tt.shares)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestSplitReader(t *testing.T) {
+	// with zero coefficients every share equals the secret
+	shares, err := split(bytes.NewReader(make([]byte, 64)), []byte("abc"), 3, 2)
+	require.NoError(t, err)
+	for _, share := range shares {
+		assert.Equal(t, []byte("abc"), share[1:])
+	}
+
+	_, err = split(bytes.NewReader(nil), []byte("abc"), 3, 2)
+	assert.Error(t, err)
+}
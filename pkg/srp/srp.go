@@ -0,0 +1,224 @@
+// Package srp implements SRP-6a password-authenticated key exchange (RFC 5054)
+// with the 2048-bit group and SHA-256.
+//
+// The server keeps only the verifier of the password. On login both sides prove
+// that they know the same session key, the password itself is never sent.
+package srp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Argon2id parameters used to derive the secret from the password.
+const (
+	kdfTime    = 3
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+	keyLen     = 32
+)
+
+const (
+	// SaltLen is length of salt generated by NewSalt.
+	SaltLen = 16
+	// privateLen is length of ephemeral private keys.
+	privateLen = 32
+)
+
+var ErrInvalidPublic = errors.New("invalid public key")
+
+// 2048-bit group of RFC 5054, appendix A.
+var (
+	n, _ = new(big.Int).SetString("AC6BDB41324A9A9BF166DE5E1389582FAF72B6651987EE07FC3192943DB56050A37329CBB4A099ED"+
+		"8193E0757767A13DD52312AB4B03310DCD7F48A9DA04FD50E8083969EDB767B0CF6095179A163AB3661A05FBD5FAAAE82918A9962F0B"+
+		"93B855F97993EC975EEAA80D740ADBF4FF747359D041D5C33EA71D281E446B14773BCA97B43A23FB801676BD207A436C6481F1D2B9078"+
+		"717461A5B9D32E688F87748544523B524B0D57D5EA77A2775D2ECFA032CFBDBF52FB3786160279004E57AE6AF874E7303CE53299CCC04"+
+		"1C7BC308D82A5698F3A8D0C38271AE35F8E9DBFBB694B5C803D89F7AE435DE236D525F54759B65E372FCD68EF20FA7111F9E4AFF73", 16)
+	g = big.NewInt(2)
+	// k is multiplier parameter, k = H(N | PAD(g))
+	k = new(big.Int).SetBytes(hash(n.Bytes(), pad(g)))
+)
+
+// Session is result of the key exchange.
+// Key is shared by the client and the server, proofs confirm that the other side has the same key.
+type Session struct {
+	Key         []byte
+	ClientProof []byte
+	ServerProof []byte
+}
+
+// NewSalt returns random salt of the verifier.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// Secret derives secret from the password with memory-hard Argon2id,
+// so the verifier can not be cheaply brute forced, if it leaks.
+func Secret(password string, salt []byte) []byte {
+	return argon2.IDKey([]byte(password), salt, kdfTime, kdfMemory, kdfThreads, keyLen)
+}
+
+// Verifier returns verifier of the secret, the server keeps it together with salt instead of the password.
+func Verifier(identity string, salt []byte, secret []byte) []byte {
+	return new(big.Int).Exp(g, privateKey(identity, salt, secret), n).Bytes()
+}
+
+// ValidVerifier reports whether verifier is an element of the group.
+func ValidVerifier(verifier []byte) bool {
+	return inGroup(new(big.Int).SetBytes(verifier))
+}
+
+// NewClient generates ephemeral keys of the client, public key is sent to the server.
+func NewClient() (private []byte, public []byte, err error) {
+	private, err = newPrivate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return private, clientPublic(private).Bytes(), nil
+}
+
+// NewServer generates ephemeral keys of the server for the user with verifier, public key is sent to the client.
+func NewServer(verifier []byte) (private []byte, public []byte, err error) {
+	private, err = newPrivate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return private, serverPublic(verifier, private).Bytes(), nil
+}
+
+// ClientSession computes session key of the client from its private key and public key of the server.
+// It returns ErrInvalidPublic, if the server sent invalid key.
+func ClientSession(identity string, salt []byte, secret []byte, private []byte, serverPub []byte) (Session, error) {
+	A := clientPublic(private)
+	B := new(big.Int).SetBytes(serverPub)
+	if !inGroup(B) {
+		return Session{}, ErrInvalidPublic
+	}
+	u := scramble(A, B)
+	if u.Sign() == 0 {
+		return Session{}, ErrInvalidPublic
+	}
+
+	x := privateKey(identity, salt, secret)
+	// S = (B - k * g^x) ^ (a + u * x) % N
+	base := new(big.Int).Mul(k, new(big.Int).Exp(g, x, n))
+	base.Sub(B, base).Mod(base, n)
+	exp := new(big.Int).Mul(u, x)
+	exp.Add(exp, new(big.Int).SetBytes(private))
+	S := new(big.Int).Exp(base, exp, n)
+
+	return newSession(identity, salt, A, B, S), nil
+}
+
+// ServerSession computes session key of the server from its private key and public key of the client.
+// It returns ErrInvalidPublic, if the client sent invalid key.
+func ServerSession(identity string, salt []byte, verifier []byte, private []byte, clientPub []byte) (Session, error) {
+	A := new(big.Int).SetBytes(clientPub)
+	if !inGroup(A) {
+		return Session{}, ErrInvalidPublic
+	}
+	B := serverPublic(verifier, private)
+	u := scramble(A, B)
+	if u.Sign() == 0 {
+		return Session{}, ErrInvalidPublic
+	}
+
+	// S = (A * v^u) ^ b % N
+	base := new(big.Int).Exp(new(big.Int).SetBytes(verifier), u, n)
+	base.Mul(base, A).Mod(base, n)
+	S := new(big.Int).Exp(base, new(big.Int).SetBytes(private), n)
+
+	return newSession(identity, salt, A, B, S), nil
+}
+
+// VerifyClient reports whether proof of the client matches the session.
+func (s Session) VerifyClient(proof []byte) bool {
+	return hmac.Equal(s.ClientProof, proof)
+}
+
+// VerifyServer reports whether proof of the server matches the session.
+func (s Session) VerifyServer(proof []byte) bool {
+	return hmac.Equal(s.ServerProof, proof)
+}
+
+// SealKey derives key to encrypt data sent over the session, e.g. with encrypt.Encrypt.
+func (s Session) SealKey() []byte {
+	key := make([]byte, keyLen)
+	// reading less than 255 hash lengths from HKDF never fails
+	_, _ = io.ReadFull(hkdf.New(sha256.New, s.Key, nil, []byte("gophkeeper srp seal")), key)
+	return key
+}
+
+func newSession(identity string, salt []byte, A, B, S *big.Int) Session {
+	key := hash(pad(S))
+	// M1 = H(H(N) xor H(g) | H(I) | s | A | B | K)
+	hn, hg := hash(n.Bytes()), hash(pad(g))
+	for i := range hn {
+		hn[i] ^= hg[i]
+	}
+	clientProof := hash(hn, hash([]byte(identity)), salt, pad(A), pad(B), key)
+	// M2 = H(A | M1 | K)
+	serverProof := hash(pad(A), clientProof, key)
+
+	return Session{Key: key, ClientProof: clientProof, ServerProof: serverProof}
+}
+
+// privateKey returns x = H(s | H(I | ":" | P)).
+func privateKey(identity string, salt []byte, secret []byte) *big.Int {
+	return new(big.Int).SetBytes(hash(salt, hash([]byte(identity), []byte(":"), secret)))
+}
+
+// inGroup reports whether 0 < x < N, public keys equal to 0 mod N would fix the session key.
+func inGroup(x *big.Int) bool {
+	return x.Sign() > 0 && x.Cmp(n) < 0
+}
+
+// clientPublic returns A = g^a % N.
+func clientPublic(private []byte) *big.Int {
+	return new(big.Int).Exp(g, new(big.Int).SetBytes(private), n)
+}
+
+// serverPublic returns B = (k * v + g^b) % N.
+func serverPublic(verifier []byte, private []byte) *big.Int {
+	B := new(big.Int).Mul(k, new(big.Int).SetBytes(verifier))
+	B.Add(B, new(big.Int).Exp(g, new(big.Int).SetBytes(private), n))
+	return B.Mod(B, n)
+}
+
+// scramble returns u = H(PAD(A) | PAD(B)).
+func scramble(A, B *big.Int) *big.Int {
+	return new(big.Int).SetBytes(hash(pad(A), pad(B)))
+}
+
+func newPrivate() ([]byte, error) {
+	private := make([]byte, privateLen)
+	if _, err := io.ReadFull(rand.Reader, private); err != nil {
+		return nil, fmt.Errorf("generate private key: %w", err)
+	}
+	return private, nil
+}
+
+// pad returns bytes of x left padded with zeros to the length of N.
+func pad(x *big.Int) []byte {
+	return x.FillBytes(make([]byte, (n.BitLen()+7)/8))
+}
+
+func hash(data ...[]byte) []byte {
+	h := sha256.New()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,79 @@
+package srp
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	assert.Equal(t, 2048, n.BitLen())
+	assert.True(t, n.ProbablyPrime(20))
+	// N is safe prime, (N - 1) / 2 is prime too
+	q := new(big.Int).Rsh(n, 1)
+	assert.True(t, q.ProbablyPrime(20))
+}
+
+// handshake runs key exchange of the client with password and the server with verifier of the user.
+func handshake(t *testing.T, salt []byte, verifier []byte, password string) (client Session, server Session) {
+	t.Helper()
+
+	a, A, err := NewClient()
+	require.NoError(t, err)
+	b, B, err := NewServer(verifier)
+	require.NoError(t, err)
+
+	client, err = ClientSession("user@example.com", salt, Secret(password, salt), a, B)
+	require.NoError(t, err)
+	server, err = ServerSession("user@example.com", salt, verifier, b, A)
+	require.NoError(t, err)
+	return client, server
+}
+
+func TestSession(t *testing.T) {
+	salt, err := NewSalt()
+	require.NoError(t, err)
+	verifier := Verifier("user@example.com", salt, Secret("password", salt))
+	require.True(t, ValidVerifier(verifier))
+
+	client, server := handshake(t, salt, verifier, "password")
+	assert.Equal(t, server.Key, client.Key)
+	assert.True(t, server.VerifyClient(client.ClientProof))
+	assert.True(t, client.VerifyServer(server.ServerProof))
+	assert.Len(t, client.SealKey(), keyLen)
+	assert.Equal(t, server.SealKey(), client.SealKey())
+
+	again, _ := handshake(t, salt, verifier, "password")
+	assert.NotEqual(t, client.Key, again.Key, "every login has new session key")
+
+	client, server = handshake(t, salt, verifier, "wrong password")
+	assert.NotEqual(t, server.Key, client.Key)
+	assert.False(t, server.VerifyClient(client.ClientProof))
+	assert.False(t, client.VerifyServer(server.ServerProof))
+
+	other := Verifier("other@example.com", salt, Secret("password", salt))
+	assert.NotEqual(t, verifier, other, "identity is bound to the verifier")
+}
+
+func TestInvalidPublic(t *testing.T) {
+	salt, err := NewSalt()
+	require.NoError(t, err)
+	secret := Secret("password", salt)
+	verifier := Verifier("user@example.com", salt, secret)
+	a, _, err := NewClient()
+	require.NoError(t, err)
+	b, _, err := NewServer(verifier)
+	require.NoError(t, err)
+
+	for _, public := range [][]byte{nil, {0}, n.Bytes(), new(big.Int).Lsh(n, 1).Bytes()} {
+		_, err = ClientSession("user@example.com", salt, secret, a, public)
+		assert.ErrorIs(t, err, ErrInvalidPublic)
+		_, err = ServerSession("user@example.com", salt, verifier, b, public)
+		assert.ErrorIs(t, err, ErrInvalidPublic)
+	}
+
+	assert.False(t, ValidVerifier(nil))
+	assert.False(t, ValidVerifier(n.Bytes()))
+}
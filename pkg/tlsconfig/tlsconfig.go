@@ -0,0 +1,92 @@
+// Package tlsconfig builds TLS configurations of servers and clients from PEM files.
+package tlsconfig
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// pinPrefix marks hash algorithm of pins returned by SPKIPin.
+const pinPrefix = "sha256/"
+
+var ErrNoCertificates = errors.New("no certificates found")
+
+// Server returns configuration serving the certificate from certFile and keyFile.
+// When clientCAFile is set, clients have to present certificate signed by the CA from it (mutual TLS).
+func Server(certFile string, keyFile string, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pool, err := certPool(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client CA: %w", err)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// Client returns configuration verifying the server against the CA from caFile,
+// system roots are used, when caFile is empty.
+// Certificate from certFile and keyFile is presented to servers requiring mutual TLS, when they are set.
+func Client(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := certPool(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("load CA: %w", err)
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func certPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: %w", file, ErrNoCertificates)
+	}
+	return pool, nil
+}
+
+// SPKIPin returns pin of the certificate public key: base64 SHA-256 of its SubjectPublicKeyInfo prefixed with "sha256/".
+// The pin survives renewal of the certificate, as long as the key stays the same.
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return pinPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Pin makes cfg pass pin of the server public key to verify after the certificate chain is verified,
+// the handshake fails, when verify returns error.
+func Pin(cfg *tls.Config, verify func(pin string) error) {
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return ErrNoCertificates
+		}
+		return verify(SPKIPin(cs.PeerCertificates[0]))
+	}
+}
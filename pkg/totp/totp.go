@@ -0,0 +1,97 @@
+// Package totp implements time-based one-time passwords (RFC 6238)
+// compatible with authenticator apps: HMAC-SHA1, 6 digits, 30 seconds period.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	// SecretSize is size of generated secrets, RFC 4226 recommends 160 bits.
+	SecretSize = 20
+	// skew is number of periods before and after the current one, in which codes are accepted,
+	// it tolerates clock drift of the device.
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns new random secret.
+func GenerateSecret() ([]byte, error) {
+	secret := make([]byte, SecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Encode returns secret in base32 form, which users enter in authenticator apps.
+func Encode(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// URI returns provisioning URI, which authenticator apps read from QR code.
+func URI(issuer string, account string, secret []byte) string {
+	v := url.Values{}
+	v.Set("secret", Encode(secret))
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(Digits))
+	v.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// Counter returns number of the period of time t.
+func Counter(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Code returns code of the secret at time t.
+func Code(secret []byte, t time.Time) string {
+	return code(secret, Counter(t))
+}
+
+// Validate checks code of the secret at time t and returns counter of the matched period.
+// Callers should reject codes with counter not greater than the counter of the last accepted code,
+// so the code can not be replayed.
+func Validate(secret []byte, code string, t time.Time) (int64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	now := Counter(t)
+	for counter := now - skew; counter <= now+skew; counter++ {
+		if subtle.ConstantTimeCompare([]byte(Code(secret, time.Unix(counter*int64(Period.Seconds()), 0))), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// code implements HOTP (RFC 4226).
+func code(secret []byte, counter int64) string {
+	mac := hmac.New(sha1.New, secret)
+	_ = binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
@@ -0,0 +1,62 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// secret of RFC 6238 test vectors for SHA1
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	// last 6 digits of RFC 6238 appendix B values
+	tests := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range tests {
+		assert.Equal(t, want, Code(rfcSecret, time.Unix(unix, 0)), "time %d", unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code := Code(rfcSecret, now)
+
+	counter, ok := Validate(rfcSecret, code, now)
+	require.True(t, ok)
+	assert.Equal(t, Counter(now), counter)
+
+	// code of the previous period is accepted because of clock drift
+	_, ok = Validate(rfcSecret, code, now.Add(Period))
+	assert.True(t, ok)
+	_, ok = Validate(rfcSecret, code, now.Add(2*Period))
+	assert.False(t, ok)
+
+	_, ok = Validate(rfcSecret, "000000", now)
+	assert.False(t, ok)
+	_, ok = Validate(rfcSecret, "50471", now)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, SecretSize)
+
+	uri, err := url.Parse(URI("gophkeeper", "name@example.com", secret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/gophkeeper:name@example.com", uri.Path)
+	assert.Equal(t, Encode(secret), uri.Query().Get("secret"))
+	assert.Equal(t, "gophkeeper", uri.Query().Get("issuer"))
+}